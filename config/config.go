@@ -32,6 +32,9 @@ type Service struct {
 	OffsetStorageURI   string      `yaml:"offset_storage_uri"`
 	ETCD               *ETCD       `yaml:"etcd"`
 	Influx             interface{} `yaml:"influx"`
+	// AdminToken authorizes the admin requests to the http server, e.g. backfills.
+	// They are sent with the Authorization: Bearer <token> header and rejected when it's not set
+	AdminToken string `yaml:"admin_token"`
 }
 
 type ETCD struct {
@@ -47,11 +50,24 @@ type Source struct {
 	Driver       sources.SourceDriver  `yaml:"driver"`
 	Config       interface{}           `yaml:"config"`
 	StreamSchema []schema.StreamSchema `yaml:"stream_schema" validate:"required"`
+	EventRules   []EventRule           `yaml:"event_rules"`
+}
+
+// EventRule filters and remaps the event types emitted by the source, e.g.
+// to ignore deletes or to treat updates as inserts for append-only sinks.
+// Stream "*" applies the rule to all streams
+type EventRule struct {
+	Stream string            `yaml:"stream"`
+	Ignore []string          `yaml:"ignore"`
+	Remap  map[string]string `yaml:"remap"`
 }
 
 type Processor struct {
 	Driver processors.ProcessorDriver `yaml:"driver"`
 	Config interface{}                `yaml:"config"`
+	// When is an expression evaluated for every message. The processor
+	// is applied only to the matching messages, others are passed through
+	When string `yaml:"when"`
 }
 
 type Sink struct {
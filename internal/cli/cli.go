@@ -1,18 +1,30 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/postgres_cdc"
 	"github.com/usedatabrew/blink/public/server"
 	"github.com/usedatabrew/blink/public/stream"
 )
 
 var configFileLocation string
 var enableHttpServer bool
+var serverAddress string
+var backfillStreams []string
+var backfillFilters []string
+var adminToken string
 
 var cmdStart = &cobra.Command{
 	Use:   "start",
@@ -36,7 +48,7 @@ var cmdStart = &cobra.Command{
 		}
 
 		if enableHttpServer {
-			go server.CreateAndStartHttpServer(streamService)
+			go server.CreateAndStartHttpServer(streamService, serviceConfiguration.Service.AdminToken)
 		}
 
 		if err = streamService.Start(); err != nil {
@@ -45,15 +57,126 @@ var cmdStart = &cobra.Command{
 	},
 }
 
+var cmdSlots = &cobra.Command{
+	Use:   "slots",
+	Short: "Manages replication slots of the postgres_cdc source",
+	Long:  `Provide --config config.yaml to specify the pipeline whose source database is inspected`,
+}
+
+var cmdSlotsList = &cobra.Command{
+	Use:   "list",
+	Short: "Lists replication slots of the source database with the WAL retained for them",
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceConfig := readPostgresCDCConfig()
+		slots, err := postgres_cdc.ListSlots(cmd.Context(), sourceConfig)
+		if err != nil {
+			log.WithPrefix("blink-cli").Fatal("Failed to list replication slots", "error", err)
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "SLOT\tPLUGIN\tDATABASE\tACTIVE\tRETAINED BYTES\tLAG BYTES")
+		for _, slot := range slots {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%v\t%d\t%d\n", slot.Name, slot.Plugin, slot.Database, slot.Active, slot.RetainedBytes, slot.LagBytes)
+		}
+		writer.Flush()
+	},
+}
+
+var cmdSlotsDrop = &cobra.Command{
+	Use:   "drop",
+	Short: "Decommissions the pipeline dropping its replication slots and publications",
+	Long:  `The pipeline has to be stopped, as active replication slots can't be dropped`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceConfig := readPostgresCDCConfig()
+		if err := postgres_cdc.DropSlots(cmd.Context(), sourceConfig); err != nil {
+			log.WithPrefix("blink-cli").Fatal("Failed to drop replication slots", "error", err)
+		}
+
+		log.WithPrefix("blink-cli").Info("Replication slots and publications are dropped", "slot_name", sourceConfig.SlotName)
+	},
+}
+
+func readPostgresCDCConfig() postgres_cdc.Config {
+	configFile, err := os.ReadFile(configFileLocation)
+	if err != nil {
+		log.WithPrefix("blink-cli").Fatal("Failed to read config file", "file", configFileLocation, "error", err)
+	}
+
+	serviceConfiguration, err := config.ReadInitConfigFromYaml(configFile)
+	if err != nil {
+		log.WithPrefix("blink-cli").Fatal("Failed to parse config file", "file", configFileLocation, "error", err)
+	}
+
+	if serviceConfiguration.Source.Driver != sources.PostgresCDC {
+		log.WithPrefix("blink-cli").Fatal("Replication slots are managed for postgres_cdc source only", "driver", serviceConfiguration.Source.Driver)
+	}
+
+	sourceConfig, err := config.ReadDriverConfig[postgres_cdc.Config](serviceConfiguration.Source.Config, postgres_cdc.Config{})
+	if err != nil {
+		log.WithPrefix("blink-cli").Fatal("Failed to read source config", "error", err)
+	}
+
+	return sourceConfig
+}
+
+var cmdBackfill = &cobra.Command{
+	Use:   "backfill",
+	Short: "Takes the snapshot of the streams again on the running pipeline",
+	Long: `The pipeline has to be started with --http-server and service.admin_token set. ` +
+		`Rows are emitted as snapshot messages tagged with the returned backfill id`,
+	Run: func(cmd *cobra.Command, args []string) {
+		request := server.BackfillRequest{Streams: backfillStreams}
+		for _, expression := range backfillFilters {
+			filter, err := sources.ParseFilter(expression)
+			if err != nil {
+				log.WithPrefix("blink-cli").Fatal("Invalid filter", "error", err)
+			}
+			request.Filters = append(request.Filters, filter)
+		}
+
+		body, _ := json.Marshal(request)
+		httpRequest, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(serverAddress, "/")+"/backfill", bytes.NewReader(body))
+		if err != nil {
+			log.WithPrefix("blink-cli").Fatal("Failed to build backfill request", "error", err)
+		}
+		httpRequest.Header.Set("Content-Type", "application/json")
+		httpRequest.Header.Set("Authorization", "Bearer "+adminToken)
+
+		response, err := http.DefaultClient.Do(httpRequest)
+		if err != nil {
+			log.WithPrefix("blink-cli").Fatal("Failed to request backfill", "server", serverAddress, "error", err)
+		}
+		defer response.Body.Close()
+
+		var backfill server.BackfillResponse
+		if err = json.NewDecoder(response.Body).Decode(&backfill); err != nil {
+			log.WithPrefix("blink-cli").Fatal("Failed to read backfill response", "status", response.Status, "error", err)
+		}
+
+		if backfill.Error != "" {
+			log.WithPrefix("blink-cli").Fatal("Backfill is rejected", "error", backfill.Error)
+		}
+
+		log.WithPrefix("blink-cli").Info("Backfill is started", "id", backfill.ID, "streams", backfillStreams)
+	},
+}
+
 var rootCmd = &cobra.Command{}
 
 func init() {
 	cmdStart.Flags().StringVarP(&configFileLocation, "config", "c", "blink.yaml", "Specify the location of the configuration file")
 	cmdStart.Flags().BoolVarP(&enableHttpServer, "http-server", "s", false, "Define if you need blink to start http server with prometheus metrics exporter")
+	cmdSlots.PersistentFlags().StringVarP(&configFileLocation, "config", "c", "blink.yaml", "Specify the location of the configuration file")
+	cmdSlots.AddCommand(cmdSlotsList, cmdSlotsDrop)
+	cmdBackfill.Flags().StringVar(&serverAddress, "server", "http://localhost:3333", "Specify the address of the blink http server")
+	cmdBackfill.Flags().StringArrayVarP(&backfillStreams, "stream", "s", nil, "Specify the stream to backfill, can be repeated")
+	cmdBackfill.Flags().StringArrayVarP(&backfillFilters, "filter", "f", nil, "Specify the filter of the backfilled rows as <column><operator><value>, e.g. \"id>=100\", can be repeated")
+	cmdBackfill.Flags().StringVar(&adminToken, "token", os.Getenv("BLINK_ADMIN_TOKEN"), "Specify the admin token of the blink http server, defaults to BLINK_ADMIN_TOKEN")
+	cmdBackfill.MarkFlagRequired("stream")
 }
 
 func Start() {
-	rootCmd.AddCommand(cmdStart)
+	rootCmd.AddCommand(cmdStart, cmdSlots, cmdBackfill)
 	err := rootCmd.Execute()
 	if err != nil {
 		panic(err)
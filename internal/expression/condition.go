@@ -0,0 +1,98 @@
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/message"
+)
+
+// Env is the environment the conditions are evaluated against, e.g.
+//
+//	event == "update" && stream == "users" && data.age >= 18
+//
+// The row state before the change is available as before for the sources with the before image enabled
+type Env struct {
+	Stream string                 `expr:"stream"`
+	Event  string                 `expr:"event"`
+	Data   map[string]interface{} `expr:"data"`
+	Before map[string]interface{} `expr:"before"`
+}
+
+// Condition is a compiled boolean expression evaluated for the messages
+type Condition struct {
+	source  string
+	program *vm.Program
+}
+
+// Compile compiles the expression. Empty expression produces nil condition that matches everything
+func Compile(source string) (*Condition, error) {
+	if source == "" {
+		return nil, nil
+	}
+
+	program, err := expr.Compile(source, expr.Env(Env{}), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", source, err)
+	}
+
+	return &Condition{source: source, program: program}, nil
+}
+
+// Match reports if the message satisfies the condition
+func (c *Condition) Match(msg *message.Message) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+
+	result, err := expr.Run(c.program, NewEnv(msg))
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate condition %q: %w", c.source, err)
+	}
+
+	return result.(bool), nil
+}
+
+// NewEnv builds the environment from the message.
+// Numbers are converted to int64 or float64, so they can be compared with the literals
+func NewEnv(msg *message.Message) Env {
+	data := normalizeNumbers(helper.MessageRow(msg)).(map[string]interface{})
+	before, _ := data[helper.BeforeImageField].(map[string]interface{})
+	delete(data, helper.BeforeImageField)
+	delete(data, helper.MetadataField)
+
+	return Env{
+		Stream: msg.GetStream(),
+		Event:  string(msg.GetEvent()),
+		Data:   data,
+		Before: before,
+	}
+}
+
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}:
+		for key, nested := range v {
+			v[key] = normalizeNumbers(nested)
+		}
+		return v
+	case []interface{}:
+		for idx, nested := range v {
+			v[idx] = normalizeNumbers(nested)
+		}
+		return v
+	default:
+		return v
+	}
+}
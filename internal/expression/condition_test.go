@@ -0,0 +1,70 @@
+package expression
+
+import (
+	"testing"
+
+	"github.com/usedatabrew/message"
+)
+
+func TestCondition_Match(t *testing.T) {
+	msg := message.NewMessage(message.Update, "public.users", []byte(`[{"id": 1, "age": 21, "email": "max@databrew.tech", "tags": ["vip"]}]`))
+
+	cases := map[string]bool{
+		``: true,
+		`event == "update" && stream == "public.users"`:          true,
+		`data.age >= 18 && data.email endsWith "@databrew.tech"`: true,
+		`"vip" in data.tags`:                 true,
+		`event == "delete" || data.age > 30`: false,
+		`data.missing == nil`:                true,
+	}
+
+	for source, expected := range cases {
+		condition, err := Compile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		matched, err := condition.Match(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matched != expected {
+			t.Fatalf("condition %q expected to be %v", source, expected)
+		}
+	}
+
+	if _, err := Compile(`data.age +`); err == nil {
+		t.Fatal("invalid expression must fail the compilation")
+	}
+
+	if _, err := Compile(`stream + "_copy"`); err == nil {
+		t.Fatal("non boolean expression must fail the compilation")
+	}
+}
+
+func TestCondition_MatchBeforeImage(t *testing.T) {
+	msg := message.NewMessage(message.Update, "public.users", []byte(`[{"id": 1, "email": "new@databrew.tech", "_before": {"id": 1, "email": "old@databrew.tech"}}]`))
+
+	condition, err := Compile(`before.email != data.email && data._before == nil`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matched, err := condition.Match(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Fatal("before image must be available as before and removed from data")
+	}
+
+	inserted := message.NewMessage(message.Insert, "public.users", []byte(`[{"id": 1, "email": "new@databrew.tech"}]`))
+	condition, err = Compile(`before == nil`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched, err = condition.Match(inserted); err != nil || !matched {
+		t.Fatal("before must be nil for the messages without before image")
+	}
+}
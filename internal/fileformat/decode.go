@@ -0,0 +1,224 @@
+package fileformat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+)
+
+// Row is the record of the file decoded to the JSON object.
+// Offset is the position the reading resumes from after the row:
+// the byte offset for the text formats and the row number for parquet
+type Row struct {
+	Data   json.RawMessage
+	Offset int64
+}
+
+// Decoder returns the rows of the file one by one and io.EOF once all of them are read
+type Decoder interface {
+	Next() (Row, error)
+}
+
+// RowError is returned for the malformed row. The decoder skips it, so the reading can go on
+type RowError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("malformed row ending at %d: %s", e.Offset, e.Err.Error())
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+type ndjsonDecoder struct {
+	reader *bufio.Reader
+	offset int64
+}
+
+// NewNDJSONDecoder decodes the newline delimited JSON objects.
+// The reader starts at the offset of the file, blank lines are skipped
+func NewNDJSONDecoder(r io.Reader, offset int64) Decoder {
+	return &ndjsonDecoder{reader: bufio.NewReader(r), offset: offset}
+}
+
+func (d *ndjsonDecoder) Next() (Row, error) {
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		d.offset += int64(len(line))
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if !json.Valid(trimmed) {
+				return Row{Offset: d.offset}, &RowError{Offset: d.offset, Err: errors.New("line is not a valid JSON")}
+			}
+			return Row{Data: trimmed, Offset: d.offset}, nil
+		}
+
+		if err != nil {
+			return Row{Offset: d.offset}, err
+		}
+	}
+}
+
+type csvDecoder struct {
+	reader *csv.Reader
+	header []string
+	types  map[string]arrow.DataType
+	// base is the offset of the file the reader starts at
+	base   int64
+	offset int64
+}
+
+// NewCSVDecoder decodes the CSV records to the objects keyed by the header.
+// Header columns are mapped to the schema columns by name, the empty values
+// of the non string columns are decoded as null. The first record is read
+// as the header when it's nil, so the reader has to start at the beginning of the file
+func NewCSVDecoder(r io.Reader, offset int64, header []string, schema *arrow.Schema, delimiter rune) Decoder {
+	reader := newCSVReader(r, delimiter)
+	types := map[string]arrow.DataType{}
+	for _, field := range schema.Fields() {
+		types[field.Name] = field.Type
+	}
+
+	return &csvDecoder{reader: reader, header: header, types: types, base: offset, offset: offset}
+}
+
+// ReadCSVHeader reads the header of the file and returns the offset of the first record
+func ReadCSVHeader(r io.Reader, delimiter rune) ([]string, int64, error) {
+	reader := newCSVReader(r, delimiter)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return header, reader.InputOffset(), nil
+}
+
+func newCSVReader(r io.Reader, delimiter rune) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	if delimiter != 0 {
+		reader.Comma = delimiter
+	}
+
+	return reader
+}
+
+func (d *csvDecoder) Next() (Row, error) {
+	if d.header == nil {
+		header, err := d.reader.Read()
+		if err != nil {
+			return Row{Offset: d.offset}, err
+		}
+		d.header = append([]string{}, header...)
+	}
+
+	record, err := d.reader.Read()
+	d.offset = d.base + d.reader.InputOffset()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{Offset: d.offset}, &RowError{Offset: d.offset, Err: err}
+	}
+	if err != nil {
+		return Row{Offset: d.offset}, err
+	}
+
+	values := map[string]interface{}{}
+	for i, column := range d.header {
+		dataType, ok := d.types[column]
+		if !ok || i >= len(record) {
+			continue
+		}
+
+		if record[i] == "" && dataType.ID() != arrow.STRING {
+			values[column] = nil
+			continue
+		}
+		values[column] = record[i]
+	}
+
+	data, err := json.Marshal(values)
+	return Row{Data: data, Offset: d.offset}, err
+}
+
+type parquetDecoder struct {
+	file    *file.Reader
+	records pqarrow.RecordReader
+	rows    []json.RawMessage
+	offset  int64
+	skip    int64
+}
+
+// NewParquetDecoder decodes the rows of the parquet file starting from the row number
+func NewParquetDecoder(ctx context.Context, r parquet.ReaderAtSeeker, offset int64) (Decoder, error) {
+	parquetFile, err := file.NewParquetReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := pqarrow.NewFileReader(parquetFile, pqarrow.ArrowReadProperties{BatchSize: 1024}, memory.DefaultAllocator)
+	if err != nil {
+		parquetFile.Close()
+		return nil, err
+	}
+
+	records, err := reader.GetRecordReader(ctx, nil, nil)
+	if err != nil {
+		parquetFile.Close()
+		return nil, err
+	}
+
+	return &parquetDecoder{file: parquetFile, records: records, offset: offset, skip: offset}, nil
+}
+
+func (d *parquetDecoder) Next() (Row, error) {
+	for len(d.rows) == 0 {
+		if !d.records.Next() {
+			err := d.records.Err()
+			d.records.Release()
+			d.file.Close()
+			if err == nil {
+				err = io.EOF
+			}
+			return Row{Offset: d.offset}, err
+		}
+
+		record := d.records.Record()
+		if d.skip >= record.NumRows() {
+			d.skip -= record.NumRows()
+			continue
+		}
+
+		var buffer bytes.Buffer
+		slice := record.NewSlice(d.skip, record.NumRows())
+		err := array.RecordToJSON(slice, &buffer)
+		slice.Release()
+		if err != nil {
+			return Row{Offset: d.offset}, err
+		}
+		d.skip = 0
+
+		for _, line := range bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n")) {
+			d.rows = append(d.rows, line)
+		}
+	}
+
+	row := d.rows[0]
+	d.rows = d.rows[1:]
+	d.offset += 1
+	return Row{Data: row, Offset: d.offset}, nil
+}
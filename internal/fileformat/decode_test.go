@@ -0,0 +1,141 @@
+package fileformat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+)
+
+var testSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+}, nil)
+
+// readAll decodes the rows and builds the messages of them
+func readAll(t *testing.T, decoder Decoder) ([]string, []int64) {
+	builder := NewMessageBuilder("users", testSchema)
+	var messages []string
+	var offsets []int64
+	for {
+		row, err := decoder.Next()
+		if err == io.EOF {
+			return messages, offsets
+		}
+
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			messages = append(messages, "malformed")
+			offsets = append(offsets, rowErr.Offset)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg, err := builder.Build(row.Data)
+		if err != nil {
+			messages = append(messages, err.Error())
+		} else {
+			messages = append(messages, msg.AsJSONString())
+		}
+		offsets = append(offsets, row.Offset)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for path, expected := range map[string]Format{"a.ndjson": NDJSON, "a.JSONL": NDJSON, "a.csv.gz": CSV, "a.parquet": Parquet} {
+		if format, err := ParseFormat("", path); err != nil || format != expected {
+			t.Fatalf("unexpected format %s of %s", format, path)
+		}
+	}
+
+	if _, err := ParseFormat("", "a.txt"); err == nil {
+		t.Fatal("unknown extension must be rejected")
+	}
+
+	if format, _ := ParseFormat("csv", "a.txt"); format != CSV {
+		t.Fatal("configured format must be used")
+	}
+}
+
+func TestNDJSONDecoder(t *testing.T) {
+	content := "{\"id\": 1, \"name\": \"a\"}\n\n{broken\n{\"name\": \"no id\"}\n{\"id\": 2}"
+	messages, offsets := readAll(t, NewNDJSONDecoder(strings.NewReader(content), 10))
+
+	expected := []string{`[{"id":1,"name":"a"}]`, "malformed", "field id is required", `[{"id":2,"name":null}]`}
+	if strings.Join(messages, "|") != strings.Join(expected, "|") {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+
+	if offsets[0] != 10+23 || offsets[3] != 10+int64(len(content)) {
+		t.Fatalf("unexpected offsets %v", offsets)
+	}
+}
+
+func TestCSVDecoder(t *testing.T) {
+	content := "name,extra,id\n\"a, b\",x,1\nc,y,\n"
+	header, offset, err := ReadCSVHeader(strings.NewReader(content), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if offset != 14 {
+		t.Fatalf("unexpected header offset %d", offset)
+	}
+
+	messages, offsets := readAll(t, NewCSVDecoder(strings.NewReader(content[offset:]), offset, header, testSchema, ','))
+	expected := []string{`[{"id":1,"name":"a, b"}]`, "field id is required"}
+	if strings.Join(messages, "|") != strings.Join(expected, "|") {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+
+	if offsets[0] != 25 || offsets[1] != int64(len(content)) {
+		t.Fatalf("unexpected offsets %v", offsets)
+	}
+}
+
+func TestParquetDecoder(t *testing.T) {
+	// the file schema differs from the stream one, values are converted to the stream types
+	fileSchema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "name", Type: arrow.BinaryTypes.String},
+	}, nil)
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, fileSchema)
+	builder.Field(0).(*array.Int32Builder).AppendValues([]int32{1, 2, 3}, nil)
+	builder.Field(1).(*array.StringBuilder).AppendValues([]string{"a", "b", "c"}, nil)
+	record := builder.NewRecord()
+	defer record.Release()
+
+	var buffer bytes.Buffer
+	writer, err := pqarrow.NewFileWriter(fileSchema, &buffer, parquet.NewWriterProperties(parquet.WithMaxRowGroupLength(2)), pqarrow.DefaultWriterProps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder, err := NewParquetDecoder(context.Background(), bytes.NewReader(buffer.Bytes()), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, offsets := readAll(t, decoder)
+	expected := []string{`[{"id":2,"name":"b"}]`, `[{"id":3,"name":"c"}]`}
+	if strings.Join(messages, "|") != strings.Join(expected, "|") || offsets[1] != 3 {
+		t.Fatalf("rows after the offset must be decoded, got %v %v", messages, offsets)
+	}
+}
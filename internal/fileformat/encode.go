@@ -0,0 +1,260 @@
+package fileformat
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet"
+	"github.com/apache/arrow/go/v14/parquet/compress"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	"github.com/usedatabrew/message"
+)
+
+const defaultRowGroupSize = 10000
+
+// Encoder writes the rows of the messages to the file.
+// Rows are projected to the arrow schema of the stream, so the fields unknown to it are dropped
+type Encoder interface {
+	Write(msg *message.Message) error
+	// Close writes the buffered rows and the file footer. It doesn't close the writer
+	Close() error
+}
+
+type EncoderOptions struct {
+	// RowGroupSize is the max amount of rows in the parquet row group. Defaults to 10000
+	RowGroupSize int
+	// Compression of the parquet column chunks
+	Compression compress.Compression
+	// Delimiter of the CSV values. Defaults to comma
+	Delimiter rune
+}
+
+func NewEncoder(format Format, w io.Writer, schema *arrow.Schema, options EncoderOptions) (Encoder, error) {
+	switch format {
+	case NDJSON:
+		return &ndjsonEncoder{writer: w, rows: newRowBuilder(schema)}, nil
+	case CSV:
+		return newCSVEncoder(w, schema, options), nil
+	case Parquet:
+		return newParquetEncoder(w, schema, options)
+	default:
+		return nil, fmt.Errorf("unsupported file format %q", format)
+	}
+}
+
+// messageRow returns the row of the message as JSON object
+func messageRow(msg *message.Message) (json.RawMessage, error) {
+	var rows []json.RawMessage
+	if err := json.Unmarshal([]byte(msg.AsJSONString()), &rows); err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("message of stream %s has no row", msg.GetStream())
+	}
+
+	return rows[0], nil
+}
+
+// rowBuilder builds the single row records of the messages
+type rowBuilder struct {
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+}
+
+func newRowBuilder(schema *arrow.Schema) *rowBuilder {
+	return &rowBuilder{schema: schema, builder: array.NewRecordBuilder(memory.DefaultAllocator, schema)}
+}
+
+func (b *rowBuilder) Build(msg *message.Message) (arrow.Record, error) {
+	row, err := messageRow(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = b.builder.UnmarshalJSON(row); err != nil {
+		// builders of the fields decoded before the error have the extra value appended
+		b.builder.Release()
+		b.builder = array.NewRecordBuilder(memory.DefaultAllocator, b.schema)
+		return nil, err
+	}
+
+	return b.builder.NewRecord(), nil
+}
+
+func (b *rowBuilder) Release() {
+	b.builder.Release()
+}
+
+type ndjsonEncoder struct {
+	writer io.Writer
+	rows   *rowBuilder
+}
+
+func (e *ndjsonEncoder) Write(msg *message.Message) error {
+	record, err := e.rows.Build(msg)
+	if err != nil {
+		return err
+	}
+
+	defer record.Release()
+	return array.RecordToJSON(record, e.writer)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	e.rows.Release()
+	return nil
+}
+
+// csvEncoder writes the header of the schema columns before the first row.
+// Null values are written as empty, the nested values as JSON
+type csvEncoder struct {
+	writer *csv.Writer
+	rows   *rowBuilder
+	header []string
+	values []string
+	// headerWritten is set once the header is written
+	headerWritten bool
+}
+
+func newCSVEncoder(w io.Writer, schema *arrow.Schema, options EncoderOptions) *csvEncoder {
+	writer := csv.NewWriter(w)
+	if options.Delimiter != 0 {
+		writer.Comma = options.Delimiter
+	}
+
+	var header []string
+	for _, field := range schema.Fields() {
+		header = append(header, field.Name)
+	}
+
+	return &csvEncoder{writer: writer, rows: newRowBuilder(schema), header: header, values: make([]string, len(header))}
+}
+
+func (e *csvEncoder) Write(msg *message.Message) error {
+	record, err := e.rows.Build(msg)
+	if err != nil {
+		return err
+	}
+	defer record.Release()
+
+	var buffer bytes.Buffer
+	if err = array.RecordToJSON(record, &buffer); err != nil {
+		return err
+	}
+
+	var row map[string]json.RawMessage
+	if err = json.Unmarshal(buffer.Bytes(), &row); err != nil {
+		return err
+	}
+
+	for i, column := range e.header {
+		value := row[column]
+		switch {
+		case len(value) == 0 || string(value) == "null":
+			e.values[i] = ""
+		case value[0] == '"':
+			if err = json.Unmarshal(value, &e.values[i]); err != nil {
+				return err
+			}
+		default:
+			e.values[i] = string(value)
+		}
+	}
+
+	if !e.headerWritten {
+		if err = e.writer.Write(e.header); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+
+	if err = e.writer.Write(e.values); err != nil {
+		return err
+	}
+
+	// rows are flushed to keep the size of the written file up to date
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	e.rows.Release()
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// parquetEncoder buffers the rows of the row group in memory
+type parquetEncoder struct {
+	schema       *arrow.Schema
+	writer       *pqarrow.FileWriter
+	rows         *rowBuilder
+	buffered     []arrow.Record
+	rowGroupSize int
+}
+
+func newParquetEncoder(w io.Writer, schema *arrow.Schema, options EncoderOptions) (*parquetEncoder, error) {
+	rowGroupSize := options.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = defaultRowGroupSize
+	}
+
+	props := parquet.NewWriterProperties(parquet.WithMaxRowGroupLength(int64(rowGroupSize)), parquet.WithCompression(options.Compression))
+	// the parquet writer closes the closer it writes to, so the close is hidden from it
+	writer, err := pqarrow.NewFileWriter(schema, struct{ io.Writer }{w}, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+
+	return &parquetEncoder{
+		schema:       schema,
+		writer:       writer,
+		rows:         newRowBuilder(schema),
+		rowGroupSize: rowGroupSize,
+	}, nil
+}
+
+// Write buffers the rows until the row group is full
+func (e *parquetEncoder) Write(msg *message.Message) error {
+	record, err := e.rows.Build(msg)
+	if err != nil {
+		return err
+	}
+
+	e.buffered = append(e.buffered, record)
+	if len(e.buffered) >= e.rowGroupSize {
+		return e.flush()
+	}
+
+	return nil
+}
+
+func (e *parquetEncoder) flush() error {
+	if len(e.buffered) == 0 {
+		return nil
+	}
+
+	table := array.NewTableFromRecords(e.schema, e.buffered)
+	defer table.Release()
+	for _, record := range e.buffered {
+		record.Release()
+	}
+	e.buffered = nil
+
+	return e.writer.WriteTable(table, int64(e.rowGroupSize))
+}
+
+func (e *parquetEncoder) Close() error {
+	defer e.rows.Release()
+	if err := e.flush(); err != nil {
+		return err
+	}
+
+	return e.writer.Close()
+}
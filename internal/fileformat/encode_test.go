@@ -0,0 +1,57 @@
+package fileformat
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v14/parquet/compress"
+	"github.com/usedatabrew/message"
+)
+
+func TestEncoder(t *testing.T) {
+	rows := []string{`{"id": 1, "name": "a", "extra": true}`, `{"id": "broken"}`, `{"id": 2}`, `{"id": 3, "name": "c"}`}
+	expected := []string{`[{"id":1,"name":"a"}]`, `[{"id":2,"name":null}]`, `[{"id":3,"name":"c"}]`}
+
+	for _, format := range []Format{NDJSON, CSV, Parquet} {
+		var buffer bytes.Buffer
+		encoder, err := NewEncoder(format, &buffer, testSchema, EncoderOptions{RowGroupSize: 2, Compression: compress.Codecs.Zstd})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, row := range rows {
+			err = encoder.Write(message.NewMessage(message.Insert, "users", []byte("["+row+"]")))
+			if (err != nil) != strings.Contains(row, "broken") {
+				t.Fatalf("unexpected %s error of row %s: %v", format, row, err)
+			}
+		}
+
+		if err = encoder.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var decoder Decoder
+		switch format {
+		case Parquet:
+			if decoder, err = NewParquetDecoder(context.Background(), bytes.NewReader(buffer.Bytes()), 0); err != nil {
+				t.Fatal(err)
+			}
+		case CSV:
+			decoder = NewCSVDecoder(&buffer, 0, nil, testSchema, ',')
+		default:
+			decoder = NewNDJSONDecoder(&buffer, 0)
+		}
+
+		// the rejected row must not affect the rows written after it
+		messages, _ := readAll(t, decoder)
+		if format == CSV {
+			// null and empty strings are the same in CSV
+			messages[1] = strings.Replace(messages[1], `""`, "null", 1)
+		}
+		if strings.Join(messages, "|") != strings.Join(expected, "|") {
+			t.Fatalf("unexpected %s rows %v", format, messages)
+		}
+	}
+}
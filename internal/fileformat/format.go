@@ -0,0 +1,88 @@
+package fileformat
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/usedatabrew/message"
+)
+
+type Format string
+
+const (
+	NDJSON  Format = "ndjson"
+	CSV     Format = "csv"
+	Parquet Format = "parquet"
+)
+
+// ParseFormat returns the configured format or detects it by the file extension when it's empty.
+// The compression extension like .gz is skipped
+func ParseFormat(configured, path string) (Format, error) {
+	name := strings.ToLower(configured)
+	if name == "" {
+		base := strings.ToLower(filepath.Base(path))
+		for _, compression := range []string{".gz", ".zst"} {
+			base = strings.TrimSuffix(base, compression)
+		}
+		name = strings.TrimPrefix(filepath.Ext(base), ".")
+	}
+
+	switch name {
+	case "ndjson", "jsonl", "json":
+		return NDJSON, nil
+	case "csv":
+		return CSV, nil
+	case "parquet":
+		return Parquet, nil
+	default:
+		return "", fmt.Errorf("unsupported file format %q of %s", name, path)
+	}
+}
+
+// MessageBuilder validates the decoded rows against the arrow schema of the stream
+// and builds the messages of them
+type MessageBuilder struct {
+	stream  string
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+}
+
+func NewMessageBuilder(stream string, schema *arrow.Schema) *MessageBuilder {
+	return &MessageBuilder{
+		stream:  stream,
+		schema:  schema,
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+	}
+}
+
+// Build converts the row to the insert message. Fields unknown to the schema are dropped,
+// missing ones are set to null unless the column isn't nullable
+func (b *MessageBuilder) Build(row json.RawMessage) (*message.Message, error) {
+	if err := b.builder.UnmarshalJSON(row); err != nil {
+		// builders of the fields decoded before the error have the extra value appended
+		b.builder.Release()
+		b.builder = array.NewRecordBuilder(memory.DefaultAllocator, b.schema)
+		return nil, err
+	}
+
+	record := b.builder.NewRecord()
+	defer record.Release()
+
+	for i, field := range b.schema.Fields() {
+		if !field.Nullable && record.Column(i).IsNull(0) {
+			return nil, fmt.Errorf("field %s is required", field.Name)
+		}
+	}
+
+	data, err := record.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return message.NewMessage(message.Insert, b.stream, data), nil
+}
@@ -1,7 +1,9 @@
 package helper
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 
 	"github.com/apache/arrow/go/v14/arrow"
@@ -10,6 +12,33 @@ import (
 	cqtypes "github.com/cloudquery/plugin-sdk/v4/types"
 )
 
+var vectorTypeRegex = regexp.MustCompile(`^Vector\((\d+)\)$`)
+
+// VectorType builds the databrew type of the fixed size float vector, e.g. Vector(1536)
+func VectorType(dimensions int) string {
+	return fmt.Sprintf("Vector(%d)", dimensions)
+}
+
+// ParseVectorType returns the number of dimensions for the Vector(n) databrew type
+func ParseVectorType(fieldType string) (int, bool) {
+	match := vectorTypeRegex.FindStringSubmatch(fieldType)
+	if match == nil {
+		return 0, false
+	}
+
+	dimensions, err := strconv.Atoi(match[1])
+	if err != nil || dimensions <= 0 {
+		return 0, false
+	}
+
+	return dimensions, true
+}
+
+func IsVectorType(fieldType string) bool {
+	_, ok := ParseVectorType(fieldType)
+	return ok
+}
+
 func IsPrimitiveType(fieldType string) bool {
 	switch fieldType {
 	case "Boolean", "Int32", "Int64", "Uint64", "Float64", "Float32", "UUID", "bytea", "Inet", "MAC", "Date32", "String":
@@ -20,6 +49,10 @@ func IsPrimitiveType(fieldType string) bool {
 }
 
 func MapPlainTypeToArrow(fieldType string) arrow.DataType {
+	if dimensions, ok := ParseVectorType(fieldType); ok {
+		return arrow.FixedSizeListOf(int32(dimensions), arrow.PrimitiveTypes.Float32)
+	}
+
 	switch fieldType {
 	case "Boolean":
 		return arrow.FixedWidthTypes.Boolean
@@ -50,6 +83,26 @@ func MapPlainTypeToArrow(fieldType string) arrow.DataType {
 	}
 }
 
+// NormalizeDriverValue converts the values the database/sql drivers return in the storage format,
+// e.g. text as bytes or booleans as integers, to the values accepted by the column type
+func NormalizeDriverValue(value interface{}, dataType arrow.DataType) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		switch {
+		case dataType.ID() == arrow.BINARY || dataType.ID() == arrow.LARGE_BINARY:
+		case dataType.ID() == arrow.EXTENSION && len(v) == 16:
+		default:
+			return string(v)
+		}
+	case int64:
+		if dataType.ID() == arrow.BOOL {
+			return v != 0
+		}
+	}
+
+	return value
+}
+
 func InferArrowType(value interface{}) arrow.DataType {
 	switch value.(type) {
 	case int, int8, int16, int32, int64:
@@ -0,0 +1,63 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CastValue converts the decoded JSON value to the given databrew type.
+// Unknown types keep the value as is
+func CastValue(value interface{}, targetType string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	plain := PlainValue(value)
+	switch targetType {
+	case "Int16", "Int32", "Int64":
+		bitSize, _ := strconv.Atoi(strings.TrimPrefix(targetType, "Int"))
+		if i, err := strconv.ParseInt(plain, 10, bitSize); err == nil {
+			return i, nil
+		}
+		// floats are accepted only when they hold the integer value, e.g. 10.0
+		f, err := strconv.ParseFloat(plain, 64)
+		if err != nil {
+			return nil, err
+		}
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("%s is not an integer", plain)
+		}
+		if limit := math.Ldexp(1, bitSize-1); f < -limit || f >= limit {
+			return nil, fmt.Errorf("%s is out of %s range", plain, targetType)
+		}
+		return int64(f), nil
+	case "Uint64":
+		return strconv.ParseUint(plain, 10, 64)
+	case "Float32", "Float64":
+		return strconv.ParseFloat(plain, 64)
+	case "Boolean":
+		return strconv.ParseBool(plain)
+	case "String", "UUID", "Inet", "MAC":
+		return plain, nil
+	default:
+		return value, nil
+	}
+}
+
+// PlainValue formats the value as a string. Objects and lists are encoded as JSON
+func PlainValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
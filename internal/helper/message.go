@@ -0,0 +1,160 @@
+package helper
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/usedatabrew/message"
+)
+
+// MessageRow decodes the message payload into a plain map, so processors
+// can work with typed values instead of strings only.
+// Numbers are decoded as json.Number, so SetMessageRow writes them back as they are in the payload.
+// Note the message payload itself holds numbers as float64, so integers beyond 2^53 are rounded
+// before they reach the processors
+func MessageRow(msg *message.Message) map[string]interface{} {
+	var rows []map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(msg.AsJSONString()))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil || len(rows) == 0 || rows[0] == nil {
+		return map[string]interface{}{}
+	}
+
+	return rows[0]
+}
+
+// SetMessageRow replaces the message payload with the given row
+func SetMessageRow(msg *message.Message, row map[string]interface{}) error {
+	encoded, err := json.Marshal([]map[string]interface{}{row})
+	if err != nil {
+		return err
+	}
+
+	msg.Data = message.NewData(encoded)
+	return nil
+}
+
+// BeforeImageField is the reserved field holding the row state before the change.
+// It is set by the sources with the before image enabled, so processors can compare
+// the states, and it's removed before the message is written to the sink
+const BeforeImageField = "_before"
+
+// SetBeforeImage stores the row state before the change in the message
+func SetBeforeImage(msg *message.Message, before map[string]interface{}) error {
+	row := MessageRow(msg)
+	row[BeforeImageField] = before
+	return SetMessageRow(msg, row)
+}
+
+// BeforeImage returns the row state before the change or nil if the message has no before image
+func BeforeImage(msg *message.Message) map[string]interface{} {
+	before, _ := MessageRow(msg)[BeforeImageField].(map[string]interface{})
+	return before
+}
+
+// StripBeforeImage removes the before image from the message
+func StripBeforeImage(msg *message.Message) error {
+	row := MessageRow(msg)
+	if _, ok := row[BeforeImageField]; !ok {
+		return nil
+	}
+
+	delete(row, BeforeImageField)
+	return SetMessageRow(msg, row)
+}
+
+// MetadataField is the reserved field holding the metadata the source attaches to the message.
+// Sink wrapper removes it before the message is written, so it reaches only the sinks asking for it.
+// Metadata is kept in the first row, so the other rows of the multi-row messages are left as they are
+const MetadataField = "_metadata"
+
+// Metadata describes where the message comes from rather than the row itself
+type Metadata struct {
+	// BackfillId is the id of the backfill the snapshot row is emitted by.
+	// Sinks use it to tell the backfilled rows from the initial snapshot, as the rows may already exist
+	BackfillId string `json:"backfill_id,omitempty"`
+	// Position of the change in the source log, like the LSN, the binlog position or the resume token.
+	// The change emitted again after the source restart has the same position, snapshot rows have none
+	Position string `json:"position,omitempty"`
+}
+
+// SetMetadata stores the metadata in the message
+func SetMetadata(msg *message.Message, metadata Metadata) error {
+	rows := messageRows(msg)
+	if len(rows) == 0 {
+		rows = []map[string]interface{}{{}}
+	}
+
+	rows[0][MetadataField] = metadata
+	return setMessageRows(msg, rows)
+}
+
+// MessageMetadata returns the metadata of the message or the empty metadata if it has none
+func MessageMetadata(msg *message.Message) Metadata {
+	var metadata Metadata
+	encoded, err := json.Marshal(MessageRow(msg)[MetadataField])
+	if err == nil {
+		json.Unmarshal(encoded, &metadata)
+	}
+
+	return metadata
+}
+
+// StripMetadata removes the metadata from the message and returns it
+func StripMetadata(msg *message.Message) (Metadata, error) {
+	// most of the messages carry no metadata, so the payload isn't decoded for them
+	if !strings.Contains(msg.AsJSONString(), `"`+MetadataField+`"`) {
+		return Metadata{}, nil
+	}
+
+	metadata := MessageMetadata(msg)
+	rows := messageRows(msg)
+	if len(rows) == 0 {
+		return metadata, nil
+	}
+
+	delete(rows[0], MetadataField)
+	return metadata, setMessageRows(msg, rows)
+}
+
+// SetBackfillId tags the message with the backfill id
+func SetBackfillId(msg *message.Message, id string) error {
+	metadata := MessageMetadata(msg)
+	metadata.BackfillId = id
+	return SetMetadata(msg, metadata)
+}
+
+// SetPosition tags the message with its position in the source log
+func SetPosition(msg *message.Message, position string) error {
+	metadata := MessageMetadata(msg)
+	metadata.Position = position
+	return SetMetadata(msg, metadata)
+}
+
+func messageRows(msg *message.Message) []map[string]interface{} {
+	var rows []map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(msg.AsJSONString()))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil || len(rows) == 0 || rows[0] == nil {
+		return nil
+	}
+
+	return rows
+}
+
+func setMessageRows(msg *message.Message, rows []map[string]interface{}) error {
+	encoded, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	msg.Data = message.NewData(encoded)
+	return nil
+}
+
+// StreamHeader and EventHeader are the headers of the messages published by the broker sinks.
+// Broker sources read the stream and the event of the message from them
+const (
+	StreamHeader = "Blink-Stream"
+	EventHeader  = "Blink-Event"
+)
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	"github.com/usedatabrew/message"
+)
+
+// MessageTemplateData is passed to the templates rendered for the message.
+// Message fields are available as {{ .Data.field }}, stream and event type
+// as {{ .Stream }} and {{ .Event }}
+type MessageTemplateData struct {
+	Stream string
+	Event  string
+	Data   map[string]interface{}
+}
+
+// ParseMessageTemplate parses Go template that will be rendered for the messages.
+// Besides the builtin functions, `json` function is available to encode the value as JSON
+func ParseMessageTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"json": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+	}).Parse(text)
+}
+
+// NewMessageTemplateData builds the template data for the message
+func NewMessageTemplateData(msg *message.Message) MessageTemplateData {
+	return MessageTemplateData{
+		Stream: msg.GetStream(),
+		Event:  string(msg.GetEvent()),
+		Data:   MessageRow(msg),
+	}
+}
+
+// RenderMessageTemplate renders the template using the message data
+func RenderMessageTemplate(tmpl *template.Template, data MessageTemplateData) (string, error) {
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
+
+// ExpandMessageTemplate replaces the {stream} and {event} placeholders with the stream and the event
+// of the message. It's used for the subjects and the routing keys, like cdc.{stream}.{event}
+func ExpandMessageTemplate(text string, msg *message.Message) string {
+	return strings.NewReplacer("{stream}", msg.GetStream(), "{event}", string(msg.GetEvent())).Replace(text)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/usedatabrew/blink/internal/logger"
 	"strconv"
+	"sync"
 	"time"
 
 	influxdb3 "github.com/InfluxCommunity/influxdb3-go/influxdb3"
@@ -18,6 +19,8 @@ type Plugin struct {
 
 	procMetrics              map[string][]metrics.Counter
 	procExecutionTimeMetrics map[string]metrics.Gauge
+	procRuleViolations       map[string]map[string]metrics.Counter
+	ruleViolationsLock       sync.Mutex
 
 	client       *influxdb3.Client
 	writeOptions influxdb3.WriteOptions
@@ -40,6 +43,7 @@ func NewPlugin(config Config) (*Plugin, error) {
 		sourceErrorsCounter:      metrics.NewCounter(),
 		procMetrics:              map[string][]metrics.Counter{},
 		procExecutionTimeMetrics: map[string]metrics.Gauge{},
+		procRuleViolations:       map[string]map[string]metrics.Counter{},
 	}
 	plugin.receivedCounter.Clear()
 	plugin.sentCounter.Clear()
@@ -101,6 +105,20 @@ func (p *Plugin) IncrementProcessorSentMessages(proc string) {
 	p.procMetrics[proc][0].Inc(2)
 }
 
+func (p *Plugin) IncrementProcessorRuleViolations(proc string, rule string) {
+	p.ruleViolationsLock.Lock()
+	defer p.ruleViolationsLock.Unlock()
+
+	// rules are not known in advance, so the counters are created on the first violation
+	if _, ok := p.procRuleViolations[proc]; !ok {
+		p.procRuleViolations[proc] = map[string]metrics.Counter{}
+	}
+	if _, ok := p.procRuleViolations[proc][rule]; !ok {
+		p.procRuleViolations[proc][rule] = metrics.NewCounter()
+	}
+	p.procRuleViolations[proc][rule].Inc(1)
+}
+
 func (p *Plugin) RegisterProcessors(processors []string) {
 	for _, proc := range processors {
 		p.procExecutionTimeMetrics[proc] = metrics.NewGauge()
@@ -164,6 +182,24 @@ func (p *Plugin) flushMetrics() {
 		}
 	}
 
+	p.ruleViolationsLock.Lock()
+	for proc, rules := range p.procRuleViolations {
+		for rule, counter := range rules {
+			ruleViolationsPoint := influxdb3.NewPointWithMeasurement("blink_data").
+				SetTag("group", p.groupName).
+				SetTag("pipeline", strconv.Itoa(p.pipelineId)).
+				SetTag("processor", proc).
+				SetTag("rule", rule).
+				SetField("rule_violations", counter.Count()).
+				SetTimestamp(t)
+
+			if err := p.client.WritePointsWithOptions(context.Background(), &p.writeOptions, ruleViolationsPoint); err != nil {
+				panic(err)
+			}
+		}
+	}
+	p.ruleViolationsLock.Unlock()
+
 	point := influxdb3.NewPointWithMeasurement("blink_data").
 		SetTag("group", p.groupName).
 		SetTag("pipeline", strconv.Itoa(p.pipelineId)).
@@ -12,4 +12,7 @@ type Metrics interface {
 	IncrementProcessorDroppedMessages(proc string)
 	IncrementProcessorReceivedMessages(proc string)
 	IncrementProcessorSentMessages(proc string)
+	// IncrementProcessorRuleViolations counts the messages that violated
+	// the given rule of the processor (e.g. validation rules)
+	IncrementProcessorRuleViolations(proc string, rule string)
 }
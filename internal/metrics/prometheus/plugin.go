@@ -17,6 +17,7 @@ type Plugin struct {
 
 	procCounters            map[string][]prometheus.Counter
 	procExecutionTimeGauges map[string]prometheus.Gauge
+	procRuleViolations      *prometheus.CounterVec
 
 	groupName  string
 	pipelineId int
@@ -44,6 +45,10 @@ func NewPlugin(config Config) (*Plugin, error) {
 		}),
 		procCounters:            map[string][]prometheus.Counter{},
 		procExecutionTimeGauges: map[string]prometheus.Gauge{},
+		procRuleViolations: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "processor_rule_violations",
+			Help: "The total number of messages that violated the processor rule",
+		}, []string{"processor", "rule"}),
 	}
 	return plugin, nil
 }
@@ -102,3 +107,7 @@ func (p *Plugin) IncrementProcessorReceivedMessages(proc string) {
 func (p *Plugin) IncrementProcessorSentMessages(proc string) {
 	p.procCounters[proc][2].Inc()
 }
+
+func (p *Plugin) IncrementProcessorRuleViolations(proc string, rule string) {
+	p.procRuleViolations.WithLabelValues(proc, rule).Inc()
+}
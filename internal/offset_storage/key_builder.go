@@ -5,3 +5,15 @@ import "fmt"
 func BuildKey(pipelineId int64, stream string) string {
 	return fmt.Sprintf("pipeline_%d_stream_%s", pipelineId, stream)
 }
+
+func BuildCursorKey(pipelineId int64, stream string) string {
+	return fmt.Sprintf("pipeline_%d_stream_%s_cursor", pipelineId, stream)
+}
+
+func BuildSnapshotKey(pipelineId int64, stream string) string {
+	return fmt.Sprintf("pipeline_%d_stream_%s_snapshot", pipelineId, stream)
+}
+
+func BuildFileKey(pipelineId int64, stream, path string) string {
+	return fmt.Sprintf("pipeline_%d_stream_%s_file_%s", pipelineId, stream, path)
+}
@@ -1,8 +1,12 @@
 package offset_storage
 
 // OffsetStorage is used to store checkpoints/offsets for source connectors
-// which use incremental sync by primary keys
+// which use incremental sync by primary keys or cursor columns
 type OffsetStorage interface {
 	SetOffsetForPipeline(key string, offset int64) error
 	GetOffsetByPipelineStream(key string) (int64, error)
+	// SetCursorForPipeline stores the encoded cursor, so non integer cursors like timestamps can be stored
+	SetCursorForPipeline(key string, cursor string) error
+	// GetCursorByPipelineStream returns an empty cursor if nothing was stored
+	GetCursorByPipelineStream(key string) (string, error)
 }
@@ -3,6 +3,7 @@ package offset_storage
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"github.com/redis/go-redis/v9"
 	"net/url"
 )
@@ -35,3 +36,17 @@ func (o *Storage) SetOffsetForPipeline(key string, offset int64) error {
 	cmd := o.redisCache.Set(context.Background(), key, offset, 0)
 	return cmd.Err()
 }
+
+func (o *Storage) GetCursorByPipelineStream(key string) (string, error) {
+	cursor, err := o.redisCache.Get(context.Background(), key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+
+	return cursor, err
+}
+
+func (o *Storage) SetCursorForPipeline(key string, cursor string) error {
+	cmd := o.redisCache.Set(context.Background(), key, cursor, 0)
+	return cmd.Err()
+}
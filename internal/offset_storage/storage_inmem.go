@@ -2,11 +2,13 @@ package offset_storage
 
 type StorageInMem struct {
 	offsets map[string]int64
+	cursors map[string]string
 }
 
 func NewStorageInMem() *StorageInMem {
 	return &StorageInMem{
 		offsets: map[string]int64{},
+		cursors: map[string]string{},
 	}
 }
 
@@ -22,3 +24,12 @@ func (o *StorageInMem) SetOffsetForPipeline(key string, offset int64) error {
 	o.offsets[key] = offset
 	return nil
 }
+
+func (o *StorageInMem) GetCursorByPipelineStream(key string) (string, error) {
+	return o.cursors[key], nil
+}
+
+func (o *StorageInMem) SetCursorForPipeline(key string, cursor string) error {
+	o.cursors[key] = cursor
+	return nil
+}
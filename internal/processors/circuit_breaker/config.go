@@ -0,0 +1,27 @@
+package circuit_breaker
+
+const (
+	// DropFallback drops the message
+	DropFallback = "drop"
+	// PassThroughFallback sends the message downstream unchanged
+	PassThroughFallback = "pass_through"
+	// FailFallback stops the pipeline
+	FailFallback = "fail"
+)
+
+type Config struct {
+	// Processor is the wrapped processor, e.g. http or openai
+	Processor Processor `json:"processor" yaml:"processor"`
+	// FailureThreshold is the amount of consecutive failures that opens the circuit
+	FailureThreshold int `json:"failure_threshold" yaml:"failure_threshold"`
+	// CoolDown is the amount of seconds the circuit stays open before the next attempt
+	CoolDown int64 `json:"cool_down" yaml:"cool_down"`
+	// Fallback defines what happens to the failed messages and the messages
+	// received while the circuit is open: drop, pass_through (default) or fail
+	Fallback string `json:"fallback" yaml:"fallback"`
+}
+
+type Processor struct {
+	Driver string      `json:"driver" yaml:"driver"`
+	Config interface{} `json:"config" yaml:"config"`
+}
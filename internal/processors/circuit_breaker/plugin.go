@@ -0,0 +1,232 @@
+package circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/processors"
+	"github.com/usedatabrew/blink/internal/processors/inflight"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultCoolDown         = 30
+)
+
+// Plugin wraps the processor and stops calling it for a cool-down period
+// after the amount of consecutive failures reaches the threshold.
+// After the cool-down a single message is let through: the circuit is closed
+// if it succeeds and opened again otherwise
+type Plugin struct {
+	config    Config
+	ctx       *stream_context.Context
+	logger    *log.Logger
+	processor processors.DataProcessor
+	coolDown  time.Duration
+	now       func() time.Time
+
+	mutx      sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// multiPlugin is used when the wrapped processor emits multiple messages
+type multiPlugin struct {
+	*Plugin
+	multiProcessor processors.MultiMessageProcessor
+}
+
+// bufferedPlugin is used when the wrapped processor buffers messages between the calls
+type bufferedPlugin struct {
+	multiPlugin
+	bufferedProcessor processors.BufferedProcessor
+}
+
+// NewCircuitBreakerPlugin wraps the processor keeping its capabilities,
+// so multi message and buffered processors are called the same way as unwrapped
+func NewCircuitBreakerPlugin(appctx *stream_context.Context, config Config, processor processors.DataProcessor) (processors.DataProcessor, error) {
+	switch config.Fallback {
+	case "":
+		config.Fallback = PassThroughFallback
+	case DropFallback, PassThroughFallback, FailFallback:
+	default:
+		return nil, fmt.Errorf("unsupported fallback %s", config.Fallback)
+	}
+
+	if processor == nil {
+		return nil, errors.New("processor to wrap is required for circuit_breaker")
+	}
+
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+
+	if config.CoolDown <= 0 {
+		config.CoolDown = defaultCoolDown
+	}
+
+	plugin := &Plugin{
+		config:    config,
+		ctx:       appctx,
+		logger:    appctx.Logger.WithPrefix(fmt.Sprintf("processor [circuit_breaker:%s]", config.Processor.Driver)),
+		processor: processor,
+		coolDown:  time.Duration(config.CoolDown) * time.Second,
+		now:       time.Now,
+	}
+
+	multiProcessor, ok := processor.(processors.MultiMessageProcessor)
+	if !ok {
+		return plugin, nil
+	}
+
+	multi := multiPlugin{Plugin: plugin, multiProcessor: multiProcessor}
+	if bufferedProcessor, ok := processor.(processors.BufferedProcessor); ok {
+		return bufferedPlugin{multiPlugin: multi, bufferedProcessor: bufferedProcessor}, nil
+	}
+
+	return multi, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	if p.isOpen() {
+		return p.fallback(msg, errors.New("circuit is open"))
+	}
+
+	processed, err := p.processor.Process(context, msg)
+	if err != nil {
+		p.registerFailure(err)
+		return p.fallback(msg, err)
+	}
+
+	p.registerSuccess()
+	return processed, nil
+}
+
+func (p multiPlugin) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	if p.isOpen() {
+		return p.fallbackMulti(msg, errors.New("circuit is open"))
+	}
+
+	processed, err := p.multiProcessor.ProcessMulti(context, msg)
+	if err != nil {
+		p.registerFailure(err)
+		failed := msg
+		// windowed processors report the failed task of the earlier message along with the messages
+		// completed before it, the message of the call stays in the window
+		var taskErr *inflight.TaskError
+		if errors.As(err, &taskErr) {
+			failed = taskErr.Message
+		} else {
+			processed = nil
+		}
+
+		fallback, err := p.fallbackMulti(failed, err)
+		if err != nil {
+			return nil, err
+		}
+		return append(processed, fallback...), nil
+	}
+
+	p.registerSuccess()
+	return processed, nil
+}
+
+// Flush is delegated even when the circuit is open, as the buffered messages were accepted before.
+// Fallback is applied to the failed tasks of the windowed processors and the rest of the window is flushed.
+// Messages of the other failed flushes can't be passed through, so they are dropped unless the fallback is fail
+func (p bufferedPlugin) Flush(context context.Context) ([]*message.Message, error) {
+	var flushed []*message.Message
+	for {
+		processed, err := p.bufferedProcessor.Flush(context)
+		if err == nil {
+			p.registerSuccess()
+			return append(flushed, processed...), nil
+		}
+
+		p.registerFailure(err)
+		var taskErr *inflight.TaskError
+		if !errors.As(err, &taskErr) {
+			if p.config.Fallback == FailFallback {
+				return nil, err
+			}
+			p.logger.Error("failed to flush buffered messages", "error", err)
+			return flushed, nil
+		}
+
+		fallback, err := p.fallbackMulti(taskErr.Message, err)
+		if err != nil {
+			return nil, err
+		}
+		flushed = append(append(flushed, processed...), fallback...)
+	}
+}
+
+// Close releases the resources of the wrapped processor
+func (p *Plugin) Close() error {
+	closableProcessor, ok := p.processor.(processors.ClosableProcessor)
+	if !ok {
+		return nil
+	}
+
+	return closableProcessor.Close()
+}
+
+func (p *Plugin) isOpen() bool {
+	p.mutx.Lock()
+	defer p.mutx.Unlock()
+
+	return p.now().Before(p.openUntil)
+}
+
+func (p *Plugin) registerFailure(err error) {
+	p.mutx.Lock()
+	defer p.mutx.Unlock()
+
+	p.failures += 1
+	if p.failures >= p.config.FailureThreshold {
+		p.openUntil = p.now().Add(p.coolDown)
+		p.logger.Warn("circuit is open", "failures", p.failures, "cool_down", p.coolDown, "error", err)
+	}
+}
+
+func (p *Plugin) registerSuccess() {
+	p.mutx.Lock()
+	defer p.mutx.Unlock()
+
+	if p.failures >= p.config.FailureThreshold {
+		p.logger.Info("circuit is closed")
+	}
+	p.failures = 0
+}
+
+func (p *Plugin) fallback(msg *message.Message, err error) (*message.Message, error) {
+	switch p.config.Fallback {
+	case FailFallback:
+		return nil, err
+	case DropFallback:
+		return nil, nil
+	default:
+		return msg, nil
+	}
+}
+
+func (p *Plugin) fallbackMulti(msg *message.Message, err error) ([]*message.Message, error) {
+	processed, err := p.fallback(msg, err)
+	if processed == nil {
+		return nil, err
+	}
+
+	return []*message.Message{processed}, nil
+}
+
+// EvolveSchema delegates the schema evolution to the wrapped processor
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	return p.processor.EvolveSchema(streamSchema)
+}
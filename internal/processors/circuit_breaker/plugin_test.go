@@ -0,0 +1,193 @@
+package circuit_breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/processors"
+	"github.com/usedatabrew/blink/internal/processors/http"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+type flakyProcessor struct {
+	calls int
+	fail  bool
+}
+
+func (f *flakyProcessor) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	f.calls++
+	if f.fail {
+		return nil, errors.New("downstream is unavailable")
+	}
+	return msg, nil
+}
+
+func (f *flakyProcessor) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	return nil
+}
+
+// batchProcessor buffers the messages until Flush is called
+type batchProcessor struct {
+	flakyProcessor
+	batch []*message.Message
+}
+
+func (b *batchProcessor) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	b.calls++
+	if b.fail {
+		return nil, errors.New("downstream is unavailable")
+	}
+	b.batch = append(b.batch, msg)
+	return nil, nil
+}
+
+func (b *batchProcessor) Flush(context context.Context) ([]*message.Message, error) {
+	batch := b.batch
+	b.batch = nil
+	return batch, nil
+}
+
+func TestPlugin_Process(t *testing.T) {
+	wrapped := &flakyProcessor{fail: true}
+	plugin, err := NewCircuitBreakerPlugin(stream_context.CreateContext(1), Config{
+		Processor:        Processor{Driver: "http"},
+		FailureThreshold: 2,
+		CoolDown:         10,
+		Fallback:         DropFallback,
+	}, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	plugin.(*Plugin).now = func() time.Time { return now }
+
+	msg := func() *message.Message {
+		return message.NewMessage(message.Insert, "events", []byte(`[{"id": 1}]`))
+	}
+
+	for idx := 0; idx < 5; idx++ {
+		if processed, err := plugin.Process(context.Background(), msg()); err != nil || processed != nil {
+			t.Fatal("failed messages must be dropped")
+		}
+	}
+	if wrapped.calls != 2 {
+		t.Fatalf("open circuit must short-circuit the calls, got %d calls", wrapped.calls)
+	}
+
+	// after the cool-down a single failed attempt opens the circuit again
+	now = now.Add(11 * time.Second)
+	plugin.Process(context.Background(), msg())
+	plugin.Process(context.Background(), msg())
+	if wrapped.calls != 3 {
+		t.Fatalf("expected a single attempt after the cool-down, got %d calls", wrapped.calls)
+	}
+
+	now = now.Add(11 * time.Second)
+	wrapped.fail = false
+	for idx := 0; idx < 3; idx++ {
+		if processed, _ := plugin.Process(context.Background(), msg()); processed == nil {
+			t.Fatal("closed circuit must pass the messages to the wrapped processor")
+		}
+	}
+	if wrapped.calls != 6 {
+		t.Fatalf("expected the circuit to be closed, got %d calls", wrapped.calls)
+	}
+}
+
+func TestPlugin_Buffered(t *testing.T) {
+	config := Config{Processor: Processor{Driver: "embeddings"}, FailureThreshold: 1, CoolDown: 10}
+
+	plugin, err := NewCircuitBreakerPlugin(stream_context.CreateContext(1), config, &flakyProcessor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plugin.(processors.MultiMessageProcessor); ok {
+		t.Fatal("single message processor must stay single message when wrapped")
+	}
+
+	wrapped := &batchProcessor{}
+	plugin, err = NewCircuitBreakerPlugin(stream_context.CreateContext(1), config, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffered, ok := plugin.(processors.BufferedProcessor)
+	if !ok {
+		t.Fatal("buffered processor must stay buffered when wrapped")
+	}
+
+	msg := message.NewMessage(message.Insert, "events", []byte(`[{"id": 1}]`))
+	if processed, err := buffered.ProcessMulti(context.Background(), msg); err != nil || len(processed) != 0 {
+		t.Fatal("message must be buffered by the wrapped processor")
+	}
+	if flushed, err := buffered.Flush(context.Background()); err != nil || len(flushed) != 1 {
+		t.Fatal("flush must be delegated to the wrapped processor")
+	}
+
+	// pass through fallback is applied to the multi message calls
+	wrapped.fail = true
+	if processed, err := buffered.ProcessMulti(context.Background(), msg); err != nil || len(processed) != 1 {
+		t.Fatal("failed message must be passed through")
+	}
+	wrapped.fail = false
+	buffered.ProcessMulti(context.Background(), msg)
+	if wrapped.calls != 2 {
+		t.Fatalf("open circuit must short-circuit the calls, got %d calls", wrapped.calls)
+	}
+}
+
+func TestPlugin_Windowed(t *testing.T) {
+	// the first request fails while the next ones are in flight
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/events/0" {
+			w.WriteHeader(nethttp.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	wrapped, err := http.NewHttpPlugin(stream_context.CreateContext(1), http.Config{
+		StreamName:  "events",
+		Endpoint:    server.URL + "/events/{{ .Data.id }}",
+		Method:      nethttp.MethodGet,
+		Source:      "*",
+		MaxInFlight: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plugin, err := NewCircuitBreakerPlugin(stream_context.CreateContext(1), Config{Processor: Processor{Driver: "http"}}, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffered := plugin.(processors.BufferedProcessor)
+
+	var ids []string
+	collect := func(processed []*message.Message, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, msg := range processed {
+			ids = append(ids, fmt.Sprint(helper.MessageRow(msg)["id"]))
+		}
+	}
+
+	for idx := 0; idx < 6; idx++ {
+		collect(buffered.ProcessMulti(context.Background(), message.NewMessage(message.Insert, "events", []byte(fmt.Sprintf(`[{"id": %d}]`, idx)))))
+	}
+	collect(buffered.Flush(context.Background()))
+
+	// failed message is passed through in place, every message is emitted once
+	if strings.Join(ids, ",") != "0,1,2,3,4,5" {
+		t.Fatalf("unexpected messages %v", ids)
+	}
+}
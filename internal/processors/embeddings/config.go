@@ -0,0 +1,23 @@
+package embeddings
+
+type Config struct {
+	ApiKey string `json:"api_key" yaml:"api_key"`
+	// BaseURL of OpenAI compatible API, e.g. http://localhost:11434/v1 for a local server
+	BaseURL    string `json:"base_url" yaml:"base_url"`
+	Model      string `json:"model" yaml:"model"`
+	StreamName string `json:"stream_name" yaml:"stream_name"`
+	// Fields of the batched messages are embedded with a single request
+	Fields []Field `json:"fields" yaml:"fields"`
+	// BatchSize is the max amount of messages embedded with a single request. Defaults to 100.
+	// Smaller batches are sent once the pipeline flushes the processors
+	BatchSize int `json:"batch_size" yaml:"batch_size"`
+	// Dimensions of the vector returned by the model. Sent to the API
+	// for the models that support shortening embeddings
+	Dimensions     int  `json:"dimensions" yaml:"dimensions"`
+	SendDimensions bool `json:"send_dimensions" yaml:"send_dimensions"`
+}
+
+type Field struct {
+	SourceField string `json:"source_field" yaml:"source_field"`
+	TargetField string `json:"target_field" yaml:"target_field"`
+}
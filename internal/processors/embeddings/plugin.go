@@ -0,0 +1,201 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/sashabaranov/go-openai"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	defaultModel     = openai.AdaEmbeddingV2
+	defaultBatchSize = 100
+)
+
+type Plugin struct {
+	config Config
+	ctx    *stream_context.Context
+	client *openai.Client
+	logger *log.Logger
+	stream string
+	// batch holds the messages waiting for the embeddings request
+	batch []*message.Message
+}
+
+func NewEmbeddingsPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	if config.StreamName == "" || len(config.Fields) == 0 {
+		return nil, errors.New("stream_name and fields are required for embeddings processor")
+	}
+
+	if config.Dimensions <= 0 {
+		return nil, errors.New("dimensions must be set to define the vector column size")
+	}
+
+	if config.Model == "" {
+		config.Model = string(defaultModel)
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+
+	clientConfig := openai.DefaultConfig(config.ApiKey)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
+
+	return &Plugin{
+		config: config,
+		ctx:    appctx,
+		client: openai.NewClientWithConfig(clientConfig),
+		logger: appctx.Logger.WithPrefix("processor [embeddings]"),
+		stream: helper.NormalizeStreamName(config.StreamName),
+	}, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
+		return msg, nil
+	}
+
+	processed, err := p.embedMessages(context, []*message.Message{msg})
+	if err != nil {
+		return nil, err
+	}
+
+	return processed[0], nil
+}
+
+// ProcessMulti batches the messages of the stream and embeds them once the batch is full.
+// Messages of the other streams release the batch first to keep the order of the stream
+func (p *Plugin) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
+		processed, err := p.Flush(context)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(processed, msg), nil
+	}
+
+	p.batch = append(p.batch, msg)
+	if len(p.batch) < p.config.BatchSize {
+		return nil, nil
+	}
+
+	return p.Flush(context)
+}
+
+// Flush embeds the batched messages
+func (p *Plugin) Flush(context context.Context) ([]*message.Message, error) {
+	if len(p.batch) == 0 {
+		return nil, nil
+	}
+
+	batch := p.batch
+	p.batch = nil
+	return p.embedMessages(context, batch)
+}
+
+// embedMessages sends the non-empty text fields of all the messages in a single request
+func (p *Plugin) embedMessages(context context.Context, msgs []*message.Message) ([]*message.Message, error) {
+	type target struct {
+		row   map[string]interface{}
+		field string
+	}
+
+	var inputs []string
+	var targets []target
+	rows := make([]map[string]interface{}, len(msgs))
+	for idx, msg := range msgs {
+		row := helper.MessageRow(msg)
+		rows[idx] = row
+		for _, field := range p.config.Fields {
+			row[field.TargetField] = nil
+			value, ok := row[field.SourceField]
+			if !ok || value == nil {
+				continue
+			}
+
+			text := strings.TrimSpace(fmt.Sprintf("%v", value))
+			if text == "" {
+				continue
+			}
+
+			inputs = append(inputs, text)
+			targets = append(targets, target{row: row, field: field.TargetField})
+		}
+	}
+
+	if len(inputs) > 0 {
+		vectors, err := p.embed(context, inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		for idx, target := range targets {
+			target.row[target.field] = vectors[idx]
+		}
+	}
+
+	for idx, msg := range msgs {
+		if err := helper.SetMessageRow(msg, rows[idx]); err != nil {
+			return nil, err
+		}
+	}
+
+	return msgs, nil
+}
+
+func (p *Plugin) embed(context context.Context, inputs []string) ([][]float32, error) {
+	request := openai.EmbeddingRequestStrings{
+		Input: inputs,
+		Model: openai.EmbeddingModel(p.config.Model),
+	}
+	if p.config.SendDimensions {
+		request.Dimensions = p.config.Dimensions
+	}
+
+	resp, err := p.client.CreateEmbeddings(context, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for _, embedding := range resp.Data {
+		if embedding.Index < 0 || embedding.Index >= len(inputs) {
+			return nil, fmt.Errorf("unexpected embedding index %d", embedding.Index)
+		}
+		if len(embedding.Embedding) != p.config.Dimensions {
+			return nil, fmt.Errorf("model returned vector of size %d, expected %d", len(embedding.Embedding), p.config.Dimensions)
+		}
+		vectors[embedding.Index] = embedding.Embedding
+	}
+
+	return vectors, nil
+}
+
+// EvolveSchema adds a fixed size vector column for every embedded field
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	for _, field := range p.config.Fields {
+		streamSchema.AddColumn(p.stream, schema.Column{
+			Name:                field.TargetField,
+			DatabrewType:        helper.VectorType(p.config.Dimensions),
+			NativeConnectorType: fmt.Sprintf("vector(%d)", p.config.Dimensions),
+			Nullable:            true,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+// newTestServer emulates embeddings API returning the vector [len(input), index] for every input.
+// Inputs of every request are recorded
+func newTestServer(t *testing.T, requests *[][]string, status int, dimensions int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error": {"message": "model is overloaded"}}`))
+			return
+		}
+
+		var request struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		*requests = append(*requests, request.Input)
+
+		type embedding struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}
+		var data []embedding
+		// embeddings are returned in the reverse order, so the plugin has to match them by index
+		for idx := len(request.Input) - 1; idx >= 0; idx-- {
+			vector := []float32{float32(len(request.Input[idx])), float32(idx)}
+			data = append(data, embedding{Embedding: vector[:dimensions], Index: idx})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": data})
+	}))
+}
+
+func newTestPlugin(t *testing.T, baseURL string) *Plugin {
+	plugin, err := NewEmbeddingsPlugin(stream_context.CreateContext(1), Config{
+		BaseURL:    baseURL,
+		Model:      "local-model",
+		StreamName: "products",
+		Fields: []Field{
+			{SourceField: "title", TargetField: "title_embedding"},
+			{SourceField: "description", TargetField: "description_embedding"},
+		},
+		Dimensions: 2,
+		BatchSize:  3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func TestPlugin_ProcessMulti(t *testing.T) {
+	var requests [][]string
+	server := newTestServer(t, &requests, http.StatusOK, 2)
+	defer server.Close()
+
+	plugin := newTestPlugin(t, server.URL)
+	for _, payload := range []string{
+		`[{"id": 1, "title": "hat", "description": "red hat"}]`,
+		`[{"id": 2, "title": "scarf", "description": null}]`,
+	} {
+		msgs, err := plugin.ProcessMulti(context.Background(), message.NewMessage(message.Insert, "products", []byte(payload)))
+		if err != nil || len(msgs) != 0 {
+			t.Fatal("messages must be batched until the batch is full")
+		}
+	}
+
+	if len(requests) != 0 {
+		t.Fatal("messages must be batched until the batch is full")
+	}
+
+	// message of the other stream releases the batch to keep the order
+	msgs, err := plugin.ProcessMulti(context.Background(), message.NewMessage(message.Insert, "orders", []byte(`[{"id": 3}]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 3 || msgs[2].GetStream() != "orders" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+
+	if len(requests) != 1 || strings.Join(requests[0], ",") != "hat,red hat,scarf" {
+		t.Fatalf("fields of the batch must be embedded with a single request, got %v", requests)
+	}
+
+	expected := []string{
+		`[{"description":"red hat","description_embedding":[7,1],"id":1,"title":"hat","title_embedding":[3,0]}]`,
+		`[{"description":null,"description_embedding":null,"id":2,"title":"scarf","title_embedding":[5,2]}]`,
+	}
+	for idx, msg := range msgs[:2] {
+		if msg.AsJSONString() != expected[idx] {
+			t.Fatalf("unexpected embedded message %s", msg.AsJSONString())
+		}
+	}
+
+	// full batch is embedded right away
+	for i := 0; i < 3; i++ {
+		msgs, err = plugin.ProcessMulti(context.Background(), message.NewMessage(message.Insert, "products", []byte(`[{"title": "cap"}]`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(msgs) != 3 || len(requests) != 2 {
+		t.Fatalf("full batch must be embedded, got %d messages and %d requests", len(msgs), len(requests))
+	}
+
+	if msgs, err = plugin.Flush(context.Background()); err != nil || len(msgs) != 0 {
+		t.Fatalf("nothing must be left to flush, got %v %v", msgs, err)
+	}
+}
+
+func TestPlugin_APIError(t *testing.T) {
+	var requests [][]string
+	server := newTestServer(t, &requests, http.StatusInternalServerError, 2)
+	defer server.Close()
+
+	plugin := newTestPlugin(t, server.URL)
+	_, err := plugin.Process(context.Background(), message.NewMessage(message.Insert, "products", []byte(`[{"title": "hat"}]`)))
+	if err == nil {
+		t.Fatal("API error must fail the processing")
+	}
+}
+
+func TestPlugin_DimensionMismatch(t *testing.T) {
+	var requests [][]string
+	server := newTestServer(t, &requests, http.StatusOK, 1)
+	defer server.Close()
+
+	plugin := newTestPlugin(t, server.URL)
+	plugin.ProcessMulti(context.Background(), message.NewMessage(message.Insert, "products", []byte(`[{"title": "hat"}]`)))
+	_, err := plugin.Flush(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "model returned vector of size 1, expected 2") {
+		t.Fatalf("dimension mismatch must fail the processing, got %v", err)
+	}
+}
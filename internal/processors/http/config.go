@@ -1,12 +1,32 @@
 package http
 
+const (
+	// FailOnError stops the pipeline when the endpoint responds with non-2xx status
+	FailOnError = "fail"
+	// DropOnError drops the message when the endpoint responds with non-2xx status
+	DropOnError = "drop"
+	// PassThroughOnError sends the message downstream without the response fields
+	PassThroughOnError = "pass_through"
+)
+
 type Config struct {
 	Source      string `json:"source" yaml:"source"`
 	TargetField string `json:"target_field" yaml:"target_field"`
 	StreamName  string `json:"stream_name" yaml:"stream_name"`
-	Endpoint    string `json:"endpoint" yaml:"endpoint"`
-	Method      string `json:"method" yaml:"method"`
-	Auth        Auth   `json:"auth" yaml:"auth"`
+	// Endpoint is Go template rendered for every message, e.g. https://api.io/users/{{ .Data.id }}.
+	// Values in the path and the query are escaped, missing or null fields fail the request
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	Method   string `json:"method" yaml:"method"`
+	// Body is Go template of the request body. When empty, Source field is sent
+	Body string `json:"body" yaml:"body"`
+	// Headers are rendered as Go templates for every message
+	Headers     map[string]string `json:"headers" yaml:"headers"`
+	Auth        Auth              `json:"auth" yaml:"auth"`
+	TimeoutMs   int64             `json:"timeout_ms" yaml:"timeout_ms"`
+	MaxInFlight int               `json:"max_in_flight" yaml:"max_in_flight"`
+	// OnError defines how non-2xx responses are handled: fail, drop or pass_through
+	OnError        string          `json:"on_error" yaml:"on_error"`
+	ResponseFields []ResponseField `json:"response_fields" yaml:"response_fields"`
 }
 
 type Auth struct {
@@ -14,3 +34,11 @@ type Auth struct {
 	BasicAuthUser     string            `json:"basic_auth_user" yaml:"basic_auth_user"`
 	BasicAuthPassword string            `json:"basic_auth_password" yaml:"basic_auth_password"`
 }
+
+// ResponseField extracts the value from JSON response by JSONPath
+// and stores it in the column of the given type
+type ResponseField struct {
+	Path   string `json:"path" yaml:"path"`
+	Column string `json:"column" yaml:"column"`
+	Type   string `json:"type" yaml:"type"`
+}
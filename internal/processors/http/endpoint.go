@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/usedatabrew/blink/internal/helper"
+)
+
+const (
+	pathEscapeFunc  = "pathescape"
+	queryEscapeFunc = "queryescape"
+)
+
+// parseEndpoint parses the endpoint template. Values of the missing fields fail the rendering
+// instead of rendering as <no value>, the values interpolated into the path and the query are escaped
+func parseEndpoint(text string) (*template.Template, error) {
+	tmpl, err := helper.ParseMessageTemplate("endpoint", text)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl.Option("missingkey=error").Funcs(template.FuncMap{
+		pathEscapeFunc:  escapeWith(url.PathEscape),
+		queryEscapeFunc: escapeWith(url.QueryEscape),
+	})
+
+	var static strings.Builder
+	escapeActions(tmpl.Tree, tmpl.Tree.Root, &static)
+	return tmpl, nil
+}
+
+// escapeActions appends the escaping function to the actions by the part of the URL they are in.
+// Static text preceding the action tells the part: actions after the ? are the query values,
+// actions after the path of the host started are the path segments and the scheme
+// and the host are left as they are
+func escapeActions(tree *parse.Tree, list *parse.ListNode, static *strings.Builder) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+			static.Write(node.Text)
+		case *parse.ActionNode:
+			if len(node.Pipe.Decl) > 0 {
+				continue
+			}
+
+			escaper := urlPart(static.String())
+			if escaper == "" {
+				continue
+			}
+			identifier := parse.NewIdentifier(escaper).SetTree(tree).SetPos(node.Pos)
+			node.Pipe.Cmds = append(node.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: node.Pos, Args: []parse.Node{identifier}})
+		case *parse.IfNode:
+			escapeActions(tree, node.List, static)
+			escapeActions(tree, node.ElseList, static)
+		case *parse.RangeNode:
+			escapeActions(tree, node.List, static)
+			escapeActions(tree, node.ElseList, static)
+		case *parse.WithNode:
+			escapeActions(tree, node.List, static)
+			escapeActions(tree, node.ElseList, static)
+		}
+	}
+}
+
+// urlPart returns the escaping function of the action following the static text
+func urlPart(static string) string {
+	if strings.Contains(static, "?") {
+		return queryEscapeFunc
+	}
+
+	if _, host, ok := strings.Cut(static, "://"); ok && strings.Contains(host, "/") {
+		return pathEscapeFunc
+	}
+
+	return ""
+}
+
+func escapeWith(escape func(string) string) func(interface{}) (string, error) {
+	return func(value interface{}) (string, error) {
+		if value == nil {
+			return "", errors.New("endpoint value is null")
+		}
+
+		return escape(fmt.Sprint(value)), nil
+	}
+}
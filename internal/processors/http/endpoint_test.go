@@ -0,0 +1,40 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/message"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	msg := message.NewMessage(message.Insert, "users", []byte(`[{"id": 7, "name": "a/b c&d", "host": "api.io", "deleted": null}]`))
+
+	for _, test := range []struct {
+		endpoint string
+		expected string
+		fails    bool
+	}{
+		{endpoint: "https://api.io/users/{{ .Data.id }}", expected: "https://api.io/users/7"},
+		{endpoint: "https://api.io/users/{{ .Data.name }}?name={{ .Data.name }}", expected: "https://api.io/users/a%2Fb%20c&d?name=a%2Fb+c%26d"},
+		{endpoint: "https://{{ .Data.host }}/users", expected: "https://api.io/users"},
+		{endpoint: "https://api.io/users/{{ .Data.missing }}", fails: true},
+		{endpoint: "https://api.io/users?deleted={{ .Data.deleted }}", fails: true},
+	} {
+		tmpl, err := parseEndpoint(test.endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rendered, err := helper.RenderMessageTemplate(tmpl, helper.NewMessageTemplateData(msg))
+		if test.fails {
+			if err == nil {
+				t.Fatalf("%s must fail to render, got %s", test.endpoint, rendered)
+			}
+			continue
+		}
+		if err != nil || rendered != test.expected {
+			t.Fatalf("%s is rendered as %s %v", test.endpoint, rendered, err)
+		}
+	}
+}
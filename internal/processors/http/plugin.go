@@ -5,45 +5,180 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/PaesslerAG/jsonpath"
 	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/charmbracelet/log"
 	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/processors/inflight"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
-	"io"
-	"net/http"
 )
 
+const defaultTimeout = 30 * time.Second
+
 type Plugin struct {
-	config Config
-	ctx    *stream_context.Context
-	model  string
-	prompt string
+	config   Config
+	ctx      *stream_context.Context
+	logger   *log.Logger
+	stream   string
+	client   *http.Client
+	window   *inflight.Window
+	endpoint *template.Template
+	body     *template.Template
+	headers  map[string]*template.Template
 }
 
 func NewHttpPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
-	return &Plugin{config: config, ctx: appctx}, nil
+	switch config.OnError {
+	case "":
+		config.OnError = FailOnError
+	case FailOnError, DropOnError, PassThroughOnError:
+	default:
+		return nil, fmt.Errorf("unsupported on_error value %s", config.OnError)
+	}
+
+	for idx, field := range config.ResponseFields {
+		if field.Path == "" || field.Column == "" {
+			return nil, errors.New("path and column are required for http response fields")
+		}
+		if field.Type == "" {
+			config.ResponseFields[idx].Type = "String"
+		}
+	}
+
+	timeout := defaultTimeout
+	if config.TimeoutMs > 0 {
+		timeout = time.Duration(config.TimeoutMs) * time.Millisecond
+	}
+
+	maxInFlight := config.MaxInFlight
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	// single client is shared by all the requests to reuse the connections
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxInFlight
+
+	plugin := &Plugin{
+		config:  config,
+		ctx:     appctx,
+		logger:  appctx.Logger.WithPrefix("processor [http]"),
+		stream:  helper.NormalizeStreamName(config.StreamName),
+		client:  &http.Client{Transport: transport, Timeout: timeout},
+		window:  inflight.NewWindow(maxInFlight),
+		headers: map[string]*template.Template{},
+	}
+
+	var err error
+	if plugin.endpoint, err = parseEndpoint(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid endpoint template: %w", err)
+	}
+
+	if config.Body != "" {
+		if plugin.body, err = helper.ParseMessageTemplate("body", config.Body); err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+	}
+
+	for key, value := range config.Headers {
+		if plugin.headers[key], err = helper.ParseMessageTemplate(key, value); err != nil {
+			return nil, fmt.Errorf("invalid template for header %s: %w", key, err)
+		}
+	}
+
+	return plugin, nil
 }
 
 func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
-	if msg.GetStream() != p.config.StreamName {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
 		return msg, nil
 	}
 
+	req, err := p.buildRequest(context, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyResult, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		switch p.config.OnError {
+		case DropOnError:
+			p.logger.Warn("message dropped due to the endpoint response", "status", resp.StatusCode)
+			return nil, nil
+		case PassThroughOnError:
+			return msg, p.setResponseFields(msg, nil)
+		default:
+			return nil, fmt.Errorf("endpoint responded with status %d: %s", resp.StatusCode, bodyResult)
+		}
+	}
+
+	return msg, p.setResponseFields(msg, bodyResult)
+}
+
+// ProcessMulti sends the request in the background and returns the messages
+// whose requests are completed. The order of the messages is preserved
+func (p *Plugin) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	return p.window.Submit(msg, func() ([]*message.Message, error) {
+		processed, err := p.Process(context, msg)
+		if err != nil || processed == nil {
+			return nil, err
+		}
+
+		return []*message.Message{processed}, nil
+	})
+}
+
+// Flush waits for all the requests in flight
+func (p *Plugin) Flush(context context.Context) ([]*message.Message, error) {
+	return p.window.Drain()
+}
+
+func (p *Plugin) buildRequest(context context.Context, msg *message.Message) (*http.Request, error) {
+	data := helper.NewMessageTemplateData(msg)
+
+	endpoint, err := helper.RenderMessageTemplate(p.endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+
 	var requestPayload []byte
-	// means we have to pack all the message and send it over http
-	if p.config.Source == "*" {
+	switch {
+	case p.body != nil:
+		rendered, err := helper.RenderMessageTemplate(p.body, data)
+		if err != nil {
+			return nil, err
+		}
+		requestPayload = []byte(rendered)
+	case p.config.Source == "*":
+		// means we have to pack all the message and send it over http
 		requestPayload = []byte(msg.AsJSONString())
-	} else {
+	default:
 		sourceFieldValue := msg.Data.AccessProperty(p.config.Source)
-		if marshaled, err := json.Marshal(&sourceFieldValue); err != nil {
+		if requestPayload, err = json.Marshal(&sourceFieldValue); err != nil {
 			return nil, err
-		} else {
-			requestPayload = marshaled
 		}
 	}
 
-	req, err := http.NewRequest(p.config.Method, p.config.Endpoint, bytes.NewReader(requestPayload))
+	req, err := http.NewRequestWithContext(context, p.config.Method, strings.TrimSpace(endpoint), bytes.NewReader(requestPayload))
 	if err != nil {
 		return nil, err
 	}
@@ -56,37 +191,80 @@ func (p *Plugin) Process(context context.Context, msg *message.Message) (*messag
 		req.Header.Set(key, value)
 	}
 
-	client := http.Client{}
-	var resp *http.Response
-	if resp, err = client.Do(req); err != nil {
-		return nil, err
+	for key, tmpl := range p.headers {
+		value, err := helper.RenderMessageTemplate(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(key, value)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode > 400 {
-		return nil, errors.New("response code from endpoint is higher than 400")
+	return req, nil
+}
+
+// setResponseFields stores the raw response and the fields extracted by JSONPath.
+// When the body is nil (failed request passed through) all the fields are set to null
+func (p *Plugin) setResponseFields(msg *message.Message, body []byte) error {
+	if p.config.TargetField == "" && len(p.config.ResponseFields) == 0 {
+		return nil
 	}
 
-	if p.config.TargetField == "" {
-		return msg, err
+	row := helper.MessageRow(msg)
+	if p.config.TargetField != "" {
+		row[p.config.TargetField] = nil
+		if body != nil {
+			row[p.config.TargetField] = string(body)
+		}
 	}
 
-	var bodyResult []byte
-	if bodyResult, err = io.ReadAll(resp.Body); err != nil {
-		return nil, err
+	var response interface{}
+	if body != nil && len(p.config.ResponseFields) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&response); err != nil {
+			p.logger.Warn("endpoint responded with invalid JSON", "error", err)
+		}
 	}
 
-	msg.Data.SetProperty(p.config.TargetField, string(bodyResult))
+	for _, field := range p.config.ResponseFields {
+		row[field.Column] = nil
+		if response == nil {
+			continue
+		}
+
+		value, err := jsonpath.Get(field.Path, response)
+		if err != nil {
+			p.logger.Warn("failed to extract response field", "path", field.Path, "error", err)
+			continue
+		}
 
-	return msg, nil
+		if row[field.Column], err = helper.CastValue(value, field.Type); err != nil {
+			p.logger.Warn("failed to cast response field", "path", field.Path, "type", field.Type, "error", err)
+			row[field.Column] = nil
+		}
+	}
+
+	return helper.SetMessageRow(msg, row)
 }
 
-// EvolveSchema will add a string column to the schema in order to store OpenAI response
+// EvolveSchema adds a string column for the raw response and typed columns for the response fields
 func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	if p.config.TargetField == "" && len(p.config.ResponseFields) == 0 {
+		streamSchema.FakeEvolve()
+		return nil
+	}
+
 	if p.config.TargetField != "" {
 		streamSchema.AddField(p.config.StreamName, p.config.TargetField, arrow.BinaryTypes.String, helper.ArrowToPg10(arrow.BinaryTypes.String))
-	} else {
-		streamSchema.FakeEvolve()
+	}
+
+	for _, field := range p.config.ResponseFields {
+		streamSchema.AddColumn(p.stream, schema.Column{
+			Name:                field.Column,
+			DatabrewType:        field.Type,
+			NativeConnectorType: helper.ArrowToPg10(helper.MapPlainTypeToArrow(field.Type)),
+			Nullable:            true,
+		})
 	}
 
 	return nil
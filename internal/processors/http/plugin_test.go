@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/7" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Stream") != "users" || string(body) != `{"email":"max@databrew.tech"}` {
+			t.Errorf("request is not templated: %s %s", r.Header, body)
+		}
+
+		w.Write([]byte(`{"data": {"score": "42", "tags": ["a"]}}`))
+	}))
+}
+
+func newTestPlugin(t *testing.T, endpoint, onError string) *Plugin {
+	plugin, err := NewHttpPlugin(stream_context.CreateContext(1), Config{
+		StreamName: "users",
+		Endpoint:   endpoint + "/users/{{ .Data.id }}",
+		Method:     http.MethodPost,
+		Body:       `{"email":{{ json .Data.email }}}`,
+		Headers:    map[string]string{"X-Stream": "{{ .Stream }}"},
+		OnError:    onError,
+		ResponseFields: []ResponseField{
+			{Path: "$.data.score", Column: "score", Type: "Int64"},
+			{Path: "$.data.tags", Column: "tags"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func TestPlugin_EvolveSchema(t *testing.T) {
+	streamSchema := schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "users",
+			Columns:    []schema.Column{{Name: "id", DatabrewType: "Int64", PK: true}},
+		},
+	})
+	if err := newTestPlugin(t, "", "").EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	columns := streamSchema.GetLatestSchema()[0].Columns
+	if len(columns) != 3 || columns[1].DatabrewType != "Int64" || columns[2].DatabrewType != "String" {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+}
+
+func TestPlugin_Process(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	msg := message.NewMessage(message.Insert, "users", []byte(`[{"id": 7, "email": "max@databrew.tech"}]`))
+	processed, err := newTestPlugin(t, server.URL, "").Process(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := helper.MessageRow(processed)
+	if row["score"] != json.Number("42") || row["tags"] != `["a"]` {
+		t.Fatalf("response fields are not extracted %v", row)
+	}
+
+	notFound := func() *message.Message {
+		return message.NewMessage(message.Insert, "users", []byte(`[{"id": 8, "email": "max@databrew.tech"}]`))
+	}
+
+	if _, err = newTestPlugin(t, server.URL, FailOnError).Process(context.Background(), notFound()); err == nil {
+		t.Fatal("400 response must fail the pipeline")
+	}
+
+	if processed, err = newTestPlugin(t, server.URL, DropOnError).Process(context.Background(), notFound()); err != nil || processed != nil {
+		t.Fatal("400 response must drop the message")
+	}
+
+	processed, err = newTestPlugin(t, server.URL, PassThroughOnError).Process(context.Background(), notFound())
+	if err != nil || processed == nil {
+		t.Fatal("400 response must pass the message through")
+	}
+	if value, ok := helper.MessageRow(processed)["score"]; !ok || value != nil {
+		t.Fatal("response fields of passed through message must be null")
+	}
+}
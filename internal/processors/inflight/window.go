@@ -0,0 +1,108 @@
+package inflight
+
+import (
+	"sync"
+
+	"github.com/usedatabrew/message"
+)
+
+// Task is a unit of work executed by the window in a separate goroutine
+type Task func() ([]*message.Message, error)
+
+// TaskError is the error of the failed task with the message the task was submitted for.
+// Window collects the tasks of several messages at once, so the error tells which one failed
+type TaskError struct {
+	Message *message.Message
+	Err     error
+}
+
+func (e *TaskError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+type pendingTask struct {
+	done     chan struct{}
+	message  *message.Message
+	messages []*message.Message
+	err      error
+}
+
+// Window executes the tasks concurrently keeping at most size of them in flight.
+// Results are always returned in the submission order, so the processors
+// built on top of it don't reorder the messages of the stream
+type Window struct {
+	size  int
+	mutx  sync.Mutex
+	queue []*pendingTask
+}
+
+func NewWindow(size int) *Window {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Window{size: size}
+}
+
+// Submit starts the task of the message and returns the results of all the tasks completed so far
+// in the submission order. If the window is full, Submit blocks until the oldest task is done.
+// Collection stops at the failed task, the results collected before it are returned along with
+// its *TaskError and the tasks after it are collected by the next call
+func (w *Window) Submit(msg *message.Message, task Task) ([]*message.Message, error) {
+	w.mutx.Lock()
+	defer w.mutx.Unlock()
+
+	pending := &pendingTask{done: make(chan struct{}), message: msg}
+	w.queue = append(w.queue, pending)
+	go func() {
+		defer close(pending.done)
+		pending.messages, pending.err = task()
+	}()
+
+	return w.collect(false)
+}
+
+// Drain waits for all the tasks in flight and returns their results.
+// Like Submit, it stops at the failed task
+func (w *Window) Drain() ([]*message.Message, error) {
+	w.mutx.Lock()
+	defer w.mutx.Unlock()
+
+	return w.collect(true)
+}
+
+// InFlight returns the number of tasks that are not collected yet
+func (w *Window) InFlight() int {
+	w.mutx.Lock()
+	defer w.mutx.Unlock()
+
+	return len(w.queue)
+}
+
+func (w *Window) collect(wait bool) ([]*message.Message, error) {
+	var collected []*message.Message
+	for len(w.queue) > 0 {
+		head := w.queue[0]
+		if wait || len(w.queue) > w.size-1 {
+			<-head.done
+		} else {
+			select {
+			case <-head.done:
+			default:
+				return collected, nil
+			}
+		}
+
+		w.queue = w.queue[1:]
+		if head.err != nil {
+			return collected, &TaskError{Message: head.message, Err: head.err}
+		}
+		collected = append(collected, head.messages...)
+	}
+
+	return collected, nil
+}
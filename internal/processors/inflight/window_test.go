@@ -0,0 +1,84 @@
+package inflight
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/usedatabrew/message"
+)
+
+func delayedTask(id int, delay time.Duration) Task {
+	return func() ([]*message.Message, error) {
+		time.Sleep(delay)
+		return []*message.Message{
+			message.NewMessage(message.Insert, "stream", []byte(fmt.Sprintf(`[{"id": %d}]`, id))),
+		}, nil
+	}
+}
+
+func TestWindow_PreservesOrder(t *testing.T) {
+	window := NewWindow(3)
+
+	var collected []*message.Message
+	for idx, delay := range []time.Duration{30, 1, 20, 1, 10} {
+		msgs, err := window.Submit(nil, delayedTask(idx, delay*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		collected = append(collected, msgs...)
+		if window.InFlight() > 2 {
+			t.Fatalf("window keeps %d tasks in flight", window.InFlight())
+		}
+	}
+
+	msgs, err := window.Drain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collected = append(collected, msgs...)
+
+	if len(collected) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(collected))
+	}
+	for idx, msg := range collected {
+		if msg.AsJSONString() != fmt.Sprintf(`[{"id":%d}]`, idx) {
+			t.Fatalf("message %d is out of order: %s", idx, msg.AsJSONString())
+		}
+	}
+}
+
+func TestWindow_ReturnsTaskError(t *testing.T) {
+	window := NewWindow(4)
+	failed := message.NewMessage(message.Insert, "stream", []byte(`[{"id": 1}]`))
+
+	if _, err := window.Submit(nil, delayedTask(0, 50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := window.Submit(failed, func() ([]*message.Message, error) {
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.New("request failed")
+	}); err != nil {
+		t.Fatal("error must be returned once the task is collected")
+	}
+	if _, err := window.Submit(nil, delayedTask(2, time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// results before the failed task are returned along with the error of its message
+	collected, err := window.Drain()
+	var taskErr *TaskError
+	if !errors.As(err, &taskErr) || taskErr.Message != failed {
+		t.Fatalf("expected task error of the failed message, got %v", err)
+	}
+	if len(collected) != 1 || collected[0].AsJSONString() != `[{"id":0}]` {
+		t.Fatalf("results before the failed task must be returned, got %v", collected)
+	}
+
+	// tasks after the failed one are kept for the next call
+	collected, err = window.Drain()
+	if err != nil || len(collected) != 1 || collected[0].AsJSONString() != `[{"id":2}]` {
+		t.Fatalf("tasks after the failed one must be collected, got %v %v", collected, err)
+	}
+}
@@ -0,0 +1,28 @@
+package join
+
+type Config struct {
+	// StreamName is the stream that will be enriched, e.g. orders
+	StreamName string `json:"stream_name" yaml:"stream_name"`
+	StreamKey  string `json:"stream_key" yaml:"stream_key"`
+	// TableStream is materialized into the keyed state, e.g. customers
+	TableStream string `json:"table_stream" yaml:"table_stream"`
+	TableKey    string `json:"table_key" yaml:"table_key"`
+	// Columns of the table stream to add to the enriched messages.
+	// All the columns except the join key are used when empty
+	Columns []string `json:"columns" yaml:"columns"`
+	// Prefix is prepended to the joined column names to avoid collisions
+	Prefix string `json:"prefix" yaml:"prefix"`
+	// JoinType is either inner or left. Defaults to left
+	JoinType string `json:"join_type" yaml:"join_type"`
+	// EmitTableStream passes the table stream messages downstream.
+	// By default they are consumed by the processor
+	EmitTableStream bool        `json:"emit_table_stream" yaml:"emit_table_stream"`
+	State           StateConfig `json:"state" yaml:"state"`
+}
+
+type StateConfig struct {
+	// Type is either memory or disk. Defaults to memory
+	Type string `json:"type" yaml:"type"`
+	// Path to the state file for the disk state
+	Path string `json:"path" yaml:"path"`
+}
@@ -0,0 +1,191 @@
+package join
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	InnerJoin = "inner"
+	LeftJoin  = "left"
+)
+
+type Plugin struct {
+	config        Config
+	ctx           *stream_context.Context
+	logger        *log.Logger
+	state         StateStore
+	stream        string
+	tableStream   string
+	columnsToJoin []string
+}
+
+func NewJoinPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	if config.StreamName == "" || config.TableStream == "" || config.StreamKey == "" || config.TableKey == "" {
+		return nil, errors.New("stream_name, stream_key, table_stream and table_key are required for join processor")
+	}
+
+	if config.JoinType == "" {
+		config.JoinType = LeftJoin
+	}
+
+	if config.JoinType != InnerJoin && config.JoinType != LeftJoin {
+		return nil, fmt.Errorf("unsupported join type %s", config.JoinType)
+	}
+
+	var state StateStore
+	switch config.State.Type {
+	case "", "memory":
+		state = newMemoryState()
+	case "disk":
+		if config.State.Path == "" {
+			return nil, errors.New("state path is required for the disk state")
+		}
+		diskState, err := newDiskState(config.State.Path)
+		if err != nil {
+			return nil, err
+		}
+		state = diskState
+	default:
+		return nil, fmt.Errorf("unsupported state type %s", config.State.Type)
+	}
+
+	return &Plugin{
+		config:      config,
+		ctx:         appctx,
+		logger:      appctx.Logger.WithPrefix("processor [join]"),
+		state:       state,
+		stream:      helper.NormalizeStreamName(config.StreamName),
+		tableStream: helper.NormalizeStreamName(config.TableStream),
+	}, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	switch helper.NormalizeStreamName(msg.GetStream()) {
+	case p.tableStream:
+		return p.materialize(msg)
+	case p.stream:
+		return p.enrich(msg)
+	default:
+		return msg, nil
+	}
+}
+
+// materialize applies the table stream change to the keyed state
+func (p *Plugin) materialize(msg *message.Message) (*message.Message, error) {
+	row := helper.MessageRow(msg)
+	key, ok := row[p.config.TableKey]
+	if !ok || key == nil {
+		p.logger.Warn("Table stream message has no join key", "key", p.config.TableKey)
+	} else {
+		var err error
+		if msg.GetEvent() == message.Delete {
+			err = p.state.Delete(fmt.Sprintf("%v", key))
+		} else {
+			err = p.state.Set(fmt.Sprintf("%v", key), row)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.config.EmitTableStream {
+		return msg, nil
+	}
+
+	return nil, nil
+}
+
+// enrich merges the joined columns from the state into the message
+func (p *Plugin) enrich(msg *message.Message) (*message.Message, error) {
+	row := helper.MessageRow(msg)
+
+	var tableRow map[string]interface{}
+	if key, ok := row[p.config.StreamKey]; ok && key != nil {
+		var err error
+		if tableRow, _, err = p.state.Get(fmt.Sprintf("%v", key)); err != nil {
+			return nil, err
+		}
+	}
+
+	if tableRow == nil && p.config.JoinType == InnerJoin {
+		return nil, nil
+	}
+
+	for _, col := range p.columnsToJoin {
+		// nil values are written for the left join when
+		// there is no matching row in the state yet
+		row[p.config.Prefix+col] = tableRow[col]
+	}
+
+	if err := helper.SetMessageRow(msg, row); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// Close closes the state, so the disk state file is released
+func (p *Plugin) Close() error {
+	return p.state.Close()
+}
+
+// EvolveSchema adds the joined columns of the table stream to the enriched stream
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	var tableSchema, streamToEnrich *schema.StreamSchema
+	for _, stream := range streamSchema.GetLatestSchema() {
+		stream := stream
+		switch helper.NormalizeStreamName(stream.StreamName) {
+		case p.tableStream:
+			tableSchema = &stream
+		case p.stream:
+			streamToEnrich = &stream
+		}
+	}
+
+	if tableSchema == nil || streamToEnrich == nil {
+		return errors.New("join between undefined streams")
+	}
+
+	var columnsToAdd []schema.Column
+	for _, col := range tableSchema.Columns {
+		if len(p.config.Columns) > 0 && !slices.Contains(p.config.Columns, col.Name) {
+			continue
+		}
+		if len(p.config.Columns) == 0 && col.Name == p.config.TableKey {
+			continue
+		}
+
+		p.columnsToJoin = append(p.columnsToJoin, col.Name)
+		columnsToAdd = append(columnsToAdd, schema.Column{
+			Name:                p.config.Prefix + col.Name,
+			DatabrewType:        col.DatabrewType,
+			NativeConnectorType: col.NativeConnectorType,
+			Nullable:            true,
+			Columns:             col.Columns,
+		})
+	}
+
+	if len(columnsToAdd) != len(p.config.Columns) && len(p.config.Columns) > 0 {
+		return fmt.Errorf("some of the columns %v are not defined for the stream %s", p.config.Columns, p.config.TableStream)
+	}
+
+	for _, col := range columnsToAdd {
+		for _, existing := range streamToEnrich.Columns {
+			if existing.Name == col.Name {
+				return fmt.Errorf("column %s already exists in the stream %s. Use prefix to avoid collisions", col.Name, p.config.StreamName)
+			}
+		}
+		streamSchema.AddColumn(p.stream, col)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package join
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func testSchema() *schema.StreamSchemaObj {
+	return schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "public.orders",
+			Columns: []schema.Column{
+				{Name: "id", DatabrewType: "Int64", PK: true},
+				{Name: "customer_id", DatabrewType: "Int64"},
+			},
+		},
+		{
+			StreamName: "public.customers",
+			Columns: []schema.Column{
+				{Name: "id", DatabrewType: "Int64", PK: true},
+				{Name: "name", DatabrewType: "String"},
+			},
+		},
+	})
+}
+
+func newTestPlugin(t *testing.T, joinType string) *Plugin {
+	plugin, err := NewJoinPlugin(stream_context.CreateContext(1), Config{
+		StreamName:  "orders",
+		StreamKey:   "customer_id",
+		TableStream: "customers",
+		TableKey:    "id",
+		Prefix:      "customer_",
+		JoinType:    joinType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func TestPlugin_EvolveSchema(t *testing.T) {
+	streamSchema := testSchema()
+	plugin := newTestPlugin(t, LeftJoin)
+	if err := plugin.EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	orders := streamSchema.GetLatestSchema()[0]
+	if len(orders.Columns) != 3 {
+		t.Fatalf("expected 3 columns, got %d", len(orders.Columns))
+	}
+
+	if orders.Columns[2].Name != "customer_name" || orders.Columns[2].DatabrewType != "String" {
+		t.Fatalf("unexpected joined column %v", orders.Columns[2])
+	}
+}
+
+func TestPlugin_Process(t *testing.T) {
+	plugin := newTestPlugin(t, InnerJoin)
+	if err := plugin.EvolveSchema(testSchema()); err != nil {
+		t.Fatal(err)
+	}
+
+	customer := message.NewMessage(message.Insert, "customers", []byte(`[{"id": 7, "name": "Maxym"}]`))
+	if processed, err := plugin.Process(context.Background(), customer); err != nil || processed != nil {
+		t.Fatal("table stream message should be consumed by the processor")
+	}
+
+	order := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 1, "customer_id": 7}]`))
+	processed, err := plugin.Process(context.Background(), order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if processed.Data.AccessProperty("customer_name") != "Maxym" {
+		t.Fatalf("message is not enriched %s", processed.AsJSONString())
+	}
+
+	unmatched := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 2, "customer_id": 8}]`))
+	if processed, _ = plugin.Process(context.Background(), unmatched); processed != nil {
+		t.Fatal("inner join must drop unmatched messages")
+	}
+
+	plugin.config.JoinType = LeftJoin
+	processed, _ = plugin.Process(context.Background(), unmatched)
+	row := helper.MessageRow(processed)
+	if value, ok := row["customer_name"]; !ok || value != nil {
+		t.Fatal("left join must set joined columns to null")
+	}
+
+	deleted := message.NewMessage(message.Delete, "customers", []byte(`[{"id": 7}]`))
+	if _, err = plugin.Process(context.Background(), deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	order = message.NewMessage(message.Insert, "orders", []byte(`[{"id": 3, "customer_id": 7}]`))
+	processed, _ = plugin.Process(context.Background(), order)
+	if id, _ := helper.MessageRow(processed)["id"].(json.Number).Int64(); id != 3 || processed.Data.AccessProperty("customer_name") != nil {
+		t.Fatal("deleted rows must be removed from the state")
+	}
+}
+
+func TestPlugin_DiskState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "join.db")
+	config := Config{
+		StreamName:  "orders",
+		StreamKey:   "customer_id",
+		TableStream: "customers",
+		TableKey:    "id",
+		Prefix:      "customer_",
+		State:       StateConfig{Type: "disk", Path: path},
+	}
+
+	plugin, err := NewJoinPlugin(stream_context.CreateContext(1), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = plugin.EvolveSchema(testSchema()); err != nil {
+		t.Fatal(err)
+	}
+
+	customer := message.NewMessage(message.Insert, "customers", []byte(`[{"id": 42, "name": "Maxym"}]`))
+	if _, err = plugin.Process(context.Background(), customer); err != nil {
+		t.Fatal(err)
+	}
+
+	// the state file stays locked until the processor is closed
+	if err = plugin.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin, err = NewJoinPlugin(stream_context.CreateContext(1), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Close()
+	if err = plugin.EvolveSchema(testSchema()); err != nil {
+		t.Fatal(err)
+	}
+
+	// rows materialized before the restart are read from the state file
+	order := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 1, "customer_id": 42}]`))
+	processed, err := plugin.Process(context.Background(), order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"customer_id":42,"customer_name":"Maxym","id":1}]`
+	if processed.AsJSONString() != expected {
+		t.Fatalf("unexpected enriched message %s", processed.AsJSONString())
+	}
+}
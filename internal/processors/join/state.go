@@ -0,0 +1,113 @@
+package join
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// StateStore keeps the latest version of the table stream rows by the join key
+type StateStore interface {
+	Get(key string) (map[string]interface{}, bool, error)
+	Set(key string, row map[string]interface{}) error
+	Delete(key string) error
+	Close() error
+}
+
+type memoryState struct {
+	mutex sync.RWMutex
+	rows  map[string]map[string]interface{}
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{rows: map[string]map[string]interface{}{}}
+}
+
+func (m *memoryState) Get(key string) (map[string]interface{}, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	row, ok := m.rows[key]
+	return row, ok, nil
+}
+
+func (m *memoryState) Set(key string, row map[string]interface{}) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rows[key] = row
+	return nil
+}
+
+func (m *memoryState) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.rows, key)
+	return nil
+}
+
+func (m *memoryState) Close() error {
+	return nil
+}
+
+var stateBucket = []byte("join_state")
+
+// diskState persists the table stream in a bolt file,
+// so the state survives restarts and is not limited by memory
+type diskState struct {
+	db *bolt.DB
+}
+
+func newDiskState(path string) (*diskState, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(stateBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &diskState{db: db}, nil
+}
+
+func (d *diskState) Get(key string) (map[string]interface{}, bool, error) {
+	var row map[string]interface{}
+	err := d.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(stateBucket).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		// keep numbers as json.Number to match the rows decoded from messages
+		decoder := json.NewDecoder(bytes.NewReader(value))
+		decoder.UseNumber()
+		return decoder.Decode(&row)
+	})
+
+	return row, row != nil, err
+}
+
+func (d *diskState) Set(key string, row map[string]interface{}) error {
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Put([]byte(key), encoded)
+	})
+}
+
+func (d *diskState) Delete(key string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Delete([]byte(key))
+	})
+}
+
+func (d *diskState) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,26 @@
+package jq
+
+const (
+	// ReplaceMode replaces the message data with the query result
+	ReplaceMode = "replace"
+	// MergeMode merges the fields of the query result into the message data
+	MergeMode = "merge"
+)
+
+type Config struct {
+	StreamName string `json:"stream_name" yaml:"stream_name"`
+	// Query is jq expression evaluated over the message data. $stream and $event variables
+	// are available. Every object produced by the query becomes a separate message
+	Query string `json:"query" yaml:"query"`
+	// Mode is either replace (default) or merge
+	Mode string `json:"mode" yaml:"mode"`
+	// Columns declare the fields of the query result. Only declared fields are kept in the message
+	Columns []Column `json:"columns" yaml:"columns"`
+}
+
+type Column struct {
+	Name string `json:"name" yaml:"name"`
+	// Type is databrew type of the column, e.g. String, Int64, Float64, Boolean, JSON. Defaults to String
+	Type     string `json:"type" yaml:"type"`
+	Nullable bool   `json:"nullable" yaml:"nullable"`
+}
@@ -0,0 +1,204 @@
+package jq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/itchyny/gojq"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+type Plugin struct {
+	config Config
+	ctx    *stream_context.Context
+	logger *log.Logger
+	stream string
+	code   *gojq.Code
+}
+
+func NewJqPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	switch config.Mode {
+	case "":
+		config.Mode = ReplaceMode
+	case ReplaceMode, MergeMode:
+	default:
+		return nil, fmt.Errorf("unsupported jq mode %s", config.Mode)
+	}
+
+	if config.StreamName == "" || config.Query == "" {
+		return nil, errors.New("stream_name and query are required for jq processor")
+	}
+
+	if len(config.Columns) == 0 {
+		return nil, errors.New("columns of the query result must be declared for jq processor")
+	}
+
+	for idx, column := range config.Columns {
+		if column.Name == "" {
+			return nil, errors.New("column name is required for jq processor")
+		}
+		if column.Type == "" {
+			config.Columns[idx].Type = "String"
+		}
+	}
+
+	query, err := gojq.Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jq query: %w", err)
+	}
+
+	code, err := gojq.Compile(query, gojq.WithVariables([]string{"$stream", "$event"}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile jq query: %w", err)
+	}
+
+	return &Plugin{
+		config: config,
+		ctx:    appctx,
+		logger: appctx.Logger.WithPrefix("processor [jq]"),
+		stream: helper.NormalizeStreamName(config.StreamName),
+		code:   code,
+	}, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	msgs, err := p.ProcessMulti(context, msg)
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+
+	return msgs[0], nil
+}
+
+// ProcessMulti emits a message for every object produced by the query.
+// Queries producing no results (e.g. select or empty) drop the message
+func (p *Plugin) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
+		return []*message.Message{msg}, nil
+	}
+
+	row := helper.MessageRow(msg)
+
+	var msgs []*message.Message
+	iter := p.code.RunWithContext(context, row, msg.GetStream(), string(msg.GetEvent()))
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, isErr := result.(error); isErr {
+			return nil, fmt.Errorf("jq query failed: %w", err)
+		}
+
+		if result == nil {
+			continue
+		}
+
+		object, isObject := result.(map[string]interface{})
+		if !isObject {
+			return nil, fmt.Errorf("jq query must produce objects, got %T", result)
+		}
+
+		outputRow, err := p.outputRow(row, object)
+		if err != nil {
+			return nil, err
+		}
+
+		// the first result reuses the incoming message to avoid redundant allocations
+		target := msg
+		if len(msgs) > 0 {
+			target = message.NewMessage(msg.GetEvent(), msg.GetStream(), nil)
+		}
+		if err = helper.SetMessageRow(target, outputRow); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, target)
+	}
+
+	return msgs, nil
+}
+
+// outputRow builds the message data from the query result.
+// Only declared columns are taken from the result and casted to their types
+func (p *Plugin) outputRow(row map[string]interface{}, result map[string]interface{}) (map[string]interface{}, error) {
+	output := map[string]interface{}{}
+	if p.config.Mode == MergeMode {
+		for key, value := range row {
+			output[key] = value
+		}
+	}
+
+	for _, column := range p.config.Columns {
+		value, err := helper.CastValue(result[column.Name], column.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cast column %s to %s: %w", column.Name, column.Type, err)
+		}
+		output[column.Name] = value
+	}
+
+	return output, nil
+}
+
+// EvolveSchema replaces the stream columns with the declared columns in replace mode
+// and adds (or overrides) the declared columns in merge mode
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	var stream *schema.StreamSchema
+	for _, s := range streamSchema.GetLatestSchema() {
+		if helper.NormalizeStreamName(s.StreamName) == p.stream {
+			stream = &s
+			break
+		}
+	}
+
+	if stream == nil {
+		return fmt.Errorf("jq query for undefined stream %s", p.config.StreamName)
+	}
+
+	existing := map[string]schema.Column{}
+	for _, column := range stream.Columns {
+		existing[column.Name] = column
+	}
+
+	var columns []schema.Column
+	if p.config.Mode == MergeMode {
+		columns = append(columns, stream.Columns...)
+	}
+
+	for _, declared := range p.config.Columns {
+		column := schema.Column{
+			Name:                declared.Name,
+			DatabrewType:        declared.Type,
+			NativeConnectorType: helper.ArrowToPg10(helper.MapPlainTypeToArrow(declared.Type)),
+			Nullable:            declared.Nullable,
+		}
+
+		// primary key is kept when the query preserves the column with the same type
+		if original, ok := existing[declared.Name]; ok && original.DatabrewType == declared.Type {
+			column.PK = original.PK
+			column.NativeConnectorType = original.NativeConnectorType
+		}
+
+		columns = upsertColumn(columns, column)
+	}
+
+	streamSchema.SetColumns(p.stream, columns)
+
+	return nil
+}
+
+func upsertColumn(columns []schema.Column, column schema.Column) []schema.Column {
+	for idx := range columns {
+		if columns[idx].Name == column.Name {
+			columns[idx] = column
+			return columns
+		}
+	}
+
+	return append(columns, column)
+}
@@ -0,0 +1,114 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func testSchema() *schema.StreamSchemaObj {
+	return schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "webhooks",
+			Columns: []schema.Column{
+				{Name: "id", DatabrewType: "Int64", PK: true},
+				{Name: "payload", DatabrewType: "JSON"},
+			},
+		},
+	})
+}
+
+func TestPlugin_ProcessMulti(t *testing.T) {
+	plugin, err := NewJqPlugin(stream_context.CreateContext(1), Config{
+		StreamName: "webhooks",
+		Query:      `.id as $id | .payload.items[] | select(.qty > 0) | {id: $id, sku: .sku, qty: .qty, source: $event}`,
+		Columns: []Column{
+			{Name: "id", Type: "Int64"},
+			{Name: "sku"},
+			{Name: "qty", Type: "Int64"},
+			{Name: "source"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.NewMessage(message.Insert, "webhooks", []byte(`[{"id": 42, "payload": {"items": [{"sku": "a", "qty": 2}, {"sku": "b", "qty": 0}, {"sku": "c", "qty": 1}]}}]`))
+	msgs, err := plugin.ProcessMulti(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+
+	for idx, sku := range []string{"a", "c"} {
+		row := helper.MessageRow(msgs[idx])
+		if row["sku"] != sku || row["id"] != json.Number("42") || row["source"] != "insert" {
+			t.Fatalf("unexpected row %v", row)
+		}
+		if _, ok := row["payload"]; ok {
+			t.Fatal("replace mode must keep declared columns only")
+		}
+	}
+
+	empty := message.NewMessage(message.Insert, "webhooks", []byte(`[{"id": 1, "payload": {"items": []}}]`))
+	if msgs, _ = plugin.ProcessMulti(context.Background(), empty); len(msgs) != 0 {
+		t.Fatal("message without query results must be dropped")
+	}
+}
+
+func TestPlugin_MergeMode(t *testing.T) {
+	plugin, err := NewJqPlugin(stream_context.CreateContext(1), Config{
+		StreamName: "webhooks",
+		Mode:       MergeMode,
+		Query:      `{customer: .payload.customer.name}`,
+		Columns:    []Column{{Name: "customer", Nullable: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamSchema := testSchema()
+	if err = plugin.EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+	if columns := streamSchema.GetLatestSchema()[0].Columns; len(columns) != 3 || columns[2].Name != "customer" {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+
+	msg := message.NewMessage(message.Insert, "webhooks", []byte(`[{"id": 1, "payload": {"customer": {"name": "Maxym"}}}]`))
+	processed, err := plugin.Process(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := helper.MessageRow(processed)
+	if row["customer"] != "Maxym" || row["payload"] == nil {
+		t.Fatalf("result is not merged %v", row)
+	}
+}
+
+func TestPlugin_EvolveSchema(t *testing.T) {
+	plugin, _ := NewJqPlugin(stream_context.CreateContext(1), Config{
+		StreamName: "webhooks",
+		Query:      `{id, name: .payload.name}`,
+		Columns:    []Column{{Name: "id", Type: "Int64"}, {Name: "name"}},
+	})
+
+	streamSchema := testSchema()
+	if err := plugin.EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	columns := streamSchema.GetLatestSchema()[0].Columns
+	if len(columns) != 2 || !columns[0].PK || columns[1].Name != "name" {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+}
@@ -0,0 +1,23 @@
+package mask
+
+type Config struct {
+	// HashKey is used by hmac and tokenize policies. Keep it in the secret
+	// storage and reference it as #{secret.<name>} so it's resolved on config load
+	HashKey string `json:"hash_key" yaml:"hash_key"`
+	Rules   []Rule `json:"rules" yaml:"rules"`
+}
+
+type Rule struct {
+	// Stream the rule is applied to. Rule is applied to all the streams when empty or *
+	Stream string `json:"stream" yaml:"stream"`
+	// Columns are matched by exact name or by ColumnRegex
+	Columns     []string `json:"columns" yaml:"columns"`
+	ColumnRegex string   `json:"column_regex" yaml:"column_regex"`
+	// Policy is one of redact, hash, hmac, email, phone, partial, tokenize, null
+	Policy string `json:"policy" yaml:"policy"`
+	// Replacement is used by redact policy. Defaults to *****
+	Replacement string `json:"replacement" yaml:"replacement"`
+	// KeepFirst and KeepLast define characters left visible by partial policy
+	KeepFirst int `json:"keep_first" yaml:"keep_first"`
+	KeepLast  int `json:"keep_last" yaml:"keep_last"`
+}
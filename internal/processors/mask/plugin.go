@@ -0,0 +1,134 @@
+package mask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+type Plugin struct {
+	config  Config
+	ctx     *stream_context.Context
+	logger  *log.Logger
+	regexps []*regexp.Regexp
+	// rulesByStream binds masked columns of each stream to the rule
+	rulesByStream map[string]map[string]*Rule
+}
+
+func NewMaskPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	plugin := &Plugin{
+		config:        config,
+		ctx:           appctx,
+		logger:        appctx.Logger.WithPrefix("processor [mask]"),
+		rulesByStream: map[string]map[string]*Rule{},
+	}
+
+	for _, rule := range config.Rules {
+		switch rule.Policy {
+		case RedactPolicy, HashPolicy, EmailPolicy, PhonePolicy, PartialPolicy, NullPolicy:
+		case HmacPolicy, TokenizePolicy:
+			if config.HashKey == "" {
+				return nil, fmt.Errorf("hash_key is required for %s policy", rule.Policy)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported mask policy %s", rule.Policy)
+		}
+
+		if len(rule.Columns) == 0 && rule.ColumnRegex == "" {
+			return nil, errors.New("either columns or column_regex must be set for the mask rule")
+		}
+
+		var columnRegex *regexp.Regexp
+		if rule.ColumnRegex != "" {
+			compiled, err := regexp.Compile(rule.ColumnRegex)
+			if err != nil {
+				return nil, err
+			}
+			columnRegex = compiled
+		}
+		plugin.regexps = append(plugin.regexps, columnRegex)
+	}
+
+	return plugin, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	rules, ok := p.rulesByStream[helper.NormalizeStreamName(msg.GetStream())]
+	if !ok {
+		return msg, nil
+	}
+
+	row := helper.MessageRow(msg)
+	for column, rule := range rules {
+		if _, exist := row[column]; !exist {
+			continue
+		}
+		row[column] = p.applyPolicy(rule, row[column])
+	}
+
+	if err := helper.SetMessageRow(msg, row); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// EvolveSchema resolves the rules against the stream columns and changes the type
+// of masked columns to string, since most of the policies produce strings
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	for _, stream := range streamSchema.GetLatestSchema() {
+		streamName := helper.NormalizeStreamName(stream.StreamName)
+		for _, col := range stream.Columns {
+			rule := p.matchRule(streamName, col.Name)
+			if rule == nil {
+				continue
+			}
+
+			if _, ok := p.rulesByStream[streamName]; !ok {
+				p.rulesByStream[streamName] = map[string]*Rule{}
+			}
+			p.rulesByStream[streamName][col.Name] = rule
+
+			maskedColumn := col
+			maskedColumn.Nullable = col.Nullable || rule.Policy == NullPolicy
+			maskedColumn.PK = col.PK && policyKeepsUniqueness(rule.Policy)
+			if policyChangesType(rule.Policy) && col.DatabrewType != "String" {
+				maskedColumn.DatabrewType = "String"
+				maskedColumn.NativeConnectorType = helper.ArrowToPg10(arrow.BinaryTypes.String)
+				maskedColumn.Columns = nil
+			}
+			streamSchema.ReplaceColumn(streamName, col.Name, maskedColumn)
+		}
+	}
+
+	if len(p.rulesByStream) == 0 {
+		p.logger.Warn("Mask rules didn't match any column")
+	}
+
+	return nil
+}
+
+// matchRule returns the first rule that matches the column
+func (p *Plugin) matchRule(stream, column string) *Rule {
+	for idx := range p.config.Rules {
+		rule := &p.config.Rules[idx]
+		if rule.Stream != "" && rule.Stream != "*" && helper.NormalizeStreamName(rule.Stream) != stream {
+			continue
+		}
+
+		if slices.Contains(rule.Columns, column) || (p.regexps[idx] != nil && p.regexps[idx].MatchString(column)) {
+			return rule
+		}
+	}
+
+	return nil
+}
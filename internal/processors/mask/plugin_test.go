@@ -0,0 +1,85 @@
+package mask
+
+import (
+	"context"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func Test_maskEmail(t *testing.T) {
+	if masked := maskEmail("john.doe@example.com"); masked != "j*******@example.com" {
+		t.Fatalf("unexpected masked email %s", masked)
+	}
+}
+
+func Test_maskPhone(t *testing.T) {
+	if masked := maskPhone("+1 (555) 123-4567"); masked != "+* (***) ***-4567" {
+		t.Fatalf("unexpected masked phone %s", masked)
+	}
+}
+
+func Test_maskPartial(t *testing.T) {
+	if masked := maskPartial("4242424242424242", 0, 4); masked != "************4242" {
+		t.Fatalf("unexpected masked value %s", masked)
+	}
+}
+
+func TestPlugin_Process(t *testing.T) {
+	streamSchema := schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "public.users",
+			Columns: []schema.Column{
+				{Name: "id", DatabrewType: "Int64", PK: true},
+				{Name: "email", DatabrewType: "String"},
+				{Name: "ssn_number", DatabrewType: "Int64"},
+				{Name: "birth_date", DatabrewType: "Date32", Nullable: true},
+			},
+		},
+	})
+
+	plugin, err := NewMaskPlugin(stream_context.CreateContext(1), Config{
+		HashKey: "secret",
+		Rules: []Rule{
+			{Columns: []string{"email"}, Policy: EmailPolicy},
+			{Stream: "users", ColumnRegex: "^ssn_", Policy: TokenizePolicy},
+			{Columns: []string{"birth_date"}, Policy: NullPolicy},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = plugin.EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	if ssn := streamSchema.GetLatestSchema()[0].Columns[2]; ssn.DatabrewType != "String" {
+		t.Fatal("tokenized column must become a string column")
+	}
+
+	msg := message.NewMessage(message.Insert, "users", []byte(`[{"id": 1, "email": "john@example.com", "ssn_number": 123456789, "birth_date": 19000}]`))
+	processed, err := plugin.Process(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := helper.MessageRow(processed)
+	if row["email"] != "j***@example.com" {
+		t.Fatalf("email is not masked %v", row["email"])
+	}
+
+	if row["birth_date"] != nil {
+		t.Fatal("birth_date must be nulled")
+	}
+
+	other := message.NewMessage(message.Insert, "users", []byte(`[{"id": 2, "ssn_number": 123456789}]`))
+	processed, _ = plugin.Process(context.Background(), other)
+	otherRow := helper.MessageRow(processed)
+	if row["ssn_number"] != otherRow["ssn_number"] || row["ssn_number"] == "123456789" {
+		t.Fatal("tokenization must be deterministic")
+	}
+}
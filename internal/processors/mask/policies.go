@@ -0,0 +1,124 @@
+package mask
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	RedactPolicy   = "redact"
+	HashPolicy     = "hash"
+	HmacPolicy     = "hmac"
+	EmailPolicy    = "email"
+	PhonePolicy    = "phone"
+	PartialPolicy  = "partial"
+	TokenizePolicy = "tokenize"
+	NullPolicy     = "null"
+)
+
+const maskChar = '*'
+
+// policyChangesType returns true if the masked value is always a string
+// regardless of the original column type
+func policyChangesType(policy string) bool {
+	return policy != NullPolicy
+}
+
+// policyKeepsUniqueness returns true if distinct values stay distinct after masking,
+// so the column can still be used as a primary key
+func policyKeepsUniqueness(policy string) bool {
+	return policy == HashPolicy || policy == HmacPolicy || policy == TokenizePolicy
+}
+
+func (p *Plugin) applyPolicy(rule *Rule, value interface{}) interface{} {
+	if rule.Policy == NullPolicy {
+		return nil
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	plain := fmt.Sprintf("%v", value)
+	switch rule.Policy {
+	case RedactPolicy:
+		if rule.Replacement != "" {
+			return rule.Replacement
+		}
+		return "*****"
+	case HashPolicy:
+		sum := sha256.Sum256([]byte(plain))
+		return hex.EncodeToString(sum[:])
+	case HmacPolicy:
+		return hex.EncodeToString(p.sign(plain))
+	case TokenizePolicy:
+		// the token is stable for the same key and value,
+		// so masked columns can still be used in joins and group by
+		return "tok_" + hex.EncodeToString(p.sign(plain))[:24]
+	case EmailPolicy:
+		return maskEmail(plain)
+	case PhonePolicy:
+		return maskPhone(plain)
+	case PartialPolicy:
+		return maskPartial(plain, rule.KeepFirst, rule.KeepLast)
+	default:
+		return value
+	}
+}
+
+func (p *Plugin) sign(value string) []byte {
+	mac := hmac.New(sha256.New, []byte(p.config.HashKey))
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// maskEmail keeps the first character of the local part and the domain
+// john.doe@example.com -> j*******@example.com
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return maskPartial(email, 1, 0)
+	}
+
+	return maskPartial(email[:at], 1, 0) + email[at:]
+}
+
+// maskPhone hides all the digits except the last four, keeping the formatting
+// +1 (555) 123-4567 -> +* (***) ***-4567
+func maskPhone(phone string) string {
+	var digits int
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+
+	var masked strings.Builder
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			if digits > 4 {
+				r = maskChar
+			}
+			digits--
+		}
+		masked.WriteRune(r)
+	}
+
+	return masked.String()
+}
+
+func maskPartial(value string, keepFirst, keepLast int) string {
+	runes := []rune(value)
+	for idx := range runes {
+		if idx < keepFirst || idx >= len(runes)-keepLast {
+			continue
+		}
+		runes[idx] = maskChar
+	}
+
+	return string(runes)
+}
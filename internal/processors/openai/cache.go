@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+type cacheEntry struct {
+	key   string
+	value string
+}
+
+// responseCache is LRU cache of the model responses keyed by the input hash
+type responseCache struct {
+	size    int
+	mutx    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func cacheKey(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		// separator prevents collisions between ("ab", "c") and ("a", "bc")
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *responseCache) Get(key string) (string, bool) {
+	c.mutx.Lock()
+	defer c.mutx.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+
+	return element.Value.(*cacheEntry).value, true
+}
+
+func (c *responseCache) Set(key, value string) {
+	c.mutx.Lock()
+	defer c.mutx.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -1,11 +1,32 @@
 package openai
 
 type Config struct {
-	ApiKey         string `json:"api_key" yaml:"api_key"`
-	SourceField    string `json:"source_field" yaml:"source_field"`
-	TargetField    string `json:"target_field" yaml:"target_field"`
-	Model          string `json:"model" yaml:"model"`
-	Prompt         string `json:"prompt" yaml:"prompt"`
-	StreamName     string `json:"stream_name" yaml:"stream_name"`
-	LimitPerMinute int64  `json:"limit_per_minute" yaml:"limit_per_minute"`
+	ApiKey      string `json:"api_key" yaml:"api_key"`
+	BaseURL     string `json:"base_url" yaml:"base_url"`
+	SourceField string `json:"source_field" yaml:"source_field"`
+	TargetField string `json:"target_field" yaml:"target_field"`
+	Model       string `json:"model" yaml:"model"`
+	Prompt      string `json:"prompt" yaml:"prompt"`
+	StreamName  string `json:"stream_name" yaml:"stream_name"`
+	MaxTokens   int    `json:"max_tokens" yaml:"max_tokens"`
+	// LimitPerMinute limits the amount of requests sent to the API within a minute
+	LimitPerMinute int64 `json:"limit_per_minute" yaml:"limit_per_minute"`
+	// TokensPerMinute limits the amount of tokens (prompt and completion) within a minute.
+	// Requests are estimated before they are sent and corrected with the usage reported by the API
+	TokensPerMinute int64 `json:"tokens_per_minute" yaml:"tokens_per_minute"`
+	// MaxInFlight is the amount of concurrent requests. Messages order is preserved
+	MaxInFlight int `json:"max_in_flight" yaml:"max_in_flight"`
+	// MaxRetries is the amount of retries for rate limited (429) requests
+	MaxRetries int `json:"max_retries" yaml:"max_retries"`
+	// CacheSize is the amount of responses cached by the input hash. Cache is disabled when 0
+	CacheSize int `json:"cache_size" yaml:"cache_size"`
+	// OutputColumns enables structured output. The model responds with JSON object
+	// matching the columns and every column is stored in the message with its type
+	OutputColumns []OutputColumn `json:"output_columns" yaml:"output_columns"`
+}
+
+type OutputColumn struct {
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type" yaml:"type"`
+	Description string `json:"description" yaml:"description"`
 }
@@ -1,107 +1,300 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/charmbracelet/log"
 	"github.com/sashabaranov/go-openai"
 	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/processors/inflight"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
-	"sync"
-	"time"
+	"golang.org/x/time/rate"
 )
 
+const defaultMaxRetries = 3
+
+const command = "You are data pipeline assistant. You take the data from the user and perform various checks and " +
+	"analysis. You are capable of checking the data for different patterns, harmful content, etc " +
+	"You must strictly follow a given instruction:"
+
+const responseInstruction = "Your responses must always be short, without any explanation, unless your wants you to do so." +
+	"You should never explain your thoughts or process. You need to respond with an answer only" +
+	"Yours response will most likely used in database field to try to assume the correct form of response based on the instructions given"
+
 type Plugin struct {
-	config                        Config
-	ctx                           *stream_context.Context
-	client                        *openai.Client
-	model                         string
-	prompt                        string
-	rateLimiterTick               *time.Ticker
-	logger                        *log.Logger
-	mutx                          sync.Mutex
-	messagesProcessedWithinALimit int64
+	config         Config
+	ctx            *stream_context.Context
+	client         *openai.Client
+	model          string
+	prompt         string
+	stream         string
+	logger         *log.Logger
+	requestLimiter *rate.Limiter
+	tokenLimiter   *rate.Limiter
+	tokenMutex     sync.Mutex
+	// tokenCredit is the amount of the tokens the estimates reserved over the actual usage.
+	// Next requests reserve that much less
+	tokenCredit    int
+	window         *inflight.Window
+	cache          *responseCache
+	responseFormat *openai.ChatCompletionResponseFormat
 }
 
 func NewOpenAIPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+
+	for idx, column := range config.OutputColumns {
+		if column.Name == "" {
+			return nil, errors.New("output column name is required for openai processor")
+		}
+		if column.Type == "" {
+			config.OutputColumns[idx].Type = "String"
+		}
+	}
+
+	clientConfig := openai.DefaultConfig(config.ApiKey)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
+	clientConfig.HTTPClient = &http.Client{
+		Transport: &retryTransport{base: http.DefaultTransport, maxRetries: config.MaxRetries},
+	}
+
 	plugin := &Plugin{
 		config: config, ctx: appctx,
-		client: openai.NewClient(config.ApiKey),
+		client: openai.NewClientWithConfig(clientConfig),
 		model:  config.Model, prompt: config.Prompt,
-		logger: log.WithPrefix("processor [openai]: "),
+		stream: helper.NormalizeStreamName(config.StreamName),
+		logger: appctx.Logger.WithPrefix("processor [openai]"),
+		window: inflight.NewWindow(config.MaxInFlight),
 	}
 
 	if config.LimitPerMinute > 0 {
-		plugin.rateLimiterTick = time.NewTicker(time.Minute)
+		plugin.requestLimiter = rate.NewLimiter(rate.Limit(float64(config.LimitPerMinute)/60), int(config.LimitPerMinute))
+	}
+
+	if config.TokensPerMinute > 0 {
+		plugin.tokenLimiter = rate.NewLimiter(rate.Limit(float64(config.TokensPerMinute)/60), int(config.TokensPerMinute))
+	}
+
+	if config.CacheSize > 0 {
+		plugin.cache = newResponseCache(config.CacheSize)
+	}
+
+	if len(config.OutputColumns) > 0 {
+		plugin.responseFormat = buildResponseFormat(config.OutputColumns)
 	}
 
 	return plugin, nil
 }
 
 func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
-	if msg.GetStream() != p.config.StreamName {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
 		return msg, nil
 	}
-	//if p.rateLimiterTick != nil && p.messagesProcessedWithinALimit >= p.config.LimitPerMinute {
-	//	p.logger.Info("Rate limit wait")
-	//	<-p.rateLimiterTick.C
-	//	p.mutx.Lock()
-	//	p.messagesProcessedWithinALimit = 0
-	//	p.mutx.Unlock()
-	//}
 
-	processedMessage, err := p.processMessage(context, msg)
+	return p.processMessage(context, msg)
+}
+
+// ProcessMulti sends the request in the background and returns the messages
+// whose requests are completed. The order of the messages is preserved
+func (p *Plugin) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	return p.window.Submit(msg, func() ([]*message.Message, error) {
+		processed, err := p.Process(context, msg)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*message.Message{processed}, nil
+	})
+}
+
+// Flush waits for all the requests in flight
+func (p *Plugin) Flush(context context.Context) ([]*message.Message, error) {
+	return p.window.Drain()
+}
+
+func (p *Plugin) processMessage(context context.Context, msg *message.Message) (*message.Message, error) {
+	input := fmt.Sprintf("%v", msg.Data.AccessProperty(p.config.SourceField))
+
+	content, err := p.complete(context, input)
 	if err != nil {
 		return nil, err
 	}
 
-	p.mutx.Lock()
-	p.messagesProcessedWithinALimit += 1
-	p.mutx.Unlock()
-	return processedMessage, nil
+	if p.responseFormat == nil {
+		msg.Data.SetProperty(p.config.TargetField, content)
+		return msg, nil
+	}
+
+	return msg, p.setStructuredOutput(msg, content)
 }
 
-func (p *Plugin) processMessage(context context.Context, msg *message.Message) (*message.Message, error) {
-	sourceFieldValue := msg.Data.AccessProperty(p.config.SourceField)
+func (p *Plugin) complete(context context.Context, input string) (string, error) {
+	var key string
+	if p.cache != nil {
+		key = cacheKey(p.model, p.prompt, input)
+		if content, ok := p.cache.Get(key); ok {
+			return content, nil
+		}
+	}
+
+	systemMessage := command + p.prompt
+	if p.responseFormat == nil {
+		systemMessage += responseInstruction
+	}
 
-	command := "You are data pipeline assistant. You take the data from the user and perform various checks and " +
-		"analysis. You are capable of checking the data for different patterns, harmful content, etc " +
-		"You must strictly follow a given instruction:" + p.prompt +
-		"Your responses must always be short, without any explanation, unless your wants you to do so." +
-		"You should never explain your thoughts or process. You need to respond with an answer only" +
-		"Yours response will most likely used in database field to try to assume the correct form of response based on the instructions given"
+	reserved, err := p.waitForLimits(context, systemMessage+input)
+	if err != nil {
+		return "", err
+	}
 
 	resp, err := p.client.CreateChatCompletion(
 		context,
 		openai.ChatCompletionRequest{
-			Model: p.model,
+			Model:          p.model,
+			MaxTokens:      p.config.MaxTokens,
+			ResponseFormat: p.responseFormat,
 			Messages: []openai.ChatCompletionMessage{
 				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: fmt.Sprintf("%v", sourceFieldValue),
+					Role:    openai.ChatMessageRoleSystem,
+					Content: systemMessage,
 				},
 				{
-					Role:    openai.ChatMessageRoleAssistant,
-					Content: command,
+					Role:    openai.ChatMessageRoleUser,
+					Content: input,
 				},
 			},
 		},
 	)
 
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	p.reconcileTokens(reserved, resp.Usage.TotalTokens)
 
-	msg.Data.SetProperty(p.config.TargetField, resp.Choices[0].Message.Content)
+	if len(resp.Choices) == 0 {
+		return "", errors.New("openai returned response without choices")
+	}
+
+	content := resp.Choices[0].Message.Content
+	if p.cache != nil {
+		p.cache.Set(key, content)
+	}
 
-	return msg, nil
+	return content, nil
 }
 
-// EvolveSchema will add a string column to the schema in order to store OpenAI response
+// waitForLimits blocks until the request fits both request and token limits and returns
+// the amount of tokens reserved for it. The amount of tokens is estimated before the request
+// as ~4 characters per token plus the completion tokens limit, the credit left by the previous
+// estimates is used first
+func (p *Plugin) waitForLimits(context context.Context, text string) (int, error) {
+	if p.requestLimiter != nil {
+		if err := p.requestLimiter.Wait(context); err != nil {
+			return 0, err
+		}
+	}
+
+	if p.tokenLimiter == nil {
+		return 0, nil
+	}
+
+	tokens := len(text)/4 + 1 + p.config.MaxTokens
+	if tokens > p.tokenLimiter.Burst() {
+		tokens = p.tokenLimiter.Burst()
+	}
+
+	p.tokenMutex.Lock()
+	credit := p.tokenCredit
+	if credit > tokens {
+		credit = tokens
+	}
+	p.tokenCredit -= credit
+	p.tokenMutex.Unlock()
+
+	if err := p.tokenLimiter.WaitN(context, tokens-credit); err != nil {
+		return 0, err
+	}
+
+	return tokens, nil
+}
+
+// reconcileTokens corrects the token budget with the actual usage of the request.
+// Tokens used over the estimate are taken from the limiter, so the next requests wait for them,
+// the estimated tokens left unused are credited to the next requests
+func (p *Plugin) reconcileTokens(reserved, used int) {
+	if p.tokenLimiter == nil || used <= 0 {
+		return
+	}
+
+	if used > reserved {
+		extra := used - reserved
+		if extra > p.tokenLimiter.Burst() {
+			extra = p.tokenLimiter.Burst()
+		}
+		p.tokenLimiter.ReserveN(time.Now(), extra)
+		return
+	}
+
+	p.tokenMutex.Lock()
+	defer p.tokenMutex.Unlock()
+	p.tokenCredit += reserved - used
+	if p.tokenCredit > p.tokenLimiter.Burst() {
+		p.tokenCredit = p.tokenLimiter.Burst()
+	}
+}
+
+// setStructuredOutput stores the fields of the model JSON response in the output columns.
+// Fields that are missing or have unexpected type are stored as null
+func (p *Plugin) setStructuredOutput(msg *message.Message, content string) error {
+	var output map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(content)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&output); err != nil {
+		p.logger.Warn("model responded with invalid JSON", "response", content, "error", err)
+	}
+
+	row := helper.MessageRow(msg)
+	for _, column := range p.config.OutputColumns {
+		value, ok := typedValue(output[column.Name], column.Type)
+		if !ok {
+			p.logger.Warn("unexpected value type in model response", "column", column.Name, "value", output[column.Name])
+		}
+		row[column.Name] = value
+	}
+
+	return helper.SetMessageRow(msg, row)
+}
+
+// EvolveSchema adds the columns to store OpenAI response.
+// Single string column is added unless the structured output is configured
 func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
-	streamSchema.AddField(p.config.StreamName, p.config.TargetField, arrow.BinaryTypes.String, helper.ArrowToPg10(arrow.BinaryTypes.String))
+	if len(p.config.OutputColumns) == 0 {
+		streamSchema.AddField(p.config.StreamName, p.config.TargetField, arrow.BinaryTypes.String, helper.ArrowToPg10(arrow.BinaryTypes.String))
+		return nil
+	}
+
+	for _, column := range p.config.OutputColumns {
+		streamSchema.AddColumn(p.stream, schema.Column{
+			Name:                column.Name,
+			DatabrewType:        column.Type,
+			NativeConnectorType: helper.ArrowToPg10(helper.MapPlainTypeToArrow(column.Type)),
+			Nullable:            true,
+		})
+	}
+
 	return nil
 }
@@ -0,0 +1,149 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+// newTestServer emulates chat completion API. The first request is rejected with 429
+func newTestServer(t *testing.T, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "rate limited"}}`))
+			return
+		}
+
+		var request struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+
+		content, _ := json.Marshal(fmt.Sprintf(`{"sentiment": "%s", "score": 0.9}`, request.Messages[1].Content))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": ` + string(content) + `}}]}`))
+	}))
+}
+
+func newTestPlugin(t *testing.T, baseURL string) *Plugin {
+	plugin, err := NewOpenAIPlugin(stream_context.CreateContext(1), Config{
+		BaseURL:     baseURL,
+		SourceField: "comment",
+		StreamName:  "reviews",
+		Model:       "local-model",
+		MaxInFlight: 2,
+		CacheSize:   10,
+		OutputColumns: []OutputColumn{
+			{Name: "sentiment"},
+			{Name: "score", Type: "Float64"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func TestPlugin_EvolveSchema(t *testing.T) {
+	streamSchema := schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "reviews",
+			Columns:    []schema.Column{{Name: "comment", DatabrewType: "String"}},
+		},
+	})
+
+	if err := newTestPlugin(t, "").EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	columns := streamSchema.GetLatestSchema()[0].Columns
+	if len(columns) != 3 || columns[1].DatabrewType != "String" || columns[2].DatabrewType != "Float64" {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+}
+
+func TestPlugin_ProcessMulti(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, &requests)
+	defer server.Close()
+
+	plugin := newTestPlugin(t, server.URL)
+
+	var processed []*message.Message
+	for _, comment := range []string{"positive", "negative", "positive"} {
+		msg := message.NewMessage(message.Insert, "reviews", []byte(`[{"comment": "`+comment+`"}]`))
+		msgs, err := plugin.ProcessMulti(context.Background(), msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		processed = append(processed, msgs...)
+	}
+
+	msgs, err := plugin.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed = append(processed, msgs...)
+
+	if len(processed) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(processed))
+	}
+
+	for _, msg := range processed {
+		row := helper.MessageRow(msg)
+		if row["sentiment"] != row["comment"] || row["score"] != json.Number("0.9") {
+			t.Fatalf("unexpected structured output %v", row)
+		}
+	}
+
+	// 429 response, two unique inputs and the cached one
+	if requests != 3 {
+		t.Fatalf("expected cached response to be reused, got %d requests", requests)
+	}
+}
+
+func TestPlugin_ReconcileTokens(t *testing.T) {
+	plugin, err := NewOpenAIPlugin(stream_context.CreateContext(1), Config{StreamName: "reviews", TokensPerMinute: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserved, err := plugin.waitForLimits(context.Background(), strings.Repeat("a", 396))
+	if err != nil || reserved != 100 {
+		t.Fatalf("expected 100 estimated tokens, got %d %v", reserved, err)
+	}
+
+	// unused estimate is credited to the next request
+	plugin.reconcileTokens(reserved, 40)
+	before := plugin.tokenLimiter.Tokens()
+	if _, err = plugin.waitForLimits(context.Background(), strings.Repeat("a", 396)); err != nil {
+		t.Fatal(err)
+	}
+	if taken := before - plugin.tokenLimiter.Tokens(); taken > 41 {
+		t.Fatalf("credited tokens must not be taken again, took %.0f", taken)
+	}
+
+	// usage over the estimate is taken from the limiter
+	before = plugin.tokenLimiter.Tokens()
+	plugin.reconcileTokens(100, 300)
+	if taken := before - plugin.tokenLimiter.Tokens(); taken < 199 {
+		t.Fatalf("tokens used over the estimate must be taken, took %.0f", taken)
+	}
+}
@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const responseSchemaName = "blink_output"
+
+// buildResponseFormat creates strict JSON schema for the structured output.
+// Every output column becomes a required property of the response object
+func buildResponseFormat(columns []OutputColumn) *openai.ChatCompletionResponseFormat {
+	properties := map[string]interface{}{}
+	required := make([]string, 0, len(columns))
+	for _, column := range columns {
+		property := map[string]interface{}{"type": jsonSchemaType(column.Type)}
+		if column.Description != "" {
+			property["description"] = column.Description
+		}
+		properties[column.Name] = property
+		required = append(required, column.Name)
+	}
+
+	responseSchema, _ := json.Marshal(map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	})
+
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   responseSchemaName,
+			Schema: json.RawMessage(responseSchema),
+			Strict: true,
+		},
+	}
+}
+
+func jsonSchemaType(databrewType string) string {
+	switch databrewType {
+	case "Boolean":
+		return "boolean"
+	case "Int16", "Int32", "Int64", "Uint64":
+		return "integer"
+	case "Float32", "Float64":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// typedValue checks that the value returned by the model matches the column type
+func typedValue(value interface{}, databrewType string) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	switch jsonSchemaType(databrewType) {
+	case "boolean":
+		_, ok := value.(bool)
+		return valueOrNil(value, ok)
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, false
+		}
+		_, err := number.Int64()
+		return valueOrNil(value, err == nil)
+	case "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, false
+		}
+		_, err := number.Float64()
+		return valueOrNil(value, err == nil)
+	default:
+		_, ok := value.(string)
+		return valueOrNil(value, ok)
+	}
+}
+
+func valueOrNil(value interface{}, ok bool) (interface{}, bool) {
+	if !ok {
+		return nil, false
+	}
+
+	return value, true
+}
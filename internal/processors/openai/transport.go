@@ -0,0 +1,69 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxRetryDelay = time.Minute
+
+// retryTransport retries the requests rejected with 429 status code.
+// The delay is taken from Retry-After header when the API provides it
+// and falls back to exponential backoff otherwise
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// request body can be sent again only when it can be re-created
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || !canRetry || attempt >= t.maxRetries {
+			return resp, err
+		}
+
+		delay := retryDelay(resp.Header, attempt)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func retryDelay(header http.Header, attempt int) time.Duration {
+	delay := time.Second << attempt
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.ParseFloat(retryAfter, 64); err == nil {
+			delay = time.Duration(seconds * float64(time.Second))
+		} else if date, err := http.ParseTime(retryAfter); err == nil {
+			delay = time.Until(date)
+		}
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
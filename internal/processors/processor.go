@@ -16,6 +16,17 @@ const (
 	HttpProcessor               ProcessorDriver = "http"
 	SQLEnrichProcessor          ProcessorDriver = "sql_enrich"
 	LogProcessor                ProcessorDriver = "log"
+	JoinProcessor               ProcessorDriver = "join"
+	MaskProcessor               ProcessorDriver = "mask"
+	TransformProcessor          ProcessorDriver = "transform"
+	RouterProcessor             ProcessorDriver = "router"
+	EmbeddingsProcessor         ProcessorDriver = "embeddings"
+	ValidateProcessor           ProcessorDriver = "validate"
+	JqProcessor                 ProcessorDriver = "jq"
+	RedisLookupProcessor        ProcessorDriver = "redis_lookup"
+	SampleProcessor             ProcessorDriver = "sample"
+	ThrottleProcessor           ProcessorDriver = "throttle"
+	CircuitBreakerProcessor     ProcessorDriver = "circuit_breaker"
 )
 
 type DataProcessor interface {
@@ -30,3 +41,24 @@ type DataProcessor interface {
 	// you can simply return the schema you received as an argument
 	EvolveSchema(schema *schema.StreamSchemaObj) error
 }
+
+// MultiMessageProcessor is implemented by the processors that can emit
+// zero or more messages for a single incoming message (unnest, routing, etc).
+// When the processor implements it, ProcessMulti is called instead of Process
+type MultiMessageProcessor interface {
+	ProcessMulti(context context.Context, message *message.Message) ([]*message.Message, error)
+}
+
+// BufferedProcessor is implemented by the processors that keep messages in flight
+// between the calls (concurrent requests, batching). The stream calls Flush
+// once the source becomes idle, so the buffered messages are not stuck in the processor
+type BufferedProcessor interface {
+	MultiMessageProcessor
+	Flush(context context.Context) ([]*message.Message, error)
+}
+
+// ClosableProcessor is implemented by the processors holding resources like files or connections.
+// The stream closes them once the pipeline is stopped
+type ClosableProcessor interface {
+	Close() error
+}
@@ -0,0 +1,80 @@
+package redis_lookup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const defaultCacheSize = 10000
+
+type cacheEntry struct {
+	key       string
+	values    map[string]interface{}
+	expiresAt time.Time
+}
+
+// ttlCache is LRU cache of the looked up values. Entries expire after the ttl,
+// so the updates in redis are picked up by the processor
+type ttlCache struct {
+	ttl     time.Duration
+	size    int
+	mutx    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+func newTTLCache(ttl time.Duration, size int) *ttlCache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+
+	return &ttlCache{
+		ttl:     ttl,
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *ttlCache) Get(key string) (map[string]interface{}, bool) {
+	c.mutx.Lock()
+	defer c.mutx.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if c.now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+
+	return entry.values, true
+}
+
+func (c *ttlCache) Set(key string, values map[string]interface{}) {
+	c.mutx.Lock()
+	defer c.mutx.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.values, entry.expiresAt = values, expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, values: values, expiresAt: expiresAt})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,34 @@
+package redis_lookup
+
+const (
+	// StringValue reads the key with GET and stores the whole value in the column
+	StringValue = "string"
+	// HashValue reads the hash fields with HMGET
+	HashValue = "hash"
+	// JSONValue reads the key with GET and extracts the fields by JSONPath
+	JSONValue = "json"
+)
+
+type Config struct {
+	RedisAddr     string `json:"redis_addr" yaml:"redis_addr"`
+	RedisPassword string `json:"redis_password" yaml:"redis_password"`
+	StreamName    string `json:"stream_name" yaml:"stream_name"`
+	// Key is Go template rendered for every message, e.g. features:{{ .Data.user_id }}
+	Key string `json:"key" yaml:"key"`
+	// ValueType is one of string, hash or json
+	ValueType string  `json:"value_type" yaml:"value_type"`
+	Fields    []Field `json:"fields" yaml:"fields"`
+	// CacheTTL is the amount of seconds the looked up values are cached locally. Cache is disabled when 0
+	CacheTTL int64 `json:"cache_ttl" yaml:"cache_ttl"`
+	// CacheSize is the max amount of cached keys
+	CacheSize int `json:"cache_size" yaml:"cache_size"`
+}
+
+// Field maps the looked up value to the message column
+type Field struct {
+	// Source is the hash field for hash values and JSONPath for json values. Ignored for string values
+	Source string `json:"source" yaml:"source"`
+	Column string `json:"column" yaml:"column"`
+	// Type is databrew type of the column. Defaults to String
+	Type string `json:"type" yaml:"type"`
+}
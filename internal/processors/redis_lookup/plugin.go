@@ -0,0 +1,228 @@
+package redis_lookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+type Plugin struct {
+	config    Config
+	ctx       *stream_context.Context
+	logger    *log.Logger
+	stream    string
+	redisConn *redis.Client
+	key       *template.Template
+	cache     *ttlCache
+}
+
+func NewRedisLookupPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	switch config.ValueType {
+	case "":
+		config.ValueType = StringValue
+	case StringValue, HashValue, JSONValue:
+	default:
+		return nil, fmt.Errorf("unsupported value_type %s", config.ValueType)
+	}
+
+	if config.StreamName == "" || config.Key == "" || len(config.Fields) == 0 {
+		return nil, errors.New("stream_name, key and fields are required for redis_lookup processor")
+	}
+
+	if config.ValueType == StringValue && len(config.Fields) > 1 {
+		return nil, errors.New("only one field can be defined for string values")
+	}
+
+	for idx, field := range config.Fields {
+		if field.Column == "" {
+			return nil, errors.New("column is required for redis_lookup fields")
+		}
+		if field.Source == "" && config.ValueType != StringValue {
+			return nil, fmt.Errorf("source is required for the column %s", field.Column)
+		}
+		if field.Type == "" {
+			config.Fields[idx].Type = "String"
+		}
+	}
+
+	key, err := helper.ParseMessageTemplate("key", config.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key template: %w", err)
+	}
+
+	options, err := redis.ParseURL(config.RedisAddr)
+	if err != nil {
+		return nil, err
+	}
+	if config.RedisPassword != "" {
+		options.Password = config.RedisPassword
+	}
+
+	redisConn := redis.NewClient(options)
+	if err = redisConn.Ping(appctx.GetContext()).Err(); err != nil {
+		return nil, err
+	}
+
+	plugin := &Plugin{
+		config:    config,
+		ctx:       appctx,
+		logger:    appctx.Logger.WithPrefix("processor [redis_lookup]"),
+		stream:    helper.NormalizeStreamName(config.StreamName),
+		redisConn: redisConn,
+		key:       key,
+	}
+
+	if config.CacheTTL > 0 {
+		plugin.cache = newTTLCache(time.Duration(config.CacheTTL)*time.Second, config.CacheSize)
+	}
+
+	return plugin, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
+		return msg, nil
+	}
+
+	data := helper.NewMessageTemplateData(msg)
+	key, err := helper.RenderMessageTemplate(p.key, data)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := p.lookup(context, key)
+	if err != nil {
+		return nil, err
+	}
+
+	row := data.Data
+	for _, field := range p.config.Fields {
+		row[field.Column] = values[field.Column]
+	}
+
+	return msg, helper.SetMessageRow(msg, row)
+}
+
+// lookup returns the values of the fields by the column names.
+// Missing keys are cached as well, so they don't hit redis for every message
+func (p *Plugin) lookup(context context.Context, key string) (map[string]interface{}, error) {
+	if p.cache != nil {
+		if values, ok := p.cache.Get(key); ok {
+			return values, nil
+		}
+	}
+
+	var values map[string]interface{}
+	var err error
+	switch p.config.ValueType {
+	case HashValue:
+		values, err = p.lookupHash(context, key)
+	default:
+		values, err = p.lookupString(context, key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.cache != nil {
+		p.cache.Set(key, values)
+	}
+
+	return values, nil
+}
+
+func (p *Plugin) lookupString(context context.Context, key string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	raw, err := p.redisConn.Get(context, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.ValueType == StringValue {
+		field := p.config.Fields[0]
+		values[field.Column] = p.castValue(field, raw)
+		return values, nil
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.UseNumber()
+	if err = decoder.Decode(&document); err != nil {
+		p.logger.Warn("key contains invalid JSON", "key", key, "error", err)
+		return values, nil
+	}
+
+	for _, field := range p.config.Fields {
+		value, err := jsonpath.Get(field.Source, document)
+		if err != nil {
+			continue
+		}
+		values[field.Column] = p.castValue(field, value)
+	}
+
+	return values, nil
+}
+
+func (p *Plugin) lookupHash(context context.Context, key string) (map[string]interface{}, error) {
+	var sources []string
+	for _, field := range p.config.Fields {
+		sources = append(sources, field.Source)
+	}
+
+	result, err := p.redisConn.HMGet(context, key, sources...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	for idx, field := range p.config.Fields {
+		values[field.Column] = p.castValue(field, result[idx])
+	}
+
+	return values, nil
+}
+
+func (p *Plugin) castValue(field Field, value interface{}) interface{} {
+	casted, err := helper.CastValue(value, field.Type)
+	if err != nil {
+		p.logger.Warn("failed to cast looked up value", "column", field.Column, "type", field.Type, "error", err)
+		return nil
+	}
+
+	return casted
+}
+
+// Close closes the redis client, so its connections are released
+func (p *Plugin) Close() error {
+	return p.redisConn.Close()
+}
+
+// EvolveSchema adds nullable columns for the looked up fields
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	for _, field := range p.config.Fields {
+		streamSchema.AddColumn(p.stream, schema.Column{
+			Name:                field.Column,
+			DatabrewType:        field.Type,
+			NativeConnectorType: helper.ArrowToPg10(helper.MapPlainTypeToArrow(field.Type)),
+			Nullable:            true,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package redis_lookup
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func newTestPlugin(t *testing.T, server *miniredis.Miniredis, config Config) *Plugin {
+	config.RedisAddr = "redis://" + server.Addr()
+	config.StreamName = "orders"
+	plugin, err := NewRedisLookupPlugin(stream_context.CreateContext(1), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func orderMessage() *message.Message {
+	return message.NewMessage(message.Insert, "orders", []byte(`[{"id": 1, "user_id": 7}]`))
+}
+
+func TestPlugin_Process(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.HSet("users:7", "tier", "gold", "score", "12")
+	server.Set("profiles:7", `{"country": {"code": "UA"}, "age": 30}`)
+
+	hashPlugin := newTestPlugin(t, server, Config{
+		Key:       "users:{{ .Data.user_id }}",
+		ValueType: HashValue,
+		Fields: []Field{
+			{Source: "tier", Column: "user_tier"},
+			{Source: "score", Column: "user_score", Type: "Int64"},
+			{Source: "missing", Column: "user_missing"},
+		},
+	})
+
+	processed, err := hashPlugin.Process(context.Background(), orderMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := helper.MessageRow(processed)
+	if row["user_tier"] != "gold" || row["user_score"] != json.Number("12") || row["user_missing"] != nil {
+		t.Fatalf("hash fields are not merged %v", row)
+	}
+
+	jsonPlugin := newTestPlugin(t, server, Config{
+		Key:       "profiles:{{ .Data.user_id }}",
+		ValueType: JSONValue,
+		Fields: []Field{
+			{Source: "$.country.code", Column: "country"},
+			{Source: "$.age", Column: "age", Type: "Int64"},
+		},
+	})
+
+	processed, err = jsonPlugin.Process(context.Background(), orderMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row = helper.MessageRow(processed)
+	if row["country"] != "UA" || row["age"] != json.Number("30") {
+		t.Fatalf("json fields are not merged %v", row)
+	}
+}
+
+func TestPlugin_Cache(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.Set("segments:7", "vip")
+
+	plugin := newTestPlugin(t, server, Config{
+		Key:      "segments:{{ .Data.user_id }}",
+		Fields:   []Field{{Column: "segment"}},
+		CacheTTL: 60,
+	})
+
+	now := time.Now()
+	plugin.cache.now = func() time.Time { return now }
+
+	if _, err := plugin.Process(context.Background(), orderMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Set("segments:7", "regular")
+	processed, _ := plugin.Process(context.Background(), orderMessage())
+	if processed.Data.AccessProperty("segment") != "vip" {
+		t.Fatal("value must be taken from the cache")
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	processed, _ = plugin.Process(context.Background(), orderMessage())
+	if processed.Data.AccessProperty("segment") != "regular" {
+		t.Fatal("expired value must be looked up again")
+	}
+}
+
+func TestPlugin_Close(t *testing.T) {
+	server := miniredis.RunT(t)
+	plugin := newTestPlugin(t, server, Config{Key: "users:{{ .Data.user_id }}", Fields: []Field{{Column: "user_tier"}}})
+
+	if err := plugin.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := plugin.Process(context.Background(), orderMessage()); err == nil {
+		t.Fatal("closed plugin must not look up the values")
+	}
+}
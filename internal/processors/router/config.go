@@ -0,0 +1,29 @@
+package router
+
+type Config struct {
+	StreamName string  `json:"stream_name" yaml:"stream_name"`
+	Routes     []Route `json:"routes" yaml:"routes"`
+	// DefaultStream receives the messages that didn't match any route.
+	// Unmatched messages stay in the original stream when it's empty
+	DefaultStream string `json:"default_stream" yaml:"default_stream"`
+	// KeepOriginal emits the message to the original stream
+	// in addition to the matched routes
+	KeepOriginal bool `json:"keep_original" yaml:"keep_original"`
+}
+
+type Route struct {
+	// Condition is optional. Route without condition matches every message,
+	// so it can be used to rename or clone the stream
+	Condition *Condition `json:"condition" yaml:"condition"`
+	// When is an expression alternative to the condition, e.g. event == "delete" || data.amount > 100.
+	// When both are set, the message has to match both of them
+	When string `json:"when" yaml:"when"`
+	// Streams the matched message is sent to. Message is cloned for every stream
+	Streams []string `json:"streams" yaml:"streams"`
+}
+
+type Condition struct {
+	Column   string      `json:"column" yaml:"column"`
+	Operator string      `json:"operator" yaml:"operator"`
+	Value    interface{} `json:"value" yaml:"value"`
+}
@@ -0,0 +1,179 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/expression"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+type Plugin struct {
+	config     Config
+	ctx        *stream_context.Context
+	logger     *log.Logger
+	stream     string
+	conditions []*expression.Condition
+}
+
+func NewRouterPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	if config.StreamName == "" {
+		return nil, errors.New("stream_name is required for router processor")
+	}
+
+	var conditions []*expression.Condition
+	for _, route := range config.Routes {
+		if len(route.Streams) == 0 {
+			return nil, errors.New("at least one stream must be defined for the route")
+		}
+		if route.Condition != nil && (route.Condition.Column == "" || route.Condition.Operator == "") {
+			return nil, errors.New("column and operator are required for the route condition")
+		}
+
+		condition, err := expression.Compile(route.When)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	return &Plugin{
+		config:     config,
+		ctx:        appctx,
+		logger:     appctx.Logger.WithPrefix("processor [router]"),
+		stream:     helper.NormalizeStreamName(config.StreamName),
+		conditions: conditions,
+	}, nil
+}
+
+// Process is used when the processor is called outside the pipeline.
+// The pipeline calls ProcessMulti, as the message can be cloned to several streams
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	msgs, err := p.ProcessMulti(context, msg)
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+
+	return msgs[0], nil
+}
+
+func (p *Plugin) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
+		return []*message.Message{msg}, nil
+	}
+
+	targetStreams, err := p.matchStreams(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(targetStreams) == 0 {
+		if p.config.DefaultStream != "" {
+			msg.SetStream(p.config.DefaultStream)
+		}
+		return []*message.Message{msg}, nil
+	}
+
+	var routed []*message.Message
+	if p.config.KeepOriginal {
+		routed = append(routed, msg)
+	}
+
+	for _, stream := range targetStreams {
+		if len(routed) == 0 {
+			// reuse the incoming message for the first route to avoid redundant allocations
+			msg.SetStream(stream)
+			routed = append(routed, msg)
+			continue
+		}
+		routed = append(routed, message.NewMessage(msg.GetEvent(), stream, []byte(routed[0].AsJSONString())))
+	}
+
+	return routed, nil
+}
+
+// matchStreams returns unique list of streams from all the matched routes
+func (p *Plugin) matchStreams(msg *message.Message) ([]string, error) {
+	var streams []string
+	for idx, route := range p.config.Routes {
+		if route.Condition != nil && msg.Data.Where(route.Condition.Column, route.Condition.Operator, route.Condition.Value) == nil {
+			continue
+		}
+
+		matched, err := p.conditions[idx].Match(msg)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+
+		for _, stream := range route.Streams {
+			if !slices.Contains(streams, stream) {
+				streams = append(streams, stream)
+			}
+		}
+	}
+
+	return streams, nil
+}
+
+// EvolveSchema registers target streams with the columns of the routed stream
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	var sourceStream *schema.StreamSchema
+	for _, stream := range streamSchema.GetLatestSchema() {
+		if helper.NormalizeStreamName(stream.StreamName) == p.stream {
+			sourceStream = &stream
+		}
+	}
+
+	if sourceStream == nil {
+		return fmt.Errorf("routing for undefined stream %s", p.config.StreamName)
+	}
+
+	for _, route := range p.config.Routes {
+		if route.Condition == nil {
+			continue
+		}
+		if !slices.ContainsFunc(sourceStream.Columns, func(col schema.Column) bool { return col.Name == route.Condition.Column }) {
+			return fmt.Errorf("route condition column %s doesnt exist in the stream %s", route.Condition.Column, p.config.StreamName)
+		}
+	}
+
+	var targetStreams []string
+	for _, route := range p.config.Routes {
+		targetStreams = append(targetStreams, route.Streams...)
+	}
+	if p.config.DefaultStream != "" {
+		targetStreams = append(targetStreams, p.config.DefaultStream)
+	}
+
+	for _, target := range targetStreams {
+		if p.streamExists(streamSchema, target) {
+			continue
+		}
+
+		p.logger.Info("Registering routed stream", "stream", target)
+		streamSchema.AddStream(schema.StreamSchema{
+			StreamName: target,
+			Columns:    slices.Clone(sourceStream.Columns),
+		})
+	}
+
+	return nil
+}
+
+func (p *Plugin) streamExists(streamSchema *schema.StreamSchemaObj, streamName string) bool {
+	for _, stream := range streamSchema.GetLatestSchema() {
+		if helper.NormalizeStreamName(stream.StreamName) == helper.NormalizeStreamName(streamName) {
+			return true
+		}
+	}
+
+	return false
+}
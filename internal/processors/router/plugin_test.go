@@ -0,0 +1,84 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func TestPlugin_Routing(t *testing.T) {
+	streamSchema := schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "public.orders",
+			Columns: []schema.Column{
+				{Name: "id", DatabrewType: "Int64", PK: true},
+				{Name: "status", DatabrewType: "String"},
+			},
+		},
+	})
+
+	plugin, err := NewRouterPlugin(stream_context.CreateContext(1), Config{
+		StreamName: "orders",
+		Routes: []Route{
+			{
+				Condition: &Condition{Column: "status", Operator: "=", Value: "archived"},
+				Streams:   []string{"orders_archive", "orders_audit"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = plugin.EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	streams := streamSchema.GetLatestSchema()
+	if len(streams) != 3 || streams[1].StreamName != "orders_archive" || len(streams[2].Columns) != 2 {
+		t.Fatalf("routed streams must be registered in the schema %v", streams)
+	}
+
+	archived := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 1, "status": "archived"}]`))
+	msgs, err := plugin.ProcessMulti(context.Background(), archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 2 || msgs[0].GetStream() != "orders_archive" || msgs[1].GetStream() != "orders_audit" {
+		t.Fatal("archived message must be cloned to both streams")
+	}
+
+	if msgs[1].Data.AccessProperty("status") != "archived" {
+		t.Fatal("cloned message must keep the data")
+	}
+
+	active := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 2, "status": "active"}]`))
+	msgs, _ = plugin.ProcessMulti(context.Background(), active)
+	if len(msgs) != 1 || msgs[0].GetStream() != "orders" {
+		t.Fatal("unmatched message must stay in the original stream")
+	}
+}
+
+func TestPlugin_RoutingByExpression(t *testing.T) {
+	plugin, err := NewRouterPlugin(stream_context.CreateContext(1), Config{
+		StreamName: "orders",
+		Routes:     []Route{{When: `event == "delete"`, Streams: []string{"orders_deleted"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := message.NewMessage(message.Delete, "orders", []byte(`[{"id": 1}]`))
+	if msgs, _ := plugin.ProcessMulti(context.Background(), deleted); len(msgs) != 1 || msgs[0].GetStream() != "orders_deleted" {
+		t.Fatal("deleted message must be routed by the expression")
+	}
+
+	inserted := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 2}]`))
+	if msgs, _ := plugin.ProcessMulti(context.Background(), inserted); len(msgs) != 1 || msgs[0].GetStream() != "orders" {
+		t.Fatal("unmatched message must stay in the original stream")
+	}
+}
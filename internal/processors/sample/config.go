@@ -0,0 +1,12 @@
+package sample
+
+type Config struct {
+	// StreamName is the stream to sample. Use * to sample all the streams
+	StreamName string `json:"stream_name" yaml:"stream_name"`
+	// Percent of the messages to keep, from 0 to 100
+	Percent float64 `json:"percent" yaml:"percent"`
+	// Key is the column used for deterministic sampling. Messages with the same key
+	// are either all kept or all dropped. Messages are sampled randomly when empty,
+	// as well as the messages with null or missing key
+	Key string `json:"key" yaml:"key"`
+}
@@ -0,0 +1,84 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	allStreams = "*"
+	// buckets define the sampling precision, so 0.01% can be configured
+	buckets = 10000
+)
+
+type Plugin struct {
+	config    Config
+	ctx       *stream_context.Context
+	stream    string
+	threshold uint64
+}
+
+func NewSamplePlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	if config.StreamName == "" {
+		return nil, errors.New("stream_name is required for sample processor")
+	}
+
+	if config.Percent < 0 || config.Percent > 100 {
+		return nil, errors.New("percent must be between 0 and 100")
+	}
+
+	plugin := &Plugin{
+		config:    config,
+		ctx:       appctx,
+		stream:    config.StreamName,
+		threshold: uint64(config.Percent * buckets / 100),
+	}
+
+	if plugin.stream != allStreams {
+		plugin.stream = helper.NormalizeStreamName(config.StreamName)
+	}
+
+	return plugin, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	if p.stream != allStreams && helper.NormalizeStreamName(msg.GetStream()) != p.stream {
+		return msg, nil
+	}
+
+	if p.bucket(msg) < p.threshold {
+		return msg, nil
+	}
+
+	return nil, nil
+}
+
+// bucket returns the message bucket. Hash of the key is used when the key is set,
+// so the decision is stable across the restarts and the replicas of the pipeline.
+// Messages with null or missing key are sampled randomly, as they don't share the key
+func (p *Plugin) bucket(msg *message.Message) uint64 {
+	if p.config.Key == "" {
+		return uint64(rand.Intn(buckets))
+	}
+
+	key := msg.Data.AccessProperty(p.config.Key)
+	if key == nil {
+		return uint64(rand.Intn(buckets))
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(helper.PlainValue(key)))
+	return hash.Sum64() % buckets
+}
+
+// EvolveSchema is not changing the schema as sampling drops the messages only
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	return nil
+}
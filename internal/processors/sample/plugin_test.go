@@ -0,0 +1,55 @@
+package sample
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func TestPlugin_Process(t *testing.T) {
+	plugin, err := NewSamplePlugin(stream_context.CreateContext(1), Config{StreamName: "events", Percent: 25, Key: "user_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kept int
+	for idx := 0; idx < 4000; idx++ {
+		payload := fmt.Sprintf(`[{"user_id": %d}]`, idx)
+		first, _ := plugin.Process(context.Background(), message.NewMessage(message.Insert, "events", []byte(payload)))
+		second, _ := plugin.Process(context.Background(), message.NewMessage(message.Insert, "events", []byte(payload)))
+		if (first == nil) != (second == nil) {
+			t.Fatal("sampling by key must be deterministic")
+		}
+		if first != nil {
+			kept++
+		}
+	}
+
+	if kept < 800 || kept > 1200 {
+		t.Fatalf("expected ~25%% of the messages to be kept, got %d of 4000", kept)
+	}
+
+	// null and missing keys don't share the decision
+	kept = 0
+	for idx := 0; idx < 4000; idx++ {
+		payload := `[{"user_id": null}]`
+		if idx%2 == 0 {
+			payload = `[{"id": 1}]`
+		}
+		if processed, _ := plugin.Process(context.Background(), message.NewMessage(message.Insert, "events", []byte(payload))); processed != nil {
+			kept++
+		}
+	}
+
+	if kept < 800 || kept > 1200 {
+		t.Fatalf("expected ~25%% of the messages without key to be kept, got %d of 4000", kept)
+	}
+
+	other := message.NewMessage(message.Insert, "users", []byte(`[{"user_id": 1}]`))
+	if processed, _ := plugin.Process(context.Background(), other); processed == nil {
+		t.Fatal("other streams must not be sampled")
+	}
+}
@@ -0,0 +1,21 @@
+package throttle
+
+const (
+	// DelayMode blocks the pipeline until the message fits the rate
+	DelayMode = "delay"
+	// DropMode drops the messages exceeding the rate
+	DropMode = "drop"
+)
+
+type Config struct {
+	// StreamName is the stream to throttle. Use * to throttle all the streams, each one separately
+	StreamName string `json:"stream_name" yaml:"stream_name"`
+	// MessagesPerSecond is the max rate of the messages
+	MessagesPerSecond float64 `json:"messages_per_second" yaml:"messages_per_second"`
+	// Burst is the amount of messages allowed to exceed the rate at once. Defaults to 1
+	Burst int `json:"burst" yaml:"burst"`
+	// Key is the column to throttle the messages by its value instead of the stream
+	Key string `json:"key" yaml:"key"`
+	// Mode is either delay (default) or drop
+	Mode string `json:"mode" yaml:"mode"`
+}
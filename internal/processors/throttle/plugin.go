@@ -0,0 +1,119 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+	"golang.org/x/time/rate"
+)
+
+const (
+	allStreams = "*"
+	// maxLimiters bounds the memory used by the limiters of high cardinality keys.
+	// Once reached, limiters are recreated, so the rate might be exceeded once
+	maxLimiters = 10000
+)
+
+type Plugin struct {
+	config   Config
+	ctx      *stream_context.Context
+	logger   *log.Logger
+	stream   string
+	mutx     sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func NewThrottlePlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	switch config.Mode {
+	case "":
+		config.Mode = DelayMode
+	case DelayMode, DropMode:
+	default:
+		return nil, fmt.Errorf("unsupported throttle mode %s", config.Mode)
+	}
+
+	if config.StreamName == "" {
+		return nil, errors.New("stream_name is required for throttle processor")
+	}
+
+	if config.MessagesPerSecond <= 0 {
+		return nil, errors.New("messages_per_second must be greater than 0")
+	}
+
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+
+	plugin := &Plugin{
+		config:   config,
+		ctx:      appctx,
+		logger:   appctx.Logger.WithPrefix("processor [throttle]"),
+		stream:   config.StreamName,
+		limiters: map[string]*rate.Limiter{},
+	}
+
+	if plugin.stream != allStreams {
+		plugin.stream = helper.NormalizeStreamName(config.StreamName)
+	}
+
+	return plugin, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	stream := helper.NormalizeStreamName(msg.GetStream())
+	if p.stream != allStreams && stream != p.stream {
+		return msg, nil
+	}
+
+	limiter := p.limiter(p.limiterKey(stream, msg))
+	if p.config.Mode == DropMode {
+		if limiter.Allow() {
+			return msg, nil
+		}
+		return nil, nil
+	}
+
+	if err := limiter.Wait(context); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (p *Plugin) limiterKey(stream string, msg *message.Message) string {
+	if p.config.Key == "" {
+		return stream
+	}
+
+	return stream + ":" + helper.PlainValue(msg.Data.AccessProperty(p.config.Key))
+}
+
+func (p *Plugin) limiter(key string) *rate.Limiter {
+	p.mutx.Lock()
+	defer p.mutx.Unlock()
+
+	if limiter, ok := p.limiters[key]; ok {
+		return limiter
+	}
+
+	if len(p.limiters) >= maxLimiters {
+		p.logger.Warn("too many throttle keys, limiters are reset", "limit", maxLimiters)
+		p.limiters = map[string]*rate.Limiter{}
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(p.config.MessagesPerSecond), p.config.Burst)
+	p.limiters[key] = limiter
+	return limiter
+}
+
+// EvolveSchema is not changing the schema as throttling only delays or drops the messages
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	return nil
+}
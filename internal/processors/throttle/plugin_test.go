@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func userMessage(payload string) *message.Message {
+	return message.NewMessage(message.Insert, "events", []byte(payload))
+}
+
+func TestPlugin_DropMode(t *testing.T) {
+	plugin, err := NewThrottlePlugin(stream_context.CreateContext(1), Config{
+		StreamName:        "events",
+		MessagesPerSecond: 1,
+		Burst:             2,
+		Key:               "user_id",
+		Mode:              DropMode,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kept int
+	for idx := 0; idx < 5; idx++ {
+		if processed, _ := plugin.Process(context.Background(), userMessage(`[{"user_id": 1}]`)); processed != nil {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Fatalf("expected burst of 2 messages to be kept, got %d", kept)
+	}
+
+	if processed, _ := plugin.Process(context.Background(), userMessage(`[{"user_id": 2}]`)); processed == nil {
+		t.Fatal("every key must have its own limit")
+	}
+}
+
+func TestPlugin_DelayMode(t *testing.T) {
+	plugin, err := NewThrottlePlugin(stream_context.CreateContext(1), Config{StreamName: "events", MessagesPerSecond: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for idx := 0; idx < 6; idx++ {
+		if processed, _ := plugin.Process(context.Background(), userMessage(`[{"user_id": 1}]`)); processed == nil {
+			t.Fatal("delay mode must not drop the messages")
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("messages are not delayed, took %s", elapsed)
+	}
+}
@@ -0,0 +1,23 @@
+package transform
+
+type Config struct {
+	StreamName string      `json:"stream_name" yaml:"stream_name"`
+	Operations []Operation `json:"operations" yaml:"operations"`
+}
+
+// Operation describes a single transformation step. Operations are applied in the order they are defined
+type Operation struct {
+	// Op is one of rename, cast, drop, default, flatten, unnest, add_constant, add_metadata
+	Op     string `json:"op" yaml:"op"`
+	Column string `json:"column" yaml:"column"`
+	// NewName is used by rename
+	NewName string `json:"new_name" yaml:"new_name"`
+	// TargetType is the databrew type used by cast and add_constant, e.g. Int64, Float64, String
+	TargetType string `json:"target_type" yaml:"target_type"`
+	// Value is used by default and add_constant
+	Value interface{} `json:"value" yaml:"value"`
+	// Metadata is one of stream, event, processed_at and used by add_metadata
+	Metadata string `json:"metadata" yaml:"metadata"`
+	// Separator is used by flatten to build nested column names. Defaults to _
+	Separator string `json:"separator" yaml:"separator"`
+}
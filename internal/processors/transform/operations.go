@@ -0,0 +1,163 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	RenameOp      = "rename"
+	CastOp        = "cast"
+	DropOp        = "drop"
+	DefaultOp     = "default"
+	FlattenOp     = "flatten"
+	UnnestOp      = "unnest"
+	AddConstantOp = "add_constant"
+	AddMetadataOp = "add_metadata"
+)
+
+const (
+	StreamMetadata      = "stream"
+	EventMetadata       = "event"
+	ProcessedAtMetadata = "processed_at"
+)
+
+// applyOperation applies the operation to the rows produced by the previous operation.
+// Only unnest can change the number of rows
+func applyOperation(op Operation, msg *message.Message, rows []map[string]interface{}) ([]map[string]interface{}, error) {
+	if op.Op == UnnestOp {
+		return unnestRows(op.Column, rows), nil
+	}
+
+	for _, row := range rows {
+		switch op.Op {
+		case RenameOp:
+			if value, ok := row[op.Column]; ok {
+				row[op.NewName] = value
+				delete(row, op.Column)
+			}
+		case CastOp:
+			casted, err := helper.CastValue(row[op.Column], op.TargetType)
+			if err != nil {
+				return nil, fmt.Errorf("failed to cast column %s to %s: %w", op.Column, op.TargetType, err)
+			}
+			row[op.Column] = casted
+		case DropOp:
+			delete(row, op.Column)
+		case DefaultOp:
+			if row[op.Column] == nil {
+				row[op.Column] = op.Value
+			}
+		case FlattenOp:
+			if nested, ok := row[op.Column].(map[string]interface{}); ok {
+				flattenValue(row, op.Column, op.Separator, nested)
+			}
+			delete(row, op.Column)
+		case AddConstantOp:
+			row[op.Column] = op.Value
+		case AddMetadataOp:
+			row[op.Column] = metadataValue(op.Metadata, msg)
+		}
+	}
+
+	return rows, nil
+}
+
+// unnestRows emits the row for every element of the list column.
+// Rows with the null or missing list are passed through with the null element,
+// rows with the empty list are dropped
+func unnestRows(column string, rows []map[string]interface{}) []map[string]interface{} {
+	var unnested []map[string]interface{}
+	for _, row := range rows {
+		list, ok := row[column].([]interface{})
+		if !ok {
+			row[column] = nil
+			unnested = append(unnested, row)
+			continue
+		}
+
+		for _, element := range list {
+			// message data encodes empty lists as [null],
+			// so null elements are skipped the same way as empty lists
+			if element == nil {
+				continue
+			}
+			rowCopy := make(map[string]interface{}, len(row))
+			for key, value := range row {
+				rowCopy[key] = value
+			}
+			rowCopy[column] = element
+			unnested = append(unnested, rowCopy)
+		}
+	}
+
+	return unnested
+}
+
+func flattenValue(row map[string]interface{}, prefix, separator string, nested map[string]interface{}) {
+	for key, value := range nested {
+		flatKey := prefix + separator + key
+		if nestedValue, ok := value.(map[string]interface{}); ok {
+			flattenValue(row, flatKey, separator, nestedValue)
+			continue
+		}
+		row[flatKey] = value
+	}
+}
+
+// flattenColumns builds the flat column list for the nested JSON column
+func flattenColumns(prefix, separator string, columns []schema.Column) []schema.Column {
+	var flatColumns []schema.Column
+	for _, col := range columns {
+		flatName := prefix + separator + col.Name
+		if col.DatabrewType == "JSON" && len(col.Columns) > 0 {
+			flatColumns = append(flatColumns, flattenColumns(flatName, separator, col.Columns)...)
+			continue
+		}
+
+		col.Name = flatName
+		// parent can be missing, so flattened columns are always nullable
+		col.Nullable = true
+		col.PK = false
+		flatColumns = append(flatColumns, col)
+	}
+
+	return flatColumns
+}
+
+func metadataValue(metadata string, msg *message.Message) interface{} {
+	switch metadata {
+	case StreamMetadata:
+		return msg.GetStream()
+	case EventMetadata:
+		return string(msg.GetEvent())
+	case ProcessedAtMetadata:
+		return time.Now().UTC().Format(time.RFC3339)
+	default:
+		return nil
+	}
+}
+
+// inferDatabrewType is used for constant columns without explicit target type
+func inferDatabrewType(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "Boolean"
+	case int, int8, int16, int32, int64:
+		return "Int64"
+	case float32, float64:
+		return "Float64"
+	default:
+		return "String"
+	}
+}
+
+// unnestedDatabrewType returns the type of the list element
+func unnestedDatabrewType(listType string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(listType, "List<"), ">")
+}
@@ -0,0 +1,198 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+type Plugin struct {
+	config Config
+	ctx    *stream_context.Context
+	logger *log.Logger
+	stream string
+}
+
+func NewTransformPlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	if config.StreamName == "" {
+		return nil, errors.New("stream_name is required for transform processor")
+	}
+
+	for idx, op := range config.Operations {
+		if op.Op == FlattenOp && op.Separator == "" {
+			config.Operations[idx].Separator = "_"
+		}
+		if op.Op == AddMetadataOp && op.Column == "" {
+			config.Operations[idx].Column = "_" + op.Metadata
+		}
+	}
+
+	return &Plugin{
+		config: config,
+		ctx:    appctx,
+		logger: appctx.Logger.WithPrefix("processor [transform]"),
+		stream: helper.NormalizeStreamName(config.StreamName),
+	}, nil
+}
+
+// Process is used when the processor is called outside the pipeline.
+// The pipeline calls ProcessMulti, as unnest can emit several messages
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	msgs, err := p.ProcessMulti(context, msg)
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+
+	return msgs[0], nil
+}
+
+func (p *Plugin) ProcessMulti(context context.Context, msg *message.Message) ([]*message.Message, error) {
+	if helper.NormalizeStreamName(msg.GetStream()) != p.stream {
+		return []*message.Message{msg}, nil
+	}
+
+	rows := []map[string]interface{}{helper.MessageRow(msg)}
+	for _, op := range p.config.Operations {
+		var err error
+		if rows, err = applyOperation(op, msg, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	var msgs []*message.Message
+	for idx, row := range rows {
+		// the first row reuses the incoming message to avoid redundant allocations
+		target := msg
+		if idx > 0 {
+			target = message.NewMessage(msg.GetEvent(), msg.GetStream(), nil)
+		}
+		if err := helper.SetMessageRow(target, row); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, target)
+	}
+
+	return msgs, nil
+}
+
+// EvolveSchema applies the operations one by one to the stream schema
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	for _, op := range p.config.Operations {
+		stream := p.findStream(streamSchema)
+		if stream == nil {
+			return fmt.Errorf("transform for undefined stream %s", p.config.StreamName)
+		}
+
+		if op.Column == "" {
+			return fmt.Errorf("column is required for %s operation", op.Op)
+		}
+
+		column := findColumn(stream, op.Column)
+		switch op.Op {
+		case AddConstantOp, AddMetadataOp:
+			if column != nil {
+				return fmt.Errorf("column %s already exists in the stream %s", op.Column, p.config.StreamName)
+			}
+		default:
+			if column == nil {
+				return fmt.Errorf("column %s doesnt exist in the stream %s", op.Column, p.config.StreamName)
+			}
+		}
+
+		switch op.Op {
+		case RenameOp:
+			if op.NewName == "" || findColumn(stream, op.NewName) != nil {
+				return fmt.Errorf("invalid new name %q for the column %s", op.NewName, op.Column)
+			}
+			renamed := *column
+			renamed.Name = op.NewName
+			streamSchema.ReplaceColumn(p.stream, op.Column, renamed)
+		case CastOp:
+			if op.TargetType == "" {
+				return fmt.Errorf("target_type is required to cast the column %s", op.Column)
+			}
+			casted := *column
+			casted.DatabrewType = op.TargetType
+			casted.NativeConnectorType = helper.ArrowToPg10(helper.MapPlainTypeToArrow(op.TargetType))
+			casted.Columns = nil
+			streamSchema.ReplaceColumn(p.stream, op.Column, casted)
+		case DropOp:
+			streamSchema.RemoveField(p.stream, op.Column)
+		case DefaultOp:
+			withDefault := *column
+			withDefault.Nullable = op.Value == nil
+			streamSchema.ReplaceColumn(p.stream, op.Column, withDefault)
+		case FlattenOp:
+			if column.DatabrewType != "JSON" {
+				return fmt.Errorf("only JSON columns can be flattened, %s is %s", op.Column, column.DatabrewType)
+			}
+			flatColumns := flattenColumns(column.Name, op.Separator, column.Columns)
+			streamSchema.RemoveField(p.stream, op.Column)
+			for _, flatColumn := range flatColumns {
+				streamSchema.AddColumn(p.stream, flatColumn)
+			}
+		case UnnestOp:
+			if !strings.HasPrefix(column.DatabrewType, "List<") {
+				return fmt.Errorf("only List columns can be unnested, %s is %s", op.Column, column.DatabrewType)
+			}
+			unnested := *column
+			unnested.DatabrewType = unnestedDatabrewType(column.DatabrewType)
+			// rows without the list are passed through with the null element
+			unnested.Nullable = true
+			unnested.NativeConnectorType = helper.ArrowToPg10(helper.MapPlainTypeToArrow(unnested.DatabrewType))
+			streamSchema.ReplaceColumn(p.stream, op.Column, unnested)
+		case AddConstantOp:
+			databrewType := op.TargetType
+			if databrewType == "" {
+				databrewType = inferDatabrewType(op.Value)
+			}
+			streamSchema.AddColumn(p.stream, schema.Column{
+				Name:                op.Column,
+				DatabrewType:        databrewType,
+				NativeConnectorType: helper.ArrowToPg10(helper.MapPlainTypeToArrow(databrewType)),
+				Nullable:            op.Value == nil,
+			})
+		case AddMetadataOp:
+			if op.Metadata != StreamMetadata && op.Metadata != EventMetadata && op.Metadata != ProcessedAtMetadata {
+				return fmt.Errorf("unsupported metadata %s", op.Metadata)
+			}
+			streamSchema.AddColumn(p.stream, schema.Column{
+				Name:                op.Column,
+				DatabrewType:        "String",
+				NativeConnectorType: "text",
+				Nullable:            false,
+			})
+		default:
+			return fmt.Errorf("unsupported transform operation %s", op.Op)
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) findStream(streamSchema *schema.StreamSchemaObj) *schema.StreamSchema {
+	for _, stream := range streamSchema.GetLatestSchema() {
+		if helper.NormalizeStreamName(stream.StreamName) == p.stream {
+			return &stream
+		}
+	}
+
+	return nil
+}
+
+func findColumn(stream *schema.StreamSchema, name string) *schema.Column {
+	for idx := range stream.Columns {
+		if stream.Columns[idx].Name == name {
+			return &stream.Columns[idx]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package transform
+
+import (
+	"context"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func testSchema() *schema.StreamSchemaObj {
+	return schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "orders",
+			Columns: []schema.Column{
+				{Name: "id", DatabrewType: "Int64", PK: true},
+				{Name: "amount", DatabrewType: "String"},
+				{Name: "internal_note", DatabrewType: "String", Nullable: true},
+				{Name: "tags", DatabrewType: "List<String>", Nullable: true},
+				{
+					Name:         "address",
+					DatabrewType: "JSON",
+					Columns: []schema.Column{
+						{Name: "city", DatabrewType: "String"},
+						{Name: "zip", DatabrewType: "String"},
+					},
+				},
+			},
+		},
+	})
+}
+
+func testConfig() Config {
+	return Config{
+		StreamName: "orders",
+		Operations: []Operation{
+			{Op: RenameOp, Column: "id", NewName: "order_id"},
+			{Op: CastOp, Column: "amount", TargetType: "Float64"},
+			{Op: DropOp, Column: "internal_note"},
+			{Op: FlattenOp, Column: "address"},
+			{Op: UnnestOp, Column: "tags"},
+			{Op: AddConstantOp, Column: "source", Value: "shop"},
+			{Op: AddMetadataOp, Metadata: EventMetadata},
+		},
+	}
+}
+
+func TestPlugin_EvolveSchema(t *testing.T) {
+	streamSchema := testSchema()
+	plugin, err := NewTransformPlugin(stream_context.CreateContext(1), testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = plugin.EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	var columns = map[string]string{}
+	for _, col := range streamSchema.GetLatestSchema()[0].Columns {
+		columns[col.Name] = col.DatabrewType
+	}
+
+	expected := map[string]string{
+		"order_id":     "Int64",
+		"amount":       "Float64",
+		"tags":         "String",
+		"address_city": "String",
+		"address_zip":  "String",
+		"source":       "String",
+		"_event":       "String",
+	}
+	if len(columns) != len(expected) {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+	for name, databrewType := range expected {
+		if columns[name] != databrewType {
+			t.Fatalf("column %s expected to be %s, got %v", name, databrewType, columns)
+		}
+	}
+
+	plugin, _ = NewTransformPlugin(stream_context.CreateContext(1), Config{
+		StreamName: "orders",
+		Operations: []Operation{{Op: FlattenOp, Column: "amount"}},
+	})
+	if err = plugin.EvolveSchema(testSchema()); err == nil {
+		t.Fatal("non JSON columns can't be flattened")
+	}
+}
+
+func TestPlugin_ProcessMulti(t *testing.T) {
+	plugin, err := NewTransformPlugin(stream_context.CreateContext(1), testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 1, "amount": "10.5", "internal_note": "vip", "tags": ["a", "b"], "address": {"city": "Kyiv", "zip": "01001"}}]`))
+	msgs, err := plugin.ProcessMulti(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 unnested messages, got %d", len(msgs))
+	}
+
+	for idx, tag := range []string{"a", "b"} {
+		row := helper.MessageRow(msgs[idx])
+		if row["tags"] != tag || row["address_city"] != "Kyiv" || row["source"] != "shop" || row["_event"] != "insert" {
+			t.Fatalf("unexpected row %v", row)
+		}
+		if _, ok := row["internal_note"]; ok {
+			t.Fatal("dropped column is still present")
+		}
+		if amount, _ := row["amount"].(interface{ Float64() (float64, error) }).Float64(); amount != 10.5 {
+			t.Fatalf("amount is not casted %v", row["amount"])
+		}
+	}
+
+	empty := message.NewMessage(message.Insert, "orders", []byte(`[{"id": 2, "amount": "1", "tags": []}]`))
+	if msgs, _ = plugin.ProcessMulti(context.Background(), empty); len(msgs) != 0 {
+		t.Fatal("messages with empty list must be dropped by unnest")
+	}
+
+	for _, payload := range []string{`[{"id": 3, "amount": "1", "tags": null}]`, `[{"id": 4, "amount": "1"}]`} {
+		msgs, err = plugin.ProcessMulti(context.Background(), message.NewMessage(message.Insert, "orders", []byte(payload)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("message without the list must be passed through, got %d messages for %s", len(msgs), payload)
+		}
+		if tags, ok := helper.MessageRow(msgs[0])["tags"]; !ok || tags != nil {
+			t.Fatalf("unnested column must be null, got %s", msgs[0].AsJSONString())
+		}
+	}
+}
+
+func TestApplyOperation_CastInt(t *testing.T) {
+	op := Operation{Op: CastOp, Column: "quantity", TargetType: "Int32"}
+	msg := message.NewMessage(message.Insert, "orders", []byte(`[{}]`))
+
+	for value, expected := range map[interface{}]interface{}{"7": int64(7), 10.0: int64(10), "1e3": int64(1000)} {
+		rows, err := applyOperation(op, msg, []map[string]interface{}{{"quantity": value}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows[0]["quantity"] != expected {
+			t.Fatalf("%v expected to be casted to %v, got %v", value, expected, rows[0]["quantity"])
+		}
+	}
+
+	// fractions and the values out of the type range are rejected instead of being truncated
+	for _, value := range []interface{}{10.5, "0.1", 3e9, "abc"} {
+		if _, err := applyOperation(op, msg, []map[string]interface{}{{"quantity": value}}); err == nil {
+			t.Fatalf("%v must not be casted to Int32", value)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package validate
+
+const (
+	// DropInvalid drops the messages that failed the validation
+	DropInvalid = "drop"
+	// QuarantineInvalid sends the invalid messages to the quarantine stream
+	QuarantineInvalid = "quarantine"
+	// FailOnInvalid stops the pipeline on the first invalid message
+	FailOnInvalid = "fail"
+)
+
+type Config struct {
+	// StreamName is the stream to validate. Use * to validate all the streams
+	StreamName string `json:"stream_name" yaml:"stream_name"`
+	// CheckSchema validates required non-nullable columns and value types against the stream schema
+	CheckSchema bool `json:"check_schema" yaml:"check_schema"`
+	// RejectExtraFields marks the messages with fields missing in the schema as invalid
+	RejectExtraFields bool   `json:"reject_extra_fields" yaml:"reject_extra_fields"`
+	Rules             []Rule `json:"rules" yaml:"rules"`
+	// OnInvalid defines what to do with invalid messages: drop, quarantine or fail
+	OnInvalid        string `json:"on_invalid" yaml:"on_invalid"`
+	QuarantineStream string `json:"quarantine_stream" yaml:"quarantine_stream"`
+}
+
+// Rule is a custom check for the column value. Null values are skipped
+// by all the checks except NotNull
+type Rule struct {
+	// Name is used in the metrics and violation messages. Defaults to the column name
+	Name    string   `json:"name" yaml:"name"`
+	Column  string   `json:"column" yaml:"column"`
+	NotNull bool     `json:"not_null" yaml:"not_null"`
+	Regex   string   `json:"regex" yaml:"regex"`
+	Min     *float64 `json:"min" yaml:"min"`
+	Max     *float64 `json:"max" yaml:"max"`
+	Enum    []string `json:"enum" yaml:"enum"`
+}
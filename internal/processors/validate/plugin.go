@@ -0,0 +1,209 @@
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	allStreams    = "*"
+	processorName = "validate"
+)
+
+type Plugin struct {
+	config          Config
+	ctx             *stream_context.Context
+	logger          *log.Logger
+	stream          string
+	quarantine      string
+	rules           []compiledRule
+	columnsByStream map[string][]schema.Column
+}
+
+func NewValidatePlugin(appctx *stream_context.Context, config Config) (*Plugin, error) {
+	switch config.OnInvalid {
+	case "":
+		config.OnInvalid = DropInvalid
+	case DropInvalid, FailOnInvalid:
+	case QuarantineInvalid:
+		if config.QuarantineStream == "" {
+			return nil, errors.New("quarantine_stream is required to quarantine invalid messages")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported on_invalid value %s", config.OnInvalid)
+	}
+
+	if config.StreamName == "" {
+		return nil, errors.New("stream_name is required for validate processor")
+	}
+
+	plugin := &Plugin{
+		config:          config,
+		ctx:             appctx,
+		logger:          appctx.Logger.WithPrefix("processor [validate]"),
+		stream:          config.StreamName,
+		quarantine:      helper.NormalizeStreamName(config.QuarantineStream),
+		columnsByStream: map[string][]schema.Column{},
+	}
+
+	if plugin.stream != allStreams {
+		plugin.stream = helper.NormalizeStreamName(config.StreamName)
+	}
+
+	for _, rule := range config.Rules {
+		if rule.Column == "" {
+			return nil, errors.New("column is required for validation rule")
+		}
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		plugin.rules = append(plugin.rules, compiled)
+	}
+
+	return plugin, nil
+}
+
+func (p *Plugin) Process(context context.Context, msg *message.Message) (*message.Message, error) {
+	stream := helper.NormalizeStreamName(msg.GetStream())
+	if (p.stream != allStreams && stream != p.stream) || (p.quarantine != "" && stream == p.quarantine) {
+		return msg, nil
+	}
+
+	row := helper.MessageRow(msg)
+
+	var violations []violation
+	if columns, ok := p.columnsByStream[stream]; ok {
+		if p.config.CheckSchema {
+			violations = append(violations, checkSchema(row, columns)...)
+		}
+		if p.config.RejectExtraFields {
+			violations = append(violations, checkExtraFields(row, columns)...)
+		}
+	}
+
+	for _, rule := range p.rules {
+		if v := rule.check(row); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+
+	if len(violations) == 0 {
+		return msg, nil
+	}
+
+	if p.ctx.Metrics != nil {
+		for _, v := range violations {
+			p.ctx.Metrics.IncrementProcessorRuleViolations(processorName, v.Rule)
+		}
+	}
+
+	switch p.config.OnInvalid {
+	case FailOnInvalid:
+		return nil, fmt.Errorf("message of the stream %s is invalid: %s", msg.GetStream(), describeViolations(violations))
+	case QuarantineInvalid:
+		return p.quarantineMessage(msg, row, violations)
+	default:
+		p.logger.Debug("invalid message dropped", "stream", msg.GetStream(), "violations", describeViolations(violations))
+		return nil, nil
+	}
+}
+
+// quarantineMessage wraps the invalid message into the insert to the quarantine stream.
+// Original payload is stored as JSON, so the message is kept even if its values don't match the schema
+func (p *Plugin) quarantineMessage(msg *message.Message, row map[string]interface{}, violations []violation) (*message.Message, error) {
+	payload, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedViolations, err := json.Marshal(violations)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined, err := json.Marshal([]map[string]interface{}{{
+		"source_stream": msg.GetStream(),
+		"event":         string(msg.GetEvent()),
+		"payload":       string(payload),
+		"violations":    string(encodedViolations),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	return message.NewMessage(message.Insert, p.config.QuarantineStream, quarantined), nil
+}
+
+// EvolveSchema stores the current columns of the streams to validate the messages against them
+// and registers the quarantine stream
+func (p *Plugin) EvolveSchema(streamSchema *schema.StreamSchemaObj) error {
+	var quarantineExists bool
+	for _, stream := range streamSchema.GetLatestSchema() {
+		streamName := helper.NormalizeStreamName(stream.StreamName)
+		if streamName == p.quarantine {
+			quarantineExists = true
+		}
+
+		if p.stream != allStreams && streamName != p.stream {
+			continue
+		}
+
+		p.columnsByStream[streamName] = append([]schema.Column{}, stream.Columns...)
+	}
+
+	if p.stream != allStreams {
+		columns, ok := p.columnsByStream[p.stream]
+		if !ok {
+			return fmt.Errorf("stream %s is not found in the schema", p.config.StreamName)
+		}
+
+		for _, rule := range p.rules {
+			if !hasColumn(columns, rule.Column) {
+				return fmt.Errorf("column %s of the rule %s is not found in the stream %s", rule.Column, rule.Name, p.config.StreamName)
+			}
+		}
+	}
+
+	if p.config.OnInvalid == QuarantineInvalid && !quarantineExists {
+		streamSchema.AddStream(schema.StreamSchema{
+			StreamName: p.config.QuarantineStream,
+			Columns: []schema.Column{
+				{Name: "source_stream", DatabrewType: "String", NativeConnectorType: "text"},
+				{Name: "event", DatabrewType: "String", NativeConnectorType: "text"},
+				{Name: "payload", DatabrewType: "String", NativeConnectorType: "text"},
+				{Name: "violations", DatabrewType: "String", NativeConnectorType: "text"},
+			},
+		})
+	}
+
+	return nil
+}
+
+func hasColumn(columns []schema.Column, name string) bool {
+	for _, column := range columns {
+		if column.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func describeViolations(violations []violation) string {
+	var descriptions []string
+	for _, v := range violations {
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s): %s", v.Column, v.Rule, v.Reason))
+	}
+
+	return strings.Join(descriptions, "; ")
+}
@@ -0,0 +1,120 @@
+package validate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func testSchema() *schema.StreamSchemaObj {
+	return schema.NewStreamSchemaObj([]schema.StreamSchema{
+		{
+			StreamName: "public.users",
+			Columns: []schema.Column{
+				{Name: "id", DatabrewType: "Int64", PK: true},
+				{Name: "email", DatabrewType: "String"},
+				{Name: "age", DatabrewType: "Int32", Nullable: true},
+				{Name: "status", DatabrewType: "String", Nullable: true},
+				{Name: "birthday", DatabrewType: "Date32", Nullable: true},
+			},
+		},
+	})
+}
+
+func newTestPlugin(t *testing.T, onInvalid string) (*Plugin, *schema.StreamSchemaObj) {
+	minAge, maxAge := 18.0, 120.0
+	plugin, err := NewValidatePlugin(stream_context.CreateContext(1), Config{
+		StreamName:        "users",
+		CheckSchema:       true,
+		RejectExtraFields: true,
+		OnInvalid:         onInvalid,
+		QuarantineStream:  "users_quarantine",
+		Rules: []Rule{
+			{Column: "email", Regex: `^[^@]+@[^@]+$`},
+			{Name: "adult", Column: "age", Min: &minAge, Max: &maxAge},
+			{Column: "status", Enum: []string{"active", "blocked"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamSchema := testSchema()
+	if err = plugin.EvolveSchema(streamSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin, streamSchema
+}
+
+func TestPlugin_Process(t *testing.T) {
+	plugin, _ := newTestPlugin(t, DropInvalid)
+
+	valid := []string{
+		`[{"id": 1, "email": "max@databrew.tech", "age": 30, "status": "active", "birthday": "1994-02-28"}]`,
+		`[{"id": 1.0, "email": "max@databrew.tech", "birthday": "1994-02-28T00:00:00Z"}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "birthday": 8824}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "_before": {"id": 1}, "_metadata": {"backfill_id": "b1"}}]`,
+	}
+	for _, payload := range valid {
+		msg := message.NewMessage(message.Insert, "users", []byte(payload))
+		if processed, err := plugin.Process(context.Background(), msg); err != nil || processed == nil {
+			t.Fatalf("message %s must pass the validation", payload)
+		}
+	}
+
+	invalid := []string{
+		`[{"email": "max@databrew.tech"}]`,
+		`[{"id": "one", "email": "max@databrew.tech"}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "extra": true}]`,
+		`[{"id": 1, "email": "invalid"}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "age": 12}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "status": "deleted"}]`,
+		`[{"id": 1.5, "email": "max@databrew.tech"}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "age": 30.5}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "birthday": "1994-02-30"}]`,
+		`[{"id": 1, "email": "max@databrew.tech", "birthday": 8824.5}]`,
+	}
+	for _, payload := range invalid {
+		msg := message.NewMessage(message.Insert, "users", []byte(payload))
+		if processed, err := plugin.Process(context.Background(), msg); err != nil || processed != nil {
+			t.Fatalf("message %s must be dropped", payload)
+		}
+	}
+
+	plugin, _ = newTestPlugin(t, FailOnInvalid)
+	msg := message.NewMessage(message.Insert, "users", []byte(`[{"id": 1, "email": "invalid"}]`))
+	if _, err := plugin.Process(context.Background(), msg); err == nil {
+		t.Fatal("invalid message must fail the pipeline")
+	}
+}
+
+func TestPlugin_Quarantine(t *testing.T) {
+	plugin, streamSchema := newTestPlugin(t, QuarantineInvalid)
+
+	streams := streamSchema.GetLatestSchema()
+	if len(streams) != 2 || streams[1].StreamName != "users_quarantine" {
+		t.Fatalf("quarantine stream is not added to the schema %v", streams)
+	}
+
+	msg := message.NewMessage(message.Update, "users", []byte(`[{"id": 1, "email": "invalid"}]`))
+	processed, err := plugin.Process(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := helper.MessageRow(processed)
+	if processed.GetStream() != "users_quarantine" || processed.GetEvent() != message.Insert ||
+		row["source_stream"] != "users" || row["event"] != "update" {
+		t.Fatalf("unexpected quarantine message %s", processed.AsJSONString())
+	}
+
+	if !strings.Contains(row["violations"].(string), `"rule":"email"`) {
+		t.Fatalf("violations are not stored %v", row["violations"])
+	}
+}
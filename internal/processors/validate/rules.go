@@ -0,0 +1,163 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+)
+
+const (
+	requiredRuleName   = "schema_required"
+	typeRuleName       = "schema_type"
+	extraFieldRuleName = "schema_extra_field"
+)
+
+// violation describes the rule the message failed
+type violation struct {
+	Rule   string `json:"rule"`
+	Column string `json:"column"`
+	Reason string `json:"reason"`
+}
+
+type compiledRule struct {
+	Rule
+	regex *regexp.Regexp
+}
+
+func compileRule(rule Rule) (compiledRule, error) {
+	compiled := compiledRule{Rule: rule}
+	if compiled.Name == "" {
+		compiled.Name = rule.Column
+	}
+
+	if rule.Regex != "" {
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid regex for the rule %s: %w", compiled.Name, err)
+		}
+		compiled.regex = regex
+	}
+
+	return compiled, nil
+}
+
+func (r compiledRule) check(row map[string]interface{}) *violation {
+	value := row[r.Column]
+	if value == nil {
+		if r.NotNull {
+			return &violation{Rule: r.Name, Column: r.Column, Reason: "value is null"}
+		}
+		return nil
+	}
+
+	plain := helper.PlainValue(value)
+	if r.regex != nil && !r.regex.MatchString(plain) {
+		return &violation{Rule: r.Name, Column: r.Column, Reason: fmt.Sprintf("value %s doesn't match %s", plain, r.Regex)}
+	}
+
+	if r.Min != nil || r.Max != nil {
+		number, err := strconv.ParseFloat(plain, 64)
+		if err != nil {
+			return &violation{Rule: r.Name, Column: r.Column, Reason: fmt.Sprintf("value %s is not a number", plain)}
+		}
+		if r.Min != nil && number < *r.Min {
+			return &violation{Rule: r.Name, Column: r.Column, Reason: fmt.Sprintf("value %s is less than %v", plain, *r.Min)}
+		}
+		if r.Max != nil && number > *r.Max {
+			return &violation{Rule: r.Name, Column: r.Column, Reason: fmt.Sprintf("value %s is greater than %v", plain, *r.Max)}
+		}
+	}
+
+	if len(r.Enum) > 0 {
+		for _, allowed := range r.Enum {
+			if plain == allowed {
+				return nil
+			}
+		}
+		return &violation{Rule: r.Name, Column: r.Column, Reason: fmt.Sprintf("value %s is not in %v", plain, r.Enum)}
+	}
+
+	return nil
+}
+
+// checkSchema validates required columns and value types of the row
+func checkSchema(row map[string]interface{}, columns []schema.Column) []violation {
+	var violations []violation
+	for _, column := range columns {
+		value, ok := row[column.Name]
+		if !ok || value == nil {
+			if !column.Nullable {
+				violations = append(violations, violation{Rule: requiredRuleName, Column: column.Name, Reason: "non-nullable column is missing"})
+			}
+			continue
+		}
+
+		if err := checkType(value, column.DatabrewType); err != nil {
+			violations = append(violations, violation{
+				Rule: typeRuleName, Column: column.Name,
+				Reason: fmt.Sprintf("value can't be converted to %s", column.DatabrewType),
+			})
+		}
+	}
+
+	return violations
+}
+
+// checkType makes sure the value can be stored in the column of the given type.
+// Numbers are accepted for Int columns only when they hold the integer value
+func checkType(value interface{}, databrewType string) error {
+	if databrewType == "Date32" {
+		return checkDate(value)
+	}
+
+	_, err := helper.CastValue(value, databrewType)
+	return err
+}
+
+// checkDate accepts the dates as sources encode them: 2024-01-31, RFC 3339 timestamp
+// or the integral number of days since the epoch
+func checkDate(value interface{}) error {
+	plain, ok := value.(string)
+	if !ok {
+		if _, err := helper.CastValue(value, "Int32"); err != nil {
+			return fmt.Errorf("date must be a string or the number of days, got %v", value)
+		}
+		return nil
+	}
+
+	if _, err := time.Parse(time.DateOnly, plain); err == nil {
+		return nil
+	}
+
+	_, err := time.Parse(time.RFC3339Nano, plain)
+	return err
+}
+
+// checkExtraFields reports the fields of the row that are not defined in the schema.
+// Reserved fields of the before image and the metadata are removed before the row is written, so they are skipped
+func checkExtraFields(row map[string]interface{}, columns []schema.Column) []violation {
+	known := map[string]bool{helper.BeforeImageField: true, helper.MetadataField: true}
+	for _, column := range columns {
+		known[column.Name] = true
+	}
+
+	var extraFields []string
+	for field := range row {
+		if !known[field] {
+			extraFields = append(extraFields, field)
+		}
+	}
+	sort.Strings(extraFields)
+
+	var violations []violation
+	for _, field := range extraFields {
+		violations = append(violations, violation{Rule: extraFieldRuleName, Column: field, Reason: "field is not defined in the schema"})
+	}
+
+	return violations
+}
@@ -0,0 +1,61 @@
+package s3client
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const defaultRegion = "us-east-1"
+
+// Config of the S3 compatible storage shared by the s3 source and sink
+type Config struct {
+	Bucket string `json:"bucket" yaml:"bucket"`
+	// Prefix of the object keys. The slash isn't added to it
+	Prefix string `json:"prefix" yaml:"prefix"`
+	// Region defaults to us-east-1, which is accepted by the most S3 compatible storages
+	Region string `json:"region" yaml:"region"`
+	// Endpoint of the S3 compatible storage like http://localhost:9000 for MinIO.
+	// AWS endpoint of the region is used when empty
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// AccessKeyId and SecretAccessKey are the static credentials.
+	// The default AWS credential chain is used when they are empty
+	AccessKeyId     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	// ForcePathStyle addresses the bucket in the path instead of the host. MinIO requires it
+	ForcePathStyle bool `json:"force_path_style" yaml:"force_path_style"`
+}
+
+func NewClient(config Config) (s3iface.S3API, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("bucket is required")
+	}
+
+	awsConfig := &aws.Config{
+		Region:           aws.String(config.Region),
+		S3ForcePathStyle: aws.Bool(config.ForcePathStyle),
+	}
+
+	if config.Region == "" {
+		awsConfig.Region = aws.String(defaultRegion)
+	}
+
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+
+	if config.AccessKeyId != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKeyId, config.SecretAccessKey, "")
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.New(awsSession), nil
+}
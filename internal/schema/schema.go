@@ -31,7 +31,7 @@ func (s *StreamSchema) AsArrow() *arrow.Schema {
 	var outputSchemaFields []arrow.Field
 	for _, column := range s.Columns {
 		var field arrow.Field
-		if helper.IsPrimitiveType(column.DatabrewType) {
+		if helper.IsPrimitiveType(column.DatabrewType) || helper.IsVectorType(column.DatabrewType) {
 			field = arrow.Field{
 				Name:     column.Name,
 				Type:     helper.MapPlainTypeToArrow(column.DatabrewType),
@@ -46,6 +46,59 @@ func (s *StreamSchemaObj) AddField(streamName, name string, fieldType arrow.Data
 	s.streamSchemaVersions[s.lastVersion] = streamSchemaCopy
 }
 
+// AddColumn appends the column to the stream keeping its definition as is.
+// Unlike AddField it preserves the databrew type, so sinks can map it back to the native type
+func (s *StreamSchemaObj) AddColumn(streamName string, column Column) {
+	var streamSchemaCopy = s.getLastSchemaDeepCopy()
+	for idx, stream := range streamSchemaCopy {
+		if helper.NormalizeStreamName(stream.StreamName) == streamName {
+			streamSchemaCopy[idx].Columns = append(stream.Columns, column)
+		}
+	}
+
+	s.lastVersion += 1
+	s.streamSchemaVersions[s.lastVersion] = streamSchemaCopy
+}
+
+// ReplaceColumn swaps the column definition in the stream, e.g. when the processor changes the column type
+func (s *StreamSchemaObj) ReplaceColumn(streamName, columnName string, column Column) {
+	var streamSchemaCopy = s.getLastSchemaDeepCopy()
+	for streamIndex, stream := range streamSchemaCopy {
+		if helper.NormalizeStreamName(stream.StreamName) == streamName {
+			for colIdx, col := range stream.Columns {
+				if col.Name == columnName {
+					streamSchemaCopy[streamIndex].Columns[colIdx] = column
+				}
+			}
+		}
+	}
+
+	s.lastVersion += 1
+	s.streamSchemaVersions[s.lastVersion] = streamSchemaCopy
+}
+
+// SetColumns replaces all the columns of the stream, e.g. when the processor reshapes the message
+func (s *StreamSchemaObj) SetColumns(streamName string, columns []Column) {
+	var streamSchemaCopy = s.getLastSchemaDeepCopy()
+	for idx, stream := range streamSchemaCopy {
+		if helper.NormalizeStreamName(stream.StreamName) == streamName {
+			streamSchemaCopy[idx].Columns = append([]Column{}, columns...)
+		}
+	}
+
+	s.lastVersion += 1
+	s.streamSchemaVersions[s.lastVersion] = streamSchemaCopy
+}
+
+// AddStream registers a new stream in the schema, so sinks can prepare the storage for it
+func (s *StreamSchemaObj) AddStream(stream StreamSchema) {
+	var streamSchemaCopy = s.getLastSchemaDeepCopy()
+	streamSchemaCopy = append(streamSchemaCopy, stream)
+
+	s.lastVersion += 1
+	s.streamSchemaVersions[s.lastVersion] = streamSchemaCopy
+}
+
 func (s *StreamSchemaObj) FakeEvolve() {
 	var streamSchemaCopy = s.getLastSchemaDeepCopy()
 	s.lastVersion += 1
@@ -0,0 +1,25 @@
+package file
+
+// Config of the file sink. Every stream is written to its own file <path>/<stream>-<time>.<format>,
+// that is written as .tmp until it's rotated or the sink is stopped
+type Config struct {
+	// Path is the directory of the files. It's created when missing
+	Path string `json:"path" yaml:"path"`
+	// Format is ndjson, csv or parquet. Defaults to ndjson
+	Format string `json:"format" yaml:"format"`
+	// Compression is gzip or zstd. Parquet column chunks are compressed instead of the whole file
+	Compression string `json:"compression" yaml:"compression"`
+	// Delimiter of the CSV values. Defaults to comma
+	Delimiter string `json:"delimiter" yaml:"delimiter"`
+	// RowGroupSize is the max amount of rows in the parquet row group. Defaults to 10000
+	RowGroupSize int `json:"row_group_size" yaml:"row_group_size"`
+	// MaxFileSize is the size of the file in bytes it's rotated at.
+	// Size of the parquet file grows by the row groups, so it's checked once the row group is written
+	MaxFileSize int64 `json:"max_file_size" yaml:"max_file_size"`
+	// MaxMessages is the amount of the messages the file is rotated at
+	MaxMessages int `json:"max_messages" yaml:"max_messages"`
+	// RotateInterval is the max age of the file in seconds it's rotated at.
+	// Files are rotated only on stop and the schema change when none of the limits are set.
+	// Messages of the acknowledging sources are acknowledged once their file is rotated
+	RotateInterval int `json:"rotate_interval" yaml:"rotate_interval"`
+}
@@ -0,0 +1,324 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/parquet/compress"
+	"github.com/charmbracelet/log"
+	"github.com/klauspost/compress/zstd"
+	"github.com/usedatabrew/blink/internal/fileformat"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sinks"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const rotateCheckInterval = time.Second
+
+// countingWriter counts the bytes written to the file
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// streamFile is the file of the stream being written
+type streamFile struct {
+	path       string
+	schema     *arrow.Schema
+	file       *os.File
+	buffer     *bufio.Writer
+	counter    *countingWriter
+	compressor io.WriteCloser
+	encoder    fileformat.Encoder
+	messages   int
+	opened     time.Time
+	// first is the number of the first message written to the file
+	first int64
+}
+
+// SinkPlugin writes the rows of every stream to its own file. Files are written with the .tmp suffix
+// and renamed once they are rotated, so the readers see the complete files only
+type SinkPlugin struct {
+	config      Config
+	format      fileformat.Format
+	compression string
+	delimiter   rune
+	schemas     map[string]*arrow.Schema
+	files       map[string]*streamFile
+	// written is the amount of the messages written since the sink is created
+	written int64
+	mutex   sync.Mutex
+	now     func() time.Time
+	done    chan struct{}
+	stopped sync.WaitGroup
+	logger  *log.Logger
+}
+
+func NewFileSinkPlugin(config Config, schema []schema.StreamSchema, appCtx *stream_context.Context) sinks.DataSink {
+	plugin := &SinkPlugin{
+		config: config,
+		files:  map[string]*streamFile{},
+		now:    time.Now,
+		done:   make(chan struct{}),
+		logger: appCtx.Logger.WithPrefix("[sink]: file"),
+	}
+	plugin.SetExpectedSchema(schema)
+
+	return plugin
+}
+
+func (s *SinkPlugin) Connect(ctx context.Context) error {
+	if s.config.Path == "" {
+		return errors.New("path is required for the file sink")
+	}
+
+	s.format = fileformat.NDJSON
+	if s.config.Format != "" {
+		format, err := fileformat.ParseFormat(s.config.Format, "")
+		if err != nil {
+			return err
+		}
+		s.format = format
+	}
+
+	s.compression = strings.ToLower(s.config.Compression)
+	if s.compression != "" && s.compression != "gzip" && s.compression != "zstd" {
+		return fmt.Errorf("unsupported compression %q", s.config.Compression)
+	}
+
+	if s.config.Delimiter != "" {
+		delimiter := []rune(s.config.Delimiter)
+		if len(delimiter) != 1 {
+			return fmt.Errorf("delimiter must be a single character, got %q", s.config.Delimiter)
+		}
+		s.delimiter = delimiter[0]
+	}
+
+	if err := os.MkdirAll(s.config.Path, 0755); err != nil {
+		return err
+	}
+
+	if s.config.RotateInterval > 0 {
+		s.stopped.Add(1)
+		go s.rotateExpired()
+	}
+
+	return nil
+}
+
+// Write writes the row to the file of the stream and rotates the file once it reaches the limits
+func (s *SinkPlugin) Write(msg *message.Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stream := msg.GetStream()
+	current, ok := s.files[stream]
+	if !ok {
+		var err error
+		if current, err = s.open(stream); err != nil {
+			return err
+		}
+		s.files[stream] = current
+	}
+
+	s.written += 1
+	if current.first == 0 {
+		current.first = s.written
+	}
+
+	if err := current.encoder.Write(msg); err != nil {
+		return err
+	}
+	current.messages += 1
+
+	if s.config.MaxMessages > 0 && current.messages >= s.config.MaxMessages ||
+		s.config.MaxFileSize > 0 && current.counter.written >= s.config.MaxFileSize {
+		return s.rotate(stream)
+	}
+
+	return nil
+}
+
+// Buffered returns the amount of the last written messages that are not in the rotated files yet.
+// Files are rotated independently, so the messages after the first one of the oldest open file
+// are treated as not rotated
+func (s *SinkPlugin) Buffered() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	durable := s.written
+	for _, current := range s.files {
+		if current.first > 0 && current.first-1 < durable {
+			durable = current.first - 1
+		}
+	}
+
+	return s.written - durable
+}
+
+func (s *SinkPlugin) GetType() sinks.SinkDriver {
+	return sinks.FileSinkType
+}
+
+// SetExpectedSchema sets the schema of the new files. Files of the changed streams are rotated,
+// so every file has a single schema
+func (s *SinkPlugin) SetExpectedSchema(schema []schema.StreamSchema) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.schemas = map[string]*arrow.Schema{}
+	for _, streamSchema := range schema {
+		s.schemas[streamSchema.StreamName] = streamSchema.AsArrow()
+	}
+
+	for stream, current := range s.files {
+		if updated, ok := s.schemas[stream]; !ok || !updated.Equal(current.schema) {
+			if err := s.rotate(stream); err != nil {
+				s.logger.Error("Failed to rotate file", "err", err)
+			}
+		}
+	}
+}
+
+// Stop completes the files being written
+func (s *SinkPlugin) Stop() {
+	close(s.done)
+	s.stopped.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for stream := range s.files {
+		if err := s.rotate(stream); err != nil {
+			s.logger.Error("Failed to complete file", "err", err)
+		}
+	}
+}
+
+func (s *SinkPlugin) open(stream string) (*streamFile, error) {
+	streamSchema, ok := s.schemas[stream]
+	if !ok {
+		return nil, fmt.Errorf("no schema found for stream %s", stream)
+	}
+
+	now := s.now().UTC()
+	name := fmt.Sprintf("%s-%s.%s", stream, now.Format("20060102T150405.000000000Z"), s.format)
+	options := fileformat.EncoderOptions{RowGroupSize: s.config.RowGroupSize, Delimiter: s.delimiter}
+	switch {
+	case s.format == fileformat.Parquet && s.compression == "gzip":
+		options.Compression = compress.Codecs.Gzip
+	case s.format == fileformat.Parquet && s.compression == "zstd":
+		options.Compression = compress.Codecs.Zstd
+	case s.compression == "gzip":
+		name += ".gz"
+	case s.compression == "zstd":
+		name += ".zst"
+	}
+
+	current := &streamFile{path: filepath.Join(s.config.Path, name), schema: streamSchema, opened: now}
+	f, err := os.Create(current.path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	current.file = f
+	current.buffer = bufio.NewWriter(f)
+	current.counter = &countingWriter{writer: current.buffer}
+
+	var writer io.Writer = current.counter
+	if s.format != fileformat.Parquet {
+		switch s.compression {
+		case "gzip":
+			current.compressor = gzip.NewWriter(current.counter)
+		case "zstd":
+			if current.compressor, err = zstd.NewWriter(current.counter); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+		if current.compressor != nil {
+			writer = current.compressor
+		}
+	}
+
+	if current.encoder, err = fileformat.NewEncoder(s.format, writer, streamSchema, options); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return current, nil
+}
+
+// rotate completes the file of the stream and renames it to the final name.
+// The next message of the stream opens the new file
+func (s *SinkPlugin) rotate(stream string) error {
+	current := s.files[stream]
+	delete(s.files, stream)
+
+	err := current.encoder.Close()
+	if err == nil && current.compressor != nil {
+		err = current.compressor.Close()
+	}
+	if err == nil {
+		err = current.buffer.Flush()
+	}
+	if err == nil {
+		err = current.file.Sync()
+	}
+	if closeErr := current.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to complete file %s: %w", current.path, err)
+	}
+
+	if err = os.Rename(current.path+".tmp", current.path); err != nil {
+		return err
+	}
+
+	s.logger.Info("Completed file", "file", current.path, "messages", current.messages)
+	return nil
+}
+
+// rotateExpired rotates the files reaching the rotate interval
+func (s *SinkPlugin) rotateExpired() {
+	defer s.stopped.Done()
+
+	ticker := time.NewTicker(rotateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		s.mutex.Lock()
+		expired := s.now().UTC().Add(-time.Duration(s.config.RotateInterval) * time.Second)
+		for stream, current := range s.files {
+			if current.opened.Before(expired) {
+				if err := s.rotate(stream); err != nil {
+					s.logger.Error("Failed to rotate file", "err", err)
+				}
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
@@ -0,0 +1,140 @@
+package file
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String", Nullable: true},
+		},
+	},
+}
+
+func newTestPlugin(t *testing.T, config Config) *SinkPlugin {
+	plugin := NewFileSinkPlugin(config, testStreamSchema, stream_context.CreateContext(1)).(*SinkPlugin)
+
+	// every file gets its own name
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	plugin.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	if err := plugin.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func write(t *testing.T, plugin *SinkPlugin, rows ...string) {
+	for _, row := range rows {
+		if err := plugin.Write(message.NewMessage(message.Insert, "users", []byte(row))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// files returns the names of the files in the directory
+func files(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestSinkPlugin_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	plugin := newTestPlugin(t, Config{Path: dir, Compression: "gzip", MaxMessages: 2})
+
+	write(t, plugin, `[{"id": 1, "name": "a"}]`, `[{"id": 2}]`, `[{"id": 3}]`)
+	expected := []string{"users-20240501T000001.000000000Z.ndjson.gz", "users-20240501T000002.000000000Z.ndjson.gz.tmp"}
+	if names := files(t, dir); strings.Join(names, "|") != strings.Join(expected, "|") {
+		t.Fatalf("file must be completed once it reaches the max messages, got %v", names)
+	}
+	if buffered := plugin.Buffered(); buffered != 1 {
+		t.Fatalf("only the message of the open file must be buffered, got %d", buffered)
+	}
+
+	plugin.Stop()
+	names := files(t, dir)
+	if len(names) != 2 || strings.HasSuffix(names[1], ".tmp") {
+		t.Fatalf("file must be completed on stop, got %v", names)
+	}
+	if buffered := plugin.Buffered(); buffered != 0 {
+		t.Fatalf("messages of the completed files must not be buffered, got %d", buffered)
+	}
+
+	f, err := os.Open(filepath.Join(dir, names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	reader, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":null}\n" {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestSinkPlugin_SchemaChange(t *testing.T) {
+	dir := t.TempDir()
+	plugin := newTestPlugin(t, Config{Path: dir, Format: "csv"})
+
+	write(t, plugin, `[{"id": 1, "name": "a, b"}]`)
+
+	evolved := []schema.StreamSchema{{
+		StreamName: "users",
+		Columns:    append(testStreamSchema[0].Columns, schema.Column{Name: "age", DatabrewType: "Int32", Nullable: true}),
+	}}
+	plugin.SetExpectedSchema(evolved)
+	write(t, plugin, `[{"id": 2, "age": 30}]`)
+	plugin.Stop()
+
+	var contents []string
+	for _, name := range files(t, dir) {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(content))
+	}
+
+	expected := []string{"id,name\n1,\"a, b\"\n", "id,name,age\n2,,30\n"}
+	if strings.Join(contents, "|") != strings.Join(expected, "|") {
+		t.Fatalf("file must be rotated on the schema change, got %q", contents)
+	}
+}
@@ -1,8 +1,19 @@
 package nats
 
 type Config struct {
-	Url      string `json:"url" yaml:"url"`
+	Url string `json:"url" yaml:"url"`
+	// Subject may contain the {stream} and {event} placeholders, like cdc.{stream}.{event}
 	Subject  string `json:"subject" yaml:"subject"`
 	Username string `json:"username" yaml:"username"`
 	Password string `json:"password" yaml:"password"`
+	// JetStream publishes the messages to the JetStream stream of the subject and waits for the ack.
+	// Messages get the position of the change in the source log as the Nats-Msg-Id header, so the stream
+	// drops the changes published again after restart within its duplicate window.
+	// Messages without the position, like the snapshot rows, are published without the header
+	JetStream bool `json:"jetstream" yaml:"jetstream"`
+	// PublishTimeout is the time to wait for the JetStream ack in milliseconds. Defaults to 5000
+	PublishTimeout int `json:"publish_timeout" yaml:"publish_timeout"`
+	// Headers are added to the messages along with the Blink-Stream and Blink-Event ones.
+	// Values are Go templates rendered for the message, like {{ .Data.id }}
+	Headers map[string]string `json:"headers" yaml:"headers"`
 }
@@ -2,27 +2,41 @@ package nats
 
 import (
 	"context"
+	"fmt"
+	"text/template"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/sinks"
 	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
+const defaultPublishTimeout = 5000
+
 type SinkPlugin struct {
 	natsClient   *nats.Conn
+	jetStream    jetstream.JetStream
 	streamSchema []schema.StreamSchema
 	config       Config
+	headers      map[string]*template.Template
 	logger       *log.Logger
 }
 
 func NewNatsSinkPlugin(config Config, schema []schema.StreamSchema, appCtx *stream_context.Context) sinks.DataSink {
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = defaultPublishTimeout
+	}
+
 	return &SinkPlugin{
 		streamSchema: schema,
 		config:       config,
+		headers:      map[string]*template.Template{},
 		logger:       appCtx.Logger.WithPrefix("[sink]: nats"),
 	}
 }
@@ -34,6 +48,14 @@ func (s *SinkPlugin) Connect(ctx context.Context) error {
 		opt = nats.UserInfo(s.config.Username, s.config.Password)
 	}
 
+	for key, value := range s.config.Headers {
+		tmpl, err := helper.ParseMessageTemplate(key, value)
+		if err != nil {
+			return fmt.Errorf("invalid template for header %s: %w", key, err)
+		}
+		s.headers[key] = tmpl
+	}
+
 	client, err := nats.Connect(s.config.Url, opt)
 
 	if err != nil {
@@ -41,11 +63,75 @@ func (s *SinkPlugin) Connect(ctx context.Context) error {
 	}
 
 	s.natsClient = client
+
+	if s.config.JetStream {
+		if s.jetStream, err = jetstream.New(client); err != nil {
+			return err
+		}
+	}
+
 	return err
 }
 
 func (s *SinkPlugin) Write(message *message.Message) error {
-	return s.natsClient.Publish(s.config.Subject, []byte(message.AsJSONString()))
+	return s.WriteWithMetadata(message, helper.Metadata{})
+}
+
+// WriteWithMetadata publishes the message. JetStream messages with the source position
+// get it as the Nats-Msg-Id, so the change emitted again after the source restart is dropped
+func (s *SinkPlugin) WriteWithMetadata(message *message.Message, metadata helper.Metadata) error {
+	msg, err := s.buildMessage(message)
+	if err != nil {
+		return err
+	}
+
+	if s.jetStream == nil {
+		return s.natsClient.PublishMsg(msg)
+	}
+
+	if metadata.Position != "" {
+		msg.Header.Set(jetstream.MsgIDHeader, metadata.Position)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.PublishTimeout)*time.Millisecond)
+	defer cancel()
+
+	ack, err := s.jetStream.PublishMsg(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", msg.Subject, err)
+	}
+
+	if ack.Duplicate {
+		s.logger.Debug("Message is already published", "stream", ack.Stream, "sequence", ack.Sequence)
+	}
+
+	return nil
+}
+
+// buildMessage builds the message for the subject of the stream and the event
+func (s *SinkPlugin) buildMessage(message *message.Message) (*nats.Msg, error) {
+	payload := message.AsJSONString()
+	msg := &nats.Msg{
+		Subject: helper.ExpandMessageTemplate(s.config.Subject, message),
+		Data:    []byte(payload),
+		Header:  nats.Header{},
+	}
+
+	msg.Header.Set(helper.StreamHeader, message.GetStream())
+	msg.Header.Set(helper.EventHeader, string(message.GetEvent()))
+
+	if len(s.headers) > 0 {
+		data := helper.NewMessageTemplateData(message)
+		for key, tmpl := range s.headers {
+			value, err := helper.RenderMessageTemplate(tmpl, data)
+			if err != nil {
+				return nil, err
+			}
+			msg.Header.Set(key, value)
+		}
+	}
+
+	return msg, nil
 }
 
 func (s *SinkPlugin) GetType() sinks.SinkDriver {
@@ -0,0 +1,91 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+func runServer(t *testing.T) *server.Server {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+	t.Cleanup(srv.Shutdown)
+
+	return srv
+}
+
+func TestSinkPlugin_JetStream(t *testing.T) {
+	srv := runServer(t)
+	ctx := context.Background()
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "CDC", Subjects: []string{"cdc.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{Url: srv.ClientURL(), Subject: "cdc.{stream}.{event}", JetStream: true, Headers: map[string]string{"Row-Id": "{{ .Data.id }}"}}
+	plugin := NewNatsSinkPlugin(config, nil, stream_context.CreateContext(1))
+	if err = plugin.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Stop()
+
+	// the change published again is dropped by the stream, the messages without the position are kept
+	for _, write := range []struct {
+		msg      *message.Message
+		position string
+	}{
+		{message.NewMessage(message.Snapshot, "users", []byte(`[{"id": 1}]`)), ""},
+		{message.NewMessage(message.Snapshot, "users", []byte(`[{"id": 1}]`)), ""},
+		{message.NewMessage(message.Insert, "users", []byte(`[{"id": 1}]`)), "0/16B3748:0"},
+		{message.NewMessage(message.Insert, "users", []byte(`[{"id": 1}]`)), "0/16B3748:0"},
+		{message.NewMessage(message.Delete, "users", []byte(`[{"id": 1}]`)), "0/16B3790:0"},
+	} {
+		if err = plugin.(*SinkPlugin).WriteWithMetadata(write.msg, helper.Metadata{Position: write.position}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 4 {
+		t.Fatalf("duplicate message must be dropped, got %d messages", info.State.Msgs)
+	}
+
+	published, err := stream.GetLastMsgForSubject(ctx, "cdc.users.delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if published.Header.Get("Blink-Event") != "delete" || published.Header.Get("Row-Id") != "1" || published.Header.Get(jetstream.MsgIDHeader) != "0/16B3790:0" ||
+		string(published.Data) != `[{"id":1}]` {
+		t.Fatalf("unexpected message %v %s", published.Header, published.Data)
+	}
+}
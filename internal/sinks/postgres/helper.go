@@ -14,7 +14,7 @@ func generateCreateTableStatement(table string, columns []schema.Column) string
 	statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS \"%s\" (\n", table)
 
 	for idx, column := range columns {
-		statement += fmt.Sprintf("  %s %s", column.Name, helper.ArrowToPg10(helper.MapPlainTypeToArrow(column.DatabrewType)))
+		statement += fmt.Sprintf("  %s %s", column.Name, pgColumnType(column))
 		if column.PK {
 			statement += fmt.Sprint(" PRIMARY KEY")
 		}
@@ -30,12 +30,68 @@ func generateCreateTableStatement(table string, columns []schema.Column) string
 	return statement
 }
 
+// pgColumnType maps the column to the postgres type.
+// Vector(n) columns are created as pgvector vector(n)
+func pgColumnType(column schema.Column) string {
+	if dimensions, ok := helper.ParseVectorType(column.DatabrewType); ok {
+		return fmt.Sprintf("vector(%d)", dimensions)
+	}
+
+	return helper.ArrowToPg10(helper.MapPlainTypeToArrow(column.DatabrewType))
+}
+
+func generateTruncateStatement(table string) string {
+	return fmt.Sprintf("TRUNCATE TABLE \"%s\";", table)
+}
+
+// generateAddColumnStatement adds the column as nullable,
+// since the rows written before the change have no value for it
+func generateAddColumnStatement(table string, column schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE \"%s\" ADD COLUMN IF NOT EXISTS %s %s;", table, column.Name, pgColumnType(column))
+}
+
 func generateBatchInsertStatement(table schema.StreamSchema) string {
 	columnNames := getColumnNames(table.Columns)
 	valuesPlaceholder := getValuesPlaceholder(len(table.Columns))
 	return fmt.Sprintf("INSERT INTO \"%s\" (%s) VALUES %s;", table.StreamName, columnNames, valuesPlaceholder)
 }
 
+// generateBatchUpsertStatement inserts the row or updates the existing one with the same primary key.
+// It's used for the backfilled rows, as they may already exist in the table
+func generateBatchUpsertStatement(table schema.StreamSchema) string {
+	var pkColumns []string
+	var updateColumns []string
+	for _, column := range getColumnNamesSorted(table.Columns) {
+		if isPrimaryKeyColumn(table.Columns, column) {
+			pkColumns = append(pkColumns, column)
+		} else {
+			updateColumns = append(updateColumns, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+
+	insertStatement := strings.TrimSuffix(generateBatchInsertStatement(table), ";")
+	if len(pkColumns) == 0 {
+		return insertStatement + ";"
+	}
+
+	conflictAction := "DO NOTHING"
+	if len(updateColumns) > 0 {
+		conflictAction = "DO UPDATE SET " + strings.Join(updateColumns, ", ")
+	}
+
+	return fmt.Sprintf("%s ON CONFLICT (%s) %s;", insertStatement, strings.Join(pkColumns, ", "), conflictAction)
+}
+
+func isPrimaryKeyColumn(columns []schema.Column, name string) bool {
+	for _, column := range columns {
+		if column.Name == name {
+			return column.PK
+		}
+	}
+
+	return false
+}
+
 func generateBatchUpdateStatement(table schema.StreamSchema) string {
 	pkColumns := getPrimaryKeyColumnsForUpdate(table.Columns)
 	setClause := getSetClause(table.Columns)
@@ -36,6 +36,13 @@ func Test_generateBatchInsertStatement(t *testing.T) {
 	}
 }
 
+func Test_generateBatchUpsertStatement(t *testing.T) {
+	result := generateBatchUpsertStatement(testStreamSchema[0])
+	if result != "INSERT INTO \"flights\" (flights_name, id) VALUES ($1, $2 ) ON CONFLICT (id) DO UPDATE SET flights_name = EXCLUDED.flights_name;" {
+		t.Fatalf("Generated Upsert Query is not correct: %s", result)
+	}
+}
+
 func Test_generateBatchUpdateStatement(t *testing.T) {
 	result := generateBatchUpdateStatement(testStreamSchema[0])
 	if result != "UPDATE \"flights\" SET flights_name = $1 WHERE id = $2;\n" {
@@ -64,3 +71,36 @@ func Test_generateCreateTableStatement(t *testing.T) {
 	generatedStream := generateStreamNameWithPrefix("public.zenko_comments", "databrew_")
 	fmt.Println(generatedStream)
 }
+
+func Test_generateCreateTableStatementWithVector(t *testing.T) {
+	statement := generateCreateTableStatement("public.documents", []schema.Column{
+		{Name: "id", DatabrewType: "Int64", PK: true},
+		{Name: "embedding", DatabrewType: "Vector(3)", Nullable: true},
+	})
+	if statement != "CREATE TABLE IF NOT EXISTS \"documents\" (\n  id bigint PRIMARY KEY NOT NULL,\n  embedding vector(3)\n);" {
+		t.Fatal("Generated Create Table Query is not correct", statement)
+	}
+}
+
+func Test_encodeVector(t *testing.T) {
+	vector := []interface{}{0.5, -1.25, float64(3)}
+	if encoded := encodeVectorText(vector); encoded != "[0.5,-1.25,3]" {
+		t.Fatal("Text encoded vector is not correct", encoded)
+	}
+
+	encoded := encodeVectorBinary(vector).([]byte)
+	if len(encoded) != 16 || encoded[1] != 3 {
+		t.Fatal("Binary encoded vector is not correct", encoded)
+	}
+}
+
+func Test_generateControlStatements(t *testing.T) {
+	if statement := generateTruncateStatement("flights"); statement != "TRUNCATE TABLE \"flights\";" {
+		t.Fatal("Generated Truncate Query is not correct", statement)
+	}
+
+	statement := generateAddColumnStatement("flights", schema.Column{Name: "gate", DatabrewType: "String", Nullable: true})
+	if statement != "ALTER TABLE \"flights\" ADD COLUMN IF NOT EXISTS gate text;" {
+		t.Fatal("Generated Add Column Query is not correct", statement)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"github.com/charmbracelet/log"
 	"github.com/jackc/pgx/v5"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/sinks"
+	"github.com/usedatabrew/blink/internal/sources"
 	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
 	"sync"
@@ -20,13 +22,17 @@ type SinkPlugin struct {
 	conn                  *pgx.Conn
 	logger                *log.Logger
 	rowStatements         map[string]map[message.Event]string
+	upsertStatements      map[string]string
 	pkColumnNamesByStream map[string]string
+	vectorColumnsByStream map[string]map[string]bool
 	mutex                 sync.Mutex
 	messagesBuffer        []*message.Message
 	snapshotMaxBufferSize int
 	prevEvent             message.Event
 	prevSnapshotStream    string
-	snapshotTicker        *time.Timer
+	// bufferedBackfill reports if the buffered snapshot rows are backfilled and have to be upserted
+	bufferedBackfill bool
+	snapshotTicker   *time.Timer
 }
 
 func NewPostgresSinkPlugin(config Config, schema []schema.StreamSchema, appctx *stream_context.Context) sinks.DataSink {
@@ -65,14 +71,22 @@ func (s *SinkPlugin) GetType() sinks.SinkDriver {
 }
 
 func (s *SinkPlugin) Write(m *message.Message) error {
+	return s.WriteWithMetadata(m, helper.Metadata{})
+}
+
+// WriteWithMetadata writes the message. Backfilled rows are told from the snapshot by the backfill id
+func (s *SinkPlugin) WriteWithMetadata(m *message.Message, metadata helper.Metadata) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	// for snapshot event we have to perform inserts in bulk using COPY command
 	// to achieve higher insert efficiency
 
-	if m.GetEvent() == message.Snapshot && s.prevEvent == message.Snapshot && s.prevSnapshotStream != m.GetStream() {
+	backfill := m.GetEvent() == message.Snapshot && metadata.BackfillId != ""
+	if m.GetEvent() == message.Snapshot && len(s.messagesBuffer) > 0 &&
+		(s.prevSnapshotStream != m.GetStream() || s.bufferedBackfill != backfill) {
 		// we have to drain snapshot message for prev stream
-		// before we can process snapshot for another stream
+		// before we can process snapshot for another stream.
+		// Backfilled rows are upserted instead of being copied, so they are not batched with the snapshot rows
 		s.logger.Info("Changed stream for snapshot. Draining message buffer to continue")
 		err := s.writeSnapshotBatch()
 		if err != nil {
@@ -82,6 +96,8 @@ func (s *SinkPlugin) Write(m *message.Message) error {
 
 	s.prevSnapshotStream = m.GetStream()
 	if m.GetEvent() == message.Snapshot {
+		// backfilled rows may already exist in the table, so they are upserted in batches
+		s.bufferedBackfill = backfill
 		s.messagesBuffer = append(s.messagesBuffer, m)
 
 		if len(s.messagesBuffer) >= s.snapshotMaxBufferSize {
@@ -113,7 +129,7 @@ func (s *SinkPlugin) Write(m *message.Message) error {
 	}
 	// means we finished snapshot streaming. Here we must ensure that we flushed all the
 	// messages from snapshot before we start pushing rest of the messages
-	if s.prevEvent == message.Snapshot && s.prevEvent != m.GetEvent() {
+	if len(s.messagesBuffer) > 0 {
 		s.logger.Info("Snapshot streaming finished. Draining message buffer to continue")
 		err := s.writeSnapshotBatch()
 		if err != nil {
@@ -142,7 +158,7 @@ func (s *SinkPlugin) Write(m *message.Message) error {
 		for _, ss := range s.streamSchema {
 			if s.compareStreamNames(ss.StreamName, m.Stream) {
 				for _, col := range getColumnNamesSorted(ss.Columns) {
-					colValues = append(colValues, m.Data.AccessProperty(col))
+					colValues = append(colValues, s.columnValue(m, col, false))
 				}
 			}
 		}
@@ -163,7 +179,7 @@ func (s *SinkPlugin) Write(m *message.Message) error {
 			if s.compareStreamNames(ss.StreamName, m.Stream) {
 				for _, col := range getColumnNamesSorted(ss.Columns) {
 					if col != pkColName {
-						colValues = append(colValues, m.Data.AccessProperty(col))
+						colValues = append(colValues, s.columnValue(m, col, false))
 					}
 				}
 
@@ -181,6 +197,74 @@ func (s *SinkPlugin) Write(m *message.Message) error {
 	return nil
 }
 
+// writeUpsertBatch upserts the buffered backfilled rows in a single round trip
+func (s *SinkPlugin) writeUpsertBatch() error {
+	batch := &pgx.Batch{}
+	for _, m := range s.messagesBuffer {
+		var colValues []interface{}
+		for _, ss := range s.streamSchema {
+			if s.compareStreamNames(ss.StreamName, m.Stream) {
+				for _, col := range getColumnNamesSorted(ss.Columns) {
+					colValues = append(colValues, s.columnValue(m, col, false))
+				}
+			}
+		}
+
+		batch.Queue(s.upsertStatements[generateStreamNameWithPrefix(m.GetStream(), s.config.StreamPrefix)], colValues...)
+	}
+
+	return s.conn.SendBatch(s.appctx.GetContext(), batch).Close()
+}
+
+// ApplyControlEvent truncates the table or adds the new columns to it.
+// Buffered snapshot rows are written first, so they are not lost on truncate
+func (s *SinkPlugin) ApplyControlEvent(m *message.Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.writeSnapshotBatch(); err != nil {
+		return err
+	}
+	s.messagesBuffer = []*message.Message{}
+
+	table := generateStreamNameWithPrefix(m.GetStream(), s.config.StreamPrefix)
+	switch m.GetEvent() {
+	case sources.TruncateEvent:
+		s.logger.Info("Truncating table", "table", table)
+		_, err := s.conn.Exec(s.appctx.GetContext(), generateTruncateStatement(table))
+		return err
+	case sources.SchemaChangeEvent:
+		change, err := sources.ReadSchemaChange(m)
+		if err != nil {
+			return err
+		}
+
+		for _, column := range change.Columns {
+			s.logger.Info("Adding column", "table", table, "column", column.Name)
+			if _, err = s.conn.Exec(s.appctx.GetContext(), generateAddColumnStatement(table, column)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// columnValue extracts the column value from the message and encodes
+// vector columns, since pgx doesn't know the pgvector type
+func (s *SinkPlugin) columnValue(m *message.Message, column string, copyFormat bool) interface{} {
+	value := m.Data.AccessProperty(column)
+	if value == nil || !s.vectorColumnsByStream[generateStreamNameWithPrefix(m.GetStream(), s.config.StreamPrefix)][column] {
+		return value
+	}
+
+	if copyFormat {
+		return encodeVectorBinary(value)
+	}
+
+	return encodeVectorText(value)
+}
+
 func (s *SinkPlugin) compareStreamNames(streamA, streamB string) bool {
 	return generateStreamNameWithPrefix(streamA, s.config.StreamPrefix) == generateStreamNameWithPrefix(streamB, s.config.StreamPrefix)
 }
@@ -196,6 +280,14 @@ func (s *SinkPlugin) writeSnapshotBatch() error {
 		return nil
 	}
 
+	if s.bufferedBackfill {
+		if err := s.writeUpsertBatch(); err != nil {
+			return err
+		}
+		s.messagesBuffer = []*message.Message{}
+		return nil
+	}
+
 	// extract column names
 	for _, ss := range s.streamSchema {
 		if generateStreamNameWithPrefix(ss.StreamName, s.config.StreamPrefix) == generateStreamNameWithPrefix(s.messagesBuffer[0].Stream, s.config.StreamPrefix) {
@@ -212,7 +304,7 @@ func (s *SinkPlugin) writeSnapshotBatch() error {
 		for _, ss := range s.streamSchema {
 			if generateStreamNameWithPrefix(ss.StreamName, s.config.StreamPrefix) == generateStreamNameWithPrefix(bufMessage.Stream, s.config.StreamPrefix) {
 				for _, col := range ss.Columns {
-					colValues = append(colValues, bufMessage.Data.AccessProperty(col.Name))
+					colValues = append(colValues, s.columnValue(bufMessage, col.Name, true))
 				}
 			}
 		}
@@ -220,11 +312,15 @@ func (s *SinkPlugin) writeSnapshotBatch() error {
 		messagesToInsert = append(messagesToInsert, colValues)
 	}
 
-	snapStreamName := generateStreamNameWithPrefix(s.prevSnapshotStream, s.config.StreamPrefix)
+	snapStreamName := generateStreamNameWithPrefix(s.messagesBuffer[0].Stream, s.config.StreamPrefix)
 	fmt.Println(messagesToInsert, colNames)
 	_, err := s.conn.CopyFrom(context.TODO(), pgx.Identifier{snapStreamName}, colNames, pgx.CopyFromRows(messagesToInsert))
+	if err != nil {
+		return err
+	}
 
-	return err
+	s.messagesBuffer = []*message.Message{}
+	return nil
 }
 
 func (s *SinkPlugin) Stop() {
@@ -234,7 +330,9 @@ func (s *SinkPlugin) Stop() {
 func (s *SinkPlugin) createInitStatements() {
 	var dbCreateTableStatements []string
 	var rowStatements = make(map[string]map[message.Event]string)
+	var upsertStatements = make(map[string]string)
 	var pkColumnNames = make(map[string]string)
+	var vectorColumns = make(map[string]map[string]bool)
 
 	for _, stream := range s.streamSchema {
 		stream.StreamName = generateStreamNameWithPrefix(stream.StreamName, s.config.StreamPrefix)
@@ -243,6 +341,7 @@ func (s *SinkPlugin) createInitStatements() {
 		insertStatement := generateBatchInsertStatement(stream)
 		updateStatement := generateBatchUpdateStatement(stream)
 		deleteStatement := generateBatchDeleteStatement(stream)
+		upsertStatements[stream.StreamName] = generateBatchUpsertStatement(stream)
 		rowStatements[stream.StreamName] = map[message.Event]string{
 			message.Delete:   deleteStatement,
 			message.Update:   updateStatement,
@@ -254,11 +353,26 @@ func (s *SinkPlugin) createInitStatements() {
 			if col.PK {
 				pkColumnNames[stream.StreamName] = col.Name
 			}
+			if helper.IsVectorType(col.DatabrewType) {
+				if _, ok := vectorColumns[stream.StreamName]; !ok {
+					vectorColumns[stream.StreamName] = map[string]bool{}
+				}
+				vectorColumns[stream.StreamName][col.Name] = true
+			}
 		}
 	}
 
 	s.pkColumnNamesByStream = pkColumnNames
+	s.vectorColumnsByStream = vectorColumns
 	s.rowStatements = rowStatements
+	s.upsertStatements = upsertStatements
+
+	if len(vectorColumns) > 0 {
+		// vector columns require pgvector extension to be available in the sink database
+		if _, err := s.conn.Exec(s.appctx.GetContext(), "CREATE EXTENSION IF NOT EXISTS vector"); err != nil {
+			s.logger.Warn("Failed to create pgvector extension. Make sure it's installed", "error", err)
+		}
+	}
 
 	s.logger.Info("Generated init statements to create table for the sink database", "statements", dbCreateTableStatements)
 	tx, err := s.conn.Begin(s.appctx.GetContext())
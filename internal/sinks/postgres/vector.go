@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// encodeVectorText encodes the vector into pgvector text representation [1,2,3].
+// It's used for the regular statements where pgx sends unknown types as text
+func encodeVectorText(value interface{}) interface{} {
+	values, ok := vectorValues(value)
+	if !ok {
+		return value
+	}
+
+	var encoded strings.Builder
+	encoded.WriteString("[")
+	for idx, v := range values {
+		if idx > 0 {
+			encoded.WriteString(",")
+		}
+		encoded.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	}
+	encoded.WriteString("]")
+
+	return encoded.String()
+}
+
+// encodeVectorBinary encodes the vector into pgvector binary representation.
+// COPY is always performed in binary format, so text representation can't be used there
+func encodeVectorBinary(value interface{}) interface{} {
+	values, ok := vectorValues(value)
+	if !ok {
+		return value
+	}
+
+	encoded := make([]byte, 4+4*len(values))
+	binary.BigEndian.PutUint16(encoded[0:], uint16(len(values)))
+	// next two bytes are reserved by pgvector and always set to 0
+	binary.BigEndian.PutUint16(encoded[2:], 0)
+	for idx, v := range values {
+		binary.BigEndian.PutUint32(encoded[4+4*idx:], math.Float32bits(v))
+	}
+
+	return encoded
+}
+
+func vectorValues(value interface{}) ([]float32, bool) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	values := make([]float32, len(list))
+	for idx, element := range list {
+		f, ok := element.(float64)
+		if !ok {
+			return nil, false
+		}
+		values[idx] = float32(f)
+	}
+
+	return values, true
+}
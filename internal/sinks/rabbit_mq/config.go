@@ -3,5 +3,29 @@ package rabbit_mq
 type Config struct {
 	Url          string `json:"url" yaml:"url"`
 	ExchangeName string `json:"exchange_name" yaml:"exchange_name"`
-	RoutingKey   string `json:"routing_key" yaml:"routing_key"`
+	// RoutingKey may contain the {stream} and {event} placeholders, like cdc.{stream}.{event}
+	RoutingKey string `json:"routing_key" yaml:"routing_key"`
+	// ExchangeKind of the declared exchange. Defaults to direct
+	ExchangeKind string `json:"exchange_kind" yaml:"exchange_kind"`
+	// DurableExchange declares the exchange surviving the broker restart
+	DurableExchange bool `json:"durable_exchange" yaml:"durable_exchange"`
+	// Queues are declared durable and bound to the exchange on connect,
+	// so the messages published before the consumers start aren't lost
+	Queues []QueueConfig `json:"queues" yaml:"queues"`
+	// Persistent publishes the messages in the persistent delivery mode,
+	// so they survive the broker restart in the durable queues
+	Persistent bool `json:"persistent" yaml:"persistent"`
+	// Confirms waits for the broker to confirm every message. The message
+	// rejected or not confirmed within the publish timeout fails the write
+	Confirms bool `json:"confirms" yaml:"confirms"`
+	// PublishTimeout is the time to wait for the confirm in milliseconds. Defaults to 5000
+	PublishTimeout int `json:"publish_timeout" yaml:"publish_timeout"`
+}
+
+type QueueConfig struct {
+	Name string `json:"name" yaml:"name"`
+	// RoutingKeys the queue is bound with. The queue name is used when empty
+	RoutingKeys []string `json:"routing_keys" yaml:"routing_keys"`
+	// DeadLetterExchange receives the messages rejected by the consumers of the queue
+	DeadLetterExchange string `json:"dead_letter_exchange" yaml:"dead_letter_exchange"`
 }
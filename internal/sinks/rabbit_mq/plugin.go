@@ -2,25 +2,73 @@ package rabbit_mq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/sinks"
 	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
 
+	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/wagslane/go-rabbitmq"
 )
 
+const defaultPublishTimeout = 5000
+
+// publisher publishes the messages to the broker, so the tests replace it
+type publisher interface {
+	Publish(data []byte, routingKeys []string, optionFuncs ...func(*rabbitmq.PublishOptions)) error
+	PublishWithConfirm(ctx context.Context, data []byte, routingKeys []string, optionFuncs ...func(*rabbitmq.PublishOptions)) ([]confirmation, error)
+	Close()
+}
+
+// confirmation is the broker confirm of the published message
+type confirmation interface {
+	WaitContext(ctx context.Context) (bool, error)
+}
+
+// queueChannel declares the queues and their bindings
+type queueChannel interface {
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+// confirmPublisher returns the deferred confirms of the rabbitmq publisher
+type confirmPublisher struct {
+	*rabbitmq.Publisher
+}
+
+func (p confirmPublisher) PublishWithConfirm(ctx context.Context, data []byte, routingKeys []string, optionFuncs ...func(*rabbitmq.PublishOptions)) ([]confirmation, error) {
+	confirms, err := p.PublishWithDeferredConfirmWithContext(ctx, data, routingKeys, optionFuncs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []confirmation
+	for _, confirm := range confirms {
+		result = append(result, confirm)
+	}
+
+	return result, nil
+}
+
 type SinkPlugin struct {
 	rabbitmqClient *rabbitmq.Conn
-	publisher      *rabbitmq.Publisher
+	publisher      publisher
 	streamSchema   []schema.StreamSchema
 	config         Config
 	logger         *log.Logger
 }
 
 func NewRabbitMqSinkPlugin(config Config, schema []schema.StreamSchema, appCtx *stream_context.Context) sinks.DataSink {
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = defaultPublishTimeout
+	}
+
 	return &SinkPlugin{
 		streamSchema: schema,
 		config:       config,
@@ -35,35 +83,127 @@ func (s *SinkPlugin) Connect(ctx context.Context) error {
 		return err
 	}
 
-	publisher, err := rabbitmq.NewPublisher(
-		client,
+	options := []func(*rabbitmq.PublisherOptions){
 		rabbitmq.WithPublisherOptionsLogging,
 		rabbitmq.WithPublisherOptionsExchangeName(s.config.ExchangeName),
 		rabbitmq.WithPublisherOptionsExchangeDeclare,
-	)
+	}
+
+	if s.config.ExchangeKind != "" {
+		options = append(options, rabbitmq.WithPublisherOptionsExchangeKind(s.config.ExchangeKind))
+	}
+
+	if s.config.DurableExchange {
+		options = append(options, rabbitmq.WithPublisherOptionsExchangeDurable)
+	}
+
+	if s.config.Confirms {
+		options = append(options, rabbitmq.WithPublisherOptionsConfirm)
+	}
+
+	publisher, err := rabbitmq.NewPublisher(client, options...)
 
 	if err != nil {
 		return err
 	}
 
 	s.rabbitmqClient = client
-	s.publisher = publisher
-	return err
+	s.publisher = confirmPublisher{publisher}
+
+	if len(s.config.Queues) == 0 {
+		return nil
+	}
+
+	conn, err := amqp.Dial(s.config.Url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	return s.declareQueues(channel)
+}
+
+// declareQueues declares the durable queues and binds them to the exchange declared by the publisher
+func (s *SinkPlugin) declareQueues(channel queueChannel) error {
+	var err error
+	for _, queue := range s.config.Queues {
+		args := amqp.Table{}
+		if queue.DeadLetterExchange != "" {
+			args["x-dead-letter-exchange"] = queue.DeadLetterExchange
+		}
+
+		if _, err = channel.QueueDeclare(queue.Name, true, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", queue.Name, err)
+		}
+
+		// queues are bound to the default exchange by their names already
+		if s.config.ExchangeName == "" {
+			continue
+		}
+
+		routingKeys := queue.RoutingKeys
+		if len(routingKeys) == 0 {
+			routingKeys = []string{queue.Name}
+		}
+
+		for _, routingKey := range routingKeys {
+			if err = channel.QueueBind(queue.Name, routingKey, s.config.ExchangeName, false, nil); err != nil {
+				return fmt.Errorf("failed to bind queue %s with %s: %w", queue.Name, routingKey, err)
+			}
+		}
+	}
+
+	return nil
 }
 
 func (s *SinkPlugin) Write(message *message.Message) error {
-	var opts func(*rabbitmq.PublishOptions)
+	publishOptions := []func(*rabbitmq.PublishOptions){
+		rabbitmq.WithPublishOptionsContentType("application/json"),
+		rabbitmq.WithPublishOptionsHeaders(rabbitmq.Table{
+			helper.StreamHeader: message.GetStream(),
+			helper.EventHeader:  string(message.GetEvent()),
+		}),
+	}
 
+	// the publisher applies every option, so the default exchange is left without one
 	if s.config.ExchangeName != "" {
-		opts = rabbitmq.WithPublishOptionsExchange(s.config.ExchangeName)
+		publishOptions = append(publishOptions, rabbitmq.WithPublishOptionsExchange(s.config.ExchangeName))
 	}
 
-	return s.publisher.Publish(
-		[]byte(message.AsJSONString()),
-		[]string{s.config.RoutingKey},
-		rabbitmq.WithPublishOptionsContentType("application/json"),
-		opts,
-	)
+	if s.config.Persistent {
+		publishOptions = append(publishOptions, rabbitmq.WithPublishOptionsPersistentDelivery)
+	}
+
+	routingKey := helper.ExpandMessageTemplate(s.config.RoutingKey, message)
+	if !s.config.Confirms {
+		return s.publisher.Publish([]byte(message.AsJSONString()), []string{routingKey}, publishOptions...)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.PublishTimeout)*time.Millisecond)
+	defer cancel()
+
+	confirms, err := s.publisher.PublishWithConfirm(ctx, []byte(message.AsJSONString()), []string{routingKey}, publishOptions...)
+	if err != nil {
+		return err
+	}
+
+	for _, confirm := range confirms {
+		acked, err := confirm.WaitContext(ctx)
+		if err != nil {
+			return fmt.Errorf("message with routing key %s is not confirmed: %w", routingKey, err)
+		}
+		if !acked {
+			return errors.New("message with routing key " + routingKey + " is rejected by the broker")
+		}
+	}
+
+	return nil
 }
 
 func (s *SinkPlugin) GetType() sinks.SinkDriver {
@@ -0,0 +1,167 @@
+package rabbit_mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/wagslane/go-rabbitmq"
+)
+
+type published struct {
+	routingKeys []string
+	options     rabbitmq.PublishOptions
+}
+
+type fakeConfirmation struct {
+	acked bool
+	// pending confirmation waits for the publish timeout
+	pending bool
+}
+
+func (c fakeConfirmation) WaitContext(ctx context.Context) (bool, error) {
+	if c.pending {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+
+	return c.acked, nil
+}
+
+type fakePublisher struct {
+	published     []published
+	confirmations []confirmation
+}
+
+func (p *fakePublisher) Publish(data []byte, routingKeys []string, optionFuncs ...func(*rabbitmq.PublishOptions)) error {
+	options := rabbitmq.PublishOptions{}
+	// the rabbitmq publisher calls every option the same way
+	for _, optionFunc := range optionFuncs {
+		optionFunc(&options)
+	}
+
+	p.published = append(p.published, published{routingKeys: routingKeys, options: options})
+	return nil
+}
+
+func (p *fakePublisher) PublishWithConfirm(ctx context.Context, data []byte, routingKeys []string, optionFuncs ...func(*rabbitmq.PublishOptions)) ([]confirmation, error) {
+	if err := p.Publish(data, routingKeys, optionFuncs...); err != nil {
+		return nil, err
+	}
+
+	return p.confirmations, nil
+}
+
+func (p *fakePublisher) Close() {}
+
+type fakeChannel struct {
+	declared []string
+	bound    []string
+}
+
+func (c *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	c.declared = append(c.declared, fmt.Sprintf("%s durable=%t dlx=%v", name, durable, args["x-dead-letter-exchange"]))
+	return amqp.Queue{Name: name}, nil
+}
+
+func (c *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	c.bound = append(c.bound, fmt.Sprintf("%s %s %s", exchange, key, name))
+	return nil
+}
+
+func newTestPlugin(config Config) (*SinkPlugin, *fakePublisher) {
+	plugin := NewRabbitMqSinkPlugin(config, nil, stream_context.CreateContext(1)).(*SinkPlugin)
+	publisher := &fakePublisher{}
+	plugin.publisher = publisher
+
+	return plugin, publisher
+}
+
+func TestSinkPlugin_Write(t *testing.T) {
+	msg := message.NewMessage(message.Insert, "users", []byte(`[{"id": 1}]`))
+
+	// default exchange is published to without the exchange option
+	plugin, publisher := newTestPlugin(Config{RoutingKey: "cdc.{stream}.{event}"})
+	if err := plugin.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := publisher.published[0]
+	if fmt.Sprint(sent.routingKeys) != "[cdc.users.insert]" || sent.options.Exchange != "" || sent.options.DeliveryMode != 0 {
+		t.Fatalf("unexpected message published %v %+v", sent.routingKeys, sent.options)
+	}
+	if sent.options.Headers[helper.StreamHeader] != "users" || sent.options.Headers[helper.EventHeader] != "insert" {
+		t.Fatalf("message must carry the stream and the event, got %v", sent.options.Headers)
+	}
+
+	plugin, publisher = newTestPlugin(Config{ExchangeName: "cdc", RoutingKey: "{stream}", Persistent: true})
+	if err := plugin.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	sent = publisher.published[0]
+	if fmt.Sprint(sent.routingKeys) != "[users]" || sent.options.Exchange != "cdc" || sent.options.DeliveryMode != rabbitmq.Persistent {
+		t.Fatalf("unexpected message published %v %+v", sent.routingKeys, sent.options)
+	}
+}
+
+func TestSinkPlugin_Confirms(t *testing.T) {
+	msg := message.NewMessage(message.Insert, "users", []byte(`[{"id": 1}]`))
+
+	for _, test := range []struct {
+		confirmation fakeConfirmation
+		err          bool
+	}{
+		{confirmation: fakeConfirmation{acked: true}},
+		{confirmation: fakeConfirmation{acked: false}, err: true},
+		{confirmation: fakeConfirmation{pending: true}, err: true},
+	} {
+		plugin, publisher := newTestPlugin(Config{RoutingKey: "{stream}", Confirms: true, PublishTimeout: 10})
+		publisher.confirmations = []confirmation{test.confirmation}
+
+		err := plugin.Write(msg)
+		if (err != nil) != test.err {
+			t.Fatalf("unexpected error %v for the confirmation %+v", err, test.confirmation)
+		}
+		if test.confirmation.pending && !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unconfirmed message must fail by the timeout, got %v", err)
+		}
+	}
+}
+
+func TestSinkPlugin_DeclareQueues(t *testing.T) {
+	queues := []QueueConfig{
+		{Name: "users", RoutingKeys: []string{"cdc.users.insert", "cdc.users.update"}, DeadLetterExchange: "dlx"},
+		{Name: "orders"},
+	}
+
+	plugin, _ := newTestPlugin(Config{ExchangeName: "cdc", Queues: queues})
+	channel := &fakeChannel{}
+	if err := plugin.declareQueues(channel); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(channel.declared) != "[users durable=true dlx=dlx orders durable=true dlx=<nil>]" {
+		t.Fatalf("unexpected queues declared %v", channel.declared)
+	}
+	if fmt.Sprint(channel.bound) != "[cdc cdc.users.insert users cdc cdc.users.update users cdc orders orders]" {
+		t.Fatalf("unexpected bindings %v", channel.bound)
+	}
+
+	// queues are bound to the default exchange by their names
+	plugin, _ = newTestPlugin(Config{Queues: queues})
+	channel = &fakeChannel{}
+	if err := plugin.declareQueues(channel); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(channel.declared) != 2 || len(channel.bound) != 0 {
+		t.Fatalf("queues must be declared without bindings, got %v %v", channel.declared, channel.bound)
+	}
+}
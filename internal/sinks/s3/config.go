@@ -0,0 +1,21 @@
+package s3
+
+import "github.com/usedatabrew/blink/internal/s3client"
+
+// Config of the s3 sink. Objects are written to <prefix><stream>/date=<YYYY-MM-DD>/
+type Config struct {
+	s3client.Config `yaml:",inline"`
+	// Format is ndjson or parquet. Defaults to ndjson
+	Format string `json:"format" yaml:"format"`
+	// MaxFileSize is the size of the object in bytes it's uploaded at. Defaults to 64MB.
+	// Size of the parquet object grows by the row groups, so it's checked once the row group is written
+	MaxFileSize int `json:"max_file_size" yaml:"max_file_size"`
+	// RollInterval is the max age of the object in seconds it's uploaded at. Defaults to 300.
+	// Messages of the acknowledging sources are acknowledged once their object is uploaded
+	RollInterval int `json:"roll_interval" yaml:"roll_interval"`
+	// UploadRetries is the amount of the upload attempts of the object before the error stops the pipeline.
+	// Failed object is kept and retried with the exponential backoff from 1 second up to 1 minute. Defaults to 8
+	UploadRetries int `json:"upload_retries" yaml:"upload_retries"`
+	// RowGroupSize is the max amount of rows in the parquet row group. Defaults to 10000
+	RowGroupSize int `json:"row_group_size" yaml:"row_group_size"`
+}
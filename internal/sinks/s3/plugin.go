@@ -0,0 +1,370 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/usedatabrew/blink/internal/fileformat"
+	"github.com/usedatabrew/blink/internal/s3client"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sinks"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	defaultMaxFileSize   = 64 * 1024 * 1024
+	defaultRollInterval  = 300
+	rollCheckInterval    = time.Second
+	defaultUploadRetries = 8
+	minUploadBackoff     = time.Second
+	maxUploadBackoff     = time.Minute
+)
+
+// object is the file of the stream buffered in memory until it's rolled
+type object struct {
+	key     string
+	date    string
+	schema  *arrow.Schema
+	buffer  *bytes.Buffer
+	encoder fileformat.Encoder
+	opened  time.Time
+	// first is the number of the first message written to the object
+	first int64
+}
+
+// upload is the rolled object waiting for the upload
+type upload struct {
+	key      string
+	body     []byte
+	first    int64
+	attempts int
+	// retryAt is the time of the next attempt after the failed one
+	retryAt time.Time
+}
+
+// SinkPlugin writes the rows of every stream to the objects partitioned by the stream and the date.
+// Object is uploaded once it reaches the max size or age, the objects failed to upload are retried
+type SinkPlugin struct {
+	config  Config
+	format  fileformat.Format
+	client  s3iface.S3API
+	schemas map[string]*arrow.Schema
+	objects map[string]*object
+	pending []upload
+	// written is the amount of the messages written since the sink is created
+	written int64
+	mutex   sync.Mutex
+	now     func() time.Time
+	done    chan struct{}
+	stopped sync.WaitGroup
+	logger  *log.Logger
+}
+
+func NewS3SinkPlugin(config Config, schema []schema.StreamSchema, appCtx *stream_context.Context) sinks.DataSink {
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = defaultMaxFileSize
+	}
+
+	if config.RollInterval <= 0 {
+		config.RollInterval = defaultRollInterval
+	}
+
+	if config.UploadRetries <= 0 {
+		config.UploadRetries = defaultUploadRetries
+	}
+
+	plugin := &SinkPlugin{
+		config:  config,
+		objects: map[string]*object{},
+		now:     time.Now,
+		done:    make(chan struct{}),
+		logger:  appCtx.Logger.WithPrefix("[sink]: s3"),
+	}
+	plugin.SetExpectedSchema(schema)
+
+	return plugin
+}
+
+func (s *SinkPlugin) Connect(ctx context.Context) error {
+	s.format = fileformat.NDJSON
+	if s.config.Format != "" {
+		format, err := fileformat.ParseFormat(s.config.Format, "")
+		if err != nil {
+			return err
+		}
+		if format != fileformat.NDJSON && format != fileformat.Parquet {
+			return fmt.Errorf("unsupported format %s of the s3 sink", format)
+		}
+		s.format = format
+	}
+
+	if s.client == nil {
+		client, err := s3client.NewClient(s.config.Config)
+		if err != nil {
+			return err
+		}
+		s.client = client
+	}
+
+	if _, err := s.client.HeadBucketWithContext(ctx, &awss3.HeadBucketInput{Bucket: aws.String(s.config.Bucket)}); err != nil {
+		return fmt.Errorf("failed to access bucket %s: %w", s.config.Bucket, err)
+	}
+
+	s.stopped.Add(1)
+	go s.rollExpired()
+
+	return nil
+}
+
+// Write buffers the row in the object of the stream. Objects failed to upload are kept and retried,
+// the error is returned only once the retries of the object are exhausted
+func (s *SinkPlugin) Write(msg *message.Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stream := msg.GetStream()
+	now := s.now().UTC()
+	date := now.Format("2006-01-02")
+
+	current, ok := s.objects[stream]
+	if ok && current.date != date {
+		if err := s.roll(stream); err != nil {
+			s.logger.Error("Failed to upload object", "err", err)
+		}
+		current = nil
+	}
+
+	if current == nil {
+		var err error
+		if current, err = s.open(stream, now); err != nil {
+			return err
+		}
+		s.objects[stream] = current
+	}
+
+	s.written += 1
+	if current.first == 0 {
+		current.first = s.written
+	}
+
+	if err := current.encoder.Write(msg); err != nil {
+		return err
+	}
+
+	if current.buffer.Len() >= s.config.MaxFileSize {
+		return s.roll(stream)
+	}
+
+	return nil
+}
+
+// Buffered returns the amount of the last written messages that are not uploaded yet.
+// Objects are uploaded in the order they are rolled, not written, so the messages after
+// the first one of the oldest buffered object are treated as not uploaded
+func (s *SinkPlugin) Buffered() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	durable := s.written
+	for _, current := range s.objects {
+		if current.first > 0 && current.first-1 < durable {
+			durable = current.first - 1
+		}
+	}
+	for _, next := range s.pending {
+		if next.first-1 < durable {
+			durable = next.first - 1
+		}
+	}
+
+	return s.written - durable
+}
+
+func (s *SinkPlugin) GetType() sinks.SinkDriver {
+	return sinks.S3SinkType
+}
+
+// SetExpectedSchema sets the schema of the new objects. Objects of the changed streams are rolled,
+// so every object has a single schema
+func (s *SinkPlugin) SetExpectedSchema(schema []schema.StreamSchema) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.schemas = map[string]*arrow.Schema{}
+	for _, streamSchema := range schema {
+		s.schemas[streamSchema.StreamName] = streamSchema.AsArrow()
+	}
+
+	for stream, current := range s.objects {
+		if updated, ok := s.schemas[stream]; !ok || !updated.Equal(current.schema) {
+			if err := s.roll(stream); err != nil {
+				s.logger.Error("Failed to upload object", "err", err)
+			}
+		}
+	}
+}
+
+// Stop uploads the buffered objects. Pending objects are uploaded without waiting for the backoff
+func (s *SinkPlugin) Stop() {
+	close(s.done)
+	s.stopped.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for stream := range s.objects {
+		if err := s.complete(stream); err != nil {
+			s.logger.Error("Failed to complete object", "err", err)
+		}
+	}
+
+	for idx := range s.pending {
+		s.pending[idx].retryAt = time.Time{}
+	}
+	if err := s.uploadPending(); err != nil {
+		s.logger.Error("Failed to upload object", "err", err)
+	}
+
+	if len(s.pending) > 0 {
+		s.logger.Error("Objects are not uploaded", "objects", len(s.pending))
+	}
+}
+
+func (s *SinkPlugin) open(stream string, now time.Time) (*object, error) {
+	streamSchema, ok := s.schemas[stream]
+	if !ok {
+		return nil, fmt.Errorf("no schema found for stream %s", stream)
+	}
+
+	date := now.Format("2006-01-02")
+	current := &object{
+		key:    fmt.Sprintf("%s%s/date=%s/%s-%s.%s", s.config.Prefix, stream, date, now.Format("20060102T150405.000000000Z"), uuid.NewString(), s.format),
+		date:   date,
+		schema: streamSchema,
+		buffer: &bytes.Buffer{},
+		opened: now,
+	}
+
+	var err error
+	current.encoder, err = fileformat.NewEncoder(s.format, current.buffer, streamSchema, fileformat.EncoderOptions{RowGroupSize: s.config.RowGroupSize})
+	return current, err
+}
+
+// roll completes the object of the stream and uploads it together with the pending ones
+func (s *SinkPlugin) roll(stream string) error {
+	if err := s.complete(stream); err != nil {
+		return err
+	}
+
+	return s.uploadPending()
+}
+
+// complete closes the object of the stream and queues it for the upload
+func (s *SinkPlugin) complete(stream string) error {
+	current := s.objects[stream]
+	delete(s.objects, stream)
+
+	if err := current.encoder.Close(); err != nil {
+		return fmt.Errorf("failed to complete object %s: %w", current.key, err)
+	}
+
+	s.pending = append(s.pending, upload{key: current.key, body: current.buffer.Bytes(), first: current.first})
+	return nil
+}
+
+// uploadPending uploads the pending objects in order. Failed object is retried after the backoff,
+// the objects after it wait, so they are uploaded in the order they were rolled
+func (s *SinkPlugin) uploadPending() error {
+	for len(s.pending) > 0 {
+		next := &s.pending[0]
+		now := s.now()
+		if now.Before(next.retryAt) {
+			return nil
+		}
+
+		_, err := s.client.PutObjectWithContext(context.Background(), &awss3.PutObjectInput{
+			Bucket:      aws.String(s.config.Bucket),
+			Key:         aws.String(next.key),
+			Body:        bytes.NewReader(next.body),
+			ContentType: aws.String(contentType(s.format)),
+		})
+		if err != nil {
+			next.attempts += 1
+			if next.attempts >= s.config.UploadRetries {
+				return fmt.Errorf("failed to upload object %s after %d attempts: %w", next.key, next.attempts, err)
+			}
+
+			backoff := uploadBackoff(next.attempts)
+			next.retryAt = now.Add(backoff)
+			s.logger.Warn("Failed to upload object, retrying", "key", next.key, "attempt", next.attempts, "retry_in", backoff, "err", err)
+			return nil
+		}
+
+		s.logger.Info("Uploaded object", "key", next.key, "size", len(next.body))
+		s.pending = s.pending[1:]
+	}
+
+	return nil
+}
+
+// rollExpired uploads the objects reaching the roll interval and retries the failed uploads
+func (s *SinkPlugin) rollExpired() {
+	defer s.stopped.Done()
+
+	ticker := time.NewTicker(rollCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		s.mutex.Lock()
+		expired := s.now().UTC().Add(-time.Duration(s.config.RollInterval) * time.Second)
+		for stream, current := range s.objects {
+			if current.opened.Before(expired) {
+				if err := s.roll(stream); err != nil {
+					s.logger.Error("Failed to upload object", "err", err)
+				}
+			}
+		}
+
+		if err := s.uploadPending(); err != nil {
+			s.logger.Error("Failed to upload object", "err", err)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// uploadBackoff doubles the delay after every failed attempt
+func uploadBackoff(attempts int) time.Duration {
+	backoff := minUploadBackoff
+	for i := 1; i < attempts && backoff < maxUploadBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxUploadBackoff {
+		return maxUploadBackoff
+	}
+	return backoff
+}
+
+func contentType(format fileformat.Format) string {
+	if format == fileformat.Parquet {
+		return "application/vnd.apache.parquet"
+	}
+
+	return "application/x-ndjson"
+}
@@ -0,0 +1,185 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/usedatabrew/blink/internal/s3client"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String", Nullable: true},
+		},
+	},
+}
+
+// fakeClient stores the uploaded objects in memory
+type fakeClient struct {
+	s3iface.S3API
+	objects map[string]string
+	failing bool
+}
+
+func (c *fakeClient) HeadBucketWithContext(aws.Context, *awss3.HeadBucketInput, ...request.Option) (*awss3.HeadBucketOutput, error) {
+	return &awss3.HeadBucketOutput{}, nil
+}
+
+func (c *fakeClient) PutObjectWithContext(_ aws.Context, input *awss3.PutObjectInput, _ ...request.Option) (*awss3.PutObjectOutput, error) {
+	if c.failing {
+		return nil, errors.New("storage is unavailable")
+	}
+
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.objects[aws.StringValue(input.Key)] = string(body)
+	return &awss3.PutObjectOutput{}, nil
+}
+
+// uploaded returns the content of the uploaded objects in the key order
+func (c *fakeClient) uploaded() []string {
+	var keys []string
+	for key := range c.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var contents []string
+	for _, key := range keys {
+		contents = append(contents, strings.TrimSpace(c.objects[key]))
+	}
+
+	return contents
+}
+
+func newTestPlugin(t *testing.T, client *fakeClient, config Config) *SinkPlugin {
+	config.Config = s3client.Config{Bucket: "data", Prefix: "exports/"}
+	plugin := NewS3SinkPlugin(config, testStreamSchema, stream_context.CreateContext(1)).(*SinkPlugin)
+	plugin.client = client
+	if err := plugin.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func row(data string) *message.Message {
+	return message.NewMessage(message.Insert, "users", []byte(data))
+}
+
+func TestSinkPlugin_Roll(t *testing.T) {
+	client := &fakeClient{objects: map[string]string{}}
+	plugin := newTestPlugin(t, client, Config{MaxFileSize: 40})
+
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	plugin.now = func() time.Time { return now }
+
+	for _, data := range []string{`[{"id": 1, "name": "a"}]`, `[{"id": 2}]`, `[{"id": 3}]`} {
+		if err := plugin.Write(row(data)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	if len(client.objects) != 1 {
+		t.Fatalf("object must be uploaded once it reaches the max size, got %v", client.objects)
+	}
+	if buffered := plugin.Buffered(); buffered != 1 {
+		t.Fatalf("only the message of the open object must be buffered, got %d", buffered)
+	}
+
+	// object of the previous date is uploaded once the date changes
+	now = now.Add(time.Minute)
+	if err := plugin.Write(row(`[{"id": 4}]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	plugin.Stop()
+
+	var keys []string
+	for key := range client.objects {
+		keys = append(keys, key[:len("exports/users/date=2024-05-01/")])
+	}
+	sort.Strings(keys)
+
+	expectedKeys := "exports/users/date=2024-05-01/|exports/users/date=2024-05-01/|exports/users/date=2024-05-02/"
+	if strings.Join(keys, "|") != expectedKeys {
+		t.Fatalf("objects must be partitioned by the stream and the date, got %v", keys)
+	}
+
+	expected := []string{
+		"{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":null}",
+		`{"id":3,"name":null}`,
+		`{"id":4,"name":null}`,
+	}
+	if uploaded := client.uploaded(); strings.Join(uploaded, "|") != strings.Join(expected, "|") {
+		t.Fatalf("unexpected objects %v", uploaded)
+	}
+}
+
+func TestSinkPlugin_RetryUpload(t *testing.T) {
+	client := &fakeClient{objects: map[string]string{}, failing: true}
+	plugin := newTestPlugin(t, client, Config{MaxFileSize: 1, Format: "parquet", UploadRetries: 3})
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	plugin.now = func() time.Time { return now }
+
+	// failed object is kept and retried after the backoff
+	if err := plugin.Write(row(`[{"id": 1}]`)); err != nil {
+		t.Fatalf("failed upload must be retried, got %s", err)
+	}
+	if err := plugin.Write(row(`[{"id": 2}]`)); err != nil || plugin.pending[0].attempts != 1 {
+		t.Fatal("upload must not be retried before the backoff")
+	}
+	if buffered := plugin.Buffered(); buffered != 2 {
+		t.Fatalf("messages of the objects waiting for the upload must be buffered, got %d", buffered)
+	}
+
+	now = now.Add(time.Second)
+	if err := plugin.Write(row(`[{"id": 3}]`)); err != nil || plugin.pending[0].attempts != 2 {
+		t.Fatal("upload must be retried after the backoff")
+	}
+
+	now = now.Add(2 * time.Second)
+	if err := plugin.Write(row(`[{"id": 4}]`)); err == nil {
+		t.Fatal("upload error must be reported once the retries are exhausted")
+	}
+
+	client.failing = false
+	plugin.Stop()
+
+	if len(client.objects) != 4 {
+		t.Fatalf("failed objects must be uploaded with the next ones, got %d objects", len(client.objects))
+	}
+
+	for key, content := range client.objects {
+		if !strings.HasSuffix(key, ".parquet") || !bytes.HasPrefix([]byte(content), []byte("PAR1")) {
+			t.Fatalf("unexpected parquet object %s", key)
+		}
+	}
+}
+
+func TestUploadBackoff(t *testing.T) {
+	if uploadBackoff(1) != time.Second || uploadBackoff(3) != 4*time.Second || uploadBackoff(20) != time.Minute {
+		t.Fatal("backoff must double up to the max")
+	}
+}
@@ -2,6 +2,7 @@ package sinks
 
 import (
 	"context"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/message"
 )
@@ -13,3 +14,24 @@ type DataSink interface {
 	Write(m *message.Message) error
 	Stop()
 }
+
+// ControlEventSink is implemented by the sinks that can apply the control events
+// emitted by the sources, e.g. truncate the target table or add the new column
+type ControlEventSink interface {
+	ApplyControlEvent(m *message.Message) error
+}
+
+// MetadataSink is implemented by the sinks using the metadata attached by the source,
+// e.g. the backfill id. Sink wrapper removes the metadata from the message and passes it along,
+// other sinks receive the message without it
+type MetadataSink interface {
+	WriteWithMetadata(m *message.Message, metadata helper.Metadata) error
+}
+
+// BufferedSink is implemented by the sinks keeping the written messages in the buffers completed later,
+// e.g. in the files or the objects uploaded once they are rolled. Buffered returns the amount
+// of the last written messages that are not durable yet, so the stream acknowledges
+// the source messages only once the sink stores them
+type BufferedSink interface {
+	Buffered() int64
+}
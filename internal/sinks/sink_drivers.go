@@ -12,4 +12,6 @@ const (
 	RabbitMqSinkType  SinkDriver = "rabbitmq"
 	RedisSinkType     SinkDriver = "redis"
 	ClickHouse        SinkDriver = "clickhouse"
+	S3SinkType        SinkDriver = "s3"
+	FileSinkType      SinkDriver = "file"
 )
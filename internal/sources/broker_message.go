@@ -0,0 +1,79 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/usedatabrew/blink/internal/fileformat"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/message"
+)
+
+// BrokerMessageBuilder builds the messages of the payloads received from the message brokers
+// and validates them against the stream schema
+type BrokerMessageBuilder struct {
+	streams  []string
+	builders map[string]*fileformat.MessageBuilder
+}
+
+func NewBrokerMessageBuilder(streamSchema []schema.StreamSchema) *BrokerMessageBuilder {
+	builder := &BrokerMessageBuilder{builders: map[string]*fileformat.MessageBuilder{}}
+	for stream, outputSchema := range BuildOutputSchema(streamSchema) {
+		builder.builders[stream] = fileformat.NewMessageBuilder(stream, outputSchema)
+	}
+	for _, collection := range streamSchema {
+		builder.streams = append(builder.streams, collection.StreamName)
+	}
+
+	return builder
+}
+
+// Build binds the payload to the stream named by the stream header, the stream named by the tokens
+// of the dot separated topic like the subject or the routing key, or the first stream of the schema.
+// The payload is the JSON object or the array of the single one as written by the broker sinks.
+// The event is taken from the event header and defaults to insert
+func (b *BrokerMessageBuilder) Build(streamHeader, eventHeader, topic string, payload []byte) (*message.Message, error) {
+	if len(b.streams) == 0 {
+		return nil, fmt.Errorf("no stream found for topic %s", topic)
+	}
+
+	row := bytes.TrimSpace(payload)
+	if len(row) > 0 && row[0] == '[' {
+		var rows []json.RawMessage
+		if err := json.Unmarshal(row, &rows); err != nil {
+			return nil, err
+		}
+		if len(rows) != 1 {
+			return nil, fmt.Errorf("payload must have a single row, got %d", len(rows))
+		}
+		row = rows[0]
+	}
+
+	msg, err := b.builders[b.bindStream(streamHeader, topic)].Build(row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch event := message.Event(eventHeader); event {
+	case message.Insert, message.Update, message.Delete, message.Snapshot:
+		msg.SetEvent(event)
+	}
+
+	return msg, nil
+}
+
+func (b *BrokerMessageBuilder) bindStream(header, topic string) string {
+	if _, ok := b.builders[header]; ok {
+		return header
+	}
+
+	for _, stream := range b.streams {
+		if strings.Contains("."+topic+".", "."+stream+".") {
+			return stream
+		}
+	}
+
+	return b.streams[0]
+}
@@ -0,0 +1,54 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/message"
+)
+
+// Control events are emitted by the sources along with the row changes.
+// They are not written as rows, but applied by the pipeline and the sinks
+const (
+	TruncateEvent     message.Event = "truncate"
+	SchemaChangeEvent message.Event = "schema_change"
+)
+
+// SchemaChange describes the columns added to the stream upstream
+type SchemaChange struct {
+	Columns []schema.Column `json:"columns"`
+}
+
+func IsControlEvent(event message.Event) bool {
+	return event == TruncateEvent || event == SchemaChangeEvent
+}
+
+// NewTruncateMessage builds the control message signaling that all rows of the stream were removed
+func NewTruncateMessage(stream string) *message.Message {
+	return message.NewMessage(TruncateEvent, stream, []byte(`[{}]`))
+}
+
+// NewSchemaChangeMessage builds the control message signaling that the columns were added to the stream
+func NewSchemaChangeMessage(stream string, change SchemaChange) (*message.Message, error) {
+	encoded, err := json.Marshal([]SchemaChange{change})
+	if err != nil {
+		return nil, err
+	}
+
+	return message.NewMessage(SchemaChangeEvent, stream, encoded), nil
+}
+
+// ReadSchemaChange decodes the schema change from the control message
+func ReadSchemaChange(msg *message.Message) (SchemaChange, error) {
+	var changes []SchemaChange
+	if err := json.Unmarshal([]byte(msg.AsJSONString()), &changes); err != nil {
+		return SchemaChange{}, err
+	}
+
+	if len(changes) == 0 {
+		return SchemaChange{}, errors.New("schema change message is empty")
+	}
+
+	return changes[0], nil
+}
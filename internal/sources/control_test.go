@@ -0,0 +1,33 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/schema"
+)
+
+func TestSchemaChangeMessage(t *testing.T) {
+	msg, err := NewSchemaChangeMessage("users", SchemaChange{Columns: []schema.Column{
+		{Name: "email", DatabrewType: "String", NativeConnectorType: "text", Nullable: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsControlEvent(msg.GetEvent()) {
+		t.Fatal("schema change must be a control event")
+	}
+
+	change, err := ReadSchemaChange(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(change.Columns) != 1 || change.Columns[0].Name != "email" || !change.Columns[0].Nullable {
+		t.Fatalf("unexpected schema change %+v", change)
+	}
+
+	if IsControlEvent(NewTruncateMessage("users").GetEvent()) == false {
+		t.Fatal("truncate must be a control event")
+	}
+}
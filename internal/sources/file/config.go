@@ -0,0 +1,18 @@
+package file
+
+// Config of the file source. Files are read to the first stream of the stream schema
+type Config struct {
+	// Path is the file, the glob or the directory to read the files of
+	Path string `json:"path" yaml:"path"`
+	// Format is ndjson, csv or parquet. It's detected by the file extension when empty
+	Format string `json:"format" yaml:"format"`
+	// Watch keeps listing the glob or the directory for the new files
+	Watch bool `json:"watch" yaml:"watch"`
+	// Tail keeps reading the lines appended to the files, so they are never completed.
+	// Parquet files are read once, as they can't be appended
+	Tail bool `json:"tail" yaml:"tail"`
+	// PollInterval is the interval of checking the new files and lines in milliseconds. Defaults to 1000
+	PollInterval int `json:"poll_interval" yaml:"poll_interval"`
+	// Delimiter of the CSV values. Defaults to comma
+	Delimiter string `json:"delimiter" yaml:"delimiter"`
+}
@@ -0,0 +1,348 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/fileformat"
+	"github.com/usedatabrew/blink/internal/offset_storage"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+)
+
+const (
+	defaultPollInterval = 1000
+	// offsetStoreInterval is the amount of rows read between the offset writes
+	offsetStoreInterval = 1000
+)
+
+// SourcePlugin reads the rows of the files. The offset of every file is stored,
+// so the restarted source skips the rows already read
+type SourcePlugin struct {
+	ctx           context.Context
+	appCtx        *stream_context.Context
+	config        Config
+	stream        string
+	outputSchema  *arrow.Schema
+	offsetStorage offset_storage.OffsetStorage
+	logger        *log.Logger
+	delimiter     rune
+	// completed are the files read to the end that can't grow
+	completed     map[string]bool
+	messageStream chan sources.MessageEvent
+}
+
+func NewFileSourcePlugin(appCtx *stream_context.Context, config Config, schema []schema.StreamSchema) sources.DataSource {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	plugin := &SourcePlugin{
+		appCtx:        appCtx,
+		config:        config,
+		logger:        appCtx.Logger.WithPrefix("[source]: file"),
+		completed:     map[string]bool{},
+		messageStream: make(chan sources.MessageEvent),
+	}
+
+	// the rows are read into the first stream, missing stream is reported on connect
+	if len(schema) > 0 {
+		plugin.stream = schema[0].StreamName
+		plugin.outputSchema = sources.BuildOutputSchema(schema)[schema[0].StreamName]
+	}
+
+	return plugin
+}
+
+func (p *SourcePlugin) Connect(ctx context.Context) error {
+	p.ctx = ctx
+
+	if p.config.Path == "" {
+		return errors.New("path is required for the file source")
+	}
+
+	if p.stream == "" {
+		return errors.New("stream_schema has to define the stream the file rows are read into")
+	}
+
+	if p.config.Format != "" {
+		if _, err := fileformat.ParseFormat(p.config.Format, p.config.Path); err != nil {
+			return err
+		}
+	}
+
+	if p.config.Delimiter != "" {
+		delimiter := []rune(p.config.Delimiter)
+		if len(delimiter) != 1 {
+			return fmt.Errorf("delimiter must be a single character, got %q", p.config.Delimiter)
+		}
+		p.delimiter = delimiter[0]
+	}
+
+	p.offsetStorage = p.appCtx.OffsetStorage()
+	if p.offsetStorage == nil {
+		p.logger.Warn("No offset storage configured. Files are read from the beginning on restart")
+		p.offsetStorage = offset_storage.NewStorageInMem()
+	}
+
+	files, err := p.listFiles()
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 && !p.config.Watch {
+		return fmt.Errorf("no files found at %s", p.config.Path)
+	}
+
+	return nil
+}
+
+func (p *SourcePlugin) Start() {
+	go p.run()
+}
+
+func (p *SourcePlugin) Events() chan sources.MessageEvent {
+	return p.messageStream
+}
+
+func (p *SourcePlugin) Stop() {}
+
+func (p *SourcePlugin) run() {
+	files, err := p.listFiles()
+	if err != nil {
+		p.logger.Fatalf("Failed to list files: %s", err.Error())
+	}
+
+	for {
+		for _, path := range files {
+			if err = p.readFile(path); err != nil {
+				p.emitError(fmt.Errorf("failed to read file %s: %w", path, err))
+			}
+		}
+
+		if !p.config.Watch && !p.config.Tail {
+			p.logger.Info("All files are read", "files", len(files))
+			return
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(time.Duration(p.config.PollInterval) * time.Millisecond):
+		}
+
+		if p.config.Watch {
+			if files, err = p.listFiles(); err != nil {
+				p.emitError(fmt.Errorf("failed to list files: %w", err))
+			}
+		}
+	}
+}
+
+// listFiles returns the files of the directory or the files matching the path in the name order.
+// Hidden and temporary files are skipped, as they are still being written
+func (p *SourcePlugin) listFiles() ([]string, error) {
+	pattern := p.config.Path
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		pattern = filepath.Join(pattern, "*")
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, match := range matches {
+		name := filepath.Base(match)
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+
+		if info, err := os.Stat(match); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		files = append(files, match)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func (p *SourcePlugin) readFile(path string) error {
+	if p.completed[path] {
+		return nil
+	}
+
+	format, err := fileformat.ParseFormat(p.config.Format, path)
+	if err != nil {
+		return err
+	}
+
+	offset, err := p.loadOffset(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if format == fileformat.Parquet {
+		decoder, err := fileformat.NewParquetDecoder(p.ctx, f, offset)
+		if err != nil {
+			return err
+		}
+
+		if err = p.readRows(path, decoder, offset); err != nil {
+			return err
+		}
+
+		p.completed[path] = true
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() < offset {
+		p.logger.Warn("File is truncated. Reading it from the beginning", "file", path)
+		offset = 0
+	}
+
+	// the last line may still be written in tail mode, so only the complete lines are read
+	end := info.Size()
+	if p.config.Tail {
+		if end, err = lastLineEnd(f, offset, end); err != nil {
+			return err
+		}
+	}
+
+	if end > offset {
+		var decoder fileformat.Decoder
+		if format == fileformat.CSV {
+			header, headerEnd, err := fileformat.ReadCSVHeader(io.NewSectionReader(f, 0, end), p.delimiter)
+			if err != nil {
+				return fmt.Errorf("failed to read CSV header: %w", err)
+			}
+
+			if offset < headerEnd {
+				offset = headerEnd
+			}
+			decoder = fileformat.NewCSVDecoder(io.NewSectionReader(f, offset, end-offset), offset, header, p.outputSchema, p.delimiter)
+		} else {
+			decoder = fileformat.NewNDJSONDecoder(io.NewSectionReader(f, offset, end-offset), offset)
+		}
+
+		if err = p.readRows(path, decoder, offset); err != nil {
+			return err
+		}
+	}
+
+	if !p.config.Tail {
+		p.completed[path] = true
+	}
+
+	return nil
+}
+
+// readRows emits the rows of the file. Malformed rows are reported and skipped
+func (p *SourcePlugin) readRows(path string, decoder fileformat.Decoder, offset int64) error {
+	builder := fileformat.NewMessageBuilder(p.stream, p.outputSchema)
+	var read, processed = 0, 0
+	for {
+		row, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+
+		var rowErr *fileformat.RowError
+		if errors.As(err, &rowErr) {
+			p.emitError(fmt.Errorf("file %s: %w", path, err))
+			offset = rowErr.Offset
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		msg, err := builder.Build(row.Data)
+		if err != nil {
+			p.emitError(fmt.Errorf("file %s: row ending at %d: %w", path, row.Offset, err))
+		} else {
+			p.messageStream <- sources.MessageEvent{Message: msg, Err: nil}
+			read += 1
+		}
+
+		offset = row.Offset
+		processed += 1
+		if processed%offsetStoreInterval == 0 {
+			if err = p.storeOffset(path, offset); err != nil {
+				return err
+			}
+		}
+	}
+
+	if read > 0 {
+		p.logger.Info("Read rows", "file", path, "rows", read)
+	}
+
+	return p.storeOffset(path, offset)
+}
+
+func (p *SourcePlugin) loadOffset(path string) (int64, error) {
+	stored, err := p.offsetStorage.GetCursorByPipelineStream(offset_storage.BuildFileKey(p.appCtx.PipelineId(), p.stream, path))
+	if err != nil || stored == "" {
+		return 0, err
+	}
+
+	return strconv.ParseInt(stored, 10, 64)
+}
+
+func (p *SourcePlugin) storeOffset(path string, offset int64) error {
+	return p.offsetStorage.SetCursorForPipeline(offset_storage.BuildFileKey(p.appCtx.PipelineId(), p.stream, path), strconv.FormatInt(offset, 10))
+}
+
+func (p *SourcePlugin) emitError(err error) {
+	p.messageStream <- sources.MessageEvent{Message: nil, Err: err}
+}
+
+// lastLineEnd returns the offset after the last newline between the offset and the end of the file
+func lastLineEnd(f *os.File, offset, end int64) (int64, error) {
+	buffer := make([]byte, 64*1024)
+	for blockEnd := end; blockEnd > offset; {
+		blockStart := blockEnd - int64(len(buffer))
+		if blockStart < offset {
+			blockStart = offset
+		}
+
+		block := buffer[:blockEnd-blockStart]
+		if _, err := f.ReadAt(block, blockStart); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		if idx := bytes.LastIndexByte(block, '\n'); idx != -1 {
+			return blockStart + int64(idx) + 1, nil
+		}
+		blockEnd = blockStart
+	}
+
+	return offset, nil
+}
@@ -0,0 +1,137 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/offset_storage"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String", Nullable: true},
+		},
+	},
+}
+
+func newTestPlugin(t *testing.T, appCtx *stream_context.Context, config Config) *SourcePlugin {
+	plugin := NewFileSourcePlugin(appCtx, config, testStreamSchema).(*SourcePlugin)
+	plugin.messageStream = make(chan sources.MessageEvent, 100)
+	if err := plugin.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return plugin
+}
+
+func received(plugin *SourcePlugin) []string {
+	var messages []string
+	for {
+		select {
+		case event := <-plugin.messageStream:
+			if event.Err != nil {
+				messages = append(messages, "error")
+			} else {
+				messages = append(messages, event.Message.AsJSONString())
+			}
+		default:
+			return messages
+		}
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourcePlugin_Tail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.ndjson")
+	appendFile(t, path, "{\"id\": 1}\n{\"id\": 2")
+
+	appCtx := stream_context.CreateContext(1)
+	appCtx.SetOffsetStorage(offset_storage.NewStorageInMem())
+	plugin := newTestPlugin(t, appCtx, Config{Path: path, Tail: true})
+
+	if err := plugin.readFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if messages := received(plugin); fmt.Sprint(messages) != `[[{"id":1,"name":null}]]` {
+		t.Fatalf("only the complete lines must be read, got %v", messages)
+	}
+
+	appendFile(t, path, ", \"name\": \"b\"}\n")
+	if err := plugin.readFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if messages := received(plugin); fmt.Sprint(messages) != `[[{"id":2,"name":"b"}]]` {
+		t.Fatalf("appended line must be read, got %v", messages)
+	}
+
+	// restarted source resumes from the stored offset
+	appendFile(t, path, "{\"id\": 3}\n")
+	plugin = newTestPlugin(t, appCtx, Config{Path: path, Tail: true})
+	if err := plugin.readFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if messages := received(plugin); fmt.Sprint(messages) != `[[{"id":3,"name":null}]]` {
+		t.Fatalf("only the new lines must be read after restart, got %v", messages)
+	}
+}
+
+func TestSourcePlugin_Directory(t *testing.T) {
+	dir := t.TempDir()
+	appendFile(t, filepath.Join(dir, "b.csv"), "id,name\n2,b\nx,broken\n")
+	appendFile(t, filepath.Join(dir, "a.csv"), "name,id\na,1\n")
+	appendFile(t, filepath.Join(dir, "c.csv.tmp"), "id\n3\n")
+	appendFile(t, filepath.Join(dir, ".d.csv"), "id\n4\n")
+
+	appCtx := stream_context.CreateContext(1)
+	plugin := newTestPlugin(t, appCtx, Config{Path: dir})
+
+	files, err := plugin.listFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "a.csv" {
+		t.Fatalf("temporary and hidden files must be skipped, got %v", files)
+	}
+
+	plugin.run()
+	expected := `[[{"id":1,"name":"a"}] [{"id":2,"name":"b"}] error]`
+	if messages := received(plugin); fmt.Sprint(messages) != expected {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+
+	// completed files are not read again
+	appendFile(t, files[0], "c,3\n")
+	if err = plugin.readFile(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if messages := received(plugin); len(messages) != 0 {
+		t.Fatalf("completed file must not be read again, got %v", messages)
+	}
+}
+
+func TestSourcePlugin_NoStream(t *testing.T) {
+	plugin := NewFileSourcePlugin(stream_context.CreateContext(1), Config{Path: "users.csv"}, nil)
+	if err := plugin.Connect(context.Background()); err == nil {
+		t.Fatal("source without the stream must be rejected")
+	}
+}
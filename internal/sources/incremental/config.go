@@ -0,0 +1,26 @@
+package incremental
+
+const (
+	defaultBatchSize    = 10000
+	defaultPollInterval = 5
+)
+
+// Config holds the sync settings shared by the incremental sources.
+// Drivers embed it inline next to their connection settings
+type Config struct {
+	StreamSnapshot bool `json:"stream_snapshot" yaml:"stream_snapshot"`
+	// CursorColumn is a monotonically increasing column the rows are synced by,
+	// e.g. updated_at timestamp, bigint sequence or UUIDv7. Primary key is used by default
+	// and as a tie-breaker for the rows sharing the cursor value
+	CursorColumn string `json:"cursor_column" yaml:"cursor_column"`
+	// CursorColumns overrides the cursor column for the streams
+	CursorColumns map[string]string `json:"cursor_columns" yaml:"cursor_columns"`
+	// CursorLookback in seconds re-reads the rows of the timestamp cursors committed
+	// late with the earlier timestamps. Rows already synced within the window are stored with the cursor
+	// and skipped, also after restart. The cursor is stored once the stream is synced,
+	// so the rows emitted by the interrupted sync are emitted again
+	CursorLookback int `json:"cursor_lookback" yaml:"cursor_lookback"`
+	BatchSize      int `json:"batch_size" yaml:"batch_size"`
+	// PollInterval in seconds
+	PollInterval int `json:"poll_interval" yaml:"poll_interval"`
+}
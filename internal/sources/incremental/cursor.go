@@ -0,0 +1,130 @@
+package incremental
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// cursorState is the position of the last synced row: the value of the cursor column
+// and the primary key used as a tie-breaker for the rows sharing the cursor value.
+// Values are kept as text, so any column type can be stored and passed back to the query.
+// Rows synced within the lookback window are stored with the cursor, so they are not emitted again after restart
+type cursorState struct {
+	Cursor string    `json:"cursor"`
+	Pk     string    `json:"pk"`
+	Seen   []seenRow `json:"seen,omitempty"`
+}
+
+// seenRow is the position of the row synced within the lookback window
+type seenRow struct {
+	Cursor string `json:"cursor"`
+	Pk     string `json:"pk"`
+}
+
+func (c cursorState) IsEmpty() bool {
+	return c.Cursor == ""
+}
+
+func (c cursorState) Encode() string {
+	encoded, _ := json.Marshal(c)
+	return string(encoded)
+}
+
+func decodeCursorState(encoded string) (cursorState, error) {
+	var state cursorState
+	if encoded == "" {
+		return state, nil
+	}
+
+	err := json.Unmarshal([]byte(encoded), &state)
+	return state, err
+}
+
+// cursorText converts the value returned by the driver to the text accepted by the database back.
+// Timestamps are formatted with the layout of the dialect
+func cursorText(value interface{}, timeLayout string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(timeLayout)
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// lookbackStart moves the timestamp cursor back by the lookback window,
+// so the rows committed late with the earlier timestamps are synced as well.
+// Only timestamp cursors can be moved back
+func lookbackStart(cursor string, lookback time.Duration, timeLayout string) (string, time.Time, bool) {
+	cursorTime, err := time.Parse(timeLayout, cursor)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	start := cursorTime.Add(-lookback)
+	return start.Format(timeLayout), start, true
+}
+
+// seenRows remembers the rows synced within the lookback window,
+// so they are not emitted again when the window is re-read
+type seenRows map[seenRow]time.Time
+
+// newSeenRows restores the rows stored with the cursor
+func newSeenRows(rows []seenRow, timeLayout string) seenRows {
+	seen := seenRows{}
+	for _, row := range rows {
+		seen.Add(cursorState{Cursor: row.Cursor, Pk: row.Pk}, timeLayout)
+	}
+
+	return seen
+}
+
+func (s seenRows) Add(state cursorState, timeLayout string) bool {
+	key := seenRow{Cursor: state.Cursor, Pk: state.Pk}
+	if _, ok := s[key]; ok {
+		return false
+	}
+
+	cursorTime, _ := time.Parse(timeLayout, state.Cursor)
+	s[key] = cursorTime
+	return true
+}
+
+// Rows returns the remembered rows ordered by the cursor and the primary key, so they can be stored with the cursor
+func (s seenRows) Rows() []seenRow {
+	rows := make([]seenRow, 0, len(s))
+	for row := range s {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Cursor != rows[j].Cursor {
+			return rows[i].Cursor < rows[j].Cursor
+		}
+		return rows[i].Pk < rows[j].Pk
+	})
+
+	return rows
+}
+
+// Prune forgets the rows that fell out of the lookback window
+func (s seenRows) Prune(start time.Time) {
+	for key, cursorTime := range s {
+		if cursorTime.Before(start) {
+			delete(s, key)
+		}
+	}
+}
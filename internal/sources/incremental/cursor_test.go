@@ -0,0 +1,95 @@
+package incremental
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorState_Encode(t *testing.T) {
+	state := cursorState{Cursor: "2024-01-01T00:00:00Z", Pk: "10"}
+	decoded, err := decodeCursorState(state.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, state) {
+		t.Fatalf("unexpected decoded state %+v", decoded)
+	}
+
+	state.Seen = []seenRow{{Cursor: "2024-01-01T00:00:00Z", Pk: "9"}}
+	if decoded, err = decodeCursorState(state.Encode()); err != nil || !reflect.DeepEqual(decoded, state) {
+		t.Fatalf("seen rows must be stored with the cursor, got %+v", decoded)
+	}
+
+	empty, err := decodeCursorState("")
+	if err != nil || !empty.IsEmpty() {
+		t.Fatal("missing cursor must decode to the empty state")
+	}
+}
+
+func TestCursorText(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 1, 10, 0, 0, 500, time.UTC)
+	cases := map[string]interface{}{
+		"2024-01-01T10:00:00.0000005Z":         updatedAt,
+		"42":                                   int64(42),
+		"7":                                    int32(7),
+		"abc":                                  "abc",
+		"00112233-4455-6677-8899-aabbccddeeff": [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+	}
+
+	for expected, value := range cases {
+		if text := cursorText(value, time.RFC3339Nano); text != expected {
+			t.Fatalf("expected %s, got %s", expected, text)
+		}
+	}
+}
+
+func TestLookbackStart(t *testing.T) {
+	start, startTime, ok := lookbackStart("2024-01-01T10:00:00Z", time.Minute, time.RFC3339Nano)
+	if !ok || start != "2024-01-01T09:59:00Z" || !startTime.Equal(time.Date(2024, 1, 1, 9, 59, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected lookback start %s", start)
+	}
+
+	if _, _, ok = lookbackStart("42", time.Minute, time.RFC3339Nano); ok {
+		t.Fatal("only timestamp cursors can be moved back")
+	}
+}
+
+func TestSeenRows(t *testing.T) {
+	seen := seenRows{}
+	row := cursorState{Cursor: "2024-01-01T10:00:00Z", Pk: "1"}
+	if !seen.Add(row, time.RFC3339Nano) || seen.Add(row, time.RFC3339Nano) {
+		t.Fatal("row must be reported as new only once")
+	}
+
+	seen.Prune(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	if len(seen) != 1 {
+		t.Fatal("rows within the window must be kept")
+	}
+
+	seen.Prune(time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC))
+	if len(seen) != 0 {
+		t.Fatal("rows out of the window must be pruned")
+	}
+}
+
+func TestSeenRows_Restore(t *testing.T) {
+	seen := seenRows{}
+	seen.Add(cursorState{Cursor: "2024-01-01T10:00:00Z", Pk: "2"}, time.RFC3339Nano)
+	seen.Add(cursorState{Cursor: "2024-01-01T10:00:00Z", Pk: "1"}, time.RFC3339Nano)
+
+	rows := seen.Rows()
+	if len(rows) != 2 || rows[0].Pk != "1" || rows[1].Pk != "2" {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+
+	restored := newSeenRows(rows, time.RFC3339Nano)
+	if restored.Add(cursorState{Cursor: "2024-01-01T10:00:00Z", Pk: "1"}, time.RFC3339Nano) {
+		t.Fatal("restored rows must not be emitted again")
+	}
+
+	restored.Prune(time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC))
+	if len(restored) != 0 {
+		t.Fatal("restored rows must keep the cursor time for pruning")
+	}
+}
@@ -0,0 +1,17 @@
+package incremental
+
+// Dialect adapts the incremental source to the database.
+// The connection is opened with database/sql, so the driver has to be registered by the dialect package
+type Dialect interface {
+	// Name is used in the logs
+	Name() string
+	// DriverName is the name of the registered database/sql driver
+	DriverName() string
+	DSN() string
+	// Placeholder returns the bind parameter for the position starting from 1
+	Placeholder(position int) string
+	// Limit restricts the ordered query to the amount of rows
+	Limit(query string, limit int) string
+	// TimeLayout is the layout timestamp cursors are stored and passed back to the database in
+	TimeLayout() string
+}
@@ -0,0 +1,349 @@
+package incremental
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/charmbracelet/log"
+	"github.com/cloudquery/plugin-sdk/v4/scalar"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/offset_storage"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+	"strings"
+	"time"
+)
+
+// Source polls the tables for the rows after the stored cursor.
+// Queries are built for the dialect, so any database reachable with database/sql can be synced
+type Source struct {
+	ctx     context.Context
+	config  Config
+	dialect Dialect
+
+	syncTicker            *time.Ticker
+	appCtx                *stream_context.Context
+	offsetStorage         offset_storage.OffsetStorage
+	streamSchema          []schema.StreamSchema
+	streamSchemaMap       map[string]schema.StreamSchema
+	streamPks             map[string]string
+	streamCursors         map[string]string
+	streamSeenRows        map[string]seenRows
+	logger                *log.Logger
+	streamColumnsToSelect map[string][]string
+	db                    *sql.DB
+	messagesStream        chan sources.MessageEvent
+}
+
+func NewSource(appCtx *stream_context.Context, config Config, dialect Dialect, s []schema.StreamSchema) *Source {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	source := &Source{
+		appCtx:                appCtx,
+		syncTicker:            time.NewTicker(time.Second * time.Duration(config.PollInterval)),
+		config:                config,
+		dialect:               dialect,
+		offsetStorage:         appCtx.OffsetStorage(),
+		streamSchema:          s,
+		logger:                appCtx.Logger.WithPrefix(fmt.Sprintf("Source [%s]", dialect.Name())),
+		streamSchemaMap:       map[string]schema.StreamSchema{},
+		streamPks:             map[string]string{},
+		streamCursors:         map[string]string{},
+		streamSeenRows:        map[string]seenRows{},
+		streamColumnsToSelect: map[string][]string{},
+		messagesStream:        make(chan sources.MessageEvent),
+	}
+
+	if source.offsetStorage == nil {
+		source.logger.Warn("No offset storage configured. Offsets are kept in memory and the sync restarts from scratch")
+		source.offsetStorage = offset_storage.NewStorageInMem()
+	}
+
+	source.buildSchemaMap()
+	return source
+}
+
+func (p *Source) Connect(ctx context.Context) error {
+	for _, stream := range p.streamSchema {
+		if p.streamPks[stream.StreamName] == "" {
+			return fmt.Errorf("primary key is required for the stream %s", stream.StreamName)
+		}
+		if p.columnIndex(stream.StreamName, p.streamCursors[stream.StreamName]) == -1 {
+			return fmt.Errorf("cursor column %s is not defined for the stream %s", p.streamCursors[stream.StreamName], stream.StreamName)
+		}
+	}
+
+	db, err := sql.Open(p.dialect.DriverName(), p.dialect.DSN())
+	if err != nil {
+		return err
+	}
+
+	if err = db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	p.db = db
+	p.ctx = ctx
+	return nil
+}
+
+func (p *Source) Events() chan sources.MessageEvent {
+	return p.messagesStream
+}
+
+func (p *Source) Start() {
+	for {
+		select {
+		case <-p.syncTicker.C:
+			for _, stream := range p.streamSchema {
+				if err := p.syncStream(stream.StreamName); err != nil {
+					p.logger.Fatalf("Failed to sync stream %s: %s", stream.StreamName, err.Error())
+				}
+			}
+		}
+	}
+}
+
+// syncStream reads the rows after the stored cursor page by page until there are no more rows.
+// With the lookback enabled the reading starts from the beginning of the lookback window,
+// and the rows already synced within the window are skipped
+func (p *Source) syncStream(streamName string) error {
+	state, err := p.loadCursor(streamName)
+	if err != nil {
+		return err
+	}
+
+	if state.IsEmpty() && !p.config.StreamSnapshot {
+		if state, err = p.selectLastCursor(streamName); err != nil {
+			return err
+		}
+		p.logger.Info("Snapshot streaming is disabled. Starting sync from the cursor", "stream", streamName, "cursor", state.Cursor)
+	}
+
+	from, inclusive := state, false
+	var seen seenRows
+	var windowStart time.Time
+	if p.config.CursorLookback > 0 && !state.IsEmpty() {
+		var start string
+		var ok bool
+		if start, windowStart, ok = lookbackStart(state.Cursor, time.Duration(p.config.CursorLookback)*time.Second, p.dialect.TimeLayout()); ok {
+			from, inclusive = cursorState{Cursor: start}, true
+			if seen = p.streamSeenRows[streamName]; seen == nil {
+				seen = newSeenRows(state.Seen, p.dialect.TimeLayout())
+				p.streamSeenRows[streamName] = seen
+			}
+		}
+	}
+
+	var rowsSynced = 0
+	for {
+		last, rowsFetched, emitted, err := p.syncPage(streamName, from, inclusive, seen)
+		if err != nil {
+			return err
+		}
+
+		rowsSynced += emitted
+		if rowsFetched > 0 {
+			state, from, inclusive = last, last, false
+		}
+
+		if rowsFetched < p.config.BatchSize {
+			break
+		}
+	}
+
+	if seen != nil {
+		seen.Prune(windowStart)
+		state.Seen = seen.Rows()
+	}
+
+	if rowsSynced > 0 {
+		p.logger.Info("Synced rows", "stream", streamName, "rows", rowsSynced, "cursor", state.Cursor)
+	}
+
+	return p.offsetStorage.SetCursorForPipeline(offset_storage.BuildCursorKey(p.appCtx.PipelineId(), streamName), state.Encode())
+}
+
+// syncPage emits the batch of rows after the cursor ordered by the cursor and the primary key.
+// It returns the cursor of the last row, the amount of the fetched and the emitted rows
+func (p *Source) syncPage(streamName string, from cursorState, inclusive bool, seen seenRows) (cursorState, int, int, error) {
+	query, args := p.buildPageQuery(streamName, from, inclusive)
+	rows, err := p.db.QueryContext(p.ctx, query, args...)
+	if err != nil {
+		return from, 0, 0, fmt.Errorf("failed to query data for cursor: %w", err)
+	}
+	defer rows.Close()
+
+	streamSchema := p.streamSchemaMap[streamName]
+	arrowSchema := streamSchema.AsArrow()
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, arrowSchema)
+	cursorIndex := p.columnIndex(streamName, p.streamCursors[streamName])
+	pkIndex := p.columnIndex(streamName, p.streamPks[streamName])
+	splitStream := strings.Split(streamName, ".")
+	timeLayout := p.dialect.TimeLayout()
+
+	values := make([]interface{}, len(p.streamColumnsToSelect[streamName]))
+	valuePtrs := make([]interface{}, len(values))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var last cursorState
+	var rowsFetched, rowsEmitted = 0, 0
+	for rows.Next() {
+		rowsFetched += 1
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return from, rowsFetched, rowsEmitted, err
+		}
+
+		last = cursorState{Cursor: cursorText(values[cursorIndex], timeLayout), Pk: cursorText(values[pkIndex], timeLayout)}
+		if seen != nil && !seen.Add(last, timeLayout) {
+			continue
+		}
+
+		for i, v := range values {
+			s := scalar.NewScalar(arrowSchema.Field(i).Type)
+			if err := s.Set(helper.NormalizeDriverValue(v, arrowSchema.Field(i).Type)); err != nil {
+				return from, rowsFetched, rowsEmitted, err
+			}
+
+			scalar.AppendToBuilder(builder.Field(i), s)
+		}
+
+		data, _ := builder.NewRecord().MarshalJSON()
+		m := message.NewMessage(message.Insert, splitStream[len(splitStream)-1], data)
+		p.messagesStream <- sources.MessageEvent{
+			Message: m,
+			Err:     nil,
+		}
+		rowsEmitted += 1
+	}
+
+	return last, rowsFetched, rowsEmitted, rows.Err()
+}
+
+// buildPageQuery builds the query for the rows after the cursor.
+// Primary key breaks the ties, so the rows sharing the cursor value are not skipped between the pages
+func (p *Source) buildPageQuery(streamName string, from cursorState, inclusive bool) (string, []interface{}) {
+	cursorColumn := p.streamCursors[streamName]
+	pkColumn := p.streamPks[streamName]
+	selectColumns := strings.Join(p.streamColumnsToSelect[streamName], ", ")
+
+	var where string
+	var args []interface{}
+	switch {
+	case from.IsEmpty():
+	case inclusive:
+		where = fmt.Sprintf(" WHERE %s >= %s", cursorColumn, p.dialect.Placeholder(1))
+		args = append(args, from.Cursor)
+	case cursorColumn == pkColumn || from.Pk == "":
+		where = fmt.Sprintf(" WHERE %s > %s", cursorColumn, p.dialect.Placeholder(1))
+		args = append(args, from.Cursor)
+	default:
+		// placeholders can't be reused by all the dialects, so the cursor is passed twice
+		where = fmt.Sprintf(" WHERE (%s > %s OR (%s = %s AND %s > %s))",
+			cursorColumn, p.dialect.Placeholder(1), cursorColumn, p.dialect.Placeholder(2), pkColumn, p.dialect.Placeholder(3))
+		args = append(args, from.Cursor, from.Cursor, from.Pk)
+	}
+
+	orderBy := cursorColumn
+	if cursorColumn != pkColumn {
+		orderBy = fmt.Sprintf("%s ASC, %s", cursorColumn, pkColumn)
+	}
+
+	return p.dialect.Limit(fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s ASC", selectColumns, streamName, where, orderBy), p.config.BatchSize), args
+}
+
+// loadCursor returns the stored cursor. Integer offsets stored by the previous versions
+// are used as the cursor when the stream is synced by the primary key
+func (p *Source) loadCursor(streamName string) (cursorState, error) {
+	encoded, err := p.offsetStorage.GetCursorByPipelineStream(offset_storage.BuildCursorKey(p.appCtx.PipelineId(), streamName))
+	if err != nil {
+		return cursorState{}, err
+	}
+
+	if encoded != "" {
+		return decodeCursorState(encoded)
+	}
+
+	if p.streamCursors[streamName] != p.streamPks[streamName] {
+		return cursorState{}, nil
+	}
+
+	offset, _ := p.offsetStorage.GetOffsetByPipelineStream(offset_storage.BuildKey(p.appCtx.PipelineId(), streamName))
+	if offset == 0 {
+		return cursorState{}, nil
+	}
+
+	return cursorState{Cursor: cursorText(offset, p.dialect.TimeLayout())}, nil
+}
+
+func (p *Source) Stop() {
+	p.syncTicker.Stop()
+	if p.db != nil {
+		p.db.Close()
+	}
+}
+
+// selectLastCursor returns the cursor of the last row, so only the rows added later are synced
+func (p *Source) selectLastCursor(streamName string) (cursorState, error) {
+	cursorColumn := p.streamCursors[streamName]
+	pkColumn := p.streamPks[streamName]
+	res := p.db.QueryRowContext(p.ctx, p.dialect.Limit(fmt.Sprintf(
+		"SELECT %s, %s FROM %s ORDER BY %s DESC, %s DESC",
+		cursorColumn, pkColumn, streamName, cursorColumn, pkColumn,
+	), 1))
+
+	var cursor, pk interface{}
+	if err := res.Scan(&cursor, &pk); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return cursorState{}, nil
+		}
+		return cursorState{}, err
+	}
+
+	return cursorState{Cursor: cursorText(cursor, p.dialect.TimeLayout()), Pk: cursorText(pk, p.dialect.TimeLayout())}, nil
+}
+
+func (p *Source) columnIndex(streamName, column string) int {
+	for idx, name := range p.streamColumnsToSelect[streamName] {
+		if name == column {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+func (p *Source) buildSchemaMap() {
+	for _, stream := range p.streamSchema {
+		var columnsToSelect []string
+		p.streamSchemaMap[stream.StreamName] = stream
+		for _, c := range stream.Columns {
+			if c.PK {
+				p.streamPks[stream.StreamName] = c.Name
+			}
+			columnsToSelect = append(columnsToSelect, c.Name)
+		}
+		p.streamColumnsToSelect[stream.StreamName] = columnsToSelect
+
+		p.streamCursors[stream.StreamName] = p.streamPks[stream.StreamName]
+		if p.config.CursorColumn != "" {
+			p.streamCursors[stream.StreamName] = p.config.CursorColumn
+		}
+		if cursorColumn, ok := p.config.CursorColumns[stream.StreamName]; ok {
+			p.streamCursors[stream.StreamName] = cursorColumn
+		}
+	}
+}
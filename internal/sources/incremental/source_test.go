@@ -0,0 +1,76 @@
+package incremental
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+)
+
+type testDialect struct{}
+
+func (d testDialect) Name() string       { return "test" }
+func (d testDialect) DriverName() string { return "test" }
+func (d testDialect) DSN() string        { return "" }
+func (d testDialect) TimeLayout() string { return time.RFC3339Nano }
+
+func (d testDialect) Placeholder(position int) string {
+	return fmt.Sprintf("$%d", position)
+}
+
+func (d testDialect) Limit(query string, limit int) string {
+	return fmt.Sprintf("%s LIMIT %d", query, limit)
+}
+
+func TestSource_BuildPageQuery(t *testing.T) {
+	source := &Source{
+		config:  Config{BatchSize: 100, CursorColumn: "updated_at"},
+		dialect: testDialect{},
+		streamSchema: []schema.StreamSchema{
+			{
+				StreamName: "users",
+				Columns: []schema.Column{
+					{Name: "id", PK: true},
+					{Name: "updated_at"},
+				},
+			},
+		},
+		streamSchemaMap:       map[string]schema.StreamSchema{},
+		streamPks:             map[string]string{},
+		streamCursors:         map[string]string{},
+		streamColumnsToSelect: map[string][]string{},
+	}
+	source.buildSchemaMap()
+
+	query, args := source.buildPageQuery("users", cursorState{}, false)
+	if query != "SELECT id, updated_at FROM users ORDER BY updated_at ASC, id ASC LIMIT 100" || len(args) != 0 {
+		t.Fatalf("unexpected initial query %s", query)
+	}
+
+	query, args = source.buildPageQuery("users", cursorState{Cursor: "2024-01-01T10:00:00Z", Pk: "5"}, false)
+	if query != "SELECT id, updated_at FROM users WHERE (updated_at > $1 OR (updated_at = $2 AND id > $3)) ORDER BY updated_at ASC, id ASC LIMIT 100" || len(args) != 3 {
+		t.Fatalf("unexpected query %s", query)
+	}
+
+	query, args = source.buildPageQuery("users", cursorState{Cursor: "2024-01-01T09:59:00Z"}, true)
+	if query != "SELECT id, updated_at FROM users WHERE updated_at >= $1 ORDER BY updated_at ASC, id ASC LIMIT 100" || len(args) != 1 {
+		t.Fatalf("unexpected lookback query %s", query)
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	if helper.NormalizeDriverValue([]byte("abc"), arrow.BinaryTypes.String) != "abc" {
+		t.Fatal("text returned as bytes must be converted to string")
+	}
+
+	if _, ok := helper.NormalizeDriverValue([]byte("abc"), arrow.BinaryTypes.Binary).([]byte); !ok {
+		t.Fatal("binary values must be kept as bytes")
+	}
+
+	if helper.NormalizeDriverValue(int64(1), arrow.FixedWidthTypes.Boolean) != true {
+		t.Fatal("booleans stored as integers must be converted")
+	}
+}
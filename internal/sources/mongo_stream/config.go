@@ -1,7 +1,15 @@
 package mongo_stream
 
+import "github.com/usedatabrew/blink/internal/sources/snapshot"
+
 type Config struct {
 	Uri            string `json:"uri" yaml:"uri"`
 	Database       string `json:"database" yaml:"database"`
 	StreamSnapshot bool   `json:"stream_snapshot" yaml:"stream_snapshot"`
+	// BeforeImage attaches fullDocumentBeforeChange to the update and delete messages.
+	// It requires changeStreamPreAndPostImages to be enabled for the collections
+	BeforeImage bool `json:"before_image" yaml:"before_image"`
+	// Chunked snapshot splits the collections by _id when the chunk size is set.
+	// Change streams of the collections aren't ordered with each other, so watermarks aren't supported
+	snapshot.Config `yaml:",inline"`
 }
@@ -1,33 +1,45 @@
 package mongo_stream
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/apache/arrow/go/v14/arrow"
 	"github.com/apache/arrow/go/v14/arrow/array"
 	"github.com/apache/arrow/go/v14/arrow/memory"
 	"github.com/goccy/go-json"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SourcePlugin struct {
 	ctx           context.Context
+	appCtx        *stream_context.Context
 	config        Config
 	client        *mongo.Client
 	database      *mongo.Database
 	inputSchema   []schema.StreamSchema
 	outputSchema  map[string]*arrow.Schema
 	messageStream chan sources.MessageEvent
+	snapshotter   *snapshot.Snapshotter
+	// startAt is the operation time captured before the snapshot,
+	// so the changes made while the snapshot is taken are watched after it
+	startAt *primitive.Timestamp
 }
 
-func NewMongoStreamSourcePlugin(config Config, schema []schema.StreamSchema) sources.DataSource {
+func NewMongoStreamSourcePlugin(appCtx *stream_context.Context, config Config, schema []schema.StreamSchema) sources.DataSource {
 	return &SourcePlugin{
+		appCtx:        appCtx,
 		config:        config,
 		inputSchema:   schema,
 		outputSchema:  sources.BuildOutputSchema(schema),
@@ -47,11 +59,18 @@ func (p *SourcePlugin) Connect(ctx context.Context) error {
 
 	p.database = client.Database(p.config.Database)
 
+	if p.config.SnapshotWatermarks {
+		p.appCtx.Logger.Warn("Snapshot watermarks aren't supported by mongo_stream. Snapshot is taken before the changes are watched")
+	}
+	p.snapshotter = snapshot.NewSnapshotter(p.appCtx, p.config.Config, snapshotReader{plugin: p}, nil, p.inputSchema)
+
 	return nil
 }
 
 func (p *SourcePlugin) Start() {
-	if p.config.StreamSnapshot {
+	if p.config.StreamSnapshot && p.config.Chunked() {
+		go p.takeChunkedSnapshot()
+	} else if p.config.StreamSnapshot {
 		go p.takeSnapshot()
 	} else {
 		go p.watch()
@@ -69,6 +88,10 @@ func (p *SourcePlugin) Events() chan sources.MessageEvent {
 }
 
 func (p *SourcePlugin) takeSnapshot() {
+	if err := p.captureOperationTime(); err != nil {
+		panic(err)
+	}
+
 	for _, v := range p.inputSchema {
 		filter := bson.D{}
 		opts := options.Find().SetSort(bson.M{"_id": "1"})
@@ -100,7 +123,15 @@ func (p *SourcePlugin) watch() {
 
 		collection := p.database.Collection(v.StreamName)
 
-		stream, err := collection.Watch(p.ctx, mongo.Pipeline{}, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+		streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if p.config.BeforeImage {
+			streamOptions.SetFullDocumentBeforeChange(options.WhenAvailable)
+		}
+		if p.startAt != nil {
+			streamOptions.SetStartAtOperationTime(p.startAt)
+		}
+
+		stream, err := collection.Watch(p.ctx, mongo.Pipeline{}, streamOptions)
 
 		if err != nil {
 			panic(err)
@@ -121,8 +152,6 @@ func (p *SourcePlugin) watch() {
 }
 
 func (p *SourcePlugin) process(stream string, data map[string]interface{}, snapshot bool) {
-	builder := array.NewRecordBuilder(memory.DefaultAllocator, p.outputSchema[stream])
-
 	var eventOperation string
 	var eventData map[string]interface{}
 
@@ -144,7 +173,56 @@ func (p *SourcePlugin) process(stream string, data map[string]interface{}, snaps
 		eventOperation = string(message.Snapshot)
 	}
 
-	encodedJson, _ := json.Marshal(&eventData)
+	mbytes := p.encodeDocument(stream, eventData)
+	m := message.NewMessage(message.Event(eventOperation), stream, mbytes)
+
+	if before, ok := data["fullDocumentBeforeChange"].(bson.M); ok && p.config.BeforeImage {
+		var beforeRows []map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(p.encodeDocument(stream, before)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&beforeRows); err == nil && len(beforeRows) > 0 {
+			if err = helper.SetBeforeImage(m, beforeRows[0]); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	// change events are identified by the resume token they carry as the _id
+	if token, ok := data["_id"].(bson.M); ok && !snapshot {
+		if resumeToken, ok := token["_data"].(string); ok {
+			if err := helper.SetPosition(m, resumeToken); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	p.messageStream <- sources.MessageEvent{
+		Message: m,
+		Err:     nil,
+	}
+}
+
+// captureOperationTime stores the operation time of the cluster the changes are watched from after the snapshot
+func (p *SourcePlugin) captureOperationTime() error {
+	var result bson.Raw
+	if err := p.database.RunCommand(p.ctx, bson.D{{Key: "ping", Value: 1}}).Decode(&result); err != nil {
+		return err
+	}
+
+	t, i, ok := result.Lookup("operationTime").TimestampOK()
+	if !ok {
+		return errors.New("operationTime is missing from the server response, change streams require a replica set")
+	}
+
+	p.startAt = &primitive.Timestamp{T: t, I: i}
+	return nil
+}
+
+// encodeDocument encodes the document as a record of the stream schema
+func (p *SourcePlugin) encodeDocument(stream string, document map[string]interface{}) []byte {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, p.outputSchema[stream])
+
+	encodedJson, _ := json.Marshal(&document)
 	err := json.Unmarshal(encodedJson, &builder)
 	// TODO:: rewrite
 	if err != nil {
@@ -152,10 +230,10 @@ func (p *SourcePlugin) process(stream string, data map[string]interface{}, snaps
 	}
 
 	mbytes, _ := builder.NewRecord().MarshalJSON()
-	m := message.NewMessage(message.Event(eventOperation), stream, mbytes)
+	return mbytes
+}
 
-	p.messageStream <- sources.MessageEvent{
-		Message: m,
-		Err:     nil,
-	}
+// BeforeImageEnabled reports if the update and delete messages carry the before image
+func (p *SourcePlugin) BeforeImageEnabled() bool {
+	return p.config.BeforeImage
 }
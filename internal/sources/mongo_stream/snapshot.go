@@ -0,0 +1,150 @@
+package mongo_stream
+
+import (
+	"context"
+
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/message"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoOperators maps the backfill filter operators to the query operators
+var mongoOperators = map[string]string{
+	"=":  "$eq",
+	"!=": "$ne",
+	">":  "$gt",
+	">=": "$gte",
+	"<":  "$lt",
+	"<=": "$lte",
+}
+
+// snapshotReader reads the collection chunks split by _id
+type snapshotReader struct {
+	plugin *SourcePlugin
+}
+
+func (r snapshotReader) Boundaries(ctx context.Context, stream string, chunkSize int) ([]string, error) {
+	var boundaries []string
+	var lower interface{}
+	for {
+		filter := bson.M{}
+		if lower != nil {
+			filter = bson.M{"_id": bson.M{"$gt": lower}}
+		}
+
+		opts := options.FindOne().
+			SetSort(bson.M{"_id": 1}).
+			SetSkip(int64(chunkSize - 1)).
+			SetProjection(bson.M{"_id": 1})
+
+		var document bson.M
+		err := r.plugin.database.Collection(stream).FindOne(ctx, filter, opts).Decode(&document)
+		if err == mongo.ErrNoDocuments {
+			return boundaries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		boundary, err := encodeKey(document["_id"])
+		if err != nil {
+			return nil, err
+		}
+
+		boundaries = append(boundaries, boundary)
+		lower = document["_id"]
+	}
+}
+
+func (r snapshotReader) ReadChunk(ctx context.Context, stream string, chunk snapshot.Chunk) ([]*message.Message, error) {
+	keyFilter := bson.M{}
+	if chunk.Lower != "" {
+		lower, err := decodeKey(chunk.Lower)
+		if err != nil {
+			return nil, err
+		}
+		keyFilter["$gt"] = lower
+	}
+	if chunk.Upper != "" {
+		upper, err := decodeKey(chunk.Upper)
+		if err != nil {
+			return nil, err
+		}
+		keyFilter["$lte"] = upper
+	}
+
+	filter := bson.M{}
+	if len(keyFilter) > 0 {
+		filter["_id"] = keyFilter
+	}
+
+	conditions := bson.A{filter}
+	for _, backfillFilter := range chunk.Filters {
+		conditions = append(conditions, bson.M{backfillFilter.Column: bson.M{mongoOperators[backfillFilter.Operator]: backfillFilter.Value}})
+	}
+	if len(conditions) > 1 {
+		filter = bson.M{"$and": conditions}
+	}
+
+	cursor, err := r.plugin.database.Collection(stream).Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*message.Message
+	for cursor.Next(ctx) {
+		var document bson.M
+		if err = cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message.NewMessage(message.Snapshot, stream, r.plugin.encodeDocument(stream, document)))
+	}
+
+	return messages, cursor.Err()
+}
+
+// encodeKey encodes _id as extended JSON, so its BSON type is kept in the snapshot progress
+func encodeKey(key interface{}) (string, error) {
+	encoded, err := bson.MarshalExtJSON(bson.M{"_id": key}, true, false)
+	return string(encoded), err
+}
+
+func decodeKey(encoded string) (interface{}, error) {
+	var document bson.M
+	if err := bson.UnmarshalExtJSON([]byte(encoded), true, &document); err != nil {
+		return nil, err
+	}
+
+	return document["_id"], nil
+}
+
+// takeChunkedSnapshot runs the chunked snapshot before the changes are watched.
+// The changes are watched from the operation time captured before the snapshot, so none are missed
+func (p *SourcePlugin) takeChunkedSnapshot() {
+	if err := p.captureOperationTime(); err != nil {
+		panic(err)
+	}
+
+	if err := p.snapshotter.Run(p.ctx, p.emit); err != nil {
+		panic(err)
+	}
+
+	p.watch()
+}
+
+// Backfill takes the snapshot of the collections again while the changes are watched
+func (p *SourcePlugin) Backfill(request sources.BackfillRequest) error {
+	return p.snapshotter.StartBackfill(p.ctx, request.ID, request.Streams, request.Filters, p.emit)
+}
+
+func (p *SourcePlugin) emit(msg *message.Message) {
+	p.messageStream <- sources.MessageEvent{
+		Message: msg,
+		Err:     nil,
+	}
+}
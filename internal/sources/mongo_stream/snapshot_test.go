@@ -0,0 +1,26 @@
+package mongo_stream
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSnapshotKey(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	for _, key := range []interface{}{objectID, int32(42), "user-1"} {
+		encoded, err := encodeKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := decodeKey(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded != key {
+			t.Fatalf("key %v must keep its type, got %T %v", key, decoded, decoded)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package mysql_cdc
 
+import "github.com/usedatabrew/blink/internal/sources/snapshot"
+
 type Config struct {
 	Host           string `json:"host" yaml:"host"`
 	Port           uint16 `json:"port" yaml:"port"`
@@ -8,4 +10,11 @@ type Config struct {
 	Password       string `json:"password" yaml:"password"`
 	Flavor         string `json:"flavor" yaml:"flavor"`
 	StreamSnapshot bool   `json:"stream_snapshot" yaml:"stream_snapshot"`
+	// BeforeImage attaches the row state before the change to the update and delete messages.
+	// It requires binlog_row_image=FULL, so the binlog contains all the columns
+	BeforeImage bool `json:"before_image" yaml:"before_image"`
+	// Chunked snapshot replaces mysqldump when the chunk size is set.
+	// Watermarks are written to the blink_watermarks table created in the database,
+	// backfills use them as well to run along with the binlog stream
+	snapshot.Config `yaml:",inline"`
 }
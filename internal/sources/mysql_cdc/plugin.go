@@ -1,7 +1,9 @@
 package mysql_cdc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -13,6 +15,8 @@ import (
 	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
 )
 
@@ -26,15 +30,18 @@ type ProcessEventParams struct {
 }
 
 type SourcePlugin struct {
+	ctx            context.Context
+	appCtx         *stream_context.Context
 	config         Config
 	inputSchema    map[string]schema.StreamSchema
 	outputSchema   map[string]DataTableSchema
 	messagesStream chan sources.MessageEvent
 	canal          *canal.Canal
+	snapshotter    *snapshot.Snapshotter
 	canal.DummyEventHandler
 }
 
-func NewMysqlSourcePlugin(config Config, sCh []schema.StreamSchema) sources.DataSource {
+func NewMysqlSourcePlugin(appCtx *stream_context.Context, config Config, sCh []schema.StreamSchema) sources.DataSource {
 	iSchema := make(map[string]schema.StreamSchema)
 
 	for _, stream := range sCh {
@@ -42,6 +49,7 @@ func NewMysqlSourcePlugin(config Config, sCh []schema.StreamSchema) sources.Data
 	}
 
 	instance := &SourcePlugin{
+		appCtx:         appCtx,
 		config:         config,
 		inputSchema:    iSchema,
 		messagesStream: make(chan sources.MessageEvent),
@@ -76,14 +84,26 @@ func (p *SourcePlugin) Connect(ctx context.Context) error {
 	}
 
 	p.canal = c
+	p.ctx = ctx
 
-	return nil
+	return p.connectSnapshot(ctx)
 }
 
 func (p *SourcePlugin) Start() {
 	p.canal.SetEventHandler(p)
 
-	if p.config.StreamSnapshot {
+	if p.config.StreamSnapshot && p.config.Chunked() {
+		// binlog is read from the position before the snapshot, so no change is missed.
+		// Without the watermarks the snapshot is taken before the binlog is read
+		coords, _ := p.canal.GetMasterPos()
+		if p.config.SnapshotWatermarks {
+			go p.takeSnapshot()
+		} else {
+			p.takeSnapshot()
+		}
+
+		p.canal.RunFrom(coords)
+	} else if p.config.StreamSnapshot {
 		p.canal.Run()
 	} else {
 		coords, _ := p.canal.GetMasterPos()
@@ -105,6 +125,10 @@ func (p *SourcePlugin) OnRow(e *canal.RowsEvent) error {
 		return nil
 	}
 
+	if e.Table.Name == snapshot.WatermarkTable {
+		return p.handleWatermarks(e)
+	}
+
 	if _, ok := p.inputSchema[e.Table.Name]; !ok {
 		return nil
 	}
@@ -122,46 +146,111 @@ func (p *SourcePlugin) OnRow(e *canal.RowsEvent) error {
 }
 
 func (p *SourcePlugin) processEvent(e *canal.RowsEvent, params ProcessEventParams) error {
-	inputSchema := p.inputSchema[e.Table.Name]
-	outputSchema := p.outputSchema[e.Table.Name]
+	if p.config.BeforeImage && e.Action != canal.InsertAction {
+		return p.processEventWithBeforeImage(e, params)
+	}
 
+	outputSchema := p.outputSchema[e.Table.Name]
 	builder := array.NewRecordBuilder(memory.DefaultAllocator, outputSchema.Schema)
 
 	for i := params.initValue; i < len(e.Rows); i += params.incrementValue {
-		for i, v := range e.Rows[i] {
-			outputIndex := -1
+		p.appendRow(builder, e, e.Rows[i])
+	}
 
-			for inputSchemaIndex, inputSchemaColumn := range inputSchema.Columns {
-				if e.Table.Columns[i].Name == inputSchemaColumn.Name {
-					outputIndex = inputSchemaIndex
-				}
-			}
+	bytes, _ := builder.NewRecord().MarshalJSON()
+	m := message.NewMessage(message.Event(e.Action), e.Table.Name, bytes)
+	if err := p.setPosition(m, e, params.initValue); err != nil {
+		return err
+	}
 
-			if outputIndex == -1 {
-				continue
-			}
+	p.emitChange(m)
 
-			s := scalar.NewScalar(outputSchema.Schema.Field(outputIndex).Type)
+	return nil
+}
 
-			if err := s.Set(convertData(e.Table.Columns[i], v)); err != nil {
-				panic(err)
-			}
+// processEventWithBeforeImage emits a message per changed row, so every message
+// carries its own before image. Update rows come in before/after pairs,
+// delete rows contain the deleted state that is used as the before image as well
+func (p *SourcePlugin) processEventWithBeforeImage(e *canal.RowsEvent, params ProcessEventParams) error {
+	outputSchema := p.outputSchema[e.Table.Name]
 
-			scalar.AppendToBuilder(builder.Field(outputIndex), s)
+	for i := params.initValue; i < len(e.Rows); i += params.incrementValue {
+		before := e.Rows[i]
+		if e.Action == canal.UpdateAction {
+			before = e.Rows[i-1]
 		}
-	}
 
-	bytes, _ := builder.NewRecord().MarshalJSON()
-	m := message.NewMessage(message.Event(e.Action), e.Table.Name, bytes)
+		builder := array.NewRecordBuilder(memory.DefaultAllocator, outputSchema.Schema)
+		p.appendRow(builder, e, e.Rows[i])
+		p.appendRow(builder, e, before)
+
+		var rows []map[string]interface{}
+		recordBytes, _ := builder.NewRecord().MarshalJSON()
+		decoder := json.NewDecoder(bytes.NewReader(recordBytes))
+		decoder.UseNumber()
+		if err := decoder.Decode(&rows); err != nil {
+			return err
+		}
+
+		rows[0][helper.BeforeImageField] = rows[1]
+		encoded, err := json.Marshal(rows[:1])
+		if err != nil {
+			return err
+		}
 
-	p.messagesStream <- sources.MessageEvent{
-		Message: m,
-		Err:     nil,
+		m := message.NewMessage(message.Event(e.Action), e.Table.Name, encoded)
+		if err = p.setPosition(m, e, i); err != nil {
+			return err
+		}
+
+		p.emitChange(m)
 	}
 
 	return nil
 }
 
+// setPosition tags the message with the binlog file, the end position of the rows event
+// and the index of the first row of the message. Rows read by the dump have no position
+func (p *SourcePlugin) setPosition(msg *message.Message, e *canal.RowsEvent, row int) error {
+	if e.Header == nil {
+		return nil
+	}
+
+	return helper.SetPosition(msg, fmt.Sprintf("%s:%d:%d", p.canal.SyncedPosition().Name, e.Header.LogPos, row))
+}
+
+func (p *SourcePlugin) appendRow(builder *array.RecordBuilder, e *canal.RowsEvent, row []interface{}) {
+	inputSchema := p.inputSchema[e.Table.Name]
+	outputSchema := p.outputSchema[e.Table.Name]
+
+	for i, v := range row {
+		outputIndex := -1
+
+		for inputSchemaIndex, inputSchemaColumn := range inputSchema.Columns {
+			if e.Table.Columns[i].Name == inputSchemaColumn.Name {
+				outputIndex = inputSchemaIndex
+			}
+		}
+
+		if outputIndex == -1 {
+			continue
+		}
+
+		s := scalar.NewScalar(outputSchema.Schema.Field(outputIndex).Type)
+
+		if err := s.Set(convertData(e.Table.Columns[i], v)); err != nil {
+			panic(err)
+		}
+
+		scalar.AppendToBuilder(builder.Field(outputIndex), s)
+	}
+}
+
+// BeforeImageEnabled reports if the update and delete messages carry the before image
+func (p *SourcePlugin) BeforeImageEnabled() bool {
+	return p.config.BeforeImage
+}
+
 func (p *SourcePlugin) buildOutputSchema() {
 	outputSchema := make(map[string]DataTableSchema)
 
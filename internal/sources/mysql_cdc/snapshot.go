@@ -0,0 +1,117 @@
+package mysql_cdc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-sql-driver/mysql"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/message"
+)
+
+type snapshotDialect struct{}
+
+func (d snapshotDialect) Placeholder(position int) string {
+	return "?"
+}
+
+func (d snapshotDialect) Page(query string, limit, offset int) string {
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", query, limit, offset)
+}
+
+// connectSnapshot prepares the chunked snapshot and the backfills. Time values are read as text,
+// so the snapshot rows are encoded the same way as the binlog rows
+func (p *SourcePlugin) connectSnapshot(ctx context.Context) error {
+	cfg := mysql.NewConfig()
+	cfg.User = p.config.User
+	cfg.Passwd = p.config.Password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", p.config.Host, p.config.Port)
+	cfg.DBName = p.config.Database
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+
+	var watermarks snapshot.Watermarks
+	if p.config.SnapshotWatermarks {
+		sqlWatermarks := snapshot.NewSQLWatermarks(db, snapshotDialect{}, "")
+		if err = sqlWatermarks.CreateTable(ctx); err != nil {
+			return fmt.Errorf("failed to create watermark table: %w", err)
+		}
+		watermarks = sqlWatermarks
+	}
+
+	var streamSchema []schema.StreamSchema
+	for _, stream := range p.inputSchema {
+		streamSchema = append(streamSchema, stream)
+	}
+
+	reader := snapshot.NewSQLReader(db, snapshotDialect{}, "", streamSchema)
+	p.snapshotter = snapshot.NewSnapshotter(p.appCtx, p.config.Config, reader, watermarks, streamSchema)
+	return nil
+}
+
+// takeSnapshot runs the chunked snapshot. With the watermarks it runs along with the binlog stream
+func (p *SourcePlugin) takeSnapshot() {
+	if err := p.snapshotter.Run(p.ctx, p.emit); err != nil {
+		p.appCtx.Logger.Fatalf("Failed to take snapshot: %s", err.Error())
+	}
+}
+
+// Backfill takes the snapshot of the tables again while the binlog stream keeps running
+func (p *SourcePlugin) Backfill(request sources.BackfillRequest) error {
+	return p.snapshotter.StartBackfill(p.ctx, request.ID, request.Streams, request.Filters, p.emit)
+}
+
+func (p *SourcePlugin) emit(msg *message.Message) {
+	p.messagesStream <- sources.MessageEvent{
+		Message: msg,
+		Err:     nil,
+	}
+}
+
+// emitChange emits the row change, so the snapshot drops the row from the chunk being read
+func (p *SourcePlugin) emitChange(msg *message.Message) {
+	if p.snapshotter != nil {
+		p.snapshotter.OnChange(msg)
+	}
+
+	p.emit(msg)
+}
+
+// handleWatermarks passes the watermarks inserted to the watermark table to the snapshot
+func (p *SourcePlugin) handleWatermarks(e *canal.RowsEvent) error {
+	if p.snapshotter == nil || e.Action != canal.InsertAction {
+		return nil
+	}
+
+	idIndex := -1
+	for i, column := range e.Table.Columns {
+		if column.Name == "id" {
+			idIndex = i
+		}
+	}
+
+	if idIndex == -1 {
+		return nil
+	}
+
+	for _, row := range e.Rows {
+		id := fmt.Sprint(row[idIndex])
+		if raw, ok := row[idIndex].([]byte); ok {
+			id = string(raw)
+		}
+
+		if err := p.snapshotter.OnWatermark(id, p.emit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package mysql_incremental
+
+import (
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/incremental"
+	"github.com/usedatabrew/blink/internal/stream_context"
+)
+
+type dialect struct {
+	config Config
+}
+
+func NewMysqlIncrSourcePlugin(appCtx *stream_context.Context, config Config, s []schema.StreamSchema) sources.DataSource {
+	return incremental.NewSource(appCtx, config.Config, dialect{config: config}, s)
+}
+
+func (d dialect) Name() string {
+	return "mysql_incremental"
+}
+
+func (d dialect) DriverName() string {
+	return "mysql"
+}
+
+// DSN enables parseTime, so the timestamp cursors are read as time and compared by the database as datetime
+func (d dialect) DSN() string {
+	cfg := mysql.NewConfig()
+	cfg.User = d.config.User
+	cfg.Passwd = d.config.Password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", d.config.Host, d.config.Port)
+	cfg.DBName = d.config.Database
+	cfg.ParseTime = true
+	if d.config.SSLRequired {
+		cfg.TLSConfig = "true"
+	}
+
+	return cfg.FormatDSN()
+}
+
+func (d dialect) Placeholder(position int) string {
+	return "?"
+}
+
+func (d dialect) Limit(query string, limit int) string {
+	return fmt.Sprintf("%s LIMIT %d", query, limit)
+}
+
+func (d dialect) TimeLayout() string {
+	return "2006-01-02 15:04:05.999999"
+}
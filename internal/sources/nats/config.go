@@ -0,0 +1,20 @@
+package nats
+
+// Config of the nats source. Messages are read from the JetStream stream by the durable pull consumer
+// and acknowledged once they are written by the sink
+type Config struct {
+	Url      string `json:"url" yaml:"url"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// Stream is the JetStream stream to consume
+	Stream string `json:"stream" yaml:"stream"`
+	// Durable is the name of the consumer. It's created when missing and keeps the position across restarts
+	Durable string `json:"durable" yaml:"durable"`
+	// Subjects filter the messages of the stream. All of them are consumed when empty
+	Subjects []string `json:"subjects" yaml:"subjects"`
+	// AckWait is the time in seconds the message is redelivered after unless it's written by the sink.
+	// Messages held by the buffered processors are acknowledged once the pipeline is idle. Defaults to 30
+	AckWait int `json:"ack_wait" yaml:"ack_wait"`
+	// MaxAckPending is the max amount of the messages waiting for the ack. Defaults to 1000
+	MaxAckPending int `json:"max_ack_pending" yaml:"max_ack_pending"`
+}
@@ -0,0 +1,172 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	defaultAckWait       = 30
+	defaultMaxAckPending = 1000
+)
+
+// SourcePlugin consumes the JetStream messages. Every message is bound to the stream of the schema
+// and acknowledged once the stream reports it's written, the failed ones are redelivered after the ack wait
+type SourcePlugin struct {
+	config        Config
+	conn          *nats.Conn
+	consumer      jetstream.Consumer
+	iterator      jetstream.MessagesContext
+	builder       *sources.BrokerMessageBuilder
+	pending       map[*message.Message]jetstream.Msg
+	mutex         sync.Mutex
+	logger        *log.Logger
+	messageStream chan sources.MessageEvent
+}
+
+func NewNatsSourcePlugin(config Config, schema []schema.StreamSchema, appCtx *stream_context.Context) sources.DataSource {
+	if config.AckWait <= 0 {
+		config.AckWait = defaultAckWait
+	}
+
+	if config.MaxAckPending <= 0 {
+		config.MaxAckPending = defaultMaxAckPending
+	}
+
+	return &SourcePlugin{
+		config:        config,
+		builder:       sources.NewBrokerMessageBuilder(schema),
+		pending:       map[*message.Message]jetstream.Msg{},
+		logger:        appCtx.Logger.WithPrefix("[source]: nats"),
+		messageStream: make(chan sources.MessageEvent),
+	}
+}
+
+func (p *SourcePlugin) Connect(ctx context.Context) error {
+	if p.config.Stream == "" || p.config.Durable == "" {
+		return errors.New("stream and durable are required for the nats source")
+	}
+
+	var opt nats.Option
+	if p.config.Username != "" {
+		opt = nats.UserInfo(p.config.Username, p.config.Password)
+	}
+
+	conn, err := nats.Connect(p.config.Url, opt)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return err
+	}
+
+	consumerConfig := jetstream.ConsumerConfig{
+		Durable:       p.config.Durable,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       time.Duration(p.config.AckWait) * time.Second,
+		MaxAckPending: p.config.MaxAckPending,
+	}
+
+	// the single filter subject is supported by the servers before 2.10 as well
+	if len(p.config.Subjects) == 1 {
+		consumerConfig.FilterSubject = p.config.Subjects[0]
+	} else {
+		consumerConfig.FilterSubjects = p.config.Subjects
+	}
+
+	if p.consumer, err = js.CreateOrUpdateConsumer(ctx, p.config.Stream, consumerConfig); err != nil {
+		return fmt.Errorf("failed to create consumer %s of stream %s: %w", p.config.Durable, p.config.Stream, err)
+	}
+
+	return nil
+}
+
+func (p *SourcePlugin) Start() {
+	iterator, err := p.consumer.Messages()
+	if err != nil {
+		p.logger.Fatalf("Failed to consume stream %s: %s", p.config.Stream, err.Error())
+	}
+	p.iterator = iterator
+
+	go p.run()
+}
+
+func (p *SourcePlugin) Events() chan sources.MessageEvent {
+	return p.messageStream
+}
+
+// Ack acknowledges the messages written by the sink
+func (p *SourcePlugin) Ack(messages []*message.Message) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, msg := range messages {
+		natsMsg, ok := p.pending[msg]
+		if !ok {
+			continue
+		}
+
+		delete(p.pending, msg)
+		if err := natsMsg.Ack(); err != nil {
+			p.logger.Error("Failed to ack message", "subject", natsMsg.Subject(), "err", err)
+		}
+	}
+}
+
+// Stop closes the consumer. Messages in flight are redelivered once the ack wait expires
+func (p *SourcePlugin) Stop() {
+	if p.iterator != nil {
+		p.iterator.Stop()
+	}
+
+	if p.conn != nil {
+		_ = p.conn.Drain()
+	}
+}
+
+func (p *SourcePlugin) run() {
+	for {
+		natsMsg, err := p.iterator.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			return
+		}
+		if err != nil {
+			p.emitError(fmt.Errorf("failed to receive message: %w", err))
+			continue
+		}
+
+		headers := natsMsg.Headers()
+		msg, err := p.builder.Build(headers.Get(helper.StreamHeader), headers.Get(helper.EventHeader), natsMsg.Subject(), natsMsg.Data())
+		if err != nil {
+			// the message can't be read on the redelivery either
+			_ = natsMsg.Term()
+			p.emitError(fmt.Errorf("message of subject %s is rejected: %w", natsMsg.Subject(), err))
+			continue
+		}
+
+		p.mutex.Lock()
+		p.pending[msg] = natsMsg
+		p.mutex.Unlock()
+
+		p.messageStream <- sources.MessageEvent{Message: msg, Err: nil}
+	}
+}
+
+func (p *SourcePlugin) emitError(err error) {
+	p.messageStream <- sources.MessageEvent{Message: nil, Err: err}
+}
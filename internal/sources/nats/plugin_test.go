@@ -0,0 +1,125 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String", Nullable: true},
+		},
+	},
+	{
+		StreamName: "orders",
+		Columns:    []schema.Column{{Name: "id", DatabrewType: "Int64", PK: true}},
+	},
+}
+
+func runServer(t *testing.T) *server.Server {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+	t.Cleanup(srv.Shutdown)
+
+	return srv
+}
+
+func startPlugin(t *testing.T, url string) *SourcePlugin {
+	plugin := NewNatsSourcePlugin(Config{Url: url, Stream: "CDC", Durable: "blink", AckWait: 1}, testStreamSchema, stream_context.CreateContext(1)).(*SourcePlugin)
+	if err := plugin.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	plugin.Start()
+
+	return plugin
+}
+
+func receive(t *testing.T, plugin *SourcePlugin, count int) ([]*message.Message, []string) {
+	var messages []*message.Message
+	var received []string
+	for len(received) < count {
+		select {
+		case event := <-plugin.Events():
+			if event.Err != nil {
+				received = append(received, "error")
+				continue
+			}
+			messages = append(messages, event.Message)
+			received = append(received, fmt.Sprintf("%s %s %s", event.Message.GetStream(), event.Message.GetEvent(), event.Message.AsJSONString()))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for messages, got %v", received)
+		}
+	}
+
+	return messages, received
+}
+
+func TestSourcePlugin(t *testing.T) {
+	srv := runServer(t)
+	ctx := context.Background()
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = js.CreateStream(ctx, jetstream.StreamConfig{Name: "CDC", Subjects: []string{"cdc.>"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	update := &nats.Msg{Subject: "cdc.users", Data: []byte(`[{"id": 1, "name": "a"}]`), Header: nats.Header{"Blink-Event": []string{"update"}}}
+	for _, msg := range []*nats.Msg{update, {Subject: "cdc.orders.insert", Data: []byte(`{"id": 2}`)}, {Subject: "cdc.users", Data: []byte(`{broken`)}} {
+		if _, err = js.PublishMsg(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plugin := startPlugin(t, srv.ClientURL())
+	messages, received := receive(t, plugin, 3)
+	expected := `[users update [{"id":1,"name":"a"}] orders insert [{"id":2}] error]`
+	if fmt.Sprint(received) != expected {
+		t.Fatalf("unexpected messages %v", received)
+	}
+
+	// unacknowledged message is redelivered after restart once the ack wait expires
+	plugin.Ack(messages[:1])
+	plugin.Stop()
+
+	plugin = startPlugin(t, srv.ClientURL())
+	defer plugin.Stop()
+
+	if _, received = receive(t, plugin, 1); fmt.Sprint(received) != `[orders insert [{"id":2}]]` {
+		t.Fatalf("unacknowledged message must be redelivered, got %v", received)
+	}
+
+	select {
+	case event := <-plugin.Events():
+		t.Fatalf("acknowledged and rejected messages must not be redelivered, got %v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
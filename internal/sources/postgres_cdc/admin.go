@@ -0,0 +1,240 @@
+package postgres_cdc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+)
+
+// Slot is the replication slot with the amount of WAL it retains
+type Slot struct {
+	Name     string
+	Plugin   string
+	Database string
+	Active   bool
+	// RetainedBytes is the WAL kept on the disk for the slot since its restart position
+	RetainedBytes int64
+	// LagBytes is the WAL written after the position the consumer of the slot confirmed
+	LagBytes int64
+}
+
+// connectionString builds the DSN with the escaped credentials, so the passwords
+// with the special characters don't break it
+func connectionString(config Config, replication bool) string {
+	params := url.Values{}
+	if replication {
+		params.Set("replication", "database")
+	}
+	if config.SSLRequired {
+		params.Set("sslmode", "verify-full")
+	}
+
+	link := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     fmt.Sprintf("%s:%d", config.Host, config.Port),
+		Path:     "/" + config.Database,
+		RawQuery: params.Encode(),
+	}
+
+	return link.String()
+}
+
+func slotName(config Config) string {
+	return fmt.Sprintf("rs_%s", config.SlotName)
+}
+
+// migratedSlotName is the pgoutput slot replacing the slot created by the versions
+// streaming the changes with wal2json, as the slot can't change its output plugin
+func migratedSlotName(config Config) string {
+	return fmt.Sprintf("%s_%s", slotName(config), outputPlugin)
+}
+
+// publicationName is the publication managed for the configured streams
+func publicationName(config Config) string {
+	if config.Publication != "" {
+		return config.Publication
+	}
+
+	return fmt.Sprintf("blink_%s", config.SlotName)
+}
+
+// qualifiedTables returns the tables of the streams with the schema name.
+// The watermark table is published along with them when the snapshot uses the watermarks
+func qualifiedTables(config Config, streamSchema []schema.StreamSchema) []pgx.Identifier {
+	var tables []pgx.Identifier
+	for _, stream := range streamSchema {
+		tables = append(tables, strings.SplitN(qualifiedTable(config, stream.StreamName), ".", 2))
+	}
+
+	if config.SnapshotWatermarks {
+		tables = append(tables, pgx.Identifier{config.Schema, snapshot.WatermarkTable})
+	}
+
+	return tables
+}
+
+// validateDatabase checks that the database is configured for logical replication
+// and the tables can stream updates and deletes
+func validateDatabase(ctx context.Context, conn *pgx.Conn, config Config, streamSchema []schema.StreamSchema) error {
+	var walLevel string
+	if err := conn.QueryRow(ctx, "SHOW wal_level").Scan(&walLevel); err != nil {
+		return err
+	}
+
+	if walLevel != "logical" {
+		return fmt.Errorf("wal_level is %s, but logical replication requires it to be logical. "+
+			"Run ALTER SYSTEM SET wal_level = logical and restart the database", walLevel)
+	}
+
+	var tables []string
+	for _, stream := range streamSchema {
+		tables = append(tables, qualifiedTable(config, stream.StreamName))
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT n.nspname || '.' || c.relname, c.relreplident::text, EXISTS(
+			SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary
+		)
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname || '.' || c.relname = ANY($1) AND c.relkind IN ('r', 'p')`,
+		tables,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := map[string]bool{}
+	var problems []string
+	for rows.Next() {
+		var table string
+		var replicaIdentity string
+		var hasPk bool
+		if err = rows.Scan(&table, &replicaIdentity, &hasPk); err != nil {
+			return err
+		}
+
+		found[table] = true
+		switch {
+		case replicaIdentity == "n":
+			problems = append(problems, fmt.Sprintf("table %s has REPLICA IDENTITY NOTHING, "+
+				"run ALTER TABLE %s REPLICA IDENTITY DEFAULT or FULL", table, table))
+		case config.BeforeImage && replicaIdentity != "f":
+			problems = append(problems, fmt.Sprintf("table %s requires REPLICA IDENTITY FULL for the before image, "+
+				"run ALTER TABLE %s REPLICA IDENTITY FULL", table, table))
+		case replicaIdentity == "d" && !hasPk:
+			problems = append(problems, fmt.Sprintf("table %s has no primary key for REPLICA IDENTITY DEFAULT, "+
+				"add the primary key or run ALTER TABLE %s REPLICA IDENTITY FULL", table, table))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if !found[table] {
+			problems = append(problems, fmt.Sprintf("table %s doesn't exist", table))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// ensurePublication creates the publication or updates it to contain exactly the configured tables.
+// It's never dropped on connect, as pgoutput fails to decode the changes made before the publication was created
+func ensurePublication(ctx context.Context, conn *pgx.Conn, name string, tables []pgx.Identifier) error {
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM pg_publication WHERE pubname = $1)", name).Scan(&exists); err != nil {
+		return err
+	}
+
+	statement := fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", pgx.Identifier{name}.Sanitize(), publicationTableList(tables))
+	if exists {
+		statement = fmt.Sprintf("ALTER PUBLICATION %s SET TABLE %s", pgx.Identifier{name}.Sanitize(), publicationTableList(tables))
+	}
+
+	if _, err := conn.Exec(ctx, statement); err != nil {
+		return fmt.Errorf("failed to set up publication %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// publicationTableList quotes every table, so the names can't break the publication statement
+func publicationTableList(tables []pgx.Identifier) string {
+	var quoted []string
+	for _, table := range tables {
+		quoted = append(quoted, table.Sanitize())
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// ListSlots returns the replication slots of the database server with the WAL they retain and their lag in bytes.
+// Retained WAL grows for the abandoned slots, so they can be found before they fill the disk
+func ListSlots(ctx context.Context, config Config) ([]Slot, error) {
+	conn, err := pgx.Connect(ctx, connectionString(config, false))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, `
+		SELECT slot_name, COALESCE(plugin, ''), COALESCE(database, ''), active,
+			COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn), 0)::bigint,
+			COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn), 0)::bigint
+		FROM pg_replication_slots ORDER BY slot_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []Slot
+	for rows.Next() {
+		var slot Slot
+		if err = rows.Scan(&slot.Name, &slot.Plugin, &slot.Database, &slot.Active, &slot.RetainedBytes, &slot.LagBytes); err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+
+	return slots, rows.Err()
+}
+
+// DropSlots decommissions the pipeline: drops its replication slots and publications.
+// Slots can't be dropped while the pipeline is running
+func DropSlots(ctx context.Context, config Config) error {
+	conn, err := pgx.Connect(ctx, connectionString(config, false))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx,
+		"SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = ANY($1)",
+		[]string{slotName(config), migratedSlotName(config)},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to drop replication slots: %w", err)
+	}
+
+	// pglog_stream_ publication is left by the versions streaming the changes with wal2json
+	for _, publication := range []string{publicationName(config), fmt.Sprintf("pglog_stream_%s", slotName(config))} {
+		if _, err = conn.Exec(ctx, fmt.Sprintf("DROP PUBLICATION IF EXISTS %s", pgx.Identifier{publication}.Sanitize())); err != nil {
+			return fmt.Errorf("failed to drop publication %s: %w", publication, err)
+		}
+	}
+
+	return nil
+}
@@ -1,16 +1,40 @@
 package postgres_cdc
 
-import "github.com/usedatabrew/pglogicalstream"
+import (
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/pglogicalstream"
+)
 
 type Config struct {
-	Host           string                           `json:"host" yaml:"host"`
-	Port           int                              `json:"port" yaml:"port"`
-	Database       string                           `json:"database" yaml:"database"`
-	User           string                           `json:"user" yaml:"user"`
-	Schema         string                           `json:"schema" yaml:"schema"`
-	Password       string                           `json:"password" yaml:"password"`
-	TablesSchema   []pglogicalstream.DbTablesSchema `json:"tables_schema" yaml:"tables_schema"`
-	SSLRequired    bool                             `json:"ssl_required" yaml:"ssl_required"`
-	StreamSnapshot bool                             `json:"stream_snapshot" yaml:"stream_snapshot"`
-	SlotName       string                           `json:"slot_name" yaml:"slot_name"`
+	Host         string                           `json:"host" yaml:"host"`
+	Port         int                              `json:"port" yaml:"port"`
+	Database     string                           `json:"database" yaml:"database"`
+	User         string                           `json:"user" yaml:"user"`
+	Schema       string                           `json:"schema" yaml:"schema"`
+	Password     string                           `json:"password" yaml:"password"`
+	TablesSchema []pglogicalstream.DbTablesSchema `json:"tables_schema" yaml:"tables_schema"`
+	// SSLRequired connects with sslmode=verify-full, the server certificate is verified
+	// against the system roots and the host name
+	SSLRequired    bool `json:"ssl_required" yaml:"ssl_required"`
+	StreamSnapshot bool `json:"stream_snapshot" yaml:"stream_snapshot"`
+	// SlotName names the rs_<slot_name> replication slot read with the pgoutput plugin.
+	// Breaking change: the slot created by the versions streaming the changes with wal2json can't be read.
+	// It's migrated on connect to the new rs_<slot_name>_pgoutput slot, which starts at the current
+	// WAL position, and dropped then. Changes the old slot hasn't confirmed are lost unless
+	// the snapshot is streamed, so stop the previous version once it delivered them or backfill the streams
+	SlotName string `json:"slot_name" yaml:"slot_name"`
+	// ControlEvents enables truncate and schema change events.
+	// They are emitted along with the row changes in the WAL order, columns added upstream
+	// are decoded from the rows once the schema change is emitted
+	ControlEvents bool `json:"control_events" yaml:"control_events"`
+	// BeforeImage attaches the row state before the change to the update and delete messages.
+	// It requires REPLICA IDENTITY FULL, so the old tuple contains all the columns
+	BeforeImage bool `json:"before_image" yaml:"before_image"`
+	// Publication is created or updated on connect to contain exactly the configured streams.
+	// The changes are read from it with the pgoutput plugin. Defaults to blink_<slot_name>
+	Publication string `json:"publication" yaml:"publication"`
+	// Chunked snapshot replaces the snapshot of the replication slot when the chunk size is set.
+	// Watermarks are written to the blink_watermarks table created in the schema,
+	// backfills use them as well to run along with the replication stream
+	snapshot.Config `yaml:",inline"`
 }
@@ -0,0 +1,344 @@
+package postgres_cdc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/cloudquery/plugin-sdk/v4/scalar"
+	"github.com/jackc/pgx/v5"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/message"
+	"github.com/usedatabrew/pglogicalstream"
+)
+
+// pgTypes maps the OIDs of the common postgres types to the databrew and native types
+var pgTypes = map[uint32][2]string{
+	16:   {"Boolean", "boolean"},
+	17:   {"bytea", "bytea"},
+	20:   {"Int64", "bigint"},
+	21:   {"Int16", "smallint"},
+	23:   {"Int32", "integer"},
+	700:  {"Float32", "real"},
+	701:  {"Float64", "double precision"},
+	829:  {"MAC", "macaddr"},
+	869:  {"Inet", "inet"},
+	1082: {"Date32", "date"},
+	2950: {"UUID", "uuid"},
+}
+
+// streamTable is the table streamed by the source. Rows are decoded into the columns of the stream,
+// columns added upstream are appended once the relation message reports them
+type streamTable struct {
+	stream  string
+	columns []schema.Column
+	arrow   *arrow.Schema
+	// known holds the columns of the table upstream, so only the added ones are reported
+	known map[string]bool
+}
+
+func newStreamTable(stream string, columns []schema.Column) *streamTable {
+	table := &streamTable{stream: stream, known: map[string]bool{}}
+	for _, column := range columns {
+		table.addColumn(column)
+	}
+
+	return table
+}
+
+// addColumn adds the column and rebuilds the arrow schema the rows are decoded with
+func (t *streamTable) addColumn(column schema.Column) {
+	t.columns = append(t.columns, column)
+	t.known[column.Name] = true
+
+	var fields []arrow.Field
+	for _, column := range t.columns {
+		fields = append(fields, arrow.Field{
+			Name:     column.Name,
+			Type:     helper.MapPlainTypeToArrow(column.DatabrewType),
+			Nullable: column.Nullable,
+			Metadata: arrow.Metadata{},
+		})
+	}
+	t.arrow = arrow.NewSchema(fields, nil)
+}
+
+// encodeRow encodes the text values of the columns the same way for the snapshot and the changes.
+// Columns missing in the values are encoded as nulls
+func (t *streamTable) encodeRow(values map[string]interface{}) ([]byte, error) {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, t.arrow)
+	defer builder.Release()
+
+	for idx, field := range t.arrow.Fields() {
+		s := scalar.NewScalar(field.Type)
+		if err := s.Set(values[field.Name]); err != nil {
+			return nil, fmt.Errorf("error setting value for column %s: %w", field.Name, err)
+		}
+
+		scalar.AppendToBuilder(builder.Field(idx), s)
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+	return record.MarshalJSON()
+}
+
+// changeDecoder turns pgoutput messages into the pipeline messages. Tables are matched
+// by the schema and the name of the relation, so tables with the same name in different schemas
+// don't collide. Relation messages precede the changes of the table after every schema change,
+// so the rows are always decoded with the columns of the relation they were written with
+type changeDecoder struct {
+	controlEvents bool
+	beforeImage   bool
+	tables        map[string]*streamTable
+	relations     map[uint32]*pglogicalstream.RelationMessage
+}
+
+func newChangeDecoder(config Config, streamSchema []schema.StreamSchema) *changeDecoder {
+	decoder := &changeDecoder{
+		controlEvents: config.ControlEvents,
+		beforeImage:   config.BeforeImage,
+		tables:        map[string]*streamTable{},
+		relations:     map[uint32]*pglogicalstream.RelationMessage{},
+	}
+
+	for _, stream := range streamSchema {
+		decoder.tables[qualifiedTable(config, stream.StreamName)] = newStreamTable(tableName(stream.StreamName), stream.Columns)
+	}
+
+	if config.SnapshotWatermarks {
+		decoder.tables[qualifiedTable(config, snapshot.WatermarkTable)] = newStreamTable(snapshot.WatermarkTable, []schema.Column{
+			{Name: "id", DatabrewType: "String", NativeConnectorType: "character varying", PK: true},
+		})
+	}
+
+	return decoder
+}
+
+// tableNames returns the schema and the name of every streamed table
+func (d *changeDecoder) tableNames() [][2]string {
+	var names [][2]string
+	for qualified := range d.tables {
+		parts := strings.SplitN(qualified, ".", 2)
+		names = append(names, [2]string{parts[0], parts[1]})
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i][0]+"."+names[i][1] < names[j][0]+"."+names[j][1]
+	})
+
+	return names
+}
+
+// markKnown marks the column of the table existing upstream, so it's not reported as added
+func (d *changeDecoder) markKnown(namespace, table, column string) {
+	if streamed, ok := d.tables[fmt.Sprintf("%s.%s", namespace, table)]; ok {
+		streamed.known[column] = true
+	}
+}
+
+// loadColumns marks the current columns of the tables known, so only the columns added later are reported
+func (d *changeDecoder) loadColumns(ctx context.Context, conn *pgx.Conn) error {
+	var tables []string
+	for qualified := range d.tables {
+		tables = append(tables, qualified)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT table_schema, table_name, column_name FROM information_schema.columns
+		WHERE table_schema || '.' || table_name = ANY($1)`, tables)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var namespace, table, column string
+		if err = rows.Scan(&namespace, &table, &column); err != nil {
+			return err
+		}
+		d.markKnown(namespace, table, column)
+	}
+
+	return rows.Err()
+}
+
+// table returns the streamed table of the relation or nil if it's not streamed
+func (d *changeDecoder) table(relationID uint32) (*pglogicalstream.RelationMessage, *streamTable) {
+	relation, ok := d.relations[relationID]
+	if !ok {
+		return nil, nil
+	}
+
+	return relation, d.tables[fmt.Sprintf("%s.%s", relation.Namespace, relation.RelationName)]
+}
+
+// decode returns the messages for the row changes and the control events of the logical message
+func (d *changeDecoder) decode(logicalMsg pglogicalstream.Message) ([]*message.Message, error) {
+	switch logicalMsg := logicalMsg.(type) {
+	case *pglogicalstream.RelationMessage:
+		d.relations[logicalMsg.RelationID] = logicalMsg
+		return d.schemaChange(logicalMsg)
+	case *pglogicalstream.InsertMessage:
+		return d.rowChange(message.Insert, logicalMsg.RelationID, logicalMsg.Tuple, nil)
+	case *pglogicalstream.UpdateMessage:
+		return d.rowChange(message.Update, logicalMsg.RelationID, logicalMsg.NewTuple, logicalMsg.OldTuple)
+	case *pglogicalstream.DeleteMessage:
+		// delete carries the replica identity columns only, unless it's FULL
+		return d.rowChange(message.Delete, logicalMsg.RelationID, logicalMsg.OldTuple, nil)
+	case *pglogicalstream.TruncateMessage:
+		if !d.controlEvents {
+			return nil, nil
+		}
+
+		var truncated []*message.Message
+		for _, relationID := range logicalMsg.RelationIDs {
+			if _, table := d.table(relationID); table != nil {
+				truncated = append(truncated, sources.NewTruncateMessage(table.stream))
+			}
+		}
+		return truncated, nil
+	}
+
+	return nil, nil
+}
+
+func (d *changeDecoder) rowChange(event message.Event, relationID uint32, tuple, oldTuple *pglogicalstream.TupleData) ([]*message.Message, error) {
+	relation, table := d.table(relationID)
+	if table == nil || tuple == nil {
+		return nil, nil
+	}
+
+	values := tupleValues(relation, tuple)
+	if oldTuple != nil {
+		// unchanged TOASTed values are not sent with the new tuple, but the old one has them with REPLICA IDENTITY FULL
+		for name, value := range tupleValues(relation, oldTuple) {
+			if _, ok := values[name]; !ok {
+				values[name] = value
+			}
+		}
+	}
+
+	row, err := table.encodeRow(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s of %s: %w", event, table.stream, err)
+	}
+
+	msg := message.NewMessage(event, table.stream, row)
+	if !d.beforeImage || event == message.Insert {
+		return []*message.Message{msg}, nil
+	}
+
+	// deleted row is the state before the delete, update carries the old tuple with REPLICA IDENTITY FULL
+	before := oldTuple
+	if event == message.Delete {
+		before = tuple
+	}
+	if before == nil {
+		return []*message.Message{msg}, nil
+	}
+
+	beforeRow, err := table.encodeRow(tupleValues(relation, before))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode before image of %s: %w", table.stream, err)
+	}
+
+	var beforeRows []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(beforeRow))
+	decoder.UseNumber()
+	if err = decoder.Decode(&beforeRows); err != nil {
+		return nil, err
+	}
+
+	if err = helper.SetBeforeImage(msg, beforeRows[0]); err != nil {
+		return nil, err
+	}
+
+	return []*message.Message{msg}, nil
+}
+
+// schemaChange adds the columns of the relation that are not known yet to the table,
+// so the following rows are decoded with them, and reports them as the schema change.
+// Dropped columns are kept in the schema. Columns are added only with the control events enabled,
+// as the pipeline doesn't evolve the schema otherwise
+func (d *changeDecoder) schemaChange(relation *pglogicalstream.RelationMessage) ([]*message.Message, error) {
+	table := d.tables[fmt.Sprintf("%s.%s", relation.Namespace, relation.RelationName)]
+	if table == nil || !d.controlEvents {
+		return nil, nil
+	}
+
+	var change sources.SchemaChange
+	for _, column := range relation.Columns {
+		if table.known[column.Name] {
+			continue
+		}
+
+		pgType, ok := pgTypes[column.DataType]
+		if !ok {
+			pgType = [2]string{"String", "text"}
+		}
+
+		added := schema.Column{
+			Name:                column.Name,
+			DatabrewType:        pgType[0],
+			NativeConnectorType: pgType[1],
+			Nullable:            true,
+		}
+		table.addColumn(added)
+		change.Columns = append(change.Columns, added)
+	}
+
+	if len(change.Columns) == 0 {
+		return nil, nil
+	}
+
+	msg, err := sources.NewSchemaChangeMessage(table.stream, change)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*message.Message{msg}, nil
+}
+
+// tupleValues maps the text values of the tuple to the relation columns.
+// Unchanged TOASTed values are left out, as the tuple doesn't carry them
+func tupleValues(relation *pglogicalstream.RelationMessage, tuple *pglogicalstream.TupleData) map[string]interface{} {
+	values := map[string]interface{}{}
+	for idx, column := range tuple.Columns {
+		if idx >= len(relation.Columns) {
+			break
+		}
+
+		switch column.DataType {
+		case pglogicalstream.TupleDataTypeText:
+			values[relation.Columns[idx].Name] = string(column.Data)
+		case pglogicalstream.TupleDataTypeNull:
+			values[relation.Columns[idx].Name] = nil
+		}
+	}
+
+	return values
+}
+
+// qualifiedTable returns the table of the stream with the schema name.
+// Streams without the schema in the name belong to the configured schema
+func qualifiedTable(config Config, stream string) string {
+	if strings.Contains(stream, ".") {
+		return stream
+	}
+
+	return fmt.Sprintf("%s.%s", config.Schema, stream)
+}
+
+func tableName(stream string) string {
+	parts := strings.Split(stream, ".")
+	return parts[len(parts)-1]
+}
@@ -0,0 +1,172 @@
+package postgres_cdc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/message"
+	"github.com/usedatabrew/pglogicalstream"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "public.users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String", Nullable: true},
+		},
+	},
+}
+
+func testRelation(relationID uint32, namespace string, columns ...string) *pglogicalstream.RelationMessage {
+	relation := &pglogicalstream.RelationMessage{RelationID: relationID, Namespace: namespace, RelationName: "users"}
+	types := map[string]uint32{"id": 20, "name": 25, "legacy": 25, "age": 23}
+	for _, column := range columns {
+		relation.Columns = append(relation.Columns, &pglogicalstream.RelationMessageColumn{Name: column, DataType: types[column]})
+	}
+
+	return relation
+}
+
+// testTuple builds the tuple of text values. nil is sent as null and "-" as unchanged TOASTed value
+func testTuple(values ...interface{}) *pglogicalstream.TupleData {
+	tuple := &pglogicalstream.TupleData{}
+	for _, value := range values {
+		switch value {
+		case nil:
+			tuple.Columns = append(tuple.Columns, &pglogicalstream.TupleDataColumn{DataType: pglogicalstream.TupleDataTypeNull})
+		case "-":
+			tuple.Columns = append(tuple.Columns, &pglogicalstream.TupleDataColumn{DataType: pglogicalstream.TupleDataTypeToast})
+		default:
+			tuple.Columns = append(tuple.Columns, &pglogicalstream.TupleDataColumn{DataType: pglogicalstream.TupleDataTypeText, Data: []byte(value.(string))})
+		}
+	}
+
+	return tuple
+}
+
+func decodeOne(t *testing.T, decoder *changeDecoder, logicalMsg pglogicalstream.Message) *message.Message {
+	messages, err := decoder.decode(logicalMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected a single message, got %d", len(messages))
+	}
+
+	return messages[0]
+}
+
+func TestChangeDecoder_SchemaChange(t *testing.T) {
+	decoder := newChangeDecoder(Config{Schema: "public", ControlEvents: true}, testStreamSchema)
+	// legacy column exists upstream, but it's not streamed
+	decoder.markKnown("public", "users", "legacy")
+
+	change := decodeOne(t, decoder, testRelation(1, "public", "id", "name", "legacy", "age"))
+	if change.GetEvent() != sources.SchemaChangeEvent || change.GetStream() != "users" {
+		t.Fatalf("expected schema change of users, got %s %s", change.GetEvent(), change.GetStream())
+	}
+
+	columns, err := sources.ReadSchemaChange(change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns.Columns) != 1 || columns.Columns[0].Name != "age" || columns.Columns[0].DatabrewType != "Int32" {
+		t.Fatalf("unexpected schema change %+v", columns.Columns)
+	}
+
+	// value of the added column is decoded from the following rows
+	inserted := decodeOne(t, decoder, &pglogicalstream.InsertMessage{RelationID: 1, Tuple: testTuple("1", "Max", "x", "30")})
+	row := helper.MessageRow(inserted)
+	if inserted.GetEvent() != message.Insert || row["age"] != json.Number("30") || row["name"] != "Max" {
+		t.Fatalf("unexpected row %s", inserted.AsJSONString())
+	}
+	if _, ok := row["legacy"]; ok {
+		t.Fatal("columns not in the stream schema must not be decoded")
+	}
+
+	if messages, _ := decoder.decode(testRelation(1, "public", "id", "name", "legacy", "age")); len(messages) != 0 {
+		t.Fatal("columns must be reported only once")
+	}
+
+	// table with the same name in the other schema is not streamed
+	if messages, _ := decoder.decode(testRelation(2, "archive", "id", "name", "email")); len(messages) != 0 {
+		t.Fatal("tables of the other schemas must be skipped")
+	}
+	if messages, _ := decoder.decode(&pglogicalstream.InsertMessage{RelationID: 2, Tuple: testTuple("1", "Max", "max@databrew.tech")}); len(messages) != 0 {
+		t.Fatal("rows of the other schemas must be skipped")
+	}
+
+	truncated := decodeOne(t, decoder, &pglogicalstream.TruncateMessage{RelationIDs: []uint32{1, 2}})
+	if truncated.GetEvent() != sources.TruncateEvent || truncated.GetStream() != "users" {
+		t.Fatalf("unexpected truncate %s %s", truncated.GetEvent(), truncated.GetStream())
+	}
+}
+
+func TestChangeDecoder_ControlEventsDisabled(t *testing.T) {
+	decoder := newChangeDecoder(Config{Schema: "public"}, testStreamSchema)
+	if messages, _ := decoder.decode(testRelation(1, "public", "id", "name", "age")); len(messages) != 0 {
+		t.Fatal("schema changes must not be emitted without the control events")
+	}
+
+	inserted := decodeOne(t, decoder, &pglogicalstream.InsertMessage{RelationID: 1, Tuple: testTuple("1", "Max", "30")})
+	if _, ok := helper.MessageRow(inserted)["age"]; ok {
+		t.Fatal("schema is static without the control events")
+	}
+
+	if messages, _ := decoder.decode(&pglogicalstream.TruncateMessage{RelationIDs: []uint32{1}}); len(messages) != 0 {
+		t.Fatal("truncates must not be emitted without the control events")
+	}
+}
+
+func TestChangeDecoder_Update(t *testing.T) {
+	decoder := newChangeDecoder(Config{Schema: "public"}, testStreamSchema)
+	decoder.decode(testRelation(1, "public", "id", "name"))
+
+	// unchanged TOASTed value is taken from the old tuple
+	updated := decodeOne(t, decoder, &pglogicalstream.UpdateMessage{RelationID: 1, OldTuple: testTuple("1", "Max"), NewTuple: testTuple("1", "-")})
+	if row := helper.MessageRow(updated); updated.GetEvent() != message.Update || row["name"] != "Max" {
+		t.Fatalf("unexpected row %s", updated.AsJSONString())
+	}
+
+	updated = decodeOne(t, decoder, &pglogicalstream.UpdateMessage{RelationID: 1, OldTuple: testTuple("1", "Max"), NewTuple: testTuple("1", nil)})
+	if row := helper.MessageRow(updated); row["name"] != nil || row["id"] != json.Number("1") {
+		t.Fatalf("null must not be replaced by the old value %s", updated.AsJSONString())
+	}
+
+	deleted := decodeOne(t, decoder, &pglogicalstream.DeleteMessage{RelationID: 1, OldTuple: testTuple("1", nil)})
+	if row := helper.MessageRow(deleted); deleted.GetEvent() != message.Delete || row["id"] != json.Number("1") {
+		t.Fatalf("unexpected row %s", deleted.AsJSONString())
+	}
+}
+
+func TestChangeDecoder_BeforeImage(t *testing.T) {
+	decoder := newChangeDecoder(Config{Schema: "public", BeforeImage: true}, testStreamSchema)
+	decoder.decode(testRelation(1, "public", "id", "name"))
+
+	inserted := decodeOne(t, decoder, &pglogicalstream.InsertMessage{RelationID: 1, Tuple: testTuple("1", "Max")})
+	if helper.BeforeImage(inserted) != nil {
+		t.Fatal("insert must not carry the before image")
+	}
+
+	updated := decodeOne(t, decoder, &pglogicalstream.UpdateMessage{RelationID: 1, OldTupleType: 'O', OldTuple: testTuple("1", "Max"), NewTuple: testTuple("1", "Alex")})
+	before := helper.BeforeImage(updated)
+	if before == nil || before["name"] != "Max" || before["id"] != json.Number("1") || helper.MessageRow(updated)["name"] != "Alex" {
+		t.Fatalf("unexpected update %s", updated.AsJSONString())
+	}
+
+	deleted := decodeOne(t, decoder, &pglogicalstream.DeleteMessage{RelationID: 1, OldTupleType: 'O', OldTuple: testTuple("1", "Alex")})
+	if before = helper.BeforeImage(deleted); before == nil || before["name"] != "Alex" {
+		t.Fatalf("unexpected delete %s", deleted.AsJSONString())
+	}
+
+	decoder = newChangeDecoder(Config{Schema: "public"}, testStreamSchema)
+	decoder.decode(testRelation(1, "public", "id", "name"))
+	updated = decodeOne(t, decoder, &pglogicalstream.UpdateMessage{RelationID: 1, OldTuple: testTuple("1", "Max"), NewTuple: testTuple("1", "Alex")})
+	if helper.BeforeImage(updated) != nil {
+		t.Fatal("before image must be attached only when enabled")
+	}
+}
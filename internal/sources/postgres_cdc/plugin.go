@@ -2,59 +2,63 @@ package postgres_cdc
 
 import (
 	"context"
-	"fmt"
 	"github.com/charmbracelet/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/sources"
-	"github.com/usedatabrew/message"
-	"github.com/usedatabrew/pglogicalstream"
-	"strings"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/blink/internal/stream_context"
 )
 
 type SourcePlugin struct {
 	ctx            context.Context
+	appCtx         *stream_context.Context
 	config         Config
+	logger         *log.Logger
 	streamSchema   []schema.StreamSchema
-	stream         *pglogicalstream.Stream
+	stream         *replicationStream
 	messagesStream chan sources.MessageEvent
+	snapshotter    *snapshot.Snapshotter
 }
 
-func NewPostgresSourcePlugin(config Config, schema []schema.StreamSchema) sources.DataSource {
+func NewPostgresSourcePlugin(appCtx *stream_context.Context, config Config, schema []schema.StreamSchema) sources.DataSource {
 	return &SourcePlugin{
+		appCtx:         appCtx,
 		config:         config,
+		logger:         log.WithPrefix("[source]: PostgreSQL-CDC"),
 		streamSchema:   schema,
 		messagesStream: make(chan sources.MessageEvent),
 	}
 }
 
 func (p *SourcePlugin) Connect(ctx context.Context) error {
-	tlsVerifyMode := pglogicalstream.TlsNoVerify
-	if p.config.SSLRequired {
-		tlsVerifyMode = pglogicalstream.TlsRequireVerify
+	conn, err := pgx.Connect(ctx, connectionString(p.config, false))
+	if err != nil {
+		return err
 	}
+	defer conn.Close(ctx)
 
-	pgStream, err := pglogicalstream.NewPgStream(pglogicalstream.Config{
-		DbHost:                     p.config.Host,
-		DbPassword:                 p.config.Password,
-		DbUser:                     p.config.User,
-		DbPort:                     p.config.Port,
-		DbName:                     p.config.Database,
-		DbSchema:                   p.config.Schema,
-		DbTablesSchema:             p.buildPluginsSchema(),
-		ReplicationSlotName:        fmt.Sprintf("rs_%s", p.config.SlotName),
-		TlsVerify:                  tlsVerifyMode,
-		StreamOldData:              p.config.StreamSnapshot,
-		SnapshotMemorySafetyFactor: 0.3,
-		BatchSize:                  13500,
-		SeparateChanges:            true,
-	}, log.WithPrefix("[source]: PostgreSQL-CDC"))
-	if err != nil {
+	if err = validateDatabase(ctx, conn, p.config, p.streamSchema); err != nil {
 		return err
 	}
 
-	p.stream = pgStream
 	p.ctx = ctx
-	return nil
+	if err = p.connectSnapshot(ctx); err != nil {
+		return err
+	}
+
+	if err = ensurePublication(ctx, conn, publicationName(p.config), qualifiedTables(p.config, p.streamSchema)); err != nil {
+		return err
+	}
+
+	decoder := newChangeDecoder(p.config, p.streamSchema)
+	if err = decoder.loadColumns(ctx, conn); err != nil {
+		return err
+	}
+
+	p.stream = newReplicationStream(p.config, decoder)
+	return p.stream.Connect(ctx)
 }
 
 func (p *SourcePlugin) Events() chan sources.MessageEvent {
@@ -62,62 +66,52 @@ func (p *SourcePlugin) Events() chan sources.MessageEvent {
 }
 
 func (p *SourcePlugin) Start() {
-	for {
-		select {
-		// snapshot messages channel always opens first
-		// so, here we can be positive about message ordering
-		// logical replication messages will be streamed after the snapshot is processed
-		case snapshotMessage := <-p.stream.SnapshotMessageC():
-			m := snapshotMessage.Changes[0].Row
-			mbytes, _ := m.MarshalJSON()
-			builtMessage := message.NewMessage(message.Snapshot, snapshotMessage.Changes[0].Table, mbytes)
-			p.messagesStream <- sources.MessageEvent{
-				Message: builtMessage,
-				Err:     nil,
-			}
-		case lrMessage := <-p.stream.LrMessageC():
-			m := lrMessage.Changes[0].Row
-			mbytes, _ := m.MarshalJSON()
-			builtMessage := message.NewMessage(message.Event(lrMessage.Changes[0].Kind), lrMessage.Changes[0].Table, mbytes)
-			p.messagesStream <- sources.MessageEvent{
-				Message: builtMessage,
-				Err:     nil,
-			}
-			p.stream.AckLSN(lrMessage.Lsn)
+	// the snapshot exported by the slot is streamed before the changes
+	go p.stream.Start(p.ctx)
+
+	if p.config.StreamSnapshot && p.config.Chunked() {
+		// without the watermarks the snapshot is taken before the replication stream is consumed,
+		// with them the stream has to run along to receive the watermarks
+		if p.config.SnapshotWatermarks {
+			go p.takeSnapshot()
+		} else {
+			p.takeSnapshot()
 		}
 	}
 
-}
+	for event := range p.stream.Events() {
+		if event.commit {
+			p.stream.Confirm(event.lsn)
+			continue
+		}
 
-func (p *SourcePlugin) Stop() {
-	err := p.stream.Stop()
-	if err != nil {
-		fmt.Println("Failed to close producer", err)
+		builtMessage := event.message
+		if builtMessage.GetStream() == snapshot.WatermarkTable {
+			p.handleWatermark(builtMessage)
+			continue
+		}
+		if p.snapshotter != nil && !sources.IsControlEvent(builtMessage.GetEvent()) {
+			p.snapshotter.OnChange(builtMessage)
+		}
+		if event.position != "" && !sources.IsControlEvent(builtMessage.GetEvent()) {
+			if err := helper.SetPosition(builtMessage, event.position); err != nil {
+				p.logger.Fatalf("Failed to set the position of the change: %s", err.Error())
+			}
+		}
+		p.messagesStream <- sources.MessageEvent{
+			Message: builtMessage,
+			Err:     nil,
+		}
 	}
 }
 
-func (p *SourcePlugin) buildPluginsSchema() []pglogicalstream.DbTablesSchema {
-	var tablesSchema []pglogicalstream.DbTablesSchema
-	for _, stream := range p.streamSchema {
-		tSch := pglogicalstream.DbTablesSchema{}
-		if len(strings.Split(stream.StreamName, ".")) == 2 {
-			// schema name included in stream.
-			tSch.Table = stream.StreamName
-		} else {
-			tSch.Table = fmt.Sprintf("%s.%s", p.config.Schema, stream.StreamName)
-		}
-		for _, schemaCol := range stream.Columns {
-			tSch.Columns = append(tSch.Columns, pglogicalstream.DbSchemaColumn{
-				Name:                schemaCol.Name,
-				DatabrewType:        schemaCol.DatabrewType,
-				NativeConnectorType: schemaCol.NativeConnectorType,
-				Pk:                  schemaCol.PK,
-				Nullable:            schemaCol.Nullable,
-			})
-		}
+// BeforeImageEnabled reports if the update and delete messages carry the before image
+func (p *SourcePlugin) BeforeImageEnabled() bool {
+	return p.config.BeforeImage
+}
 
-		tablesSchema = append(tablesSchema, tSch)
+func (p *SourcePlugin) Stop() {
+	if p.stream != nil {
+		p.stream.Stop()
 	}
-
-	return tablesSchema
 }
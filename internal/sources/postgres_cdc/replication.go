@@ -0,0 +1,320 @@
+package postgres_cdc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/usedatabrew/message"
+	"github.com/usedatabrew/pglogicalstream"
+)
+
+const (
+	outputPlugin          = "pgoutput"
+	standbyMessageTimeout = 10 * time.Second
+)
+
+// changeEvent is the message decoded from the WAL with the position of the change.
+// Commit events carry no message, their position is confirmed once the preceding messages are delivered
+type changeEvent struct {
+	lsn     pglogicalstream.LSN
+	commit  bool
+	message *message.Message
+	// position is the LSN of the change and its index among the changes of the same LSN,
+	// e.g. the rows of the multi-row insert. Snapshot rows have none
+	position string
+}
+
+// replicationStream reads the managed publication from the pgoutput slot. Row changes, truncates
+// and relation messages come from the single slot in the WAL order, so the control events
+// are ordered with the row changes by their LSN
+type replicationStream struct {
+	config      Config
+	conn        *pgconn.PgConn
+	logger      *log.Logger
+	slotName    string
+	publication string
+	decoder     *changeDecoder
+	events      chan changeEvent
+	// snapshotName is exported by the slot created on connect.
+	// The snapshot is streamed before the changes made after it
+	snapshotName string
+
+	mutx      sync.Mutex
+	confirmed pglogicalstream.LSN
+
+	// lastLSN and lastIndex number the changes sharing the LSN
+	lastLSN   pglogicalstream.LSN
+	lastIndex int
+}
+
+func newReplicationStream(config Config, decoder *changeDecoder) *replicationStream {
+	return &replicationStream{
+		config:      config,
+		logger:      log.WithPrefix("[source]: PostgreSQL-CDC replication"),
+		slotName:    slotName(config),
+		publication: publicationName(config),
+		decoder:     decoder,
+		events:      make(chan changeEvent),
+	}
+}
+
+// Connect creates the replication slot unless it exists. The publication has to be created beforehand.
+// The slot created with wal2json by the previous versions is migrated to the new pgoutput slot
+func (r *replicationStream) Connect(ctx context.Context) error {
+	cfg, err := pgconn.ParseConfig(connectionString(r.config, true))
+	if err != nil {
+		return err
+	}
+
+	if r.conn, err = pgconn.ConnectConfig(ctx, cfg); err != nil {
+		return err
+	}
+
+	migrated, err := r.slotInfo(ctx, migratedSlotName(r.config))
+	if err != nil {
+		return err
+	}
+	legacy, err := r.slotInfo(ctx, r.slotName)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case migrated != nil:
+		r.slotName = migrated.name
+		if legacy != nil {
+			// the previous run stopped before the old slot was dropped
+			return r.dropLegacySlot(ctx, legacy)
+		}
+		return nil
+	case legacy != nil && legacy.plugin == outputPlugin:
+		return nil
+	case legacy != nil:
+		return r.migrateSlot(ctx, legacy)
+	}
+
+	return r.createSlot(ctx)
+}
+
+// slotState is the state of the existing replication slot
+type slotState struct {
+	name   string
+	plugin string
+	active bool
+	// pendingBytes is the WAL written after the position confirmed by the slot
+	pendingBytes int64
+}
+
+// slotInfo returns the state of the slot, nil when it doesn't exist
+func (r *replicationStream) slotInfo(ctx context.Context, name string) (*slotState, error) {
+	results, err := r.conn.Exec(ctx, fmt.Sprintf(
+		"SELECT plugin, active, COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn), 0)::bigint "+
+			"FROM pg_replication_slots WHERE slot_name = '%s'", name,
+	)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 || len(results[0].Rows) == 0 {
+		return nil, nil
+	}
+
+	row := results[0].Rows[0]
+	pendingBytes, err := strconv.ParseInt(string(row[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &slotState{name: name, plugin: string(row[0]), active: string(row[1]) == "t", pendingBytes: pendingBytes}, nil
+}
+
+// migrateSlot switches the pipeline from the slot of the other output plugin to the new pgoutput slot.
+// The new slot starts at the current WAL position, so the changes the old slot hasn't confirmed
+// are not streamed unless the snapshot is. The old slot is dropped once the new one is created
+func (r *replicationStream) migrateSlot(ctx context.Context, legacy *slotState) error {
+	if legacy.active {
+		return fmt.Errorf("replication slot %s uses %s output plugin and is in use. "+
+			"Stop the pipeline reading it to migrate the slot to %s", legacy.name, legacy.plugin, outputPlugin)
+	}
+
+	r.slotName = migratedSlotName(r.config)
+	r.logger.Warn("Migrating replication slot to the new output plugin", "slot", legacy.name, "plugin", legacy.plugin,
+		"new_slot", r.slotName, "new_plugin", outputPlugin)
+
+	if err := r.createSlot(ctx); err != nil {
+		return err
+	}
+
+	// the snapshot exported by the new slot resyncs the streams
+	if legacy.pendingBytes > 0 && r.snapshotName == "" {
+		r.logger.Warn("Changes not confirmed by the old replication slot are not streamed, backfill the streams to resync them",
+			"slot", legacy.name, "pending_bytes", legacy.pendingBytes)
+	}
+
+	return r.dropLegacySlot(ctx, legacy)
+}
+
+func (r *replicationStream) dropLegacySlot(ctx context.Context, legacy *slotState) error {
+	r.logger.Info("Dropping replication slot", "slot", legacy.name)
+	if _, err := r.conn.Exec(ctx, fmt.Sprintf("SELECT pg_drop_replication_slot('%s')", legacy.name)).ReadAll(); err != nil {
+		return fmt.Errorf("failed to drop replication slot %s: %w", legacy.name, err)
+	}
+
+	return nil
+}
+
+func (r *replicationStream) createSlot(ctx context.Context) error {
+	options := pglogicalstream.CreateReplicationSlotOptions{Mode: pglogicalstream.LogicalReplication}
+	if r.config.StreamSnapshot && !r.config.Chunked() {
+		options.SnapshotAction = "export"
+	}
+
+	r.logger.Info("Creating replication slot", "slot", r.slotName)
+	result, err := pglogicalstream.CreateReplicationSlot(ctx, r.conn, r.slotName, outputPlugin, options)
+	if err != nil {
+		return fmt.Errorf("failed to create replication slot %s: %w", r.slotName, err)
+	}
+
+	if options.SnapshotAction == "export" {
+		r.snapshotName = result.SnapshotName
+	}
+
+	return nil
+}
+
+func (r *replicationStream) Events() chan changeEvent {
+	return r.events
+}
+
+// Confirm lets the slot advance to the position once the messages before it are delivered
+func (r *replicationStream) Confirm(lsn pglogicalstream.LSN) {
+	r.mutx.Lock()
+	defer r.mutx.Unlock()
+
+	if lsn > r.confirmed {
+		r.confirmed = lsn
+	}
+}
+
+func (r *replicationStream) confirmedLSN() pglogicalstream.LSN {
+	r.mutx.Lock()
+	defer r.mutx.Unlock()
+
+	return r.confirmed
+}
+
+func (r *replicationStream) Start(ctx context.Context) {
+	if r.snapshotName != "" {
+		if err := r.streamSnapshot(ctx); err != nil {
+			r.logger.Fatalf("Failed to take snapshot: %s", err.Error())
+		}
+	}
+
+	publication := strings.ReplaceAll(pgx.Identifier{r.publication}.Sanitize(), "'", "''")
+	err := pglogicalstream.StartReplication(ctx, r.conn, r.slotName, 0, pglogicalstream.StartReplicationOptions{
+		Mode:       pglogicalstream.LogicalReplication,
+		PluginArgs: []string{"proto_version '1'", fmt.Sprintf("publication_names '%s'", publication)},
+	})
+	if err != nil {
+		r.logger.Fatalf("Starting replication slot failed: %s", err.Error())
+	}
+	r.logger.Info("Started logical replication on slot", "slot", r.slotName, "publication", r.publication)
+
+	nextStandbyMessageDeadline := time.Now().Add(standbyMessageTimeout)
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Now().After(nextStandbyMessageDeadline) {
+			if err := pglogicalstream.SendStandbyStatusUpdate(ctx, r.conn, pglogicalstream.StandbyStatusUpdate{
+				WALWritePosition: r.confirmedLSN(),
+			}); err != nil {
+				r.logger.Fatalf("SendStandbyStatusUpdate failed: %s", err.Error())
+			}
+			nextStandbyMessageDeadline = time.Now().Add(standbyMessageTimeout)
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, nextStandbyMessageDeadline)
+		rawMsg, err := r.conn.ReceiveMessage(receiveCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) {
+				continue
+			}
+			r.logger.Fatalf("Failed to receive messages from PostgreSQL %s", err.Error())
+		}
+
+		if errMsg, ok := rawMsg.(*pgproto3.ErrorResponse); ok {
+			r.logger.Fatalf("Received broken Postgres WAL. Error: %+v", errMsg)
+		}
+
+		msg, ok := rawMsg.(*pgproto3.CopyData)
+		if !ok {
+			continue
+		}
+
+		switch msg.Data[0] {
+		case pglogicalstream.PrimaryKeepaliveMessageByteID:
+			pkm, err := pglogicalstream.ParsePrimaryKeepaliveMessage(msg.Data[1:])
+			if err != nil {
+				r.logger.Fatalf("ParsePrimaryKeepaliveMessage failed: %s", err.Error())
+			}
+			if pkm.ReplyRequested {
+				nextStandbyMessageDeadline = time.Time{}
+			}
+		case pglogicalstream.XLogDataByteID:
+			xld, err := pglogicalstream.ParseXLogData(msg.Data[1:])
+			if err != nil {
+				r.logger.Fatalf("ParseXLogData failed: %s", err.Error())
+			}
+			if err = r.handle(xld); err != nil {
+				r.logger.Fatalf("Failed to decode logical replication message: %s", err.Error())
+			}
+		}
+	}
+}
+
+func (r *replicationStream) handle(xld pglogicalstream.XLogData) error {
+	logicalMsg, err := pglogicalstream.Parse(xld.WALData)
+	if err != nil {
+		return err
+	}
+
+	if commit, ok := logicalMsg.(*pglogicalstream.CommitMessage); ok {
+		r.events <- changeEvent{lsn: commit.TransactionEndLSN, commit: true}
+		return nil
+	}
+
+	messages, err := r.decoder.decode(logicalMsg)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		if xld.WALStart == r.lastLSN {
+			r.lastIndex += 1
+		} else {
+			r.lastLSN = xld.WALStart
+			r.lastIndex = 0
+		}
+
+		r.events <- changeEvent{lsn: xld.WALStart, message: msg, position: fmt.Sprintf("%s:%d", xld.WALStart, r.lastIndex)}
+	}
+
+	return nil
+}
+
+func (r *replicationStream) Stop() {
+	if r.conn != nil {
+		r.conn.Close(context.Background())
+	}
+}
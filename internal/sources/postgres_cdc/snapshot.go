@@ -0,0 +1,142 @@
+package postgres_cdc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+	"github.com/usedatabrew/message"
+)
+
+type snapshotDialect struct{}
+
+func (d snapshotDialect) Placeholder(position int) string {
+	return fmt.Sprintf("$%d", position)
+}
+
+func (d snapshotDialect) Page(query string, limit, offset int) string {
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", query, limit, offset)
+}
+
+// connectSnapshot prepares the chunked snapshot and the backfills. The watermark table has to exist
+// before the publication is set up, as it's published along with the tables
+func (p *SourcePlugin) connectSnapshot(ctx context.Context) error {
+	db, err := sql.Open("pgx", connectionString(p.config, false))
+	if err != nil {
+		return err
+	}
+
+	var watermarks snapshot.Watermarks
+	if p.config.SnapshotWatermarks {
+		sqlWatermarks := snapshot.NewSQLWatermarks(db, snapshotDialect{}, p.config.Schema)
+		if err = sqlWatermarks.CreateTable(ctx); err != nil {
+			return fmt.Errorf("failed to create watermark table: %w", err)
+		}
+		watermarks = sqlWatermarks
+	}
+
+	reader := snapshot.NewSQLReader(db, snapshotDialect{}, p.config.Schema, p.streamSchema)
+	p.snapshotter = snapshot.NewSnapshotter(p.appCtx, p.config.Config, reader, watermarks, p.streamSchema)
+	return nil
+}
+
+// takeSnapshot runs the chunked snapshot. With the watermarks it runs along with the replication stream
+func (p *SourcePlugin) takeSnapshot() {
+	if err := p.snapshotter.Run(p.ctx, p.emit); err != nil {
+		p.logger.Fatalf("Failed to take snapshot: %s", err.Error())
+	}
+}
+
+// Backfill takes the snapshot of the streams again while the replication stream keeps running
+func (p *SourcePlugin) Backfill(request sources.BackfillRequest) error {
+	return p.snapshotter.StartBackfill(p.ctx, request.ID, request.Streams, request.Filters, p.emit)
+}
+
+func (p *SourcePlugin) emit(msg *message.Message) {
+	p.messagesStream <- sources.MessageEvent{
+		Message: msg,
+		Err:     nil,
+	}
+}
+
+// streamSnapshot reads the tables within the snapshot exported by the slot created on connect,
+// so the changes streamed from the slot start right after the rows of the snapshot
+func (r *replicationStream) streamSnapshot(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, connectionString(r.config, false))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", r.snapshotName)); err != nil {
+		return err
+	}
+
+	for _, name := range r.decoder.tableNames() {
+		table := r.decoder.tables[name[0]+"."+name[1]]
+		if table.stream == snapshot.WatermarkTable {
+			continue
+		}
+
+		r.logger.Info("Processing database snapshot", "schema", name[0], "table", name[1])
+		var columns []string
+		for _, column := range table.columns {
+			columns = append(columns, pgx.Identifier{column.Name}.Sanitize())
+		}
+
+		// values are read as text, so they are decoded the same way as the values of the changes
+		query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), pgx.Identifier{name[0], name[1]}.Sanitize())
+		rows, err := tx.Query(ctx, query, pgx.QueryResultFormats{pgx.TextFormatCode})
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			values := map[string]interface{}{}
+			for idx, raw := range rows.RawValues() {
+				if raw != nil {
+					values[table.columns[idx].Name] = string(raw)
+				}
+			}
+
+			row, err := table.encodeRow(values)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			r.events <- changeEvent{message: message.NewMessage(message.Snapshot, table.stream, row)}
+		}
+
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// handleWatermark passes the watermark received by the replication stream to the snapshot
+func (p *SourcePlugin) handleWatermark(msg *message.Message) {
+	if p.snapshotter == nil || msg.GetEvent() != message.Insert {
+		return
+	}
+
+	id, _ := helper.MessageRow(msg)["id"].(string)
+	if err := p.snapshotter.OnWatermark(id, p.emit); err != nil {
+		p.logger.Fatalf("Failed to store snapshot progress: %s", err.Error())
+	}
+}
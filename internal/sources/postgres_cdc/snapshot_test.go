@@ -0,0 +1,25 @@
+package postgres_cdc
+
+import (
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/sources/snapshot"
+)
+
+func TestChangeDecoder_WatermarkTable(t *testing.T) {
+	config := Config{Schema: "public", StreamSnapshot: true}
+	if tables := qualifiedTables(config, testStreamSchema); len(tables) != 1 || len(newChangeDecoder(config, testStreamSchema).tables) != 1 {
+		t.Fatal("watermark table must be streamed only with the watermarks enabled")
+	}
+
+	config.Config = snapshot.Config{SnapshotChunkSize: 1000, SnapshotWatermarks: true}
+	tables := qualifiedTables(config, testStreamSchema)
+	if len(tables) != 2 || publicationTableList(tables[1:]) != `"public"."blink_watermarks"` {
+		t.Fatalf("unexpected tables %+v", tables)
+	}
+
+	decoder := newChangeDecoder(config, testStreamSchema)
+	if names := decoder.tableNames(); len(names) != 2 || names[0] != [2]string{"public", "blink_watermarks"} {
+		t.Fatalf("unexpected decoded tables %v", names)
+	}
+}
@@ -1,15 +1,18 @@
 package postgres_incr_sync
 
-import "github.com/usedatabrew/pglogicalstream"
+import (
+	"github.com/usedatabrew/blink/internal/sources/incremental"
+	"github.com/usedatabrew/pglogicalstream"
+)
 
 type Config struct {
-	Host           string                           `json:"host" yaml:"host"`
-	Port           int                              `json:"port" yaml:"port"`
-	Database       string                           `json:"database" yaml:"database"`
-	User           string                           `json:"user" yaml:"user"`
-	Schema         string                           `json:"schema" yaml:"schema"`
-	Password       string                           `json:"password" yaml:"password"`
-	TablesSchema   []pglogicalstream.DbTablesSchema `json:"tables_schema" yaml:"tables_schema"`
-	SSLRequired    bool                             `json:"ssl_required" yaml:"ssl_required"`
-	StreamSnapshot bool                             `json:"stream_snapshot" yaml:"stream_snapshot"`
+	Host               string                           `json:"host" yaml:"host"`
+	Port               int                              `json:"port" yaml:"port"`
+	Database           string                           `json:"database" yaml:"database"`
+	User               string                           `json:"user" yaml:"user"`
+	Schema             string                           `json:"schema" yaml:"schema"`
+	Password           string                           `json:"password" yaml:"password"`
+	TablesSchema       []pglogicalstream.DbTablesSchema `json:"tables_schema" yaml:"tables_schema"`
+	SSLRequired        bool                             `json:"ssl_required" yaml:"ssl_required"`
+	incremental.Config `yaml:",inline"`
 }
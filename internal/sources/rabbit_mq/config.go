@@ -0,0 +1,23 @@
+package rabbit_mq
+
+// Config of the rabbit_mq source. Messages are acknowledged once they are written by the sink,
+// the unacknowledged ones are requeued by the broker when the connection is closed
+type Config struct {
+	Url string `json:"url" yaml:"url"`
+	// Queue to consume
+	Queue string `json:"queue" yaml:"queue"`
+	// Prefetch is the max amount of the messages delivered and not acknowledged yet. Defaults to 100
+	Prefetch int `json:"prefetch" yaml:"prefetch"`
+	// Declare declares the durable queue and binds it to the exchange with the routing keys.
+	// The queue has to exist otherwise
+	Declare bool `json:"declare" yaml:"declare"`
+	// ExchangeName and RoutingKeys are the bindings of the declared queue
+	ExchangeName string   `json:"exchange_name" yaml:"exchange_name"`
+	RoutingKeys  []string `json:"routing_keys" yaml:"routing_keys"`
+	// DeadLetterExchange of the declared queue receives the messages that can't be read or the pipeline fails to process.
+	// Such messages are dropped when the queue has no dead letter exchange
+	DeadLetterExchange string `json:"dead_letter_exchange" yaml:"dead_letter_exchange"`
+	// RequeueOnFailure returns the messages the pipeline fails to process to the queue instead of rejecting them,
+	// so they are delivered again. Messages that can't be read are rejected anyway
+	RequeueOnFailure bool `json:"requeue_on_failure" yaml:"requeue_on_failure"`
+}
@@ -0,0 +1,170 @@
+package rabbit_mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+
+	"github.com/wagslane/go-rabbitmq"
+)
+
+const defaultPrefetch = 100
+
+// SourcePlugin consumes the queue. Every message is bound to the stream of the schema
+// and acknowledged once the stream reports it's written
+type SourcePlugin struct {
+	config        Config
+	conn          *rabbitmq.Conn
+	consumer      *rabbitmq.Consumer
+	builder       *sources.BrokerMessageBuilder
+	pending       map[*message.Message]rabbitmq.Delivery
+	mutex         sync.Mutex
+	logger        *log.Logger
+	messageStream chan sources.MessageEvent
+}
+
+func NewRabbitMqSourcePlugin(config Config, schema []schema.StreamSchema, appCtx *stream_context.Context) sources.DataSource {
+	if config.Prefetch <= 0 {
+		config.Prefetch = defaultPrefetch
+	}
+
+	return &SourcePlugin{
+		config:        config,
+		builder:       sources.NewBrokerMessageBuilder(schema),
+		pending:       map[*message.Message]rabbitmq.Delivery{},
+		logger:        appCtx.Logger.WithPrefix("[source]: rabbit_mq"),
+		messageStream: make(chan sources.MessageEvent),
+	}
+}
+
+func (p *SourcePlugin) Connect(ctx context.Context) error {
+	if p.config.Queue == "" {
+		return errors.New("queue is required for the rabbit_mq source")
+	}
+
+	conn, err := rabbitmq.NewConn(p.config.Url, rabbitmq.WithConnectionOptionsLogging)
+	if err != nil {
+		return err
+	}
+
+	p.conn = conn
+	return nil
+}
+
+// Start starts consuming. Messages are handled one by one to keep the order of the queue
+func (p *SourcePlugin) Start() {
+	options := []func(*rabbitmq.ConsumerOptions){
+		rabbitmq.WithConsumerOptionsQOSPrefetch(p.config.Prefetch),
+		rabbitmq.WithConsumerOptionsConcurrency(1),
+	}
+
+	if p.config.Declare {
+		options = append(options, rabbitmq.WithConsumerOptionsQueueDurable)
+		if p.config.DeadLetterExchange != "" {
+			options = append(options, rabbitmq.WithConsumerOptionsQueueArgs(rabbitmq.Table{"x-dead-letter-exchange": p.config.DeadLetterExchange}))
+		}
+		if p.config.ExchangeName != "" {
+			options = append(options, rabbitmq.WithConsumerOptionsExchangeName(p.config.ExchangeName))
+			for _, routingKey := range p.config.RoutingKeys {
+				options = append(options, rabbitmq.WithConsumerOptionsRoutingKey(routingKey))
+			}
+		}
+	} else {
+		options = append(options, rabbitmq.WithConsumerOptionsQueueNoDeclare)
+	}
+
+	consumer, err := rabbitmq.NewConsumer(p.conn, p.handle, p.config.Queue, options...)
+	if err != nil {
+		p.logger.Fatalf("Failed to consume queue %s: %s", p.config.Queue, err.Error())
+	}
+
+	p.consumer = consumer
+}
+
+func (p *SourcePlugin) Events() chan sources.MessageEvent {
+	return p.messageStream
+}
+
+// Ack acknowledges the messages written by the sink
+func (p *SourcePlugin) Ack(messages []*message.Message) {
+	for _, delivery := range p.settle(messages) {
+		if err := delivery.Ack(false); err != nil {
+			p.logger.Error("Failed to ack message", "routing_key", delivery.RoutingKey, "err", err)
+		}
+	}
+}
+
+// Nack rejects the messages the pipeline failed to process, so they go to the dead letter exchange of the queue.
+// They are requeued instead when the source requeues on failure
+func (p *SourcePlugin) Nack(messages []*message.Message) {
+	for _, delivery := range p.settle(messages) {
+		if err := delivery.Nack(false, p.config.RequeueOnFailure); err != nil {
+			p.logger.Error("Failed to reject message", "routing_key", delivery.RoutingKey, "err", err)
+		}
+	}
+}
+
+// settle returns the pending deliveries of the messages and forgets them, so every delivery is settled once
+func (p *SourcePlugin) settle(messages []*message.Message) []rabbitmq.Delivery {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var deliveries []rabbitmq.Delivery
+	for _, msg := range messages {
+		delivery, ok := p.pending[msg]
+		if !ok {
+			continue
+		}
+
+		delete(p.pending, msg)
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries
+}
+
+// Stop closes the consumer, the broker requeues the messages in flight
+func (p *SourcePlugin) Stop() {
+	if p.consumer != nil {
+		p.consumer.Close()
+	}
+
+	if p.conn != nil {
+		p.conn.Close()
+	}
+}
+
+// handle emits the message and leaves the ack until the message is written.
+// Messages that can't be read are rejected, so they go to the dead letter exchange of the queue
+func (p *SourcePlugin) handle(delivery rabbitmq.Delivery) rabbitmq.Action {
+	streamHeader, _ := delivery.Headers[helper.StreamHeader].(string)
+	eventHeader, _ := delivery.Headers[helper.EventHeader].(string)
+
+	msg, err := p.builder.Build(streamHeader, eventHeader, delivery.RoutingKey, delivery.Body)
+	if err != nil {
+		if nackErr := delivery.Nack(false, false); nackErr != nil {
+			p.logger.Error("Failed to reject message", "routing_key", delivery.RoutingKey, "err", nackErr)
+		}
+		p.emitError(fmt.Errorf("message with routing key %s is rejected: %w", delivery.RoutingKey, err))
+		return rabbitmq.Manual
+	}
+
+	p.mutex.Lock()
+	p.pending[msg] = delivery
+	p.mutex.Unlock()
+
+	p.messageStream <- sources.MessageEvent{Message: msg, Err: nil}
+	return rabbitmq.Manual
+}
+
+func (p *SourcePlugin) emitError(err error) {
+	p.messageStream <- sources.MessageEvent{Message: nil, Err: err}
+}
@@ -0,0 +1,128 @@
+package rabbit_mq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/wagslane/go-rabbitmq"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String", Nullable: true},
+		},
+	},
+	{
+		StreamName: "orders",
+		Columns:    []schema.Column{{Name: "id", DatabrewType: "Int64", PK: true}},
+	},
+}
+
+// acknowledger records the acknowledgements of the deliveries
+type acknowledger struct {
+	acked  []uint64
+	nacked []string
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.nacked = append(a.nacked, fmt.Sprintf("%d requeue=%t", tag, requeue))
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestSourcePlugin_Handle(t *testing.T) {
+	plugin := NewRabbitMqSourcePlugin(Config{Queue: "cdc"}, testStreamSchema, stream_context.CreateContext(1)).(*SourcePlugin)
+	ack := &acknowledger{}
+
+	deliveries := []amqp.Delivery{
+		{DeliveryTag: 1, RoutingKey: "cdc.orders.insert", Body: []byte(`[{"id": 1}]`)},
+		{DeliveryTag: 2, RoutingKey: "cdc", Headers: amqp.Table{helper.StreamHeader: "users", helper.EventHeader: "update"}, Body: []byte(`{"id": 2, "name": "b"}`)},
+		{DeliveryTag: 3, RoutingKey: "cdc.users", Body: []byte(`{"name": "no id"}`)},
+	}
+
+	var received []string
+	var messages []*message.Message
+	for _, delivery := range deliveries {
+		delivery.Acknowledger = ack
+		done := make(chan rabbitmq.Action)
+		go func(delivery amqp.Delivery) {
+			done <- plugin.handle(rabbitmq.Delivery{Delivery: delivery})
+		}(delivery)
+
+		select {
+		case event := <-plugin.Events():
+			if event.Err != nil {
+				received = append(received, "error")
+			} else {
+				messages = append(messages, event.Message)
+				received = append(received, fmt.Sprintf("%s %s %s", event.Message.GetStream(), event.Message.GetEvent(), event.Message.AsJSONString()))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+
+		if action := <-done; action != rabbitmq.Manual {
+			t.Fatalf("deliveries must be acknowledged manually, got %v", action)
+		}
+	}
+
+	expected := `[orders insert [{"id":1}] users update [{"id":2,"name":"b"}] error]`
+	if fmt.Sprint(received) != expected {
+		t.Fatalf("unexpected messages %v", received)
+	}
+
+	// malformed message goes to the dead letter exchange instead of the queue
+	if fmt.Sprint(ack.nacked) != "[3 requeue=false]" || len(ack.acked) != 0 {
+		t.Fatalf("only the malformed message must be rejected, got acked %v nacked %v", ack.acked, ack.nacked)
+	}
+
+	plugin.Ack(messages[1:])
+	plugin.Ack(messages[1:])
+	if fmt.Sprint(ack.acked) != "[2]" || len(plugin.pending) != 1 {
+		t.Fatalf("written message must be acked once, got %v", ack.acked)
+	}
+
+	// message the pipeline failed to process goes to the dead letter exchange as well
+	plugin.Nack(messages)
+	if fmt.Sprint(ack.nacked) != "[3 requeue=false 1 requeue=false]" || len(plugin.pending) != 0 {
+		t.Fatalf("failed message must be rejected, got %v", ack.nacked)
+	}
+}
+
+func TestSourcePlugin_RequeueOnFailure(t *testing.T) {
+	plugin := NewRabbitMqSourcePlugin(Config{Queue: "cdc", RequeueOnFailure: true}, testStreamSchema, stream_context.CreateContext(1)).(*SourcePlugin)
+	ack := &acknowledger{}
+
+	go plugin.handle(rabbitmq.Delivery{Delivery: amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, RoutingKey: "cdc.orders.insert", Body: []byte(`{"id": 1}`)}})
+
+	var event sources.MessageEvent
+	select {
+	case event = <-plugin.Events():
+	case <-time.After(time.Second):
+		t.Fatal("message is not received")
+	}
+
+	plugin.Nack([]*message.Message{event.Message})
+	if fmt.Sprint(ack.nacked) != "[1 requeue=true]" {
+		t.Fatalf("failed message must be requeued, got %v", ack.nacked)
+	}
+}
@@ -0,0 +1,19 @@
+package s3
+
+import "github.com/usedatabrew/blink/internal/s3client"
+
+// Config of the s3 source. Objects are read to the first stream of the stream schema
+type Config struct {
+	s3client.Config `yaml:",inline"`
+	// Format is ndjson, csv or parquet. It's detected by the object key extension when empty.
+	// Gzip compressed objects are decompressed
+	Format string `json:"format" yaml:"format"`
+	// Watch keeps listing the prefix for the new objects. The listing starts after the last processed key,
+	// so the objects have to be added in the key order, e.g. with the timestamped names the s3 sink writes.
+	// Objects changed or added before the last key are read after restart, when the whole prefix is listed
+	Watch bool `json:"watch" yaml:"watch"`
+	// PollInterval is the interval of listing the prefix in milliseconds. Defaults to 10000
+	PollInterval int `json:"poll_interval" yaml:"poll_interval"`
+	// Delimiter of the CSV values. Defaults to comma
+	Delimiter string `json:"delimiter" yaml:"delimiter"`
+}
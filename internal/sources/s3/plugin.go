@@ -0,0 +1,292 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/fileformat"
+	"github.com/usedatabrew/blink/internal/offset_storage"
+	"github.com/usedatabrew/blink/internal/s3client"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+)
+
+const defaultPollInterval = 10000
+
+// SourcePlugin reads the rows of the objects under the prefix. The ETag of every processed object
+// is stored, so the restarted source skips them. Objects are read as a whole, the object interrupted
+// in the middle is read again after restart
+type SourcePlugin struct {
+	ctx           context.Context
+	appCtx        *stream_context.Context
+	config        Config
+	client        s3iface.S3API
+	stream        string
+	outputSchema  *arrow.Schema
+	offsetStorage offset_storage.OffsetStorage
+	logger        *log.Logger
+	delimiter     rune
+	// lastKey is the key the next listing starts after. Objects before it are processed,
+	// so the watched prefix isn't listed again from the beginning on every poll
+	lastKey       string
+	messageStream chan sources.MessageEvent
+}
+
+func NewS3SourcePlugin(appCtx *stream_context.Context, config Config, schema []schema.StreamSchema) sources.DataSource {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	plugin := &SourcePlugin{
+		appCtx:        appCtx,
+		config:        config,
+		logger:        appCtx.Logger.WithPrefix("[source]: s3"),
+		messageStream: make(chan sources.MessageEvent),
+	}
+
+	// the rows are read into the first stream, missing stream is reported on connect
+	if len(schema) > 0 {
+		plugin.stream = schema[0].StreamName
+		plugin.outputSchema = sources.BuildOutputSchema(schema)[schema[0].StreamName]
+	}
+
+	return plugin
+}
+
+func (p *SourcePlugin) Connect(ctx context.Context) error {
+	p.ctx = ctx
+
+	if p.stream == "" {
+		return errors.New("stream_schema has to define the stream the object rows are read into")
+	}
+
+	if p.config.Format != "" {
+		if _, err := fileformat.ParseFormat(p.config.Format, ""); err != nil {
+			return err
+		}
+	}
+
+	if p.config.Delimiter != "" {
+		delimiter := []rune(p.config.Delimiter)
+		if len(delimiter) != 1 {
+			return fmt.Errorf("delimiter must be a single character, got %q", p.config.Delimiter)
+		}
+		p.delimiter = delimiter[0]
+	}
+
+	if p.client == nil {
+		client, err := s3client.NewClient(p.config.Config)
+		if err != nil {
+			return err
+		}
+		p.client = client
+	}
+
+	if _, err := p.client.HeadBucketWithContext(ctx, &awss3.HeadBucketInput{Bucket: aws.String(p.config.Bucket)}); err != nil {
+		return fmt.Errorf("failed to access bucket %s: %w", p.config.Bucket, err)
+	}
+
+	p.offsetStorage = p.appCtx.OffsetStorage()
+	if p.offsetStorage == nil {
+		p.logger.Warn("No offset storage configured. Objects are read again on restart")
+		p.offsetStorage = offset_storage.NewStorageInMem()
+	}
+
+	return nil
+}
+
+func (p *SourcePlugin) Start() {
+	go p.run()
+}
+
+func (p *SourcePlugin) Events() chan sources.MessageEvent {
+	return p.messageStream
+}
+
+func (p *SourcePlugin) Stop() {}
+
+func (p *SourcePlugin) run() {
+	for {
+		read := p.poll()
+		if !p.config.Watch {
+			p.logger.Info("All objects are read", "objects", read)
+			return
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(time.Duration(p.config.PollInterval) * time.Millisecond):
+		}
+	}
+}
+
+// poll reads the objects listed after the last key and returns their amount.
+// The last key stops before the first failed object, so it's read again by the next poll
+func (p *SourcePlugin) poll() int {
+	objects, err := p.listObjects()
+	if err != nil {
+		if !p.config.Watch {
+			p.logger.Fatalf("Failed to list objects: %s", err.Error())
+		}
+		p.emitError(fmt.Errorf("failed to list objects: %w", err))
+	}
+
+	failed := false
+	for _, object := range objects {
+		if err = p.readObject(object); err != nil {
+			failed = true
+			p.emitError(fmt.Errorf("failed to read object %s: %w", aws.StringValue(object.Key), err))
+			continue
+		}
+
+		if !failed {
+			p.lastKey = aws.StringValue(object.Key)
+		}
+	}
+
+	return len(objects)
+}
+
+// listObjects returns the objects under the prefix after the last key in the key order.
+// Folder placeholders, hidden and temporary objects are skipped
+func (p *SourcePlugin) listObjects() ([]*awss3.Object, error) {
+	var objects []*awss3.Object
+	input := &awss3.ListObjectsV2Input{Bucket: aws.String(p.config.Bucket), Prefix: aws.String(p.config.Prefix)}
+	if p.lastKey != "" {
+		input.StartAfter = aws.String(p.lastKey)
+	}
+	err := p.client.ListObjectsV2PagesWithContext(p.ctx, input, func(page *awss3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			name := path.Base(key)
+			if strings.HasSuffix(key, "/") || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".tmp") {
+				continue
+			}
+			objects = append(objects, object)
+		}
+		return true
+	})
+
+	return objects, err
+}
+
+// readObject emits the rows of the object unless the object with the same ETag is already processed
+func (p *SourcePlugin) readObject(object *awss3.Object) error {
+	key := aws.StringValue(object.Key)
+	etag := aws.StringValue(object.ETag)
+	offsetKey := offset_storage.BuildFileKey(p.appCtx.PipelineId(), p.stream, fmt.Sprintf("s3://%s/%s", p.config.Bucket, key))
+	processed, err := p.offsetStorage.GetCursorByPipelineStream(offsetKey)
+	if err != nil {
+		return err
+	}
+	if processed != "" && processed == etag {
+		return nil
+	}
+
+	format, err := fileformat.ParseFormat(p.config.Format, key)
+	if err != nil {
+		return err
+	}
+
+	output, err := p.client.GetObjectWithContext(p.ctx, &awss3.GetObjectInput{Bucket: aws.String(p.config.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	defer output.Body.Close()
+
+	body, err := decompress(output.Body)
+	if err != nil {
+		return err
+	}
+
+	var decoder fileformat.Decoder
+	switch format {
+	case fileformat.Parquet:
+		// parquet footer is at the end of the file, so the object is read to the memory
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		if decoder, err = fileformat.NewParquetDecoder(p.ctx, bytes.NewReader(content), 0); err != nil {
+			return err
+		}
+	case fileformat.CSV:
+		decoder = fileformat.NewCSVDecoder(body, 0, nil, p.outputSchema, p.delimiter)
+	default:
+		decoder = fileformat.NewNDJSONDecoder(body, 0)
+	}
+
+	if err = p.readRows(key, decoder); err != nil {
+		return err
+	}
+
+	return p.offsetStorage.SetCursorForPipeline(offsetKey, etag)
+}
+
+// readRows emits the rows of the object. Malformed rows are reported and skipped
+func (p *SourcePlugin) readRows(key string, decoder fileformat.Decoder) error {
+	builder := fileformat.NewMessageBuilder(p.stream, p.outputSchema)
+	read := 0
+	for {
+		row, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+
+		var rowErr *fileformat.RowError
+		if errors.As(err, &rowErr) {
+			p.emitError(fmt.Errorf("object %s: %w", key, err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		msg, err := builder.Build(row.Data)
+		if err != nil {
+			p.emitError(fmt.Errorf("object %s: row ending at %d: %w", key, row.Offset, err))
+			continue
+		}
+
+		p.messageStream <- sources.MessageEvent{Message: msg, Err: nil}
+		read += 1
+	}
+
+	p.logger.Info("Read object", "key", key, "rows", read)
+	return nil
+}
+
+func (p *SourcePlugin) emitError(err error) {
+	p.messageStream <- sources.MessageEvent{Message: nil, Err: err}
+}
+
+// decompress detects the gzip content by the magic number, as the content encoding
+// may be already removed by the HTTP client and the .gz objects may lack it
+func decompress(r io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(reader)
+	}
+
+	return reader, nil
+}
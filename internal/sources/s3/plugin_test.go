@@ -0,0 +1,206 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/blink/internal/offset_storage"
+	"github.com/usedatabrew/blink/internal/s3client"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String", Nullable: true},
+		},
+	},
+}
+
+type fakeObject struct {
+	body []byte
+	etag string
+}
+
+// fakeClient serves the objects of the bucket from memory
+type fakeClient struct {
+	s3iface.S3API
+	objects map[string]fakeObject
+}
+
+func (c *fakeClient) HeadBucketWithContext(aws.Context, *awss3.HeadBucketInput, ...request.Option) (*awss3.HeadBucketOutput, error) {
+	return &awss3.HeadBucketOutput{}, nil
+}
+
+func (c *fakeClient) ListObjectsV2PagesWithContext(_ aws.Context, input *awss3.ListObjectsV2Input, fn func(*awss3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	var keys []string
+	for key := range c.objects {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) && key > aws.StringValue(input.StartAfter) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// every object is returned on its own page
+	for i, key := range keys {
+		page := &awss3.ListObjectsV2Output{Contents: []*awss3.Object{{Key: aws.String(key), ETag: aws.String(c.objects[key].etag)}}}
+		if !fn(page, i == len(keys)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeClient) GetObjectWithContext(_ aws.Context, input *awss3.GetObjectInput, _ ...request.Option) (*awss3.GetObjectOutput, error) {
+	object, ok := c.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, fmt.Errorf("no such key %s", aws.StringValue(input.Key))
+	}
+
+	return &awss3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(object.body))}, nil
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func received(plugin *SourcePlugin) []string {
+	var messages []string
+	for {
+		select {
+		case event := <-plugin.messageStream:
+			if event.Err != nil {
+				messages = append(messages, "error")
+			} else {
+				messages = append(messages, event.Message.AsJSONString())
+			}
+		default:
+			return messages
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	driverConfig, err := config.ReadDriverConfig[Config](map[string]interface{}{"bucket": "data", "force_path_style": true, "format": "csv"}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if driverConfig.Bucket != "data" || !driverConfig.ForcePathStyle || driverConfig.Format != "csv" {
+		t.Fatalf("client config must be inlined, got %+v", driverConfig)
+	}
+}
+
+func TestSourcePlugin_MissingStream(t *testing.T) {
+	appCtx := stream_context.CreateContext(1)
+	plugin := NewS3SourcePlugin(appCtx, Config{Config: s3client.Config{Bucket: "data"}}, nil)
+	if err := plugin.Connect(context.Background()); err == nil {
+		t.Fatal("connect must fail without the stream")
+	}
+}
+
+func TestSourcePlugin(t *testing.T) {
+	client := &fakeClient{objects: map[string]fakeObject{
+		"exports/a.ndjson.gz":    {body: gzipped(t, "{\"id\": 1, \"name\": \"a\"}\n{broken\n"), etag: "1"},
+		"exports/b.csv":          {body: []byte("name,id\nb,2\n"), etag: "2"},
+		"exports/nested/":        {etag: "3"},
+		"exports/.c.ndjson":      {body: []byte("{\"id\": 3}\n"), etag: "4"},
+		"other/d.ndjson":         {body: []byte("{\"id\": 4}\n"), etag: "5"},
+		"exports/e.ndjson.tmp":   {body: []byte("{\"id\": 5}\n"), etag: "6"},
+		"exports/nested/f.jsonl": {body: []byte("{\"id\": 6}\n"), etag: "7"},
+	}}
+
+	appCtx := stream_context.CreateContext(1)
+	appCtx.SetOffsetStorage(offset_storage.NewStorageInMem())
+	newPlugin := func() *SourcePlugin {
+		plugin := NewS3SourcePlugin(appCtx, Config{Config: s3client.Config{Bucket: "data", Prefix: "exports/"}}, testStreamSchema).(*SourcePlugin)
+		plugin.client = client
+		plugin.messageStream = make(chan sources.MessageEvent, 100)
+		if err := plugin.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return plugin
+	}
+
+	plugin := newPlugin()
+	plugin.run()
+	expected := `[[{"id":1,"name":"a"}] error [{"id":2,"name":"b"}] [{"id":6,"name":null}]]`
+	if messages := received(plugin); fmt.Sprint(messages) != expected {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+
+	// processed objects are skipped after restart unless they are changed
+	client.objects["exports/b.csv"] = fakeObject{body: []byte("id,name\n7,g\n"), etag: "8"}
+	plugin = newPlugin()
+	plugin.run()
+	if messages := received(plugin); fmt.Sprint(messages) != `[[{"id":7,"name":"g"}]]` {
+		t.Fatalf("only the changed object must be read again, got %v", messages)
+	}
+}
+
+func TestSourcePlugin_Watch(t *testing.T) {
+	client := &fakeClient{objects: map[string]fakeObject{
+		"exports/2024-05-01.ndjson": {body: []byte("{\"id\": 1}\n"), etag: "1"},
+		"exports/2024-05-02.ndjson": {body: []byte("{\"id\": 2}\n"), etag: "2"},
+	}}
+
+	appCtx := stream_context.CreateContext(1)
+	appCtx.SetOffsetStorage(offset_storage.NewStorageInMem())
+	plugin := NewS3SourcePlugin(appCtx, Config{Config: s3client.Config{Bucket: "data", Prefix: "exports/"}, Watch: true}, testStreamSchema).(*SourcePlugin)
+	plugin.client = client
+	plugin.messageStream = make(chan sources.MessageEvent, 100)
+	if err := plugin.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if read := plugin.poll(); read != 2 || plugin.lastKey != "exports/2024-05-02.ndjson" {
+		t.Fatalf("unexpected poll of %d objects up to %s", read, plugin.lastKey)
+	}
+	received(plugin)
+
+	// objects before the last key are not listed again
+	client.objects["exports/2024-05-03.ndjson"] = fakeObject{body: []byte("{\"id\": 3}\n"), etag: "3"}
+	if read := plugin.poll(); read != 1 {
+		t.Fatalf("only the objects after the last key must be listed, got %d", read)
+	}
+	if messages := received(plugin); fmt.Sprint(messages) != `[[{"id":3,"name":null}]]` {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+
+	// failed object is listed again by the next poll
+	client.objects["exports/2024-05-04.parquet"] = fakeObject{body: []byte("broken"), etag: "4"}
+	client.objects["exports/2024-05-05.ndjson"] = fakeObject{body: []byte("{\"id\": 5}\n"), etag: "5"}
+	plugin.poll()
+	received(plugin)
+	if plugin.lastKey != "exports/2024-05-03.ndjson" {
+		t.Fatalf("last key must stop before the failed object, got %s", plugin.lastKey)
+	}
+	if read := plugin.poll(); read != 2 {
+		t.Fatalf("failed object must be listed again, got %d", read)
+	}
+}
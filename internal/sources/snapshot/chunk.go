@@ -0,0 +1,71 @@
+package snapshot
+
+import (
+	"encoding/json"
+
+	"github.com/usedatabrew/blink/internal/sources"
+)
+
+// Chunk is the primary key range (Lower, Upper] of the stream.
+// Empty bound is open, so the first and the last chunks cover the rest of the keys.
+// Filters are the backfill filters the reader applies to the rows of the range.
+// Their values are converted to the column types
+type Chunk struct {
+	Index   int              `json:"index"`
+	Lower   string           `json:"lower"`
+	Upper   string           `json:"upper"`
+	Filters []sources.Filter `json:"filters,omitempty"`
+}
+
+// progress is the snapshot state of the stream persisted in the offset storage,
+// so the snapshot resumes from the chunks not completed yet
+type progress struct {
+	Chunks    []Chunk      `json:"chunks"`
+	Completed map[int]bool `json:"completed"`
+	Done      bool         `json:"done"`
+}
+
+func (p progress) Encode() string {
+	encoded, _ := json.Marshal(p)
+	return string(encoded)
+}
+
+func (p progress) Pending() []Chunk {
+	var pending []Chunk
+	for _, chunk := range p.Chunks {
+		if !p.Completed[chunk.Index] {
+			pending = append(pending, chunk)
+		}
+	}
+
+	return pending
+}
+
+func decodeProgress(encoded string) (progress, error) {
+	state := progress{Completed: map[int]bool{}}
+	if encoded == "" {
+		return state, nil
+	}
+
+	if err := json.Unmarshal([]byte(encoded), &state); err != nil {
+		return state, err
+	}
+
+	if state.Completed == nil {
+		state.Completed = map[int]bool{}
+	}
+
+	return state, nil
+}
+
+// planChunks splits the keys by the boundaries. Every boundary is the last key of its chunk
+func planChunks(boundaries []string) []Chunk {
+	var chunks []Chunk
+	var lower string
+	for idx, boundary := range boundaries {
+		chunks = append(chunks, Chunk{Index: idx, Lower: lower, Upper: boundary})
+		lower = boundary
+	}
+
+	return append(chunks, Chunk{Index: len(boundaries), Lower: lower})
+}
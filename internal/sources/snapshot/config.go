@@ -0,0 +1,24 @@
+package snapshot
+
+const (
+	defaultParallelism = 1
+	// defaultChunkSize is used by the backfills of the sources taking no chunked snapshot
+	defaultChunkSize = 10000
+)
+
+// Config holds the chunked snapshot settings shared by the CDC sources.
+// Drivers embed it inline, the chunked snapshot is taken when SnapshotChunkSize is set.
+// Backfills requested on the running pipeline are read with the same settings
+type Config struct {
+	// SnapshotChunkSize is the amount of rows in the primary key range read at once
+	SnapshotChunkSize int `json:"snapshot_chunk_size" yaml:"snapshot_chunk_size"`
+	// SnapshotParallelism is the amount of chunks read concurrently
+	SnapshotParallelism int `json:"snapshot_parallelism" yaml:"snapshot_parallelism"`
+	// SnapshotWatermarks interleaves the snapshot with the live changes without locks.
+	// The rows changed while the chunk is read are dropped from it, as the change stream carries the newer state
+	SnapshotWatermarks bool `json:"snapshot_watermarks" yaml:"snapshot_watermarks"`
+}
+
+func (c Config) Chunked() bool {
+	return c.SnapshotChunkSize > 0
+}
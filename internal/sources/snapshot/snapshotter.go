@@ -0,0 +1,527 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/offset_storage"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+// Reader reads the chunks of the streams from the database
+type Reader interface {
+	// Boundaries returns the primary keys splitting the stream into the chunks of the size
+	Boundaries(ctx context.Context, stream string, chunkSize int) ([]string, error)
+	// ReadChunk returns the snapshot messages of the rows within the chunk
+	ReadChunk(ctx context.Context, stream string, chunk Chunk) ([]*message.Message, error)
+}
+
+// Watermarks writes the watermarks the change stream receives in order with the row changes
+type Watermarks interface {
+	WriteWatermark(ctx context.Context, id string) error
+	DeleteWatermarks(ctx context.Context, ids ...string) error
+}
+
+// window is the chunk read between the low and the high watermarks.
+// Keys changed within the window are dropped from the chunk rows,
+// as the change stream has already emitted their newer state
+type window struct {
+	stream   string
+	chunk    Chunk
+	backfill string
+	low      string
+	high     string
+	open     bool
+	rows     []*message.Message
+	changed  map[string]bool
+	done     chan struct{}
+}
+
+// Snapshotter takes the snapshot of the streams chunk by chunk.
+// Without the watermarks chunks are emitted as soon as they are read, so the snapshot
+// has to be taken before the change stream starts. With the watermarks the change stream
+// runs along and emits the chunks once it receives their high watermarks
+type Snapshotter struct {
+	config        Config
+	reader        Reader
+	watermarks    Watermarks
+	appCtx        *stream_context.Context
+	offsetStorage offset_storage.OffsetStorage
+	streamSchema  []schema.StreamSchema
+	streamPks     map[string]string
+	logger        *log.Logger
+
+	mutx      sync.Mutex
+	storeMutx sync.Mutex
+	progress  map[string]*progress
+	windows   map[string]*window
+}
+
+func NewSnapshotter(appCtx *stream_context.Context, config Config, reader Reader, watermarks Watermarks, s []schema.StreamSchema) *Snapshotter {
+	if config.SnapshotParallelism <= 0 {
+		config.SnapshotParallelism = defaultParallelism
+	}
+
+	snapshotter := &Snapshotter{
+		config:        config,
+		reader:        reader,
+		watermarks:    watermarks,
+		appCtx:        appCtx,
+		offsetStorage: appCtx.OffsetStorage(),
+		streamSchema:  s,
+		streamPks:     map[string]string{},
+		logger:        appCtx.Logger.WithPrefix("Snapshot"),
+		progress:      map[string]*progress{},
+		windows:       map[string]*window{},
+	}
+
+	if snapshotter.offsetStorage == nil {
+		snapshotter.logger.Warn("No offset storage configured. Snapshot progress is kept in memory and can't be resumed")
+		snapshotter.offsetStorage = offset_storage.NewStorageInMem()
+	}
+
+	for _, stream := range s {
+		for _, column := range stream.Columns {
+			if column.PK {
+				snapshotter.streamPks[streamTable(stream.StreamName)] = column.Name
+			}
+		}
+	}
+
+	return snapshotter
+}
+
+// Run takes the snapshot of the streams not completed yet. Chunks of the stream are read concurrently,
+// emit has to be safe to call from the multiple goroutines
+func (s *Snapshotter) Run(ctx context.Context, emit func(*message.Message)) error {
+	for _, stream := range s.streamSchema {
+		if err := s.runStream(ctx, streamTable(stream.StreamName), emit); err != nil {
+			return fmt.Errorf("failed to snapshot stream %s: %w", stream.StreamName, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Snapshotter) runStream(ctx context.Context, stream string, emit func(*message.Message)) error {
+	state, err := s.loadProgress(stream)
+	if err != nil {
+		return err
+	}
+
+	if state.Done {
+		s.logger.Info("Snapshot is already taken", "stream", stream)
+		return nil
+	}
+
+	if len(state.Chunks) == 0 {
+		boundaries, err := s.reader.Boundaries(ctx, stream, s.config.SnapshotChunkSize)
+		if err != nil {
+			return err
+		}
+		state.Chunks = planChunks(boundaries)
+		if err = s.storeProgress(stream, state); err != nil {
+			return err
+		}
+	}
+
+	pending := state.Pending()
+	s.logger.Info("Taking snapshot", "stream", stream, "chunks", len(state.Chunks), "pending", len(pending), "parallelism", s.config.SnapshotParallelism)
+
+	if err = s.runChunks(ctx, stream, pending, "", emit); err != nil {
+		return err
+	}
+
+	s.mutx.Lock()
+	state.Done = true
+	s.mutx.Unlock()
+
+	s.logger.Info("Snapshot is taken", "stream", stream)
+	return s.storeProgress(stream, state)
+}
+
+// StartBackfill validates the streams and takes their snapshot again in the background.
+// It's used to repair the sink while the change stream keeps running
+func (s *Snapshotter) StartBackfill(ctx context.Context, id string, streams []string, filters []sources.Filter, emit func(*message.Message)) error {
+	if err := s.checkStreams(streams); err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		if _, err := s.streamFilters(stream, filters); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		if err := s.Backfill(ctx, id, streams, filters, emit); err != nil {
+			s.logger.Error("Backfill failed", "id", id, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Backfill takes the snapshot of the rows matching the filters again.
+// Rows are tagged with the backfill id and no progress is stored,
+// so the interrupted backfill has to be requested once more
+func (s *Snapshotter) Backfill(ctx context.Context, id string, streams []string, filters []sources.Filter, emit func(*message.Message)) error {
+	if err := s.checkStreams(streams); err != nil {
+		return err
+	}
+
+	if s.watermarks == nil {
+		s.logger.Warn("Backfill runs without the watermarks. Rows changed while the chunks are read may be overwritten by their older state", "id", id)
+	}
+
+	chunkSize := s.config.SnapshotChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	for _, stream := range streams {
+		stream = streamTable(stream)
+		streamFilters, err := s.streamFilters(stream, filters)
+		if err != nil {
+			return err
+		}
+
+		boundaries, err := s.reader.Boundaries(ctx, stream, chunkSize)
+		if err != nil {
+			return fmt.Errorf("failed to backfill stream %s: %w", stream, err)
+		}
+
+		chunks := planChunks(boundaries)
+		for idx := range chunks {
+			chunks[idx].Filters = streamFilters
+		}
+
+		s.logger.Info("Backfilling stream", "id", id, "stream", stream, "chunks", len(chunks), "filters", filters)
+		if err = s.runChunks(ctx, stream, chunks, id, emit); err != nil {
+			return fmt.Errorf("failed to backfill stream %s: %w", stream, err)
+		}
+	}
+
+	s.logger.Info("Backfill is completed", "id", id)
+	return nil
+}
+
+func (s *Snapshotter) checkStreams(streams []string) error {
+	if len(streams) == 0 {
+		return fmt.Errorf("no streams to backfill")
+	}
+
+	known := map[string]bool{}
+	for _, stream := range s.streamSchema {
+		known[streamTable(stream.StreamName)] = true
+	}
+
+	for _, stream := range streams {
+		if !known[streamTable(stream)] {
+			return fmt.Errorf("stream %s is not configured for the source", stream)
+		}
+	}
+
+	return nil
+}
+
+// streamFilters checks the filter columns against the stream schema
+// and converts the values to the column types, so the readers bind them as the typed parameters
+func (s *Snapshotter) streamFilters(stream string, filters []sources.Filter) ([]sources.Filter, error) {
+	columns := map[string]schema.Column{}
+	for _, streamSchema := range s.streamSchema {
+		if streamTable(streamSchema.StreamName) == streamTable(stream) {
+			for _, column := range streamSchema.Columns {
+				columns[column.Name] = column
+			}
+		}
+	}
+
+	var converted []sources.Filter
+	for _, filter := range filters {
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+
+		column, ok := columns[filter.Column]
+		if !ok {
+			return nil, fmt.Errorf("column %s of the filter is not defined for the stream %s", filter.Column, stream)
+		}
+
+		value, err := helper.CastValue(filter.Value, column.DatabrewType)
+		if err != nil {
+			return nil, fmt.Errorf("value of the filter is invalid for the column %s: %w", filter.Column, err)
+		}
+
+		filter.Value = value
+		converted = append(converted, filter)
+	}
+
+	return converted, nil
+}
+
+// runChunks reads the chunks with the pool of SnapshotParallelism workers
+func (s *Snapshotter) runChunks(ctx context.Context, stream string, pending []Chunk, backfill string, emit func(*message.Message)) error {
+	chunks := make(chan Chunk)
+	errs := make(chan error, s.config.SnapshotParallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.SnapshotParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				if err := s.runChunk(ctx, stream, chunk, backfill, emit); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var runErr error
+dispatch:
+	for _, chunk := range pending {
+		select {
+		case chunks <- chunk:
+		case runErr = <-errs:
+			break dispatch
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if runErr == nil {
+		select {
+		case runErr = <-errs:
+		default:
+		}
+	}
+
+	return runErr
+}
+
+func (s *Snapshotter) runChunk(ctx context.Context, stream string, chunk Chunk, backfill string, emit func(*message.Message)) error {
+	if s.watermarks == nil {
+		rows, err := s.readChunk(ctx, stream, chunk, backfill)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			emit(row)
+		}
+
+		return s.completeChunk(stream, chunk, backfill)
+	}
+
+	w := &window{
+		stream:   stream,
+		chunk:    chunk,
+		backfill: backfill,
+		low:      uuid.NewString(),
+		high:     uuid.NewString(),
+		changed:  map[string]bool{},
+		done:     make(chan struct{}),
+	}
+
+	s.mutx.Lock()
+	s.windows[w.low] = w
+	s.windows[w.high] = w
+	s.mutx.Unlock()
+
+	if err := s.awaitWindow(ctx, w); err != nil {
+		// the failed chunk must not drop the changes of the stream or wait for its watermarks
+		s.mutx.Lock()
+		delete(s.windows, w.low)
+		delete(s.windows, w.high)
+		s.mutx.Unlock()
+		return err
+	}
+
+	return s.watermarks.DeleteWatermarks(ctx, w.low, w.high)
+}
+
+// awaitWindow reads the chunk between the watermarks and waits until the change stream receives the high one
+func (s *Snapshotter) awaitWindow(ctx context.Context, w *window) error {
+	if err := s.watermarks.WriteWatermark(ctx, w.low); err != nil {
+		return err
+	}
+
+	rows, err := s.readChunk(ctx, w.stream, w.chunk, w.backfill)
+	if err != nil {
+		return err
+	}
+
+	s.mutx.Lock()
+	w.rows = rows
+	s.mutx.Unlock()
+
+	if err = s.watermarks.WriteWatermark(ctx, w.high); err != nil {
+		return err
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnChange records the keys of the row change received by the change stream,
+// so the open windows of the stream drop them from their chunks
+func (s *Snapshotter) OnChange(msg *message.Message) {
+	s.mutx.Lock()
+	defer s.mutx.Unlock()
+
+	if len(s.windows) == 0 {
+		return
+	}
+
+	stream := msg.GetStream()
+	keys := messageKeys(msg, s.streamPks[stream])
+	for id, w := range s.windows {
+		if !w.open || w.stream != stream || id != w.low {
+			continue
+		}
+
+		for _, key := range keys {
+			w.changed[key] = true
+		}
+	}
+}
+
+// OnWatermark handles the watermark received by the change stream. The low watermark opens the window,
+// the high one emits the chunk rows not changed within the window. Unknown watermarks are skipped
+func (s *Snapshotter) OnWatermark(id string, emit func(*message.Message)) error {
+	s.mutx.Lock()
+	w, ok := s.windows[id]
+	if !ok {
+		s.mutx.Unlock()
+		return nil
+	}
+
+	if id == w.low {
+		w.open = true
+		s.mutx.Unlock()
+		return nil
+	}
+
+	delete(s.windows, w.low)
+	delete(s.windows, w.high)
+	pk := s.streamPks[w.stream]
+	s.mutx.Unlock()
+
+	var dropped = 0
+	for _, row := range w.rows {
+		keys := messageKeys(row, pk)
+		if len(keys) > 0 && w.changed[keys[0]] {
+			dropped += 1
+			continue
+		}
+		emit(row)
+	}
+
+	if dropped > 0 {
+		s.logger.Info("Dropped rows changed while the chunk was read", "stream", w.stream, "chunk", w.chunk.Index, "rows", dropped)
+	}
+
+	err := s.completeChunk(w.stream, w.chunk, w.backfill)
+	close(w.done)
+	return err
+}
+
+// readChunk reads the chunk rows and tags them with the backfill id
+func (s *Snapshotter) readChunk(ctx context.Context, stream string, chunk Chunk, backfill string) ([]*message.Message, error) {
+	rows, err := s.reader.ReadChunk(ctx, stream, chunk)
+	if err != nil || backfill == "" {
+		return rows, err
+	}
+
+	for _, row := range rows {
+		if err = helper.SetBackfillId(row, backfill); err != nil {
+			return nil, err
+		}
+	}
+
+	return rows, nil
+}
+
+// completeChunk stores the snapshot progress. Backfill progress isn't stored
+func (s *Snapshotter) completeChunk(stream string, chunk Chunk, backfill string) error {
+	if backfill != "" {
+		return nil
+	}
+
+	s.mutx.Lock()
+	state := s.progress[stream]
+	state.Completed[chunk.Index] = true
+	s.mutx.Unlock()
+
+	return s.storeProgress(stream, state)
+}
+
+func (s *Snapshotter) loadProgress(stream string) (*progress, error) {
+	encoded, err := s.offsetStorage.GetCursorByPipelineStream(offset_storage.BuildSnapshotKey(s.appCtx.PipelineId(), stream))
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := decodeProgress(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutx.Lock()
+	s.progress[stream] = &state
+	s.mutx.Unlock()
+
+	return &state, nil
+}
+
+// storeProgress writes are serialized, so the progress of the chunks completed concurrently
+// is never overwritten by the older state
+func (s *Snapshotter) storeProgress(stream string, state *progress) error {
+	s.storeMutx.Lock()
+	defer s.storeMutx.Unlock()
+
+	s.mutx.Lock()
+	encoded := state.Encode()
+	s.mutx.Unlock()
+
+	return s.offsetStorage.SetCursorForPipeline(offset_storage.BuildSnapshotKey(s.appCtx.PipelineId(), stream), encoded)
+}
+
+// messageKeys returns the primary keys of the message rows as text
+func messageKeys(msg *message.Message, pk string) []string {
+	var rows []map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(msg.AsJSONString()))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil {
+		return nil
+	}
+
+	var keys []string
+	for _, row := range rows {
+		if key, ok := row[pk]; ok && key != nil {
+			keys = append(keys, fmt.Sprint(key))
+		}
+	}
+
+	return keys
+}
+
+// streamTable returns the table name the messages of the stream are emitted with
+func streamTable(stream string) string {
+	parts := strings.Split(stream, ".")
+	return parts[len(parts)-1]
+}
@@ -0,0 +1,300 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/offset_storage"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+	_ "modernc.org/sqlite"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String"},
+		},
+	},
+}
+
+type testDialect struct{}
+
+func (d testDialect) Placeholder(position int) string {
+	return "?"
+}
+
+func (d testDialect) Page(query string, limit, offset int) string {
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", query, limit, offset)
+}
+
+func openTestDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// keys have gaps, so the chunks are split by the keys, not by the key range
+	for _, id := range []int{1, 2, 5, 8, 13, 21, 34} {
+		if _, err = db.Exec("INSERT INTO users VALUES (?, ?)", id, fmt.Sprintf("user %d", id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+type collector struct {
+	mutx sync.Mutex
+	rows []string
+}
+
+func (c *collector) emit(msg *message.Message) {
+	c.mutx.Lock()
+	defer c.mutx.Unlock()
+	c.rows = append(c.rows, msg.AsJSONString())
+}
+
+func (c *collector) sorted() []string {
+	sort.Strings(c.rows)
+	return c.rows
+}
+
+func TestPlanChunks(t *testing.T) {
+	chunks := planChunks([]string{"5", "21"})
+	expected := []Chunk{{Index: 0, Upper: "5"}, {Index: 1, Lower: "5", Upper: "21"}, {Index: 2, Lower: "21"}}
+	if fmt.Sprint(chunks) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected chunks %v", chunks)
+	}
+
+	state := progress{Chunks: chunks, Completed: map[int]bool{1: true}}
+	decoded, err := decodeProgress(state.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pending := decoded.Pending(); len(pending) != 2 || pending[0].Index != 0 || pending[1].Index != 2 {
+		t.Fatalf("unexpected pending chunks %v", pending)
+	}
+}
+
+func TestSQLReader_Chunks(t *testing.T) {
+	reader := NewSQLReader(openTestDatabase(t), testDialect{}, "", testStreamSchema)
+
+	boundaries, err := reader.Boundaries(context.Background(), "users", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(boundaries) != "[5 21]" {
+		t.Fatalf("unexpected boundaries %v", boundaries)
+	}
+
+	rows, err := reader.ReadChunk(context.Background(), "users", Chunk{Index: 1, Lower: "5", Upper: "21"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 || rows[0].AsJSONString() != `[{"id":8,"name":"user 8"}]` || rows[0].GetEvent() != message.Snapshot {
+		t.Fatalf("unexpected chunk rows %d", len(rows))
+	}
+}
+
+func TestSnapshotter_Run(t *testing.T) {
+	db := openTestDatabase(t)
+	appCtx := stream_context.CreateContext(1)
+	appCtx.SetOffsetStorage(offset_storage.NewStorageInMem())
+	config := Config{SnapshotChunkSize: 2, SnapshotParallelism: 3}
+
+	snapshotter := NewSnapshotter(appCtx, config, NewSQLReader(db, testDialect{}, "", testStreamSchema), nil, testStreamSchema)
+	rows := &collector{}
+	if err := snapshotter.Run(context.Background(), rows.emit); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows.rows) != 7 {
+		t.Fatalf("all rows must be emitted, got %d", len(rows.rows))
+	}
+
+	rows = &collector{}
+	snapshotter = NewSnapshotter(appCtx, config, NewSQLReader(db, testDialect{}, "", testStreamSchema), nil, testStreamSchema)
+	if err := snapshotter.Run(context.Background(), rows.emit); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows.rows) != 0 {
+		t.Fatal("completed snapshot must not be taken again")
+	}
+}
+
+func TestSnapshotter_Resume(t *testing.T) {
+	db := openTestDatabase(t)
+	appCtx := stream_context.CreateContext(1)
+	appCtx.SetOffsetStorage(offset_storage.NewStorageInMem())
+
+	state := progress{Chunks: planChunks([]string{"5", "21"}), Completed: map[int]bool{0: true, 2: true}}
+	if err := appCtx.OffsetStorage().SetCursorForPipeline(offset_storage.BuildSnapshotKey(1, "users"), state.Encode()); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotter := NewSnapshotter(appCtx, Config{SnapshotChunkSize: 3}, NewSQLReader(db, testDialect{}, "", testStreamSchema), nil, testStreamSchema)
+	rows := &collector{}
+	if err := snapshotter.Run(context.Background(), rows.emit); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{`[{"id":13,"name":"user 13"}]`, `[{"id":21,"name":"user 21"}]`, `[{"id":8,"name":"user 8"}]`}
+	if fmt.Sprint(rows.sorted()) != fmt.Sprint(expected) {
+		t.Fatalf("only the pending chunk must be emitted, got %v", rows.rows)
+	}
+}
+
+// changeStream imitates the change stream receiving the watermarks in order with the row changes.
+// The row 2 is updated right after every low watermark
+type changeStream struct {
+	events chan *message.Message
+}
+
+func (c *changeStream) WriteWatermark(ctx context.Context, id string) error {
+	c.events <- message.NewMessage(message.Insert, WatermarkTable, []byte(fmt.Sprintf(`[{"id":"%s"}]`, id)))
+	c.events <- message.NewMessage(message.Update, "users", []byte(`[{"id":2,"name":"updated"}]`))
+	return nil
+}
+
+func (c *changeStream) DeleteWatermarks(ctx context.Context, ids ...string) error {
+	return nil
+}
+
+func TestSnapshotter_Watermarks(t *testing.T) {
+	db := openTestDatabase(t)
+	appCtx := stream_context.CreateContext(1)
+	stream := &changeStream{events: make(chan *message.Message, 100)}
+
+	snapshotter := NewSnapshotter(appCtx, Config{SnapshotChunkSize: 3, SnapshotParallelism: 2}, NewSQLReader(db, testDialect{}, "", testStreamSchema), stream, testStreamSchema)
+
+	rows := &collector{}
+	done := make(chan error)
+	go func() {
+		done <- snapshotter.Run(context.Background(), rows.emit)
+	}()
+
+	for {
+		select {
+		case event := <-stream.events:
+			if event.GetStream() == WatermarkTable {
+				var id string
+				fmt.Sscanf(event.AsJSONString(), `[{"id":"%36s"}]`, &id)
+				if err := snapshotter.OnWatermark(id, rows.emit); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			snapshotter.OnChange(event)
+			rows.emit(event)
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var snapshotRows, changes = 0, 0
+			for _, row := range rows.rows {
+				if row == `[{"id":2,"name":"user 2"}]` {
+					t.Fatal("row changed within the window must be dropped from the chunk")
+				}
+				if row == `[{"id":2,"name":"updated"}]` {
+					changes += 1
+				} else {
+					snapshotRows += 1
+				}
+			}
+
+			if snapshotRows != 6 || changes != 6 {
+				t.Fatalf("unexpected rows %v", rows.rows)
+			}
+			return
+		}
+	}
+}
+
+func TestSnapshotter_Backfill(t *testing.T) {
+	db := openTestDatabase(t)
+	appCtx := stream_context.CreateContext(1)
+	appCtx.SetOffsetStorage(offset_storage.NewStorageInMem())
+
+	snapshotter := NewSnapshotter(appCtx, Config{SnapshotParallelism: 2}, NewSQLReader(db, testDialect{}, "", testStreamSchema), nil, testStreamSchema)
+	if err := snapshotter.Backfill(context.Background(), "b1", []string{"orders"}, nil, (&collector{}).emit); err == nil {
+		t.Fatal("unknown stream must not be backfilled")
+	}
+
+	invalid := [][]sources.Filter{
+		{{Column: "email", Operator: "=", Value: "max@databrew.tech"}},
+		{{Column: "id", Operator: "=", Value: "1; DROP TABLE users"}},
+		{{Column: "id", Operator: "LIKE", Value: "1"}},
+	}
+	for _, filters := range invalid {
+		if err := snapshotter.StartBackfill(context.Background(), "b1", []string{"users"}, filters, (&collector{}).emit); err == nil {
+			t.Fatalf("filters %+v must be rejected", filters)
+		}
+	}
+
+	rows := &collector{}
+	filters := []sources.Filter{{Column: "id", Operator: ">", Value: "10"}, {Column: "name", Operator: "!=", Value: "user 21"}}
+	if err := snapshotter.Backfill(context.Background(), "b1", []string{"public.users"}, filters, rows.emit); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`[{"_metadata":{"backfill_id":"b1"},"id":13,"name":"user 13"}]`,
+		`[{"_metadata":{"backfill_id":"b1"},"id":34,"name":"user 34"}]`,
+	}
+	if fmt.Sprint(rows.sorted()) != fmt.Sprint(expected) {
+		t.Fatalf("only the filtered rows must be backfilled, got %v", rows.rows)
+	}
+
+	encoded, err := appCtx.OffsetStorage().GetCursorByPipelineStream(offset_storage.BuildSnapshotKey(1, "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded != "" {
+		t.Fatal("backfill must not store the snapshot progress")
+	}
+}
+
+type failingReader struct {
+	Reader
+}
+
+func (r failingReader) ReadChunk(ctx context.Context, stream string, chunk Chunk) ([]*message.Message, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestSnapshotter_FailedChunk(t *testing.T) {
+	db := openTestDatabase(t)
+	appCtx := stream_context.CreateContext(1)
+	stream := &changeStream{events: make(chan *message.Message, 100)}
+	reader := failingReader{Reader: NewSQLReader(db, testDialect{}, "", testStreamSchema)}
+
+	snapshotter := NewSnapshotter(appCtx, Config{SnapshotChunkSize: 3, SnapshotParallelism: 1}, reader, stream, testStreamSchema)
+	if err := snapshotter.Run(context.Background(), (&collector{}).emit); err == nil {
+		t.Fatal("failed chunk must fail the snapshot")
+	}
+
+	if len(snapshotter.windows) != 0 {
+		t.Fatalf("window of the failed chunk must be closed, got %d", len(snapshotter.windows))
+	}
+}
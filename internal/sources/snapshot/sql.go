@@ -0,0 +1,224 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/cloudquery/plugin-sdk/v4/scalar"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/message"
+)
+
+// WatermarkTable is the table the watermarks are written to.
+// The change stream has to receive its inserts along with the row changes of the streams
+const WatermarkTable = "blink_watermarks"
+
+// Dialect adapts the SQL reader to the database
+type Dialect interface {
+	// Placeholder returns the bind parameter for the position starting from 1
+	Placeholder(position int) string
+	// Page restricts the ordered query to the amount of rows after the offset
+	Page(query string, limit, offset int) string
+}
+
+// SQLReader reads the chunks with database/sql, so the sources share the chunk queries
+type SQLReader struct {
+	db           *sql.DB
+	dialect      Dialect
+	schemaName   string
+	streamSchema map[string]schema.StreamSchema
+	streamPks    map[string]string
+}
+
+// NewSQLReader creates the reader of the streams. Stream tables are qualified
+// with the schema name unless it's empty or the stream name already contains it
+func NewSQLReader(db *sql.DB, dialect Dialect, schemaName string, s []schema.StreamSchema) *SQLReader {
+	reader := &SQLReader{
+		db:           db,
+		dialect:      dialect,
+		schemaName:   schemaName,
+		streamSchema: map[string]schema.StreamSchema{},
+		streamPks:    map[string]string{},
+	}
+
+	for _, stream := range s {
+		reader.streamSchema[streamTable(stream.StreamName)] = stream
+		for _, column := range stream.Columns {
+			if column.PK {
+				reader.streamPks[streamTable(stream.StreamName)] = column.Name
+			}
+		}
+	}
+
+	return reader
+}
+
+func (r *SQLReader) Boundaries(ctx context.Context, stream string, chunkSize int) ([]string, error) {
+	pk := r.streamPks[stream]
+	if pk == "" {
+		return nil, fmt.Errorf("primary key is required to split the stream %s into chunks", stream)
+	}
+
+	var boundaries []string
+	for {
+		var query string
+		var args []interface{}
+		if len(boundaries) == 0 {
+			query = fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", pk, r.table(stream), pk)
+		} else {
+			query = fmt.Sprintf("SELECT %s FROM %s WHERE %s > %s ORDER BY %s", pk, r.table(stream), pk, r.dialect.Placeholder(1), pk)
+			args = append(args, boundaries[len(boundaries)-1])
+		}
+
+		var boundary interface{}
+		err := r.db.QueryRowContext(ctx, r.dialect.Page(query, 1, chunkSize-1), args...).Scan(&boundary)
+		if err == sql.ErrNoRows {
+			return boundaries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		boundaries = append(boundaries, keyText(boundary))
+	}
+}
+
+func (r *SQLReader) ReadChunk(ctx context.Context, stream string, chunk Chunk) ([]*message.Message, error) {
+	streamSchema := r.streamSchema[stream]
+	pk := r.streamPks[stream]
+
+	var columns []string
+	for _, column := range streamSchema.Columns {
+		columns = append(columns, column.Name)
+	}
+
+	var conditions []string
+	var args []interface{}
+	if chunk.Lower != "" {
+		args = append(args, chunk.Lower)
+		conditions = append(conditions, fmt.Sprintf("%s > %s", pk, r.dialect.Placeholder(len(args))))
+	}
+	if chunk.Upper != "" {
+		args = append(args, chunk.Upper)
+		conditions = append(conditions, fmt.Sprintf("%s <= %s", pk, r.dialect.Placeholder(len(args))))
+	}
+	for _, filter := range chunk.Filters {
+		// columns are checked against the stream schema by the snapshotter
+		args = append(args, filter.Value)
+		conditions = append(conditions, fmt.Sprintf("%s %s %s", filter.Column, filter.Operator, r.dialect.Placeholder(len(args))))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), r.table(stream))
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY "+pk, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunk %d: %w", chunk.Index, err)
+	}
+	defer rows.Close()
+
+	arrowSchema := streamSchema.AsArrow()
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, arrowSchema)
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(values))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var messages []*message.Message
+	for rows.Next() {
+		if err = rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		for i, v := range values {
+			s := scalar.NewScalar(arrowSchema.Field(i).Type)
+			if err = s.Set(helper.NormalizeDriverValue(v, arrowSchema.Field(i).Type)); err != nil {
+				return nil, err
+			}
+
+			scalar.AppendToBuilder(builder.Field(i), s)
+		}
+
+		data, _ := builder.NewRecord().MarshalJSON()
+		messages = append(messages, message.NewMessage(message.Snapshot, stream, data))
+	}
+
+	return messages, rows.Err()
+}
+
+func (r *SQLReader) table(stream string) string {
+	name := r.streamSchema[stream].StreamName
+	if r.schemaName == "" || strings.Contains(name, ".") {
+		return name
+	}
+
+	return fmt.Sprintf("%s.%s", r.schemaName, name)
+}
+
+// SQLWatermarks writes the watermarks to the WatermarkTable
+type SQLWatermarks struct {
+	db         *sql.DB
+	dialect    Dialect
+	schemaName string
+}
+
+func NewSQLWatermarks(db *sql.DB, dialect Dialect, schemaName string) *SQLWatermarks {
+	return &SQLWatermarks{db: db, dialect: dialect, schemaName: schemaName}
+}
+
+// CreateTable creates the watermark table if it doesn't exist
+func (w *SQLWatermarks) CreateTable(ctx context.Context) error {
+	_, err := w.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(64) PRIMARY KEY)", w.table()))
+	return err
+}
+
+func (w *SQLWatermarks) WriteWatermark(ctx context.Context, id string) error {
+	_, err := w.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id) VALUES (%s)", w.table(), w.dialect.Placeholder(1)), id)
+	return err
+}
+
+func (w *SQLWatermarks) DeleteWatermarks(ctx context.Context, ids ...string) error {
+	var placeholders []string
+	var args []interface{}
+	for idx, id := range ids {
+		placeholders = append(placeholders, w.dialect.Placeholder(idx+1))
+		args = append(args, id)
+	}
+
+	_, err := w.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", w.table(), strings.Join(placeholders, ", ")), args...)
+	return err
+}
+
+func (w *SQLWatermarks) table() string {
+	if w.schemaName == "" {
+		return WatermarkTable
+	}
+
+	return fmt.Sprintf("%s.%s", w.schemaName, WatermarkTable)
+}
+
+// keyText converts the primary key returned by the driver to the text accepted by the database back
+func keyText(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -2,6 +2,9 @@ package sources
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
 	"github.com/usedatabrew/message"
 )
 
@@ -16,3 +19,85 @@ type DataSource interface {
 	Events() chan MessageEvent
 	Stop()
 }
+
+// BeforeImageSource is implemented by the sources that can attach
+// the row state before the change to the update and delete messages
+type BeforeImageSource interface {
+	BeforeImageEnabled() bool
+}
+
+// BackfillRequest asks the source to take the snapshot of the streams again.
+// Only the rows matching all the filters are backfilled
+type BackfillRequest struct {
+	ID      string
+	Streams []string
+	Filters []Filter
+}
+
+// BackfillSource is implemented by the sources that can take the snapshot
+// of the streams while the change stream keeps running.
+// Backfilled rows are emitted as snapshot messages tagged with the backfill id
+type BackfillSource interface {
+	Backfill(request BackfillRequest) error
+}
+
+// AckSource is implemented by the sources that acknowledge the messages once they are written by the sink
+// or dropped by the pipeline. Messages are passed as emitted by the source, unacknowledged ones are redelivered
+type AckSource interface {
+	Ack(messages []*message.Message)
+}
+
+// NackSource is implemented by the acknowledging sources that reject the messages the pipeline fails to process,
+// e.g. to the dead letter exchange, so they aren't redelivered to the restarted pipeline to fail again
+type NackSource interface {
+	Nack(messages []*message.Message)
+}
+
+// FilterOperators are the comparisons supported by the backfill filters
+var FilterOperators = []string{">=", "<=", "!=", "=", ">", "<"}
+
+// Filter compares the column of the backfilled rows with the value.
+// Columns are checked against the stream schema and the values are bound as query parameters,
+// so the filter is never interpolated into the query
+type Filter struct {
+	Column   string      `json:"column"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+func (f Filter) Validate() error {
+	if f.Column == "" {
+		return fmt.Errorf("column is required for the filter")
+	}
+
+	if f.Value == nil {
+		return fmt.Errorf("value is required for the filter of %s", f.Column)
+	}
+
+	for _, operator := range FilterOperators {
+		if f.Operator == operator {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown operator %s for the filter of %s, expected one of %s", f.Operator, f.Column, strings.Join(FilterOperators, " "))
+}
+
+// ParseFilter parses the filter written as <column><operator><value>, e.g. "id>=100" or "status = active".
+// Value is kept as text, sources convert it to the column type
+func ParseFilter(expression string) (Filter, error) {
+	for idx := range expression {
+		for _, operator := range FilterOperators {
+			if strings.HasPrefix(expression[idx:], operator) {
+				filter := Filter{
+					Column:   strings.TrimSpace(expression[:idx]),
+					Operator: operator,
+					Value:    strings.TrimSpace(expression[idx+len(operator):]),
+				}
+				return filter, filter.Validate()
+			}
+		}
+	}
+
+	return Filter{}, fmt.Errorf("filter %s has no operator, expected one of %s", expression, strings.Join(FilterOperators, " "))
+}
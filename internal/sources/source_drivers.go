@@ -3,12 +3,20 @@ package sources
 type SourceDriver string
 
 const (
-	PostgresCDC         SourceDriver = "postgres_cdc"
-	PostgresIncremental SourceDriver = "postgres_incremental"
-	MongoStream         SourceDriver = "mongo_stream"
-	WebSockets          SourceDriver = "websocket"
-	AirTable            SourceDriver = "airtable"
-	Playground          SourceDriver = "playground"
-	MysqlCDC            SourceDriver = "mysql_cdc"
-	Kafka               SourceDriver = "kafka"
+	PostgresCDC          SourceDriver = "postgres_cdc"
+	PostgresIncremental  SourceDriver = "postgres_incremental"
+	MongoStream          SourceDriver = "mongo_stream"
+	WebSockets           SourceDriver = "websocket"
+	AirTable             SourceDriver = "airtable"
+	Playground           SourceDriver = "playground"
+	MysqlCDC             SourceDriver = "mysql_cdc"
+	Kafka                SourceDriver = "kafka"
+	MysqlIncremental     SourceDriver = "mysql_incremental"
+	SqliteIncremental    SourceDriver = "sqlite_incremental"
+	SqlServerIncremental SourceDriver = "sqlserver_incremental"
+	Webhook              SourceDriver = "webhook"
+	File                 SourceDriver = "file"
+	Nats                 SourceDriver = "nats"
+	S3                   SourceDriver = "s3"
+	RabbitMq             SourceDriver = "rabbit_mq"
 )
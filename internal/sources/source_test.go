@@ -0,0 +1,32 @@
+package sources
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	cases := map[string]Filter{
+		"id>=100":         {Column: "id", Operator: ">=", Value: "100"},
+		"status = active": {Column: "status", Operator: "=", Value: "active"},
+		"name != a=b":     {Column: "name", Operator: "!=", Value: "a=b"},
+		"age<30":          {Column: "age", Operator: "<", Value: "30"},
+	}
+
+	for expression, expected := range cases {
+		filter, err := ParseFilter(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filter != expected {
+			t.Fatalf("unexpected filter %+v for %s", filter, expression)
+		}
+	}
+
+	for _, expression := range []string{"id", "= 1", "id ~ 1"} {
+		if _, err := ParseFilter(expression); err == nil {
+			t.Fatalf("filter %s must be rejected", expression)
+		}
+	}
+
+	if err := (Filter{Column: "id", Operator: "LIKE", Value: "1"}).Validate(); err == nil {
+		t.Fatal("unknown operator must be rejected")
+	}
+}
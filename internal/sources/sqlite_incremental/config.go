@@ -0,0 +1,9 @@
+package sqlite_incremental
+
+import "github.com/usedatabrew/blink/internal/sources/incremental"
+
+type Config struct {
+	// Path is the path to the database file
+	Path               string `json:"path" yaml:"path"`
+	incremental.Config `yaml:",inline"`
+}
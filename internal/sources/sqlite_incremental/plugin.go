@@ -0,0 +1,44 @@
+package sqlite_incremental
+
+import (
+	"fmt"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/incremental"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	_ "modernc.org/sqlite"
+)
+
+type dialect struct {
+	config Config
+}
+
+func NewSqliteIncrSourcePlugin(appCtx *stream_context.Context, config Config, s []schema.StreamSchema) sources.DataSource {
+	return incremental.NewSource(appCtx, config.Config, dialect{config: config}, s)
+}
+
+func (d dialect) Name() string {
+	return "sqlite_incremental"
+}
+
+func (d dialect) DriverName() string {
+	return "sqlite"
+}
+
+// DSN opens the database in the read-only mode, so the source never locks it for writing
+func (d dialect) DSN() string {
+	return fmt.Sprintf("file:%s?mode=ro", d.config.Path)
+}
+
+func (d dialect) Placeholder(position int) string {
+	return "?"
+}
+
+func (d dialect) Limit(query string, limit int) string {
+	return fmt.Sprintf("%s LIMIT %d", query, limit)
+}
+
+// TimeLayout matches CURRENT_TIMESTAMP, as SQLite compares the timestamps stored as text by their text
+func (d dialect) TimeLayout() string {
+	return "2006-01-02 15:04:05.999999999"
+}
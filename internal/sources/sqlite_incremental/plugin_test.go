@@ -0,0 +1,98 @@
+package sqlite_incremental
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources/incremental"
+	"github.com/usedatabrew/blink/internal/stream_context"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "users",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "name", DatabrewType: "String"},
+			{Name: "updated_at", DatabrewType: "String"},
+		},
+	},
+}
+
+func TestConfig_Inline(t *testing.T) {
+	driverConfig, err := config.ReadDriverConfig[Config](map[string]interface{}{
+		"path":          "test.db",
+		"cursor_column": "updated_at",
+		"batch_size":    10,
+	}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if driverConfig.Path != "test.db" || driverConfig.CursorColumn != "updated_at" || driverConfig.BatchSize != 10 {
+		t.Fatalf("unexpected config %+v", driverConfig)
+	}
+}
+
+func TestSourcePlugin_Sync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, updated_at TEXT);
+		INSERT INTO users VALUES (3, 'c', '2024-01-01 10:00:00'), (1, 'a', '2024-01-01 09:00:00'), (2, 'b', '2024-01-01 10:00:00');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewSqliteIncrSourcePlugin(stream_context.CreateContext(1), Config{
+		Path: path,
+		Config: incremental.Config{
+			StreamSnapshot: true,
+			CursorColumn:   "updated_at",
+			BatchSize:      2,
+			PollInterval:   1,
+		},
+	}, testStreamSchema)
+	if err = source.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	go source.Start()
+
+	expectRows := func(expected ...string) {
+		for _, row := range expected {
+			select {
+			case event := <-source.Events():
+				if event.Message.AsJSONString() != row {
+					t.Fatalf("expected %s, got %s", row, event.Message.AsJSONString())
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("row %s wasn't synced", row)
+			}
+		}
+	}
+
+	expectRows(
+		`[{"id":1,"name":"a","updated_at":"2024-01-01 09:00:00"}]`,
+		`[{"id":2,"name":"b","updated_at":"2024-01-01 10:00:00"}]`,
+		`[{"id":3,"name":"c","updated_at":"2024-01-01 10:00:00"}]`,
+	)
+
+	_, err = db.Exec(`INSERT INTO users VALUES (4, 'd', '2024-01-01 10:00:00'), (0, 'e', '2024-01-01 11:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectRows(
+		`[{"id":4,"name":"d","updated_at":"2024-01-01 10:00:00"}]`,
+		`[{"id":0,"name":"e","updated_at":"2024-01-01 11:00:00"}]`,
+	)
+}
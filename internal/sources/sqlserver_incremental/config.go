@@ -0,0 +1,13 @@
+package sqlserver_incremental
+
+import "github.com/usedatabrew/blink/internal/sources/incremental"
+
+type Config struct {
+	Host               string `json:"host" yaml:"host"`
+	Port               int    `json:"port" yaml:"port"`
+	Database           string `json:"database" yaml:"database"`
+	User               string `json:"user" yaml:"user"`
+	Password           string `json:"password" yaml:"password"`
+	SSLRequired        bool   `json:"ssl_required" yaml:"ssl_required"`
+	incremental.Config `yaml:",inline"`
+}
@@ -0,0 +1,58 @@
+package sqlserver_incremental
+
+import (
+	"fmt"
+	_ "github.com/microsoft/go-mssqldb"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/sources/incremental"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"net/url"
+)
+
+type dialect struct {
+	config Config
+}
+
+func NewSqlServerIncrSourcePlugin(appCtx *stream_context.Context, config Config, s []schema.StreamSchema) sources.DataSource {
+	return incremental.NewSource(appCtx, config.Config, dialect{config: config}, s)
+}
+
+func (d dialect) Name() string {
+	return "sqlserver_incremental"
+}
+
+func (d dialect) DriverName() string {
+	return "sqlserver"
+}
+
+func (d dialect) DSN() string {
+	query := url.Values{}
+	query.Set("database", d.config.Database)
+	if d.config.SSLRequired {
+		query.Set("encrypt", "true")
+	}
+
+	link := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(d.config.User, d.config.Password),
+		Host:     fmt.Sprintf("%s:%d", d.config.Host, d.config.Port),
+		RawQuery: query.Encode(),
+	}
+
+	return link.String()
+}
+
+func (d dialect) Placeholder(position int) string {
+	return fmt.Sprintf("@p%d", position)
+}
+
+// Limit uses OFFSET FETCH, as SQL Server has no LIMIT. The queries are always ordered, as it requires
+func (d dialect) Limit(query string, limit int) string {
+	return fmt.Sprintf("%s OFFSET 0 ROWS FETCH NEXT %d ROWS ONLY", query, limit)
+}
+
+// TimeLayout is ISO 8601, the only text format converted to datetime and datetime2 regardless of the language settings
+func (d dialect) TimeLayout() string {
+	return "2006-01-02T15:04:05.9999999"
+}
@@ -0,0 +1,25 @@
+package webhook
+
+type Config struct {
+	// Listen is the address of the HTTP server. Defaults to :8080
+	Listen string `json:"listen" yaml:"listen"`
+	// PathPrefix prefixes the stream endpoints, messages of the stream are posted to <path_prefix>/<stream>.
+	// Defaults to /webhooks
+	PathPrefix string `json:"path_prefix" yaml:"path_prefix"`
+	// BearerToken is required in the Authorization header of the requests when set
+	BearerToken string `json:"bearer_token" yaml:"bearer_token"`
+	// SignatureScheme enables the HMAC-SHA256 verification of the request body.
+	// One of github (X-Hub-Signature-256), stripe (Stripe-Signature) or hmac_sha256 (hex digest in SignatureHeader)
+	SignatureScheme string `json:"signature_scheme" yaml:"signature_scheme"`
+	SignatureSecret string `json:"signature_secret" yaml:"signature_secret"`
+	// SignatureHeader overrides the header holding the signature
+	SignatureHeader string `json:"signature_header" yaml:"signature_header"`
+	// SignatureTolerance is the max age of the stripe signature timestamp in seconds. Defaults to 300
+	SignatureTolerance int `json:"signature_tolerance" yaml:"signature_tolerance"`
+	// MaxBodySize is the max size of the request body in bytes. Defaults to 10MB
+	MaxBodySize int64 `json:"max_body_size" yaml:"max_body_size"`
+	// AcceptTimeout is how long the request waits for the pipeline to accept its messages
+	// in milliseconds before it's rejected with 503. Request accepted partially is answered with 202
+	// and the amount of the rejected trailing records to resend. Defaults to 1000
+	AcceptTimeout int `json:"accept_timeout" yaml:"accept_timeout"`
+}
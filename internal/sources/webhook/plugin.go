@@ -0,0 +1,261 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/charmbracelet/log"
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/message"
+)
+
+const (
+	defaultListen        = ":8080"
+	defaultPathPrefix    = "/webhooks"
+	defaultMaxBodySize   = 10 << 20
+	defaultAcceptTimeout = 1000
+)
+
+// response is the body of the webhook response
+type response struct {
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// SourcePlugin receives the messages of the streams posted to their HTTP endpoints.
+// Request is answered with 202 once its messages are accepted by the pipeline
+type SourcePlugin struct {
+	config        Config
+	inputSchema   []schema.StreamSchema
+	outputSchema  map[string]*arrow.Schema
+	verifier      *verifier
+	listener      net.Listener
+	server        *http.Server
+	logger        *log.Logger
+	messageStream chan sources.MessageEvent
+}
+
+func NewWebhookSourcePlugin(config Config, schema []schema.StreamSchema) sources.DataSource {
+	if config.Listen == "" {
+		config.Listen = defaultListen
+	}
+	if config.PathPrefix == "" {
+		config.PathPrefix = defaultPathPrefix
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaultMaxBodySize
+	}
+	if config.AcceptTimeout <= 0 {
+		config.AcceptTimeout = defaultAcceptTimeout
+	}
+
+	return &SourcePlugin{
+		config:        config,
+		inputSchema:   schema,
+		outputSchema:  sources.BuildOutputSchema(schema),
+		logger:        log.WithPrefix("[source]: webhook"),
+		messageStream: make(chan sources.MessageEvent),
+	}
+}
+
+func (p *SourcePlugin) Connect(ctx context.Context) error {
+	v, err := newVerifier(p.config)
+	if err != nil {
+		return err
+	}
+	p.verifier = v
+
+	listener, err := net.Listen("tcp", p.config.Listen)
+	if err != nil {
+		return err
+	}
+
+	p.listener = listener
+	p.server = &http.Server{Handler: p.handler()}
+	return nil
+}
+
+func (p *SourcePlugin) Start() {
+	go func() {
+		p.logger.Info("Receiving webhooks", "address", p.listener.Addr().String(), "path_prefix", p.config.PathPrefix)
+		if err := p.server.Serve(p.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			p.logger.Fatal(err)
+		}
+	}()
+}
+
+func (p *SourcePlugin) Events() chan sources.MessageEvent {
+	return p.messageStream
+}
+
+func (p *SourcePlugin) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.server.Shutdown(ctx)
+}
+
+// handler routes the requests to the endpoints of the streams
+func (p *SourcePlugin) handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, stream := range p.inputSchema {
+		stream := stream.StreamName
+		mux.HandleFunc(strings.TrimSuffix(p.config.PathPrefix, "/")+"/"+stream, func(w http.ResponseWriter, r *http.Request) {
+			p.receive(stream, w, r)
+		})
+	}
+
+	return mux
+}
+
+func (p *SourcePlugin) receive(stream string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, response{Error: "method is not allowed"})
+		return
+	}
+
+	if !p.authorized(r) {
+		writeResponse(w, http.StatusUnauthorized, response{Error: "bearer token is invalid"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, p.config.MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeResponse(w, http.StatusRequestEntityTooLarge, response{Error: err.Error()})
+			return
+		}
+		writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	if p.verifier != nil {
+		if err = p.verifier.Verify(r.Header, body); err != nil {
+			writeResponse(w, http.StatusUnauthorized, response{Error: err.Error()})
+			return
+		}
+	}
+
+	messages, err := p.decode(stream, body, isNDJSON(r.Header.Get("Content-Type")))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	// messages accepted before the timeout can't be taken back, so the partially accepted request
+	// is answered with 202 reporting how many of the leading records the client has to resend.
+	// Request is rejected with 503 only when none of its records are accepted
+	timeout := time.NewTimer(time.Duration(p.config.AcceptTimeout) * time.Millisecond)
+	defer timeout.Stop()
+	for idx, m := range messages {
+		select {
+		case p.messageStream <- sources.MessageEvent{Message: m, Err: nil}:
+		case <-timeout.C:
+			w.Header().Set("Retry-After", "1")
+			status := http.StatusServiceUnavailable
+			if idx > 0 {
+				status = http.StatusAccepted
+			}
+			writeResponse(w, status, response{Accepted: idx, Rejected: len(messages) - idx, Error: "pipeline is saturated"})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	writeResponse(w, http.StatusAccepted, response{Accepted: len(messages)})
+}
+
+func (p *SourcePlugin) authorized(r *http.Request) bool {
+	if p.config.BearerToken == "" {
+		return true
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(p.config.BearerToken)) == 1
+}
+
+// decode validates the records of the body against the stream schema. The body is a JSON object,
+// an array of them or the newline delimited objects. No message is emitted if any record is invalid
+func (p *SourcePlugin) decode(stream string, body []byte, ndjson bool) ([]*message.Message, error) {
+	var records []json.RawMessage
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case ndjson:
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(trimmed)+1)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				records = append(records, append(json.RawMessage{}, line...))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, err
+		}
+	default:
+		records = append(records, trimmed)
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("body contains no records")
+	}
+
+	arrowSchema := p.outputSchema[stream]
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, arrowSchema)
+	defer builder.Release()
+
+	var messages []*message.Message
+	for idx, raw := range records {
+		if err := builder.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("record %d: %w", idx, err)
+		}
+
+		record := builder.NewRecord()
+		for i, field := range arrowSchema.Fields() {
+			if !field.Nullable && record.Column(i).IsNull(0) {
+				record.Release()
+				return nil, fmt.Errorf("record %d: field %s is required", idx, field.Name)
+			}
+		}
+
+		data, err := record.MarshalJSON()
+		record.Release()
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message.NewMessage(message.Insert, stream, data))
+	}
+
+	return messages, nil
+}
+
+func isNDJSON(contentType string) bool {
+	return strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonlines")
+}
+
+func writeResponse(w http.ResponseWriter, status int, body response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/usedatabrew/blink/internal/schema"
+)
+
+var testStreamSchema = []schema.StreamSchema{
+	{
+		StreamName: "orders",
+		Columns: []schema.Column{
+			{Name: "id", DatabrewType: "Int64", PK: true},
+			{Name: "status", DatabrewType: "String", Nullable: true},
+		},
+	},
+}
+
+func newTestPlugin(t *testing.T, config Config) *SourcePlugin {
+	plugin := NewWebhookSourcePlugin(config, testStreamSchema).(*SourcePlugin)
+	v, err := newVerifier(plugin.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin.verifier = v
+	return plugin
+}
+
+// consume accepts the messages of the plugin until the test ends
+func consume(t *testing.T, plugin *SourcePlugin) chan string {
+	received := make(chan string, 100)
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			select {
+			case event := <-plugin.Events():
+				received <- event.Message.AsJSONString()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return received
+}
+
+func post(plugin *SourcePlugin, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	plugin.handler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSourcePlugin_Receive(t *testing.T) {
+	plugin := newTestPlugin(t, Config{})
+	received := consume(t, plugin)
+
+	response := post(plugin, "/webhooks/orders", `{"id": 1, "status": "paid"}`, nil)
+	if response.Code != http.StatusAccepted || <-received != `[{"id":1,"status":"paid"}]` {
+		t.Fatalf("unexpected response %d %s", response.Code, response.Body.String())
+	}
+
+	response = post(plugin, "/webhooks/orders", "{\"id\": 2}\n\n{\"id\": 3}\n", map[string]string{"Content-Type": "application/x-ndjson"})
+	if response.Code != http.StatusAccepted || <-received != `[{"id":2,"status":null}]` || <-received != `[{"id":3,"status":null}]` {
+		t.Fatalf("unexpected response %d %s", response.Code, response.Body.String())
+	}
+
+	response = post(plugin, "/webhooks/orders", `[{"id": 4}, {"status": "missing id"}]`, nil)
+	if response.Code != http.StatusBadRequest || !strings.Contains(response.Body.String(), "field id is required") {
+		t.Fatalf("record without the required field must be rejected, got %d %s", response.Code, response.Body.String())
+	}
+
+	response = post(plugin, "/webhooks/orders", `{"id": "not a number"}`, nil)
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("record of the wrong type must be rejected, got %d", response.Code)
+	}
+
+	if response = post(plugin, "/webhooks/users", `{"id": 1}`, nil); response.Code != http.StatusNotFound {
+		t.Fatalf("unknown stream must not be found, got %d", response.Code)
+	}
+
+	select {
+	case m := <-received:
+		t.Fatalf("no message of the rejected requests must be emitted, got %s", m)
+	default:
+	}
+}
+
+func TestSourcePlugin_Backpressure(t *testing.T) {
+	plugin := newTestPlugin(t, Config{AcceptTimeout: 10})
+
+	response := post(plugin, "/webhooks/orders", `{"id": 1}`, nil)
+	if response.Code != http.StatusServiceUnavailable || response.Header().Get("Retry-After") == "" {
+		t.Fatalf("saturated pipeline must reject the request, got %d", response.Code)
+	}
+
+	// pipeline accepts the first record only
+	go func() { <-plugin.Events() }()
+	response = post(plugin, "/webhooks/orders", `[{"id": 1}, {"id": 2}, {"id": 3}]`, nil)
+	if response.Code != http.StatusAccepted || !strings.Contains(response.Body.String(), `"accepted":1,"rejected":2`) {
+		t.Fatalf("partially accepted request must report the rejected records, got %d %s", response.Code, response.Body.String())
+	}
+}
+
+func TestSourcePlugin_BearerToken(t *testing.T) {
+	plugin := newTestPlugin(t, Config{BearerToken: "secret"})
+	consume(t, plugin)
+
+	if response := post(plugin, "/webhooks/orders", `{"id": 1}`, map[string]string{"Authorization": "Bearer wrong"}); response.Code != http.StatusUnauthorized {
+		t.Fatalf("request with the wrong token must be rejected, got %d", response.Code)
+	}
+
+	if response := post(plugin, "/webhooks/orders", `{"id": 1}`, map[string]string{"Authorization": "Bearer secret"}); response.Code != http.StatusAccepted {
+		t.Fatalf("request with the token must be accepted, got %d", response.Code)
+	}
+}
+
+func TestSourcePlugin_Signatures(t *testing.T) {
+	body := `{"id": 1}`
+
+	github := newTestPlugin(t, Config{SignatureScheme: SchemeGithub, SignatureSecret: "secret"})
+	consume(t, github)
+	if response := post(github, "/webhooks/orders", body, map[string]string{"X-Hub-Signature-256": "sha256=" + sign("secret", body)}); response.Code != http.StatusAccepted {
+		t.Fatalf("signed request must be accepted, got %d %s", response.Code, response.Body.String())
+	}
+	if response := post(github, "/webhooks/orders", body, map[string]string{"X-Hub-Signature-256": "sha256=" + sign("other", body)}); response.Code != http.StatusUnauthorized {
+		t.Fatalf("request signed with the other secret must be rejected, got %d", response.Code)
+	}
+	if response := post(github, "/webhooks/orders", body, map[string]string{"X-Hub-Signature-256": sign("secret", body)}); response.Code != http.StatusUnauthorized {
+		t.Fatalf("github signature without the prefix must be rejected, got %d", response.Code)
+	}
+	if response := post(github, "/webhooks/orders", body, nil); response.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request must be rejected, got %d", response.Code)
+	}
+
+	stripe := newTestPlugin(t, Config{SignatureScheme: SchemeStripe, SignatureSecret: "secret"})
+	consume(t, stripe)
+	stripe.verifier.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	header := fmt.Sprintf("t=1700000000,v1=%s,v1=%s", sign("old", "1700000000."+body), sign("secret", "1700000000."+body))
+	if response := post(stripe, "/webhooks/orders", body, map[string]string{"Stripe-Signature": header}); response.Code != http.StatusAccepted {
+		t.Fatalf("signed request must be accepted, got %d %s", response.Code, response.Body.String())
+	}
+
+	header = fmt.Sprintf("t=1600000000,v1=%s", sign("secret", "1600000000."+body))
+	if response := post(stripe, "/webhooks/orders", body, map[string]string{"Stripe-Signature": header}); response.Code != http.StatusUnauthorized {
+		t.Fatalf("request signed too long ago must be rejected, got %d", response.Code)
+	}
+
+	if _, err := newVerifier(Config{SignatureScheme: SchemeHmacSha256}); err == nil {
+		t.Fatal("signature scheme requires the secret")
+	}
+}
@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SchemeGithub     = "github"
+	SchemeStripe     = "stripe"
+	SchemeHmacSha256 = "hmac_sha256"
+)
+
+var errInvalidSignature = errors.New("signature is invalid")
+
+// verifier checks the signature of the request body
+type verifier struct {
+	scheme    string
+	secret    []byte
+	header    string
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+func newVerifier(config Config) (*verifier, error) {
+	if config.SignatureScheme == "" {
+		return nil, nil
+	}
+
+	if config.SignatureSecret == "" {
+		return nil, errors.New("signature_secret is required to verify the signatures")
+	}
+
+	v := &verifier{
+		scheme:    config.SignatureScheme,
+		secret:    []byte(config.SignatureSecret),
+		header:    config.SignatureHeader,
+		tolerance: time.Duration(config.SignatureTolerance) * time.Second,
+		now:       time.Now,
+	}
+
+	var defaultHeader string
+	switch v.scheme {
+	case SchemeGithub:
+		defaultHeader = "X-Hub-Signature-256"
+	case SchemeStripe:
+		defaultHeader = "Stripe-Signature"
+	case SchemeHmacSha256:
+		defaultHeader = "X-Signature"
+	default:
+		return nil, fmt.Errorf("unsupported signature scheme %s", v.scheme)
+	}
+
+	if v.header == "" {
+		v.header = defaultHeader
+	}
+	if v.tolerance <= 0 {
+		v.tolerance = 300 * time.Second
+	}
+
+	return v, nil
+}
+
+func (v *verifier) Verify(header http.Header, body []byte) error {
+	signature := header.Get(v.header)
+	if signature == "" {
+		return fmt.Errorf("%s header is missing", v.header)
+	}
+
+	switch v.scheme {
+	case SchemeStripe:
+		return v.verifyStripe(signature, body)
+	case SchemeGithub:
+		// github signature is always prefixed with the algorithm
+		hexSignature, found := strings.CutPrefix(signature, "sha256=")
+		if !found {
+			return errors.New("signature has to be prefixed with sha256=")
+		}
+		return v.compare(hexSignature, body)
+	default:
+		// the prefix is optional for the plain hmac
+		return v.compare(strings.TrimPrefix(signature, "sha256="), body)
+	}
+}
+
+// verifyStripe checks the signature of the "<timestamp>.<body>" payload.
+// The header is t=<timestamp>,v1=<signature>[,v1=<signature>], any of v1 signatures has to match
+func (v *verifier) verifyStripe(header string, body []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return errors.New("signature header is malformed")
+	}
+
+	if age := v.now().Sub(time.Unix(signedAt, 0)); age > v.tolerance || age < -v.tolerance {
+		return errors.New("signature timestamp is outside of the tolerance")
+	}
+
+	payload := append([]byte(timestamp+"."), body...)
+	for _, signature := range signatures {
+		if v.compare(signature, payload) == nil {
+			return nil
+		}
+	}
+
+	return errInvalidSignature
+}
+
+func (v *verifier) compare(signature string, payload []byte) error {
+	received, err := hex.DecodeString(signature)
+	if err != nil {
+		return errInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write(payload)
+	if !hmac.Equal(received, mac.Sum(nil)) {
+		return errInvalidSignature
+	}
+
+	return nil
+}
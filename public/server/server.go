@@ -1,19 +1,39 @@
 package server
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/usedatabrew/blink/internal/sources"
 	"github.com/usedatabrew/blink/public/stream"
 	"io"
 	"net/http"
+	"strings"
 )
 
 type Server struct {
 	stream *stream.Stream
+	// adminToken authorizes the admin requests changing the pipeline, e.g. backfills.
+	// They are rejected when it's not set
+	adminToken string
 }
 
-func CreateAndStartHttpServer(stream *stream.Stream) {
-	server := &Server{}
+// BackfillRequest is the body of the backfill request.
+// Rows of the streams are backfilled entirely without the filters
+type BackfillRequest struct {
+	Streams []string         `json:"streams"`
+	Filters []sources.Filter `json:"filters"`
+}
+
+type BackfillResponse struct {
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func CreateAndStartHttpServer(stream *stream.Stream, adminToken string) {
+	server := &Server{stream: stream, adminToken: adminToken}
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/backfill", server.backfill)
 	http.HandleFunc("/", server.status)
 	err := http.ListenAndServe(":3333", nil)
 	if err != nil {
@@ -23,3 +43,49 @@ func CreateAndStartHttpServer(stream *stream.Stream) {
 func (s *Server) status(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, "Worker is up!")
 }
+
+// backfill triggers the snapshot of the streams while the pipeline keeps running
+func (s *Server) backfill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, BackfillResponse{Error: "method is not allowed"})
+		return
+	}
+
+	if s.adminToken == "" {
+		writeJSON(w, http.StatusForbidden, BackfillResponse{Error: "backfills are disabled, set service.admin_token to enable them"})
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, BackfillResponse{Error: "admin token is invalid"})
+		return
+	}
+
+	var request BackfillRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, BackfillResponse{Error: err.Error()})
+		return
+	}
+
+	id, err := s.stream.Backfill(request.Streams, request.Filters)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, BackfillResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, BackfillResponse{ID: id})
+}
+
+// authorized checks the bearer token of the request against the admin token
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
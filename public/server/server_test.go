@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/usedatabrew/blink/public/stream"
+)
+
+func TestServer_BackfillAuth(t *testing.T) {
+	backfill := func(server *Server, token string) int {
+		request := httptest.NewRequest(http.MethodPost, "/backfill", strings.NewReader(`{"streams":["users"]}`))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.backfill(recorder, request)
+		return recorder.Code
+	}
+
+	if status := backfill(&Server{stream: &stream.Stream{}}, "secret"); status != http.StatusForbidden {
+		t.Fatalf("backfill must be disabled without the admin token, got %d", status)
+	}
+
+	server := &Server{stream: &stream.Stream{}, adminToken: "secret"}
+	if status := backfill(server, ""); status != http.StatusUnauthorized {
+		t.Fatalf("request without the token must be rejected, got %d", status)
+	}
+
+	if status := backfill(server, "guess"); status != http.StatusUnauthorized {
+		t.Fatalf("request with the invalid token must be rejected, got %d", status)
+	}
+
+	// stream has no source, so the authorized request reaches the pipeline and fails there
+	if status := backfill(server, "secret"); status != http.StatusBadRequest {
+		t.Fatalf("authorized request must reach the pipeline, got %d", status)
+	}
+}
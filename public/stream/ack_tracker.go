@@ -0,0 +1,179 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/usedatabrew/message"
+)
+
+// trackedItem is the item sent to the pipeline with the source messages it carries
+type trackedItem struct {
+	// number of the item among the items sent to the pipeline
+	number   int64
+	messages []*message.Message
+	flush    bool
+}
+
+// writtenItem holds the source messages written by the buffered sink until it stores them
+type writtenItem struct {
+	messages []*message.Message
+	// position is the amount of the messages written by the sink once the item is completed
+	position int64
+}
+
+// ackTracker follows the source messages through the pipeline, so the source acknowledges them
+// once they are stored by the sink. Stages process the items in order, so the item completed
+// by the sink stage is always the oldest one in flight. Messages held by the buffered processors
+// are written on the flush, so they are acknowledged once the next flush completes.
+// Buffered sinks store the written messages later, so their messages wait until the sink
+// reports the position of the item durable. Messages of the item a stage fails on are rejected.
+// Methods of the nil tracker do nothing, so the stream calls them whether the source acknowledges or not
+type ackTracker struct {
+	ack      func(messages []*message.Message)
+	nack     func(messages []*message.Message)
+	buffered bool
+	// durable returns the amount of the messages stored by the buffered sink.
+	// It's nil for the sinks storing the messages on write
+	durable   func() int64
+	mutex     sync.Mutex
+	sentItems int64
+	inFlight  []trackedItem
+	held      []*message.Message
+	written   []writtenItem
+}
+
+func newAckTracker(ack, nack func(messages []*message.Message), buffered bool, durable func() int64) *ackTracker {
+	return &ackTracker{ack: ack, nack: nack, buffered: buffered, durable: durable}
+}
+
+// sent registers the item before it's sent to the pipeline
+func (t *ackTracker) sent(messages []*message.Message, flush bool) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.inFlight = append(t.inFlight, trackedItem{number: t.sentItems, messages: messages, flush: flush})
+	t.sentItems += 1
+}
+
+// completed is called once the sink stage completes the oldest item. Position is the amount
+// of the messages written by the sink so far
+func (t *ackTracker) completed(position int64) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	if len(t.inFlight) == 0 {
+		t.mutex.Unlock()
+		return
+	}
+
+	item := t.inFlight[0]
+	t.inFlight = t.inFlight[1:]
+
+	if t.buffered && !item.flush {
+		t.held = append(t.held, item.messages...)
+	} else if released := append(t.held, item.messages...); len(released) > 0 {
+		t.written = append(t.written, writtenItem{messages: released, position: position})
+		t.held = nil
+	}
+
+	acked := t.stored()
+	t.mutex.Unlock()
+
+	if len(acked) > 0 {
+		t.ack(acked)
+	}
+}
+
+// failed rejects the messages of the item the stage fails on. Number is the amount of the items
+// the stage processed before it, as every stage processes all the items in order.
+// Messages held by the buffered processors are flushed by the flush item, so they are rejected along with it
+func (t *ackTracker) failed(number int64) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	var rejected []*message.Message
+	for idx, item := range t.inFlight {
+		if item.number != number {
+			continue
+		}
+
+		rejected = append(rejected, item.messages...)
+		if item.flush {
+			rejected = append(rejected, t.held...)
+			t.held = nil
+		}
+		t.inFlight = append(t.inFlight[:idx:idx], t.inFlight[idx+1:]...)
+		break
+	}
+	t.mutex.Unlock()
+
+	if len(rejected) > 0 {
+		t.nack(rejected)
+	}
+}
+
+// rejectOnFailure numbers the items processed by the stage function, so the messages of the item
+// it fails on are rejected before the pipeline stops
+func (t *ackTracker) rejectOnFailure(function func(interface{}) (interface{}, error)) func(interface{}) (interface{}, error) {
+	if t == nil {
+		return function
+	}
+
+	var processed int64
+	return func(i interface{}) (interface{}, error) {
+		number := processed
+		processed += 1
+
+		result, err := function(i)
+		if err != nil {
+			t.failed(number)
+		}
+		return result, err
+	}
+}
+
+// release acknowledges the messages the buffered sink has stored since they were completed
+func (t *ackTracker) release() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	acked := t.stored()
+	t.mutex.Unlock()
+
+	if len(acked) > 0 {
+		t.ack(acked)
+	}
+}
+
+// stored pops the written messages the sink has stored
+func (t *ackTracker) stored() []*message.Message {
+	if len(t.written) == 0 {
+		return nil
+	}
+
+	var acked []*message.Message
+	if t.durable == nil {
+		for _, item := range t.written {
+			acked = append(acked, item.messages...)
+		}
+		t.written = nil
+		return acked
+	}
+
+	durable := t.durable()
+	for len(t.written) > 0 && t.written[0].position <= durable {
+		acked = append(acked, t.written[0].messages...)
+		t.written = t.written[1:]
+	}
+
+	return acked
+}
@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/usedatabrew/message"
+)
+
+func TestAckTracker(t *testing.T) {
+	first := message.NewMessage(message.Insert, "users", []byte(`[{"id": 1}]`))
+	second := message.NewMessage(message.Insert, "users", []byte(`[{"id": 2}]`))
+
+	var acked []*message.Message
+	tracker := newAckTracker(func(messages []*message.Message) { acked = append(acked, messages...) }, nil, false, nil)
+	tracker.sent([]*message.Message{first}, false)
+	tracker.sent([]*message.Message{second}, false)
+	tracker.completed(0)
+	if len(acked) != 1 || acked[0] != first {
+		t.Fatalf("only the completed message must be acknowledged, got %v", acked)
+	}
+
+	// messages held by the buffered processors are acknowledged with the flush
+	acked = nil
+	tracker = newAckTracker(func(messages []*message.Message) { acked = append(acked, messages...) }, nil, true, nil)
+	tracker.sent([]*message.Message{first}, false)
+	tracker.sent([]*message.Message{second}, false)
+	tracker.sent(nil, true)
+	tracker.completed(0)
+	tracker.completed(0)
+	if len(acked) != 0 {
+		t.Fatalf("buffered messages must not be acknowledged before the flush, got %v", acked)
+	}
+
+	tracker.completed(0)
+	if len(acked) != 2 {
+		t.Fatalf("flush must acknowledge the buffered messages, got %v", acked)
+	}
+
+	// messages written by the buffered sink are acknowledged once the sink stores them
+	acked = nil
+	var durable int64
+	tracker = newAckTracker(func(messages []*message.Message) { acked = append(acked, messages...) }, nil, false, func() int64 { return durable })
+	tracker.sent([]*message.Message{first}, false)
+	tracker.sent([]*message.Message{second}, false)
+	tracker.completed(1)
+	tracker.completed(2)
+	if len(acked) != 0 {
+		t.Fatalf("messages must not be acknowledged before the sink stores them, got %v", acked)
+	}
+
+	durable = 1
+	tracker.release()
+	if len(acked) != 1 || acked[0] != first {
+		t.Fatalf("only the stored message must be acknowledged, got %v", acked)
+	}
+
+	// messages of the item the stage fails on are rejected
+	var rejected []*message.Message
+	tracker = newAckTracker(func(messages []*message.Message) {}, func(messages []*message.Message) { rejected = append(rejected, messages...) }, false, nil)
+	tracker.sent([]*message.Message{first}, false)
+	tracker.sent([]*message.Message{second}, false)
+	stage := tracker.rejectOnFailure(func(i interface{}) (interface{}, error) {
+		if i == second {
+			return nil, errors.New("failed to process message")
+		}
+		return i, nil
+	})
+	for _, msg := range []*message.Message{first, second} {
+		stage(msg)
+	}
+	if len(rejected) != 1 || rejected[0] != second {
+		t.Fatalf("only the failed message must be rejected, got %v", rejected)
+	}
+
+	var nilTracker *ackTracker
+	nilTracker.sent([]*message.Message{first}, false)
+	nilTracker.completed(0)
+	nilTracker.release()
+}
@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/blink/internal/helper"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/message"
+)
+
+const allStreams = "*"
+
+// knownEvents are the events of the source messages the rules can match
+var knownEvents = map[message.Event]bool{
+	message.Snapshot:          true,
+	message.Insert:            true,
+	message.Update:            true,
+	message.Delete:            true,
+	sources.TruncateEvent:     true,
+	sources.SchemaChangeEvent: true,
+}
+
+type eventRule struct {
+	stream string
+	ignore map[message.Event]bool
+	remap  map[message.Event]message.Event
+}
+
+// eventRules are applied to the source messages in the order of definition,
+// so the event remapped by a rule is seen by the next rules
+type eventRules []eventRule
+
+func newEventRules(rules []config.EventRule) (eventRules, error) {
+	var compiled eventRules
+	for _, rule := range rules {
+		if rule.Stream == "" {
+			return nil, errors.New("stream is required for event rule")
+		}
+
+		if len(rule.Ignore) == 0 && len(rule.Remap) == 0 {
+			return nil, fmt.Errorf("event rule for %s has neither ignore nor remap", rule.Stream)
+		}
+
+		compiledRule := eventRule{
+			stream: rule.Stream,
+			ignore: map[message.Event]bool{},
+			remap:  map[message.Event]message.Event{},
+		}
+
+		if compiledRule.stream != allStreams {
+			compiledRule.stream = helper.NormalizeStreamName(rule.Stream)
+		}
+
+		for _, event := range rule.Ignore {
+			if !knownEvents[message.Event(event)] {
+				return nil, fmt.Errorf("event rule for %s ignores unknown event %s", rule.Stream, event)
+			}
+			compiledRule.ignore[message.Event(event)] = true
+		}
+
+		for from, to := range rule.Remap {
+			if to == "" {
+				return nil, fmt.Errorf("event rule for %s remaps %s to empty event", rule.Stream, from)
+			}
+			if !knownEvents[message.Event(from)] {
+				return nil, fmt.Errorf("event rule for %s remaps unknown event %s", rule.Stream, from)
+			}
+			if !knownEvents[message.Event(to)] {
+				return nil, fmt.Errorf("event rule for %s remaps %s to unknown event %s", rule.Stream, from, to)
+			}
+			compiledRule.remap[message.Event(from)] = message.Event(to)
+		}
+
+		compiled = append(compiled, compiledRule)
+	}
+
+	return compiled, nil
+}
+
+// apply remaps the message event and reports if the message has to be passed further
+func (r eventRules) apply(msg *message.Message) bool {
+	if len(r) == 0 {
+		return true
+	}
+
+	stream := helper.NormalizeStreamName(msg.GetStream())
+	for _, rule := range r {
+		if rule.stream != allStreams && rule.stream != stream {
+			continue
+		}
+
+		if rule.ignore[msg.GetEvent()] {
+			return false
+		}
+
+		if event, ok := rule.remap[msg.GetEvent()]; ok {
+			msg.SetEvent(event)
+		}
+	}
+
+	return true
+}
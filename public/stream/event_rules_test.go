@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/message"
+)
+
+func TestEventRules_Apply(t *testing.T) {
+	rules, err := newEventRules([]config.EventRule{
+		{Stream: "public.orders", Ignore: []string{"delete"}},
+		{Stream: "*", Remap: map[string]string{"update": "insert"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := message.NewMessage(message.Delete, "orders", []byte(`[{"id": 1}]`))
+	if rules.apply(deleted) {
+		t.Fatal("deletes must be ignored for orders")
+	}
+
+	deletedUser := message.NewMessage(message.Delete, "users", []byte(`[{"id": 1}]`))
+	if !rules.apply(deletedUser) || deletedUser.GetEvent() != message.Delete {
+		t.Fatal("deletes must be passed for users")
+	}
+
+	updated := message.NewMessage(message.Update, "users", []byte(`[{"id": 1}]`))
+	if !rules.apply(updated) || updated.GetEvent() != message.Insert {
+		t.Fatalf("update must be remapped to insert, got %s", updated.GetEvent())
+	}
+
+	if _, err = newEventRules([]config.EventRule{{Stream: "users"}}); err == nil {
+		t.Fatal("rule without ignore and remap must be rejected")
+	}
+
+	if _, err = newEventRules([]config.EventRule{{Stream: "users", Remap: map[string]string{"update": ""}}}); err == nil {
+		t.Fatal("remap to empty event must be rejected")
+	}
+
+	if _, err = newEventRules([]config.EventRule{{Stream: "users", Ignore: []string{"updates"}}}); err == nil {
+		t.Fatal("ignore of unknown event must be rejected")
+	}
+
+	if _, err = newEventRules([]config.EventRule{{Stream: "users", Remap: map[string]string{"delete": "upsert"}}}); err == nil {
+		t.Fatal("remap to unknown event must be rejected")
+	}
+
+	if _, err = newEventRules([]config.EventRule{{Stream: "users", Remap: map[string]string{"insrt": "update"}}}); err == nil {
+		t.Fatal("remap of unknown event must be rejected")
+	}
+
+	if _, err = newEventRules([]config.EventRule{{Stream: "*", Ignore: []string{"truncate", "schema_change"}}}); err != nil {
+		t.Fatalf("control events must be accepted: %s", err)
+	}
+}
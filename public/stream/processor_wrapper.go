@@ -5,13 +5,25 @@ import (
 	"time"
 
 	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/blink/internal/expression"
 	"github.com/usedatabrew/blink/internal/metrics"
 	"github.com/usedatabrew/blink/internal/processors"
 	"github.com/usedatabrew/blink/internal/processors/ai_content_moderation"
+	"github.com/usedatabrew/blink/internal/processors/circuit_breaker"
+	"github.com/usedatabrew/blink/internal/processors/embeddings"
 	"github.com/usedatabrew/blink/internal/processors/http"
+	"github.com/usedatabrew/blink/internal/processors/join"
+	"github.com/usedatabrew/blink/internal/processors/jq"
 	logProc "github.com/usedatabrew/blink/internal/processors/log"
+	"github.com/usedatabrew/blink/internal/processors/mask"
 	"github.com/usedatabrew/blink/internal/processors/openai"
+	"github.com/usedatabrew/blink/internal/processors/redis_lookup"
+	"github.com/usedatabrew/blink/internal/processors/router"
+	"github.com/usedatabrew/blink/internal/processors/sample"
 	sqlproc "github.com/usedatabrew/blink/internal/processors/sql"
+	"github.com/usedatabrew/blink/internal/processors/throttle"
+	"github.com/usedatabrew/blink/internal/processors/transform"
+	"github.com/usedatabrew/blink/internal/processors/validate"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
@@ -24,6 +36,7 @@ type ProcessorWrapper struct {
 	ctx             *stream_context.Context
 	metrics         metrics.Metrics
 	procDriver      string
+	condition       *expression.Condition
 }
 
 func NewProcessorWrapper(pluginType processors.ProcessorDriver, config interface{}, appctx *stream_context.Context) ProcessorWrapper {
@@ -40,7 +53,23 @@ func NewProcessorWrapper(pluginType processors.ProcessorDriver, config interface
 	return loader
 }
 
+// SetCondition makes the processor apply only to the messages matching the expression.
+// Empty expression removes the condition
+func (p *ProcessorWrapper) SetCondition(when string) error {
+	condition, err := expression.Compile(when)
+	if err != nil {
+		return err
+	}
+
+	p.condition = condition
+	return nil
+}
+
 func (p *ProcessorWrapper) Process(msg *message.Message) (*message.Message, error) {
+	if matched, err := p.condition.Match(msg); err != nil || !matched {
+		return msg, err
+	}
+
 	p.metrics.IncrementProcessorReceivedMessages(p.procDriver)
 	execStart := time.Now()
 	procMsg, err := p.processorDriver.Process(p.ctx.GetContext(), msg)
@@ -56,6 +85,104 @@ func (p *ProcessorWrapper) Process(msg *message.Message) (*message.Message, erro
 	return procMsg, err
 }
 
+// EmitsMultipleMessages reports if the processor can produce
+// more than one message for a single incoming message
+func (p *ProcessorWrapper) EmitsMultipleMessages() bool {
+	_, ok := p.processorDriver.(processors.MultiMessageProcessor)
+	return ok
+}
+
+// ProcessMessages runs the processor for every message of the batch
+// and flattens the result. Batches are produced by multi message processors
+func (p *ProcessorWrapper) ProcessMessages(msgs []*message.Message) ([]*message.Message, error) {
+	var processed []*message.Message
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+
+		matched, err := p.condition.Match(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matched {
+			// messages buffered by the processor are emitted first to keep the order of the stream
+			flushed, err := p.Flush()
+			if err != nil {
+				return nil, err
+			}
+			processed = append(processed, flushed...)
+			processed = append(processed, msg)
+			continue
+		}
+
+		if multiProcessor, ok := p.processorDriver.(processors.MultiMessageProcessor); ok {
+			procMsgs, err := p.processMulti(multiProcessor, msg)
+			if err != nil {
+				return nil, err
+			}
+			processed = append(processed, procMsgs...)
+			continue
+		}
+
+		procMsg, err := p.Process(msg)
+		if err != nil {
+			return nil, err
+		}
+		if procMsg != nil {
+			processed = append(processed, procMsg)
+		}
+	}
+
+	return processed, nil
+}
+
+// IsBuffered reports if the processor keeps messages between the calls
+// and has to be flushed when the source is idle
+func (p *ProcessorWrapper) IsBuffered() bool {
+	_, ok := p.processorDriver.(processors.BufferedProcessor)
+	return ok
+}
+
+// Flush returns the messages buffered by the processor.
+// Processors that don't buffer messages return nothing
+func (p *ProcessorWrapper) Flush() ([]*message.Message, error) {
+	bufferedProcessor, ok := p.processorDriver.(processors.BufferedProcessor)
+	if !ok {
+		return nil, nil
+	}
+
+	return bufferedProcessor.Flush(p.ctx.GetContext())
+}
+
+// Close releases the resources of the processor
+func (p *ProcessorWrapper) Close() error {
+	closableProcessor, ok := p.processorDriver.(processors.ClosableProcessor)
+	if !ok {
+		return nil
+	}
+
+	return closableProcessor.Close()
+}
+
+func (p *ProcessorWrapper) processMulti(multiProcessor processors.MultiMessageProcessor, msg *message.Message) ([]*message.Message, error) {
+	p.metrics.IncrementProcessorReceivedMessages(p.procDriver)
+	execStart := time.Now()
+	procMsgs, err := multiProcessor.ProcessMulti(p.ctx.GetContext(), msg)
+	if err == nil {
+		p.metrics.IncrementProcessorSentMessages(p.procDriver)
+	}
+	// buffered processors return messages later, so empty result doesn't mean the message is dropped
+	if err == nil && len(procMsgs) == 0 && !p.IsBuffered() {
+		p.metrics.IncrementProcessorDroppedMessages(p.procDriver)
+	}
+
+	execEnd := time.Since(execStart)
+	p.metrics.SetProcessorExecutionTime(p.procDriver, execEnd.Milliseconds())
+	return procMsgs, err
+}
+
 func (p *ProcessorWrapper) EvolveSchema(s *schema.StreamSchemaObj) error {
 	return p.processorDriver.EvolveSchema(s)
 }
@@ -96,6 +223,77 @@ func (p *ProcessorWrapper) LoadDriver(driver processors.ProcessorDriver, cfg int
 			panic("can read driver config")
 		}
 		return logProc.NewLogPlugin(p.ctx, driverConfig)
+	case processors.JoinProcessor:
+		driverConfig, err := config.ReadDriverConfig[join.Config](cfg, join.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return join.NewJoinPlugin(p.ctx, driverConfig)
+	case processors.MaskProcessor:
+		driverConfig, err := config.ReadDriverConfig[mask.Config](cfg, mask.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return mask.NewMaskPlugin(p.ctx, driverConfig)
+	case processors.TransformProcessor:
+		driverConfig, err := config.ReadDriverConfig[transform.Config](cfg, transform.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return transform.NewTransformPlugin(p.ctx, driverConfig)
+	case processors.RouterProcessor:
+		driverConfig, err := config.ReadDriverConfig[router.Config](cfg, router.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return router.NewRouterPlugin(p.ctx, driverConfig)
+	case processors.EmbeddingsProcessor:
+		driverConfig, err := config.ReadDriverConfig[embeddings.Config](cfg, embeddings.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return embeddings.NewEmbeddingsPlugin(p.ctx, driverConfig)
+	case processors.ValidateProcessor:
+		driverConfig, err := config.ReadDriverConfig[validate.Config](cfg, validate.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return validate.NewValidatePlugin(p.ctx, driverConfig)
+	case processors.JqProcessor:
+		driverConfig, err := config.ReadDriverConfig[jq.Config](cfg, jq.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return jq.NewJqPlugin(p.ctx, driverConfig)
+	case processors.RedisLookupProcessor:
+		driverConfig, err := config.ReadDriverConfig[redis_lookup.Config](cfg, redis_lookup.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return redis_lookup.NewRedisLookupPlugin(p.ctx, driverConfig)
+	case processors.SampleProcessor:
+		driverConfig, err := config.ReadDriverConfig[sample.Config](cfg, sample.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return sample.NewSamplePlugin(p.ctx, driverConfig)
+	case processors.ThrottleProcessor:
+		driverConfig, err := config.ReadDriverConfig[throttle.Config](cfg, throttle.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		return throttle.NewThrottlePlugin(p.ctx, driverConfig)
+	case processors.CircuitBreakerProcessor:
+		driverConfig, err := config.ReadDriverConfig[circuit_breaker.Config](cfg, circuit_breaker.Config{})
+		if err != nil {
+			panic("can read driver config")
+		}
+		// wrapped processor is loaded the same way as the top level ones
+		wrapped, err := p.LoadDriver(processors.ProcessorDriver(driverConfig.Processor.Driver), driverConfig.Processor.Config)
+		if err != nil {
+			return nil, err
+		}
+		return circuit_breaker.NewCircuitBreakerPlugin(p.ctx, driverConfig, wrapped)
 	default:
 		return nil, errors.New("unregistered driver provided")
 	}
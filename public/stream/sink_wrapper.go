@@ -1,16 +1,21 @@
 package stream
 
 import (
+	"sync/atomic"
+
 	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/sinks"
 	"github.com/usedatabrew/blink/internal/sinks/clickhouse"
+	"github.com/usedatabrew/blink/internal/sinks/file"
 	"github.com/usedatabrew/blink/internal/sinks/kafka"
 	"github.com/usedatabrew/blink/internal/sinks/mongodb"
 	"github.com/usedatabrew/blink/internal/sinks/nats"
 	"github.com/usedatabrew/blink/internal/sinks/postgres"
 	"github.com/usedatabrew/blink/internal/sinks/rabbit_mq"
 	"github.com/usedatabrew/blink/internal/sinks/redis"
+	"github.com/usedatabrew/blink/internal/sinks/s3"
 	"github.com/usedatabrew/blink/internal/sinks/stdout"
 	websocket "github.com/usedatabrew/blink/internal/sinks/websockets"
 	"github.com/usedatabrew/blink/internal/stream_context"
@@ -20,8 +25,11 @@ import (
 // SinkWrapper wraps plan sink writer plugin in order to
 // measure performance, build proper configuration and control the context
 type SinkWrapper struct {
-	sinkDriver sinks.DataSink
-	ctx        *stream_context.Context
+	sinkDriver       sinks.DataSink
+	ctx              *stream_context.Context
+	stripBeforeImage bool
+	// written is the amount of the messages passed to the sink. It's read by the ack tracker
+	written int64
 }
 
 func NewSinkWrapper(pluginType sinks.SinkDriver, config config.Configuration, appctx *stream_context.Context) SinkWrapper {
@@ -36,8 +44,31 @@ func (p *SinkWrapper) Init() error {
 	return p.sinkDriver.Connect(p.ctx.GetContext())
 }
 
+// StripBeforeImage removes the before image from the messages before they are written,
+// so the sinks receive the row state after the change only
+func (p *SinkWrapper) StripBeforeImage() {
+	p.stripBeforeImage = true
+}
+
 func (p *SinkWrapper) Write(msg *message.Message) error {
-	err := p.sinkDriver.Write(msg)
+	if p.stripBeforeImage {
+		if err := helper.StripBeforeImage(msg); err != nil {
+			return err
+		}
+	}
+
+	metadata, err := helper.StripMetadata(msg)
+	if err != nil {
+		return err
+	}
+
+	if metadataSink, ok := p.sinkDriver.(sinks.MetadataSink); ok {
+		err = metadataSink.WriteWithMetadata(msg, metadata)
+	} else {
+		err = p.sinkDriver.Write(msg)
+	}
+	atomic.AddInt64(&p.written, 1)
+
 	if err != nil {
 		p.ctx.Metrics.IncrementSinkErrCounter()
 	} else {
@@ -47,6 +78,40 @@ func (p *SinkWrapper) Write(msg *message.Message) error {
 	return err
 }
 
+// Written returns the amount of the messages passed to the sink
+func (p *SinkWrapper) Written() int64 {
+	return atomic.LoadInt64(&p.written)
+}
+
+// IsBuffered reports if the sink stores the written messages later, e.g. once the file is rotated
+func (p *SinkWrapper) IsBuffered() bool {
+	_, ok := p.sinkDriver.(sinks.BufferedSink)
+	return ok
+}
+
+// Durable returns the amount of the messages passed to the sink that are durably stored.
+// Written amount is read before the buffered one, so the result never runs ahead of the sink
+func (p *SinkWrapper) Durable() int64 {
+	written := p.Written()
+	if bufferedSink, ok := p.sinkDriver.(sinks.BufferedSink); ok {
+		return written - bufferedSink.Buffered()
+	}
+
+	return written
+}
+
+// ApplyControlEvent passes the control event to the sink.
+// Sinks not supporting control events skip them
+func (p *SinkWrapper) ApplyControlEvent(msg *message.Message) error {
+	controlSink, ok := p.sinkDriver.(sinks.ControlEventSink)
+	if !ok {
+		p.ctx.Logger.WithPrefix("sink").Warn("Sink doesn't support control events, skipping", "event", msg.GetEvent(), "stream", msg.GetStream())
+		return nil
+	}
+
+	return controlSink.ApplyControlEvent(msg)
+}
+
 func (p *SinkWrapper) SetExpectedSchema(s *schema.StreamSchemaObj) {
 	p.sinkDriver.SetExpectedSchema(s.GetLatestSchema())
 }
@@ -117,6 +182,22 @@ func (p *SinkWrapper) LoadDriver(driver sinks.SinkDriver, cfg config.Configurati
 		}
 
 		return clickhouse.NewClickHouseSinkPlugin(driverConfig, p.ctx)
+	case sinks.S3SinkType:
+		driverConfig, err := config.ReadDriverConfig[s3.Config](cfg.Sink.Config, s3.Config{})
+
+		if err != nil {
+			panic("can't read driver config")
+		}
+
+		return s3.NewS3SinkPlugin(driverConfig, cfg.Source.StreamSchema, p.ctx)
+	case sinks.FileSinkType:
+		driverConfig, err := config.ReadDriverConfig[file.Config](cfg.Sink.Config, file.Config{})
+
+		if err != nil {
+			panic("can't read driver config")
+		}
+
+		return file.NewFileSinkPlugin(driverConfig, cfg.Source.StreamSchema, p.ctx)
 	default:
 		p.ctx.Logger.WithPrefix("Sink loader").Fatal("Failed to load driver", "driver", driver)
 	}
@@ -1,17 +1,28 @@
 package stream
 
 import (
+	"fmt"
+
 	"github.com/usedatabrew/blink/config"
 	"github.com/usedatabrew/blink/internal/sources"
 	"github.com/usedatabrew/blink/internal/sources/airtable"
+	"github.com/usedatabrew/blink/internal/sources/file"
 	"github.com/usedatabrew/blink/internal/sources/kafka"
 	"github.com/usedatabrew/blink/internal/sources/mongo_stream"
 	"github.com/usedatabrew/blink/internal/sources/mysql_cdc"
+	"github.com/usedatabrew/blink/internal/sources/mysql_incremental"
+	"github.com/usedatabrew/blink/internal/sources/nats"
 	"github.com/usedatabrew/blink/internal/sources/playground"
 	"github.com/usedatabrew/blink/internal/sources/postgres_cdc"
 	"github.com/usedatabrew/blink/internal/sources/postgres_incr_sync"
+	rabbit_mq_source "github.com/usedatabrew/blink/internal/sources/rabbit_mq"
+	"github.com/usedatabrew/blink/internal/sources/s3"
+	"github.com/usedatabrew/blink/internal/sources/sqlite_incremental"
+	"github.com/usedatabrew/blink/internal/sources/sqlserver_incremental"
+	"github.com/usedatabrew/blink/internal/sources/webhook"
 	"github.com/usedatabrew/blink/internal/sources/websockets"
 	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
 )
 
 // SourceWrapper wraps source plugin in order to
@@ -22,6 +33,7 @@ type SourceWrapper struct {
 	config       config.Configuration
 	stream       chan sources.MessageEvent
 	ctx          *stream_context.Context
+	eventRules   eventRules
 }
 
 func NewSourceWrapper(pluginType sources.SourceDriver, config config.Configuration) SourceWrapper {
@@ -41,6 +53,57 @@ func (p *SourceWrapper) Init(appctx *stream_context.Context) error {
 	return p.sourceDriver.Connect(appctx.GetContext())
 }
 
+// SetEventRules sets the rules filtering and remapping the events emitted by the source
+func (p *SourceWrapper) SetEventRules(rules []config.EventRule) error {
+	compiled, err := newEventRules(rules)
+	if err != nil {
+		return err
+	}
+
+	p.eventRules = compiled
+	return nil
+}
+
+// EmitsBeforeImage reports if the source attaches the before image to the messages
+func (p *SourceWrapper) EmitsBeforeImage() bool {
+	beforeImageSource, ok := p.sourceDriver.(sources.BeforeImageSource)
+	return ok && beforeImageSource.BeforeImageEnabled()
+}
+
+// Backfill passes the backfill request to the source if it supports backfills
+func (p *SourceWrapper) Backfill(request sources.BackfillRequest) error {
+	if p.sourceDriver == nil {
+		return fmt.Errorf("source %s is not connected yet", p.pluginType)
+	}
+
+	backfillSource, ok := p.sourceDriver.(sources.BackfillSource)
+	if !ok {
+		return fmt.Errorf("source %s doesn't support backfills", p.pluginType)
+	}
+
+	return backfillSource.Backfill(request)
+}
+
+// AcknowledgesMessages reports if the source expects the messages to be acknowledged
+func (p *SourceWrapper) AcknowledgesMessages() bool {
+	_, ok := p.sourceDriver.(sources.AckSource)
+	return ok
+}
+
+// Ack passes the messages written by the sink to the source if it acknowledges them
+func (p *SourceWrapper) Ack(messages []*message.Message) {
+	if ackSource, ok := p.sourceDriver.(sources.AckSource); ok {
+		ackSource.Ack(messages)
+	}
+}
+
+// Nack passes the messages the pipeline failed to process to the source if it rejects them
+func (p *SourceWrapper) Nack(messages []*message.Message) {
+	if nackSource, ok := p.sourceDriver.(sources.NackSource); ok {
+		nackSource.Nack(messages)
+	}
+}
+
 func (p *SourceWrapper) Events() chan sources.MessageEvent {
 	return p.stream
 }
@@ -54,6 +117,10 @@ func (p *SourceWrapper) Start() {
 					p.ctx.Metrics.IncrementSourceErrCounter()
 				} else {
 					p.ctx.Metrics.IncrementReceivedCounter()
+					if !p.eventRules.apply(event.Message) {
+						p.Ack([]*message.Message{event.Message})
+						continue
+					}
 				}
 				p.stream <- event
 			}
@@ -80,7 +147,7 @@ func (p *SourceWrapper) LoadDriver(driver sources.SourceDriver, fcg config.Confi
 		if err != nil {
 			panic("cannot read driver config")
 		}
-		return postgres_cdc.NewPostgresSourcePlugin(driverConfig, fcg.Source.StreamSchema)
+		return postgres_cdc.NewPostgresSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
 	case sources.WebSockets:
 		driverConfig, err := config.ReadDriverConfig[websockets.Config](fcg.Source.Config, websockets.Config{})
 		if err != nil {
@@ -94,7 +161,7 @@ func (p *SourceWrapper) LoadDriver(driver sources.SourceDriver, fcg config.Confi
 			panic("cannot read driver config")
 		}
 
-		return mongo_stream.NewMongoStreamSourcePlugin(driverConfig, fcg.Source.StreamSchema)
+		return mongo_stream.NewMongoStreamSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
 	case sources.AirTable:
 		driverConfig, err := config.ReadDriverConfig[airtable.Config](fcg.Source.Config, airtable.Config{})
 
@@ -110,7 +177,7 @@ func (p *SourceWrapper) LoadDriver(driver sources.SourceDriver, fcg config.Confi
 			panic("cannot read driver config")
 		}
 
-		return mysql_cdc.NewMysqlSourcePlugin(driverConfig, fcg.Source.StreamSchema)
+		return mysql_cdc.NewMysqlSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
 	case sources.PostgresIncremental:
 		driverConfig, err := config.ReadDriverConfig[postgres_incr_sync.Config](fcg.Source.Config, postgres_incr_sync.Config{})
 
@@ -119,6 +186,30 @@ func (p *SourceWrapper) LoadDriver(driver sources.SourceDriver, fcg config.Confi
 		}
 
 		return postgres_incr_sync.NewPostgresIncrSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
+	case sources.MysqlIncremental:
+		driverConfig, err := config.ReadDriverConfig[mysql_incremental.Config](fcg.Source.Config, mysql_incremental.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return mysql_incremental.NewMysqlIncrSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
+	case sources.SqliteIncremental:
+		driverConfig, err := config.ReadDriverConfig[sqlite_incremental.Config](fcg.Source.Config, sqlite_incremental.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return sqlite_incremental.NewSqliteIncrSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
+	case sources.SqlServerIncremental:
+		driverConfig, err := config.ReadDriverConfig[sqlserver_incremental.Config](fcg.Source.Config, sqlserver_incremental.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return sqlserver_incremental.NewSqlServerIncrSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
 	case sources.Kafka:
 		driverConfig, err := config.ReadDriverConfig[kafka.Config](fcg.Source.Config, kafka.Config{})
 
@@ -127,6 +218,46 @@ func (p *SourceWrapper) LoadDriver(driver sources.SourceDriver, fcg config.Confi
 		}
 
 		return kafka.NewKafkaSourcePlugin(driverConfig, fcg.Source.StreamSchema)
+	case sources.Webhook:
+		driverConfig, err := config.ReadDriverConfig[webhook.Config](fcg.Source.Config, webhook.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return webhook.NewWebhookSourcePlugin(driverConfig, fcg.Source.StreamSchema)
+	case sources.File:
+		driverConfig, err := config.ReadDriverConfig[file.Config](fcg.Source.Config, file.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return file.NewFileSourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
+	case sources.S3:
+		driverConfig, err := config.ReadDriverConfig[s3.Config](fcg.Source.Config, s3.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return s3.NewS3SourcePlugin(p.ctx, driverConfig, fcg.Source.StreamSchema)
+	case sources.Nats:
+		driverConfig, err := config.ReadDriverConfig[nats.Config](fcg.Source.Config, nats.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return nats.NewNatsSourcePlugin(driverConfig, fcg.Source.StreamSchema, p.ctx)
+	case sources.RabbitMq:
+		driverConfig, err := config.ReadDriverConfig[rabbit_mq_source.Config](fcg.Source.Config, rabbit_mq_source.Config{})
+
+		if err != nil {
+			panic("cannot read driver config")
+		}
+
+		return rabbit_mq_source.NewRabbitMqSourcePlugin(driverConfig, fcg.Source.StreamSchema, p.ctx)
 	default:
 		p.ctx.Logger.WithPrefix("Source driver loader").Fatal("Failed to load driver", "driver", driver)
 	}
@@ -5,15 +5,36 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/usedatabrew/blink/config"
+	"github.com/usedatabrew/blink/internal/helper"
 	"github.com/usedatabrew/blink/internal/offset_storage"
 	"github.com/usedatabrew/blink/internal/schema"
 	"github.com/usedatabrew/blink/internal/service_registry"
+	"github.com/usedatabrew/blink/internal/sources"
 	"github.com/usedatabrew/blink/internal/stream_context"
 	"github.com/usedatabrew/message"
 	"github.com/usedatabrew/tango"
 )
 
+// flushInterval defines how often the buffered processors holding messages are flushed,
+// whether the source is idle or not. Messages stored by the buffered sinks are acknowledged at it too
+const flushInterval = 500 * time.Millisecond
+
+// flushMessages is the amount of the messages the buffered processors are flushed after
+// when they arrive faster than the flush interval
+const flushMessages = 1000
+
+// pipelineFlush travels through the pipeline on the flush interval or after the flush messages.
+// Every stage processes the messages flushed by the upstream processors
+// and appends the messages buffered by its own processor.
+// Control events emitted by the source travel along the flush as well,
+// so they are applied after all the preceding messages are written
+type pipelineFlush struct {
+	messages []*message.Message
+	control  *message.Message
+}
+
 type Stream struct {
 	ctx  *stream_context.Context
 	lock sync.Mutex
@@ -71,6 +92,10 @@ func InitFromConfig(config config.Configuration) (*Stream, error) {
 	}
 
 	sourceWrapper := NewSourceWrapper(config.Source.Driver, config)
+	if err := sourceWrapper.SetEventRules(config.Source.EventRules); err != nil {
+		streamContext.Logger.WithPrefix("Source").Errorf("failed to set event rules: %v", err)
+		return nil, err
+	}
 	s.source = &sourceWrapper
 
 	streamContext.Logger.WithPrefix("Source").With(
@@ -80,6 +105,10 @@ func InitFromConfig(config config.Configuration) (*Stream, error) {
 	s.schema = schema.NewStreamSchemaObj(config.Source.StreamSchema)
 	for _, processorCfg := range config.Processors {
 		procWrapper := NewProcessorWrapper(processorCfg.Driver, processorCfg.Config, s.ctx)
+		if err := procWrapper.SetCondition(processorCfg.When); err != nil {
+			streamContext.Logger.WithPrefix("Processors").Errorf("failed to set condition for %s: %v", processorCfg.Driver, err)
+			return nil, err
+		}
 		s.processors = append(s.processors, procWrapper)
 		streamContext.Logger.WithPrefix("Processors").
 			With("driver", processorCfg.Driver).
@@ -147,6 +176,15 @@ func (s *Stream) Start() error {
 
 	dataStream := tango.NewTango()
 
+	var tracker *ackTracker
+	if s.source.AcknowledgesMessages() {
+		var durable func() int64
+		if s.sinks[0].IsBuffered() {
+			durable = s.sinks[0].Durable
+		}
+		tracker = newAckTracker(s.source.Ack, s.source.Nack, s.hasBufferedProcessors(), durable)
+	}
+
 	var dataStreamStages []tango.Stage
 	for idx, _ := range s.processors {
 		// this is required as idx is a ref,
@@ -155,23 +193,39 @@ func (s *Stream) Start() error {
 		procIndex := idx
 		stage := tango.Stage{
 			Channel: make(chan interface{}),
-			Function: func(i interface{}) (interface{}, error) {
+			Function: tracker.rejectOnFailure(func(i interface{}) (interface{}, error) {
 				switch i.(type) {
 				case *message.Message:
 					if i.(*message.Message) == nil {
 						return nil, nil
 					}
+					if s.processors[procIndex].EmitsMultipleMessages() {
+						return s.processors[procIndex].ProcessMessages([]*message.Message{i.(*message.Message)})
+					}
 					return s.processors[procIndex].Process(i.(*message.Message))
+				case []*message.Message:
+					// upstream processor emitted several messages for a single source message
+					return s.processors[procIndex].ProcessMessages(i.([]*message.Message))
+				case pipelineFlush:
+					processed, err := s.processors[procIndex].ProcessMessages(i.(pipelineFlush).messages)
+					if err != nil {
+						return nil, err
+					}
+					flushed, err := s.processors[procIndex].Flush()
+					if err != nil {
+						return nil, err
+					}
+					return pipelineFlush{messages: append(processed, flushed...), control: i.(pipelineFlush).control}, nil
 				}
 				return nil, nil
-			},
+			}),
 		}
 		dataStreamStages = append(dataStreamStages, stage)
 	}
 
 	sinkStage := tango.Stage{
 		Channel: make(chan interface{}),
-		Function: func(i interface{}) (interface{}, error) {
+		Function: tracker.rejectOnFailure(func(i interface{}) (interface{}, error) {
 			switch i.(type) {
 			case *message.Message:
 				inMessage := i.(*message.Message)
@@ -186,10 +240,29 @@ func (s *Stream) Start() error {
 					messageSent += 1
 				}
 				return nil, err
+			case []*message.Message, pipelineFlush:
+				inMessages, ok := i.([]*message.Message)
+				if !ok {
+					inMessages = i.(pipelineFlush).messages
+				}
+				for _, inMessage := range inMessages {
+					if err := s.sinks[0].Write(inMessage); err != nil {
+						s.ctx.Logger.WithPrefix("sink").Errorf("failed to write to sink %v", err)
+						return nil, err
+					}
+					messageSent += 1
+				}
+
+				if flush, ok := i.(pipelineFlush); ok && flush.control != nil {
+					if err := s.applyControlEvent(flush.control); err != nil {
+						s.ctx.Logger.WithPrefix("sink").Errorf("failed to apply control event %v", err)
+						return nil, err
+					}
+				}
 			}
 
 			return nil, nil
-		},
+		}),
 	}
 	dataStreamStages = append(dataStreamStages, sinkStage)
 	dataStream.SetStages(dataStreamStages)
@@ -197,18 +270,50 @@ func (s *Stream) Start() error {
 	streamProxyChan := make(chan interface{})
 	dataStream.SetProducerChannel(streamProxyChan)
 	dataStream.OnProcessed(func(i interface{}, err error) {
-
+		if err == nil {
+			tracker.completed(s.sinks[0].Written())
+		}
 	})
 
+	bufferedProcessors := s.hasBufferedProcessors()
+	var flushTicker = make(<-chan time.Time)
+	if bufferedProcessors || tracker != nil && s.sinks[0].IsBuffered() {
+		flushTicker = time.NewTicker(flushInterval).C
+	}
+
 	go func() {
+		// unflushed is the amount of the messages sent to the buffered processors since the last flush
+		var unflushed int
+		flush := func() {
+			tracker.sent(nil, true)
+			streamProxyChan <- pipelineFlush{}
+			unflushed = 0
+		}
+
 		for {
 			select {
 			case sourceEvent := <-s.source.Events():
 				if sourceEvent.Err != nil {
 					s.ctx.Logger.Errorf("Error processing message %s", sourceEvent.Err.Error())
+				} else if sources.IsControlEvent(sourceEvent.Message.GetEvent()) {
+					tracker.sent([]*message.Message{sourceEvent.Message}, true)
+					streamProxyChan <- pipelineFlush{control: sourceEvent.Message}
+					unflushed = 0
 				} else {
+					tracker.sent([]*message.Message{sourceEvent.Message}, false)
 					streamProxyChan <- sourceEvent.Message
 					messagesReceived += 1
+					if bufferedProcessors {
+						unflushed += 1
+					}
+					if unflushed >= flushMessages {
+						flush()
+					}
+				}
+			case <-flushTicker:
+				tracker.release()
+				if unflushed > 0 {
+					flush()
 				}
 			}
 		}
@@ -219,7 +324,35 @@ func (s *Stream) Start() error {
 		s.registry.SetState(service_registry.Started)
 	}
 
-	return dataStream.Start()
+	// blocks until the pipeline is interrupted
+	err := dataStream.Start()
+	s.closeProcessors()
+	return err
+}
+
+func (s *Stream) closeProcessors() {
+	for _, processor := range s.processors {
+		if err := processor.Close(); err != nil {
+			s.ctx.Logger.WithPrefix("Processors").Errorf("failed to close processor: %v", err)
+		}
+	}
+}
+
+// Backfill triggers the snapshot of the streams on the running pipeline and returns the backfill id.
+// Rows matching the filters are emitted as snapshot messages tagged with the id
+func (s *Stream) Backfill(streams []string, filters []sources.Filter) (string, error) {
+	if s.source == nil {
+		return "", errors.New("source is not set")
+	}
+
+	id := uuid.NewString()
+	err := s.source.Backfill(sources.BackfillRequest{ID: id, Streams: streams, Filters: filters})
+	if err != nil {
+		return "", err
+	}
+
+	s.ctx.Logger.WithPrefix("Stream").Info("Backfill is started", "id", id, "streams", streams, "filters", filters)
+	return id, nil
 }
 
 func (s *Stream) validateAndInit() error {
@@ -238,9 +371,67 @@ func (s *Stream) validateAndInit() error {
 		return err
 	}
 
+	if s.source.EmitsBeforeImage() {
+		for idx := range s.sinks {
+			s.sinks[idx].StripBeforeImage()
+		}
+	}
+
+	return nil
+}
+
+// applyControlEvent evolves the schema for the added columns and passes the control event to the sink
+func (s *Stream) applyControlEvent(msg *message.Message) error {
+	s.ctx.Logger.WithPrefix("Stream").Info("Applying control event", "event", msg.GetEvent(), "stream", msg.GetStream())
+	if msg.GetEvent() != sources.SchemaChangeEvent {
+		return s.sinks[0].ApplyControlEvent(msg)
+	}
+
+	change, err := sources.ReadSchemaChange(msg)
+	if err != nil {
+		return err
+	}
+
+	stream := helper.NormalizeStreamName(msg.GetStream())
+	for _, column := range change.Columns {
+		if !s.hasColumn(stream, column.Name) {
+			s.schema.AddColumn(stream, column)
+		}
+	}
+
+	if err = s.sinks[0].ApplyControlEvent(msg); err != nil {
+		return err
+	}
+
+	s.sinks[0].SetExpectedSchema(s.schema)
 	return nil
 }
 
+func (s *Stream) hasColumn(stream, column string) bool {
+	for _, streamSchema := range s.schema.GetLatestSchema() {
+		if helper.NormalizeStreamName(streamSchema.StreamName) != stream {
+			continue
+		}
+		for _, existing := range streamSchema.Columns {
+			if existing.Name == column {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *Stream) hasBufferedProcessors() bool {
+	for _, processor := range s.processors {
+		if processor.IsBuffered() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *Stream) evolveSchemaForSinks(streamSchema *schema.StreamSchemaObj) {
 	for _, processor := range s.processors {
 		err := processor.EvolveSchema(streamSchema)
@@ -0,0 +1,66 @@
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/usedatabrew/blink/internal/schema"
+	"github.com/usedatabrew/blink/internal/sinks"
+	"github.com/usedatabrew/blink/internal/sources"
+	"github.com/usedatabrew/blink/internal/stream_context"
+	"github.com/usedatabrew/message"
+)
+
+// recordingSink keeps the expected schema and the control events it receives
+type recordingSink struct {
+	expected []schema.StreamSchema
+	control  []*message.Message
+}
+
+func (r *recordingSink) Connect(context context.Context) error { return nil }
+
+func (r *recordingSink) SetExpectedSchema(schema []schema.StreamSchema) { r.expected = schema }
+
+func (r *recordingSink) GetType() sinks.SinkDriver { return sinks.StdOutSinkType }
+
+func (r *recordingSink) Write(m *message.Message) error { return nil }
+
+func (r *recordingSink) ApplyControlEvent(m *message.Message) error {
+	r.control = append(r.control, m)
+	return nil
+}
+
+func (r *recordingSink) Stop() {}
+
+func TestStream_ApplySchemaChange(t *testing.T) {
+	ctx := stream_context.CreateContext(1)
+	sink := &recordingSink{}
+	s := &Stream{
+		ctx: ctx,
+		schema: schema.NewStreamSchemaObj([]schema.StreamSchema{
+			{StreamName: "public.users", Columns: []schema.Column{{Name: "id", DatabrewType: "Int64", PK: true}}},
+		}),
+		sinks: []SinkWrapper{{sinkDriver: sink, ctx: ctx}},
+	}
+
+	change, err := sources.NewSchemaChangeMessage("users", sources.SchemaChange{
+		Columns: []schema.Column{{Name: "age", DatabrewType: "Int32", Nullable: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same change applied twice adds the column once
+	for i := 0; i < 2; i++ {
+		if err = s.applyControlEvent(change); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(sink.control) != 2 {
+		t.Fatal("control events must be passed to the sink")
+	}
+	if len(sink.expected) != 1 || len(sink.expected[0].Columns) != 2 || sink.expected[0].Columns[1].Name != "age" {
+		t.Fatalf("sink must expect the added column to write its values, got %+v", sink.expected)
+	}
+}